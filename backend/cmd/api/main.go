@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/smoothweb/backend/internal/auth"
 	"github.com/smoothweb/backend/internal/config"
 	"github.com/smoothweb/backend/internal/custom"
+	customservices "github.com/smoothweb/backend/internal/custom/services"
 	"github.com/smoothweb/backend/internal/database"
 	"github.com/smoothweb/backend/internal/handlers"
 	"github.com/smoothweb/backend/internal/middleware"
@@ -16,9 +23,16 @@ import (
 	"github.com/smoothweb/backend/internal/services"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish once the server is stopped
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	cfg := config.LoadConfig()
 
+	// Cancelled on SIGINT or SIGTERM, which stops the server and its background work
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	gin.SetMode(cfg.GinMode)
 
 	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0755); err != nil {
@@ -67,6 +81,15 @@ func main() {
 
 	rbacMiddleware := rbac.NewMiddleware(enforcer)
 
+	// The LLM proxy's API and proxy routes share one load balancer, so both see the same provider health
+	customDeps := custom.Dependencies{
+		DB:           db.GetDB(),
+		Config:       cfg,
+		JWT:          jwtService,
+		RBAC:         rbacMiddleware,
+		LoadBalancer: customservices.NewLoadBalancer(),
+	}
+
 	v1 := router.Group("/api/v1")
 	{
 		authHandler := handlers.NewAuthHandler(authService)
@@ -179,21 +202,11 @@ func main() {
 			completionGroup.GET("/leaderboard", rbacMiddleware.Authorize("/api/v1/completion/leaderboard", "GET"), completionHandler.GetLeaderboard)
 		}
 
-		custom.RegisterRoutes(v1, custom.Dependencies{
-			DB:     db.GetDB(),
-			Config: cfg,
-			JWT:    jwtService,
-			RBAC:   rbacMiddleware,
-		})
+		custom.RegisterRoutes(v1, customDeps)
 	}
 
 	// Register LLM proxy routes at /v1 (outside /api/v1 for OpenAI compatibility)
-	custom.RegisterProxyRoutes(router, custom.Dependencies{
-		DB:     db.GetDB(),
-		Config: cfg,
-		JWT:    jwtService,
-		RBAC:   rbacMiddleware,
-	})
+	waitForProxyWork := custom.RegisterProxyRoutes(ctx, router, customDeps)
 
 	// Static file serving for uploaded media (public, no auth required)
 	router.Static("/uploads", "./uploads")
@@ -202,10 +215,23 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
-	log.Printf("Starting server on port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		log.Printf("Starting server on port %s", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server cleanly: %v", err)
 	}
+	waitForProxyWork()
 }
 
 func autoMigrate(db *database.Database) error {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -19,6 +20,11 @@ type Config struct {
 
 	AllowedOrigins []string
 	FrontendURL    string
+
+	BatchConcurrency int
+
+	FilesPath    string
+	FilesMaxSize int64
 }
 
 func LoadConfig() *Config {
@@ -35,6 +41,11 @@ func LoadConfig() *Config {
 
 		AllowedOrigins: getOriginsEnv("CORS_ORIGINS", "*"),
 		FrontendURL:    getEnv("FRONTEND_URL", "http://localhost:5173"),
+
+		BatchConcurrency: getIntEnv("BATCH_CONCURRENCY", 4),
+
+		FilesPath:    getEnv("FILES_PATH", "./data/files"),
+		FilesMaxSize: int64(getIntEnv("FILES_MAX_SIZE_MB", 512)) << 20,
 	}
 }
 
@@ -45,6 +56,15 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue string) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		duration, err := time.ParseDuration(value)
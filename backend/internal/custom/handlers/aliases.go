@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/auth"
+	"github.com/smoothweb/backend/internal/custom/services"
+)
+
+// AliasHandler handles model alias management endpoints
+type AliasHandler struct {
+	aliasService *services.AliasService
+}
+
+// NewAliasHandler creates a new AliasHandler instance
+func NewAliasHandler(aliasService *services.AliasService) *AliasHandler {
+	return &AliasHandler{
+		aliasService: aliasService,
+	}
+}
+
+// ListAliases handles GET /aliases - lists the user's own and global model aliases
+func (h *AliasHandler) ListAliases(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	aliases, err := h.aliasService.ListAliases(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, aliases)
+}
+
+// CreateAlias handles POST /aliases - creates a model alias; admins may create global ones
+func (h *AliasHandler) CreateAlias(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req services.CreateAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alias, err := h.aliasService.CreateAlias(userID, isAdmin(c), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, alias)
+}
+
+// UpdateAlias handles PUT /aliases/:id - updates an existing model alias
+func (h *AliasHandler) UpdateAlias(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	aliasID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alias id"})
+		return
+	}
+
+	var req services.UpdateAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alias, err := h.aliasService.UpdateAlias(userID, isAdmin(c), uint(aliasID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, alias)
+}
+
+// DeleteAlias handles DELETE /aliases/:id - deletes a model alias
+func (h *AliasHandler) DeleteAlias(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	aliasID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alias id"})
+		return
+	}
+
+	if err := h.aliasService.DeleteAlias(userID, isAdmin(c), uint(aliasID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// isAdmin reports whether the authenticated user manages global settings
+func isAdmin(c *gin.Context) bool {
+	return auth.GetUserRole(c) == "admin"
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/smoothweb/backend/internal/custom/services"
+)
+
+// Batch list page sizes
+const (
+	defaultBatchListLimit = 20
+	maxBatchListLimit     = 100
+)
+
+// BatchHandler handles the OpenAI and Anthropic batch API endpoints
+type BatchHandler struct {
+	proxyService *services.ProxyService
+	batchService *services.BatchService
+}
+
+// NewBatchHandler creates a new BatchHandler instance
+func NewBatchHandler(proxyService *services.ProxyService, batchService *services.BatchService) *BatchHandler {
+	return &BatchHandler{
+		proxyService: proxyService,
+		batchService: batchService,
+	}
+}
+
+// batchErrorStatus maps a batch service error to an HTTP status
+func batchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBatchNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidBatch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeBatchOpenAIError writes a batch service error in the OpenAI error shape
+func writeBatchOpenAIError(c *gin.Context, err error) {
+	statusCode := batchErrorStatus(err)
+	switch statusCode {
+	case http.StatusNotFound:
+		writeOpenAIError(c, statusCode, err.Error(), "invalid_request_error", "not_found")
+	case http.StatusBadRequest:
+		writeOpenAIError(c, statusCode, err.Error(), "invalid_request_error", "invalid_batch")
+	default:
+		writeOpenAIError(c, statusCode, err.Error(), "api_error", "server_error")
+	}
+}
+
+// writeBatchAnthropicError writes a batch service error in the Anthropic error shape
+func writeBatchAnthropicError(c *gin.Context, err error) {
+	statusCode := batchErrorStatus(err)
+	writeAnthropicError(c, statusCode, services.AnthropicErrorType(statusCode), err.Error())
+}
+
+// listLimit parses a page size query parameter
+func listLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultBatchListLimit
+	}
+	if limit > maxBatchListLimit {
+		return maxBatchListLimit
+	}
+	return limit
+}
+
+// CreateBatch handles POST /v1/batches
+// Accepts the OpenAI JSON body (input_file_id) or a multipart upload with the JSONL input as "file"
+func (h *BatchHandler) CreateBatch(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	var req services.CreateBatchRequest
+	var input []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		services.LimitMultipartBody(c, services.MaxBatchInputSize)
+		if err := c.ShouldBind(&req); err != nil {
+			if isBodyTooLarge(err) {
+				writeOpenAIError(c, http.StatusRequestEntityTooLarge, "batch input file is too large", "invalid_request_error", "file_too_large")
+				return
+			}
+			writeOpenAIError(c, http.StatusBadRequest, err.Error(), "invalid_request_error", "invalid_request")
+			return
+		}
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			writeOpenAIError(c, http.StatusBadRequest, "file is required", "invalid_request_error", "invalid_request")
+			return
+		}
+		if fileHeader.Size > services.MaxBatchInputSize {
+			writeOpenAIError(c, http.StatusRequestEntityTooLarge, "batch input file is too large", "invalid_request_error", "file_too_large")
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			writeOpenAIError(c, http.StatusBadRequest, "failed to read file", "invalid_request_error", "invalid_request")
+			return
+		}
+		defer file.Close()
+
+		if input, err = io.ReadAll(file); err != nil {
+			writeOpenAIError(c, http.StatusBadRequest, "failed to read file", "invalid_request_error", "invalid_request")
+			return
+		}
+		if metadata := c.PostForm("metadata"); metadata != "" {
+			if err := json.Unmarshal([]byte(metadata), &req.Metadata); err != nil {
+				writeOpenAIError(c, http.StatusBadRequest, "metadata must be a JSON object", "invalid_request_error", "invalid_request")
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, err.Error(), "invalid_request_error", "invalid_request")
+		return
+	}
+
+	batch, err := h.batchService.CreateOpenAIBatch(proxyKey, &req, input)
+	if err != nil {
+		writeBatchOpenAIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewOpenAIBatch(batch))
+}
+
+// ListBatches handles GET /v1/batches
+func (h *BatchHandler) ListBatches(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	batches, hasMore, err := h.batchService.ListBatches(proxyKey, models.BatchFormatOpenAI, c.Query("after"), listLimit(c))
+	if err != nil {
+		writeBatchOpenAIError(c, err)
+		return
+	}
+
+	data := make([]*services.OpenAIBatch, 0, len(batches))
+	for i := range batches {
+		data = append(data, services.NewOpenAIBatch(&batches[i]))
+	}
+
+	response := gin.H{"object": "list", "data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(data) > 0 {
+		response["first_id"] = data[0].ID
+		response["last_id"] = data[len(data)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBatch handles GET /v1/batches/:id
+func (h *BatchHandler) GetBatch(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	batch, err := h.batchService.GetBatch(proxyKey, models.BatchFormatOpenAI, c.Param("id"))
+	if err != nil {
+		writeBatchOpenAIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewOpenAIBatch(batch))
+}
+
+// CancelBatch handles POST /v1/batches/:id/cancel
+func (h *BatchHandler) CancelBatch(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	batch, err := h.batchService.CancelBatch(proxyKey, models.BatchFormatOpenAI, c.Param("id"))
+	if err != nil {
+		writeBatchOpenAIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewOpenAIBatch(batch))
+}
+
+// GetBatchOutput handles GET /v1/batches/:id/output
+// Returns the JSONL results of the requests that succeeded
+func (h *BatchHandler) GetBatchOutput(c *gin.Context) {
+	h.writeBatchOutput(c, false)
+}
+
+// GetBatchErrors handles GET /v1/batches/:id/errors
+// Returns the JSONL results of the requests that failed, were cancelled or expired
+func (h *BatchHandler) GetBatchErrors(c *gin.Context) {
+	h.writeBatchOutput(c, true)
+}
+
+// writeBatchOutput writes an OpenAI batch's output or error file content
+func (h *BatchHandler) writeBatchOutput(c *gin.Context, errorFile bool) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	output, err := h.batchService.GetOpenAIBatchOutput(proxyKey, c.Param("id"), errorFile)
+	if err != nil {
+		writeBatchOpenAIError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/x-jsonl", output)
+}
+
+// CreateMessageBatch handles POST /v1/messages/batches
+func (h *BatchHandler) CreateMessageBatch(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	var req services.AnthropicBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	batch, err := h.batchService.CreateAnthropicBatch(proxyKey, &req)
+	if err != nil {
+		writeBatchAnthropicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewAnthropicMessageBatch(batch, messageBatchResultsURL(c, batch)))
+}
+
+// ListMessageBatches handles GET /v1/messages/batches
+func (h *BatchHandler) ListMessageBatches(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	batches, hasMore, err := h.batchService.ListBatches(proxyKey, models.BatchFormatAnthropic, c.Query("after_id"), listLimit(c))
+	if err != nil {
+		writeBatchAnthropicError(c, err)
+		return
+	}
+
+	data := make([]*services.AnthropicMessageBatch, 0, len(batches))
+	for i := range batches {
+		data = append(data, services.NewAnthropicMessageBatch(&batches[i], messageBatchResultsURL(c, &batches[i])))
+	}
+
+	response := gin.H{"data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(data) > 0 {
+		response["first_id"] = data[0].ID
+		response["last_id"] = data[len(data)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMessageBatch handles GET /v1/messages/batches/:id
+func (h *BatchHandler) GetMessageBatch(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	batch, err := h.batchService.GetBatch(proxyKey, models.BatchFormatAnthropic, c.Param("id"))
+	if err != nil {
+		writeBatchAnthropicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewAnthropicMessageBatch(batch, messageBatchResultsURL(c, batch)))
+}
+
+// CancelMessageBatch handles POST /v1/messages/batches/:id/cancel
+func (h *BatchHandler) CancelMessageBatch(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	batch, err := h.batchService.CancelBatch(proxyKey, models.BatchFormatAnthropic, c.Param("id"))
+	if err != nil {
+		writeBatchAnthropicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewAnthropicMessageBatch(batch, messageBatchResultsURL(c, batch)))
+}
+
+// DeleteMessageBatch handles DELETE /v1/messages/batches/:id
+func (h *BatchHandler) DeleteMessageBatch(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	batchID := c.Param("id")
+	if err := h.batchService.DeleteBatch(proxyKey, models.BatchFormatAnthropic, batchID); err != nil {
+		writeBatchAnthropicError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": batchID, "type": "message_batch_deleted"})
+}
+
+// GetMessageBatchResults handles GET /v1/messages/batches/:id/results
+func (h *BatchHandler) GetMessageBatchResults(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	results, err := h.batchService.GetAnthropicBatchResults(proxyKey, c.Param("id"))
+	if err != nil {
+		writeBatchAnthropicError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/x-jsonl", results)
+}
+
+// messageBatchResultsURL builds the absolute results URL Anthropic SDKs download batch results from
+func messageBatchResultsURL(c *gin.Context, batch *models.Batch) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + "/v1/messages/batches/" + batch.BatchID + "/results"
+}
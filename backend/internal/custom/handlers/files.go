@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/services"
+)
+
+// FileHandler handles the OpenAI-compatible /v1/files endpoints
+type FileHandler struct {
+	proxyService *services.ProxyService
+	fileService  *services.FileService
+}
+
+// NewFileHandler creates a new FileHandler instance
+func NewFileHandler(proxyService *services.ProxyService, fileService *services.FileService) *FileHandler {
+	return &FileHandler{
+		proxyService: proxyService,
+		fileService:  fileService,
+	}
+}
+
+// writeFileError writes a file service error in the OpenAI error shape
+func writeFileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrFileNotFound):
+		writeOpenAIError(c, http.StatusNotFound, err.Error(), "invalid_request_error", "not_found")
+	case errors.Is(err, services.ErrFileTooLarge):
+		writeOpenAIError(c, http.StatusRequestEntityTooLarge, err.Error(), "invalid_request_error", "file_too_large")
+	case errors.Is(err, services.ErrInvalidFile):
+		writeOpenAIError(c, http.StatusBadRequest, err.Error(), "invalid_request_error", "invalid_file")
+	default:
+		writeOpenAIError(c, http.StatusInternalServerError, err.Error(), "api_error", "server_error")
+	}
+}
+
+// isBodyTooLarge reports whether err comes from reading past a request body's size limit
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// UploadFile handles POST /v1/files
+// Expects a multipart form with "file", "purpose" and optionally expires_after[anchor]/[seconds]
+func (h *FileHandler) UploadFile(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	services.LimitMultipartBody(c, h.fileService.MaxFileSize())
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		if isBodyTooLarge(err) {
+			writeOpenAIError(c, http.StatusRequestEntityTooLarge, "file is too large", "invalid_request_error", "file_too_large")
+			return
+		}
+		writeOpenAIError(c, http.StatusBadRequest, "file is required", "invalid_request_error", "invalid_request")
+		return
+	}
+
+	var expiresAfter time.Duration
+	if seconds := c.PostForm("expires_after[seconds]"); seconds != "" {
+		if anchor := c.PostForm("expires_after[anchor]"); anchor != "" && anchor != "created_at" {
+			writeOpenAIError(c, http.StatusBadRequest, "expires_after[anchor] must be created_at", "invalid_request_error", "invalid_request")
+			return
+		}
+		value, err := strconv.Atoi(seconds)
+		if err != nil {
+			writeOpenAIError(c, http.StatusBadRequest, "expires_after[seconds] must be an integer", "invalid_request_error", "invalid_request")
+			return
+		}
+		expiresAfter = time.Duration(value) * time.Second
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		writeOpenAIError(c, http.StatusBadRequest, "failed to read file", "invalid_request_error", "invalid_request")
+		return
+	}
+	defer file.Close()
+
+	stored, err := h.fileService.UploadFile(&services.UploadFileRequest{
+		UserID:       proxyKey.UserID,
+		Filename:     fileHeader.Filename,
+		Purpose:      c.PostForm("purpose"),
+		Content:      file,
+		ExpiresAfter: expiresAfter,
+	})
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewOpenAIFile(stored))
+}
+
+// ListFiles handles GET /v1/files
+func (h *FileHandler) ListFiles(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	files, hasMore, err := h.fileService.ListFiles(proxyKey.UserID, &services.ListFilesParams{
+		Purpose: c.Query("purpose"),
+		After:   c.Query("after"),
+		Limit:   limit,
+		Order:   c.Query("order"),
+	})
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+
+	data := make([]*services.OpenAIFile, 0, len(files))
+	for i := range files {
+		data = append(data, services.NewOpenAIFile(&files[i]))
+	}
+
+	response := gin.H{"object": "list", "data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(data) > 0 {
+		response["first_id"] = data[0].ID
+		response["last_id"] = data[len(data)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetFile handles GET /v1/files/:id
+func (h *FileHandler) GetFile(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	file, err := h.fileService.GetFile(proxyKey.UserID, c.Param("id"))
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewOpenAIFile(file))
+}
+
+// GetFileContent handles GET /v1/files/:id/content
+func (h *FileHandler) GetFileContent(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	file, err := h.fileService.GetFile(proxyKey.UserID, c.Param("id"))
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+
+	content, err := os.Open(file.FilePath)
+	if err != nil {
+		writeOpenAIError(c, http.StatusInternalServerError, "failed to read file", "api_error", "server_error")
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, file.Bytes, "application/octet-stream", content, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", file.Filename),
+	})
+}
+
+// DeleteFile handles DELETE /v1/files/:id
+func (h *FileHandler) DeleteFile(c *gin.Context) {
+	proxyKey, err := authenticateProxyKey(c, h.proxyService)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	fileID := c.Param("id")
+	if err := h.fileService.DeleteFile(proxyKey.UserID, fileID); err != nil {
+		writeFileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": fileID, "object": "file", "deleted": true})
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
 	"github.com/smoothweb/backend/internal/custom/services"
 )
 
@@ -19,102 +21,195 @@ func NewProxyHandler(proxyService *services.ProxyService) *ProxyHandler {
 	}
 }
 
+// authenticate extracts the proxy API key from the Authorization, x-api-key or api-key
+// header and validates it
+func (h *ProxyHandler) authenticate(c *gin.Context) (*models.ProxyAPIKey, error) {
+	return authenticateProxyKey(c, h.proxyService)
+}
+
+// authenticateProxyKey validates the proxy API key sent with a request
+func authenticateProxyKey(c *gin.Context, proxyService *services.ProxyService) (*models.ProxyAPIKey, error) {
+	apiKey, err := proxyService.GetProxyKeyFromRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	return proxyService.ValidateKey(apiKey)
+}
+
+// writeOpenAIError writes an error in the OpenAI API error shape
+func writeOpenAIError(c *gin.Context, statusCode int, message, errType, code string) {
+	c.JSON(statusCode, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    errType,
+			"code":    code,
+		},
+	})
+}
+
+// writeAnthropicError writes an error in the Anthropic API error shape
+func writeAnthropicError(c *gin.Context, statusCode int, errType, message string) {
+	c.JSON(statusCode, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errType,
+			"message": message,
+		},
+	})
+}
+
 // ChatCompletions handles POST /v1/chat/completions
 // This is the main OpenAI-compatible endpoint that proxies requests to the configured provider
 func (h *ProxyHandler) ChatCompletions(c *gin.Context) {
-	// Extract the proxy API key from the Authorization header
-	apiKey, err := h.proxyService.GetProxyKeyFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "authentication_error",
-				"code":    "invalid_api_key",
-			},
-		})
-		return
-	}
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyRequest(c, proxyKey)
+	})
+}
+
+// Completions handles POST /v1/completions
+// Legacy text completions; chat-only providers get the prompt wrapped as a chat message
+func (h *ProxyHandler) Completions(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyCompletions(c, proxyKey)
+	})
+}
+
+// Embeddings handles POST /v1/embeddings
+// Routes by model to an OpenAI-compatible provider and records input-token usage
+func (h *ProxyHandler) Embeddings(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyEmbeddings(c, proxyKey)
+	})
+}
+
+// AudioTranscriptions handles POST /v1/audio/transcriptions (multipart upload)
+func (h *ProxyHandler) AudioTranscriptions(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyAudioTranscription(c, proxyKey, "/audio/transcriptions")
+	})
+}
 
-	// Validate the key
-	proxyKey, err := h.proxyService.ValidateKey(apiKey)
+// AudioTranslations handles POST /v1/audio/translations (multipart upload)
+func (h *ProxyHandler) AudioTranslations(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyAudioTranscription(c, proxyKey, "/audio/translations")
+	})
+}
+
+// AudioSpeech handles POST /v1/audio/speech, streaming the generated audio back
+func (h *ProxyHandler) AudioSpeech(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyAudioSpeech(c, proxyKey)
+	})
+}
+
+// ImageGenerations handles POST /v1/images/generations
+func (h *ProxyHandler) ImageGenerations(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyImageGenerations(c, proxyKey)
+	})
+}
+
+// ImageEdits handles POST /v1/images/edits (multipart upload)
+func (h *ProxyHandler) ImageEdits(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyImageEdits(c, proxyKey)
+	})
+}
+
+// proxyOpenAI authenticates a request and writes OpenAI-style errors for proxy failures
+func (h *ProxyHandler) proxyOpenAI(c *gin.Context, proxy func(*models.ProxyAPIKey) (*services.ProxyResult, error)) {
+	proxyKey, err := h.authenticate(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "authentication_error",
-				"code":    "invalid_api_key",
-			},
-		})
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
 		return
 	}
 
-	// Proxy the request to the provider
-	result, err := h.proxyService.ProxyRequest(c, proxyKey)
+	result, err := proxy(proxyKey)
 	if err != nil {
-		// If ProxyRequest already wrote to the response (via c.Data), don't write again
+		// If the proxy already wrote to the response (for example mid-stream), don't write again
 		if c.Writer.Written() {
 			return
 		}
 
-		// Handle provider errors appropriately
 		statusCode := http.StatusBadGateway
 		if result != nil && result.StatusCode > 0 {
 			statusCode, _ = h.proxyService.HandleProviderError(result.StatusCode, result.ErrorMessage)
 		}
 
-		c.JSON(statusCode, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "api_error",
-				"code":    "proxy_error",
-			},
-		})
+		writeOpenAIError(c, statusCode, err.Error(), "api_error", "proxy_error")
 		return
 	}
+}
 
-	// Response was already written by ProxyRequest via c.Data()
-	// No need to write anything else here
+// Responses handles POST /v1/responses
+// OpenAI providers receive the request as-is; other providers get a translated chat request
+func (h *ProxyHandler) Responses(c *gin.Context) {
+	h.proxyOpenAI(c, func(proxyKey *models.ProxyAPIKey) (*services.ProxyResult, error) {
+		return h.proxyService.ProxyResponses(c, proxyKey)
+	})
 }
 
-// ListModels handles GET /v1/models
-// Returns a list of available models based on the proxy key's provider
-func (h *ProxyHandler) ListModels(c *gin.Context) {
-	// Extract the proxy API key from the Authorization header
-	apiKey, err := h.proxyService.GetProxyKeyFromRequest(c)
+// GetResponse handles GET /v1/responses/:id
+// Returns a response stored by the proxy for previous_response_id chaining
+func (h *ProxyHandler) GetResponse(c *gin.Context) {
+	proxyKey, err := h.authenticate(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "authentication_error",
-				"code":    "invalid_api_key",
-			},
-		})
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
 		return
 	}
 
-	// Validate the key
-	proxyKey, err := h.proxyService.ValidateKey(apiKey)
+	body, err := h.proxyService.GetStoredResponse(proxyKey, c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "authentication_error",
-				"code":    "invalid_api_key",
-			},
-		})
+		if errors.Is(err, services.ErrResponseNotFound) {
+			writeOpenAIError(c, http.StatusNotFound, err.Error(), "invalid_request_error", "not_found")
+			return
+		}
+		writeOpenAIError(c, http.StatusInternalServerError, err.Error(), "api_error", "server_error")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+// DeleteResponse handles DELETE /v1/responses/:id
+func (h *ProxyHandler) DeleteResponse(c *gin.Context) {
+	proxyKey, err := h.authenticate(c)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
+		return
+	}
+
+	responseID := c.Param("id")
+	if err := h.proxyService.DeleteStoredResponse(proxyKey, responseID); err != nil {
+		if errors.Is(err, services.ErrResponseNotFound) {
+			writeOpenAIError(c, http.StatusNotFound, err.Error(), "invalid_request_error", "not_found")
+			return
+		}
+		writeOpenAIError(c, http.StatusInternalServerError, err.Error(), "api_error", "server_error")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      responseID,
+		"object":  "response.deleted",
+		"deleted": true,
+	})
+}
+
+// ListModels handles GET /v1/models
+// Returns a list of available models based on the proxy key's provider
+func (h *ProxyHandler) ListModels(c *gin.Context) {
+	proxyKey, err := h.authenticate(c)
+	if err != nil {
+		writeOpenAIError(c, http.StatusUnauthorized, err.Error(), "authentication_error", "invalid_api_key")
 		return
 	}
 
 	// Get the list of available models for this key
 	models, err := h.proxyService.ListModelsForKey(proxyKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    "api_error",
-				"code":    "server_error",
-			},
-		})
+		writeOpenAIError(c, http.StatusInternalServerError, err.Error(), "api_error", "server_error")
 		return
 	}
 
@@ -122,33 +217,16 @@ func (h *ProxyHandler) ListModels(c *gin.Context) {
 }
 
 // Messages handles POST /v1/messages
-// This is the Anthropic-compatible endpoint that proxies requests to Claude Max providers
+// This is the Anthropic-compatible endpoint. Anthropic providers receive the request as-is;
+// OpenAI-compatible providers get a translated chat completion request.
 func (h *ProxyHandler) Messages(c *gin.Context) {
-	// Extract the proxy API key from the Authorization header
-	apiKey, err := h.proxyService.GetProxyKeyFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"type":    "authentication_error",
-				"message": err.Error(),
-			},
-		})
-		return
-	}
-
-	// Validate the key
-	proxyKey, err := h.proxyService.ValidateKey(apiKey)
+	proxyKey, err := h.authenticate(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": gin.H{
-				"type":    "authentication_error",
-				"message": err.Error(),
-			},
-		})
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
 		return
 	}
 
-	// Proxy the request to Anthropic
+	// Proxy the request to the provider
 	result, err := h.proxyService.ProxyAnthropicPassthrough(c, proxyKey)
 	if err != nil {
 		// If ProxyAnthropicPassthrough already wrote to the response, don't write again
@@ -162,14 +240,34 @@ func (h *ProxyHandler) Messages(c *gin.Context) {
 			statusCode = result.StatusCode
 		}
 
-		c.JSON(statusCode, gin.H{
-			"error": gin.H{
-				"type":    "api_error",
-				"message": err.Error(),
-			},
-		})
+		writeAnthropicError(c, statusCode, services.AnthropicErrorType(statusCode), err.Error())
+		return
+	}
+
+	// Response was already written (or streamed) by ProxyAnthropicPassthrough
+}
+
+// CountTokens handles POST /v1/messages/count_tokens
+// Counts the input tokens of an Anthropic Messages request without running it
+func (h *ProxyHandler) CountTokens(c *gin.Context) {
+	proxyKey, err := h.authenticate(c)
+	if err != nil {
+		writeAnthropicError(c, http.StatusUnauthorized, "authentication_error", err.Error())
 		return
 	}
 
-	// Response was already written by ProxyAnthropicPassthrough via c.Data()
+	result, err := h.proxyService.ProxyCountTokens(c, proxyKey)
+	if err != nil {
+		if c.Writer.Written() {
+			return
+		}
+
+		statusCode := http.StatusBadGateway
+		if result != nil && result.StatusCode > 0 {
+			statusCode = result.StatusCode
+		}
+
+		writeAnthropicError(c, statusCode, services.AnthropicErrorType(statusCode), err.Error())
+		return
+	}
 }
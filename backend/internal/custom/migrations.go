@@ -11,6 +11,11 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.ProxyAPIKey{},
 		&models.KeyAllowedProvider{},
 		&models.UsageRecord{},
+		&models.StoredResponse{},
+		&models.Batch{},
+		&models.BatchItem{},
+		&models.StoredFile{},
+		&models.ModelAlias{},
 	); err != nil {
 		return err
 	}
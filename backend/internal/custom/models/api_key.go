@@ -19,6 +19,21 @@ type ProxyAPIKey struct {
 	LastUsedAt *time.Time `json:"last_used_at"`
 	ExpiresAt  *time.Time `json:"expires_at"`
 
+	// FallbackEnabled retries a failed request on the next allowed provider serving the model,
+	// in the order the providers were added to the key
+	FallbackEnabled bool `gorm:"default:false" json:"fallback_enabled"`
+	// FallbackModels maps a requested model to the models to try, in order, once every provider
+	// for it has failed (e.g. "gpt-4o": ["anthropic/claude-sonnet-4-5"])
+	FallbackModels map[string][]string `gorm:"serializer:json" json:"fallback_models"`
+
+	// RoutingStrategy picks among the providers that serve a model; ModelRoutingStrategies
+	// overrides it for individual models. Empty means RoutingStrategyPriority.
+	RoutingStrategy        string            `gorm:"type:varchar(20)" json:"routing_strategy"`
+	ModelRoutingStrategies map[string]string `gorm:"serializer:json" json:"model_routing_strategies"`
+
+	// ModelAliases are the aliases visible to the key's user by name, loaded with the key for routing
+	ModelAliases map[string]*ModelAlias `gorm:"-" json:"-"`
+
 	// Relationships
 	AllowedProviders []KeyAllowedProvider `gorm:"foreignKey:ProxyAPIKeyID;constraint:OnDelete:CASCADE" json:"allowed_providers"`
 
@@ -28,6 +43,26 @@ type ProxyAPIKey struct {
 // ProxyAPIKeyPrefix is the prefix for generated proxy API keys
 const ProxyAPIKeyPrefix = "sk-smoothllm-"
 
+// Routing strategies for keys whose model is served by several providers
+const (
+	RoutingStrategyPriority      = "priority"        // First provider in key order
+	RoutingStrategyRoundRobin    = "round_robin"     // Each provider in turn
+	RoutingStrategyWeighted      = "weighted"        // In proportion to each provider's weight
+	RoutingStrategyLeastInFlight = "least_in_flight" // Provider with the fewest open requests
+	RoutingStrategyLowestLatency = "lowest_latency"  // Provider with the lowest recent latency
+)
+
+// RoutingStrategyFor returns the routing strategy that applies to a model
+func (k *ProxyAPIKey) RoutingStrategyFor(model string) string {
+	if strategy := k.ModelRoutingStrategies[model]; strategy != "" {
+		return strategy
+	}
+	if k.RoutingStrategy != "" {
+		return k.RoutingStrategy
+	}
+	return RoutingStrategyPriority
+}
+
 // IsExpired checks if the API key has expired
 func (k *ProxyAPIKey) IsExpired() bool {
 	if k.ExpiresAt == nil {
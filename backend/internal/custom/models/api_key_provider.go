@@ -15,6 +15,9 @@ type KeyAllowedProvider struct {
 	// If empty, all models of the provider are allowed
 	Models []string `gorm:"serializer:json" json:"models"`
 
+	// Weight is this provider's share of the key's traffic under weighted routing
+	Weight int `gorm:"default:1" json:"weight"`
+
 	// Relationships
 	Provider *Provider `gorm:"foreignKey:ProviderID" json:"provider,omitempty"`
 }
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Batch is a queued batch job submitted through the OpenAI /v1/batches or Anthropic
+// /v1/messages/batches API. Its requests are stored as BatchItems and run in the background.
+type Batch struct {
+	gorm.Model
+
+	BatchID          string            `gorm:"type:varchar(64);uniqueIndex;not null" json:"batch_id"` // batch_xxx or msgbatch_xxx
+	UserID           uint              `gorm:"not null;index" json:"user_id"`
+	ProxyKeyID       uint              `gorm:"not null;index" json:"proxy_key_id"`
+	Format           string            `gorm:"type:varchar(20);not null" json:"format"` // openai, anthropic
+	Endpoint         string            `gorm:"type:varchar(100);not null" json:"endpoint"`
+	CompletionWindow string            `gorm:"type:varchar(20)" json:"completion_window"`
+	Status           string            `gorm:"type:varchar(20);not null;index" json:"status"`
+	ErrorMessage     string            `gorm:"type:text" json:"error_message,omitempty"`
+	Metadata         map[string]string `gorm:"serializer:json" json:"metadata,omitempty"`
+
+	InputFileID  string `gorm:"type:varchar(64)" json:"input_file_id,omitempty"`
+	OutputFileID string `gorm:"type:varchar(64)" json:"output_file_id,omitempty"`
+	ErrorFileID  string `gorm:"type:varchar(64)" json:"error_file_id,omitempty"`
+
+	// Request counts, updated as items finish
+	TotalCount     int `gorm:"default:0" json:"total_count"`
+	CompletedCount int `gorm:"default:0" json:"completed_count"`
+	FailedCount    int `gorm:"default:0" json:"failed_count"`
+	CancelledCount int `gorm:"default:0" json:"cancelled_count"`
+	ExpiredCount   int `gorm:"default:0" json:"expired_count"`
+
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	InProgressAt *time.Time `json:"in_progress_at,omitempty"`
+	FinalizingAt *time.Time `json:"finalizing_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	FailedAt     *time.Time `json:"failed_at,omitempty"`
+	ExpiredAt    *time.Time `json:"expired_at,omitempty"`
+	CancellingAt *time.Time `json:"cancelling_at,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+
+	// Relationships
+	ProxyKey *ProxyAPIKey `gorm:"foreignKey:ProxyKeyID;constraint:OnDelete:CASCADE" json:"proxy_key,omitempty"`
+	Items    []BatchItem  `gorm:"foreignKey:BatchID;constraint:OnDelete:CASCADE" json:"-"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Batch status constants (OpenAI batch lifecycle; Anthropic's processing_status is derived from these)
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusFailed     = "failed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// Batch format constants
+const (
+	BatchFormatOpenAI    = "openai"
+	BatchFormatAnthropic = "anthropic"
+)
+
+// IsFinished returns true once the batch has stopped processing
+func (b *Batch) IsFinished() bool {
+	switch b.Status {
+	case BatchStatusCompleted, BatchStatusFailed, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// BatchItem is a single request within a batch and, once run, its result
+type BatchItem struct {
+	gorm.Model
+
+	BatchID    uint   `gorm:"not null;index" json:"batch_id"`
+	Position   int    `gorm:"not null" json:"position"` // Order in the input file
+	CustomID   string `gorm:"type:varchar(255);not null" json:"custom_id"`
+	Method     string `gorm:"type:varchar(10);not null" json:"method"`
+	URL        string `gorm:"type:varchar(100);not null" json:"url"`
+	Body       string `gorm:"type:text" json:"-"`
+	Status     string `gorm:"type:varchar(20);not null;index" json:"status"`
+	StatusCode int    `json:"status_code"`
+
+	// Response is the raw response body returned by the proxy
+	Response     string `gorm:"type:text" json:"-"`
+	ErrorMessage string `gorm:"type:text" json:"error_message,omitempty"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Batch item status constants
+const (
+	BatchItemStatusPending   = "pending"
+	BatchItemStatusSucceeded = "succeeded"
+	BatchItemStatusFailed    = "failed"
+	BatchItemStatusCancelled = "cancelled"
+	BatchItemStatusExpired   = "expired"
+)
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StoredFile is a file uploaded through the OpenAI-compatible /v1/files API (or generated by the
+// proxy, such as batch results). The content is kept on local disk at FilePath.
+type StoredFile struct {
+	gorm.Model
+
+	FileID    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"file_id"` // file-xxx
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Filename  string     `gorm:"type:varchar(255);not null" json:"filename"`
+	Purpose   string     `gorm:"type:varchar(30);not null;index" json:"purpose"`
+	Bytes     int64      `gorm:"not null" json:"bytes"`
+	FilePath  string     `gorm:"type:varchar(500);not null" json:"-"` // Never expose server paths
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// File purpose constants
+const (
+	FilePurposeAssistants       = "assistants"
+	FilePurposeAssistantsOutput = "assistants_output"
+	FilePurposeBatch            = "batch"
+	FilePurposeBatchOutput      = "batch_output"
+	FilePurposeFineTune         = "fine-tune"
+	FilePurposeVision           = "vision"
+	FilePurposeUserData         = "user_data"
+	FilePurposeEvals            = "evals"
+)
+
+// IsExpired returns true if the file's expiry time has passed
+func (f *StoredFile) IsExpired() bool {
+	return f.ExpiresAt != nil && time.Now().After(*f.ExpiresAt)
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ModelAlias publishes a stable virtual model name (such as "fast" or "code") that the proxy
+// resolves to a real provider and model. Aliases owned by a user apply to that user's keys;
+// global aliases (UserID 0, managed by admins) apply to every key, unless the user has an
+// alias of the same name.
+type ModelAlias struct {
+	gorm.Model
+
+	UserID      uint   `gorm:"not null;default:0;index" json:"user_id"` // 0 for global aliases
+	Name        string `gorm:"type:varchar(100);not null;index" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+
+	// Provider is the name or type of the provider to route to; empty lets any provider on the key
+	// that serves ModelName answer
+	Provider  string `gorm:"type:varchar(100)" json:"provider"`
+	ModelName string `gorm:"column:model;type:varchar(100);not null" json:"model"`
+
+	// Fallbacks are tried in order when the target fails, in the same "provider/model" form
+	// accepted by the proxy (e.g. ["anthropic/claude-haiku-4-5", "gpt-4o-mini"])
+	Fallbacks []string `gorm:"serializer:json" json:"fallbacks"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// IsGlobal reports whether the alias applies to every user's keys
+func (a *ModelAlias) IsGlobal() bool {
+	return a.UserID == 0
+}
+
+// Target returns the model name the alias resolves to, prefixed with its provider when set
+func (a *ModelAlias) Target() string {
+	if a.Provider == "" {
+		return a.ModelName
+	}
+	return a.Provider + "/" + a.ModelName
+}
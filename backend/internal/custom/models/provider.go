@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,11 +13,31 @@ type Provider struct {
 
 	UserID       uint     `gorm:"not null;index" json:"user_id"`
 	Name         string   `gorm:"type:varchar(100);not null" json:"name"`
-	ProviderType string   `gorm:"type:varchar(50);not null" json:"provider_type"` // openai, anthropic, anthropic_max, local
+	ProviderType string   `gorm:"type:varchar(50);not null" json:"provider_type"` // openai, anthropic, anthropic_max, local, ollama, ...
 	BaseURL      string   `gorm:"type:varchar(500)" json:"base_url"`
 	APIKey       string   `gorm:"type:varchar(500)" json:"-"` // Never expose in API responses (not required for OAuth providers)
 	Models       []string `gorm:"serializer:json" json:"models"`
 
+	// Azure OpenAI routes by deployment rather than model name
+	APIVersion  string            `gorm:"type:varchar(50)" json:"api_version"` // api-version query parameter (defaults to DefaultAzureAPIVersion)
+	Deployments map[string]string `gorm:"serializer:json" json:"deployments"`  // Model name -> deployment name; unmapped models use their own name
+
+	// AWS Bedrock signs requests with an access key pair; the secret access key is stored in APIKey
+	AWSAccessKeyID string `gorm:"type:varchar(128)" json:"aws_access_key_id"`
+	AWSRegion      string `gorm:"type:varchar(50)" json:"aws_region"` // e.g. us-east-1; also picks the default endpoint
+
+	// Custom providers describe their auth header, paths and extra headers here
+	CustomConfig *CustomProviderConfig `gorm:"serializer:json" json:"custom_config"`
+
+	// How failed requests to this provider are retried; nil means they are not
+	RetryPolicy *RetryPolicy `gorm:"serializer:json" json:"retry_policy"`
+
+	// When the proxy stops sending requests to this provider after failures; nil uses the defaults
+	CircuitBreaker *CircuitBreakerConfig `gorm:"serializer:json" json:"circuit_breaker"`
+
+	// How long the proxy waits on this provider; nil uses the defaults
+	Timeouts *ProviderTimeouts `gorm:"serializer:json" json:"timeouts"`
+
 	// OAuth fields for Claude Max subscription
 	RefreshToken   string     `gorm:"type:varchar(500)" json:"-"`           // OAuth refresh token (never expose)
 	AccessToken    string     `gorm:"type:varchar(500)" json:"-"`           // OAuth access token (never expose)
@@ -28,6 +49,11 @@ type Provider struct {
 	InputCostPerMillion  float64 `gorm:"column:input_cost_per_million;default:0" json:"input_cost_per_million"`
 	OutputCostPerMillion float64 `gorm:"column:output_cost_per_million;default:0" json:"output_cost_per_million"`
 
+	// Per-unit prices for the audio and image endpoints
+	AudioCostPerMinute      float64 `gorm:"column:audio_cost_per_minute;default:0" json:"audio_cost_per_minute"`           // Transcription and translation
+	CharacterCostPerMillion float64 `gorm:"column:character_cost_per_million;default:0" json:"character_cost_per_million"` // Speech synthesis
+	ImageCostPerImage       float64 `gorm:"column:image_cost_per_image;default:0" json:"image_cost_per_image"`             // Image generation and edits
+
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
@@ -40,8 +66,104 @@ const (
 	ProviderTypeLocal            = "local"
 	ProviderTypeZai              = "zai"
 	ProviderTypeZaiInternational = "zai_international"
+	ProviderTypeOllama           = "ollama"       // Native Ollama API (/api/chat, /api/embed)
+	ProviderTypeGemini           = "gemini"       // Google Gemini API (generateContent)
+	ProviderTypeAzureOpenAI      = "azure_openai" // Azure OpenAI (deployment URLs, api-key header)
+	ProviderTypeBedrock          = "bedrock"      // AWS Bedrock Converse API (SigV4 signed)
+	ProviderTypeCustom           = "custom"       // OpenAI-compatible vendor configured by CustomConfig
+)
+
+// CustomProviderConfig describes an OpenAI-compatible vendor (Groq, Mistral, OpenRouter, ...) that has
+// no dedicated provider type. Path templates may contain {model}, which expands to the model name.
+type CustomProviderConfig struct {
+	AuthHeader     string            `json:"auth_header,omitempty"`     // Header carrying the API key; defaults to Authorization
+	AuthPrefix     string            `json:"auth_prefix,omitempty"`     // Prepended to the API key; "Bearer " when AuthHeader is unset
+	ChatPath       string            `json:"chat_path,omitempty"`       // Defaults to /v1/chat/completions
+	ModelsPath     string            `json:"models_path,omitempty"`     // Defaults to /models beside ChatPath
+	EmbeddingsPath string            `json:"embeddings_path,omitempty"` // Defaults to /embeddings beside ChatPath
+	ExtraHeaders   map[string]string `json:"extra_headers,omitempty"`   // Static headers such as OpenAI-Organization or HTTP-Referer
+}
+
+// RetryPolicy describes how the proxy retries a request on the same provider before giving up or
+// falling back. Retries only happen before any of the response has reached the client.
+type RetryPolicy struct {
+	MaxAttempts      int   `json:"max_attempts"`                 // Attempts in total, including the first; 1 disables retries
+	InitialBackoffMs int   `json:"initial_backoff_ms,omitempty"` // Delay before the first retry, doubled for each one after it
+	MaxBackoffMs     int   `json:"max_backoff_ms,omitempty"`     // Cap on the delay, also for Retry-After; longer waits are not retried
+	RetryStatusCodes []int `json:"retry_status_codes,omitempty"` // Defaults to DefaultRetryStatusCodes
+}
+
+// Retry policy defaults for fields a provider leaves unset
+const (
+	DefaultRetryInitialBackoffMs = 500
+	DefaultRetryMaxBackoffMs     = 30000
 )
 
+// DefaultRetryStatusCodes are retried when a policy doesn't list its own: rate limits, server
+// errors and Anthropic's 529 overloaded
+var DefaultRetryStatusCodes = []int{429, 500, 502, 503, 504, 529}
+
+// CircuitBreakerConfig describes when a provider's circuit opens. After FailureThreshold
+// consecutive failed requests the proxy stops routing to the provider; once CooldownSeconds have
+// passed a single probe request is let through, which closes the circuit again if it succeeds.
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold,omitempty"` // Defaults to DefaultCircuitFailureThreshold
+	CooldownSeconds  int `json:"cooldown_seconds,omitempty"`  // Defaults to DefaultCircuitCooldownSeconds
+}
+
+// Circuit breaker defaults for fields a provider leaves unset
+const (
+	DefaultCircuitFailureThreshold = 3
+	DefaultCircuitCooldownSeconds  = 30
+)
+
+// Cooldown returns how long the circuit stays open before a probe is allowed
+func (c CircuitBreakerConfig) Cooldown() time.Duration {
+	return time.Duration(c.CooldownSeconds) * time.Second
+}
+
+// ProviderTimeouts bounds how long the proxy waits on a provider. Slow local models usually need
+// far longer first-byte and total timeouts than hosted APIs. Unset first-byte and stream idle
+// timeouts leave those phases bounded by the total timeout only.
+type ProviderTimeouts struct {
+	ConnectMs    int `json:"connect_ms,omitempty"`     // Establishing the connection; defaults to DefaultConnectTimeoutMs
+	FirstByteMs  int `json:"first_byte_ms,omitempty"`  // From sending the request to the response headers
+	StreamIdleMs int `json:"stream_idle_ms,omitempty"` // Longest wait for the next chunk of the response body
+	TotalMs      int `json:"total_ms,omitempty"`       // The whole request, body included; defaults to DefaultTotalTimeoutMs
+}
+
+// Timeout defaults for fields a provider leaves unset
+const (
+	DefaultConnectTimeoutMs = 10000
+	DefaultTotalTimeoutMs   = 300000
+)
+
+// Connect returns the connect timeout
+func (t ProviderTimeouts) Connect() time.Duration {
+	return time.Duration(t.ConnectMs) * time.Millisecond
+}
+
+// FirstByte returns the time-to-first-byte timeout; 0 means none
+func (t ProviderTimeouts) FirstByte() time.Duration {
+	return time.Duration(t.FirstByteMs) * time.Millisecond
+}
+
+// StreamIdle returns the stream idle timeout; 0 means none
+func (t ProviderTimeouts) StreamIdle() time.Duration {
+	return time.Duration(t.StreamIdleMs) * time.Millisecond
+}
+
+// Total returns the total request timeout
+func (t ProviderTimeouts) Total() time.Duration {
+	return time.Duration(t.TotalMs) * time.Millisecond
+}
+
+// DefaultCustomChatPath is the chat completions path used when a custom provider doesn't set one
+const DefaultCustomChatPath = "/v1/chat/completions"
+
+// DefaultAzureAPIVersion is the Azure OpenAI api-version used when a provider doesn't set one
+const DefaultAzureAPIVersion = "2024-10-21"
+
 // DefaultBaseURLs for known providers
 var DefaultBaseURLs = map[string]string{
 	ProviderTypeOpenAI:           "https://api.openai.com",
@@ -49,6 +171,8 @@ var DefaultBaseURLs = map[string]string{
 	ProviderTypeAnthropicMax:     "https://api.anthropic.com",
 	ProviderTypeZai:              "https://open.bigmodel.cn/api/paas/v4/",
 	ProviderTypeZaiInternational: "https://api.z.ai/api/coding/paas/v4",
+	ProviderTypeOllama:           "http://localhost:11434",
+	ProviderTypeGemini:           "https://generativelanguage.googleapis.com",
 }
 
 // GetBaseURL returns the provider's base URL, falling back to default if empty
@@ -59,14 +183,148 @@ func (p *Provider) GetBaseURL() string {
 	if defaultURL, ok := DefaultBaseURLs[p.ProviderType]; ok {
 		return defaultURL
 	}
+	// Bedrock endpoints are regional
+	if p.ProviderType == ProviderTypeBedrock && p.AWSRegion != "" {
+		return "https://bedrock-runtime." + p.AWSRegion + ".amazonaws.com"
+	}
 	return ""
 }
 
+// GetAPIVersion returns the Azure OpenAI api-version, falling back to the default if empty
+func (p *Provider) GetAPIVersion() string {
+	if p.APIVersion != "" {
+		return p.APIVersion
+	}
+	return DefaultAzureAPIVersion
+}
+
+// AzureDeployment returns the Azure deployment that serves a model
+func (p *Provider) AzureDeployment(model string) string {
+	if deployment := p.Deployments[model]; deployment != "" {
+		return deployment
+	}
+	return model
+}
+
+// GetCustomConfig returns the custom provider config with defaults filled in
+func (p *Provider) GetCustomConfig() CustomProviderConfig {
+	var cfg CustomProviderConfig
+	if p.CustomConfig != nil {
+		cfg = *p.CustomConfig
+	}
+
+	if cfg.AuthHeader == "" {
+		cfg.AuthHeader = "Authorization"
+		if cfg.AuthPrefix == "" {
+			cfg.AuthPrefix = "Bearer "
+		}
+	}
+	if cfg.ChatPath == "" {
+		cfg.ChatPath = DefaultCustomChatPath
+	}
+
+	// Other endpoints sit beside chat completions unless configured otherwise
+	apiPrefix := strings.TrimSuffix(cfg.ChatPath, "/chat/completions")
+	if cfg.ModelsPath == "" {
+		cfg.ModelsPath = apiPrefix + "/models"
+	}
+	if cfg.EmbeddingsPath == "" {
+		cfg.EmbeddingsPath = apiPrefix + "/embeddings"
+	}
+	return cfg
+}
+
+// GetRetryPolicy returns the provider's retry policy with defaults filled in
+func (p *Provider) GetRetryPolicy() RetryPolicy {
+	var policy RetryPolicy
+	if p.RetryPolicy != nil {
+		policy = *p.RetryPolicy
+	}
+
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.InitialBackoffMs <= 0 {
+		policy.InitialBackoffMs = DefaultRetryInitialBackoffMs
+	}
+	if policy.MaxBackoffMs <= 0 {
+		policy.MaxBackoffMs = DefaultRetryMaxBackoffMs
+	}
+	if len(policy.RetryStatusCodes) == 0 {
+		policy.RetryStatusCodes = DefaultRetryStatusCodes
+	}
+	return policy
+}
+
+// GetCircuitBreaker returns the provider's circuit breaker config with defaults filled in
+func (p *Provider) GetCircuitBreaker() CircuitBreakerConfig {
+	var cfg CircuitBreakerConfig
+	if p.CircuitBreaker != nil {
+		cfg = *p.CircuitBreaker
+	}
+
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if cfg.CooldownSeconds <= 0 {
+		cfg.CooldownSeconds = DefaultCircuitCooldownSeconds
+	}
+	return cfg
+}
+
+// GetTimeouts returns the provider's timeouts with defaults filled in
+func (p *Provider) GetTimeouts() ProviderTimeouts {
+	var timeouts ProviderTimeouts
+	if p.Timeouts != nil {
+		timeouts = *p.Timeouts
+	}
+
+	if timeouts.ConnectMs <= 0 {
+		timeouts.ConnectMs = DefaultConnectTimeoutMs
+	}
+	if timeouts.FirstByteMs < 0 {
+		timeouts.FirstByteMs = 0
+	}
+	if timeouts.StreamIdleMs < 0 {
+		timeouts.StreamIdleMs = 0
+	}
+	if timeouts.TotalMs <= 0 {
+		timeouts.TotalMs = DefaultTotalTimeoutMs
+	}
+	return timeouts
+}
+
 // IsOAuthProvider returns true if this provider uses OAuth authentication
 func (p *Provider) IsOAuthProvider() bool {
 	return p.ProviderType == ProviderTypeAnthropicMax
 }
 
+// IsAnthropicProvider returns true if this provider natively serves the Anthropic Messages API
+func (p *Provider) IsAnthropicProvider() bool {
+	return p.ProviderType == ProviderTypeAnthropic || p.ProviderType == ProviderTypeAnthropicMax
+}
+
+// IsOpenAICompatible returns true if this provider serves the OpenAI chat completions API, so
+// OpenAI-format requests can be forwarded without translation
+func (p *Provider) IsOpenAICompatible() bool {
+	switch p.ProviderType {
+	case ProviderTypeAnthropic, ProviderTypeAnthropicMax, ProviderTypeOllama, ProviderTypeGemini, ProviderTypeBedrock:
+		return false
+	default:
+		return true
+	}
+}
+
+// SupportsTextCompletions returns true if this provider serves the legacy /v1/completions API
+func (p *Provider) SupportsTextCompletions() bool {
+	switch p.ProviderType {
+	case ProviderTypeOpenAI, ProviderTypeVLLM, ProviderTypeLocal:
+		return true
+	default:
+		return false
+	}
+}
+
 // IsTokenExpired returns true if the OAuth access token has expired or will expire soon
 func (p *Provider) IsTokenExpired() bool {
 	if p.TokenExpiresAt == nil {
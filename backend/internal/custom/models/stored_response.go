@@ -0,0 +1,26 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// StoredResponse keeps a Responses API result so later requests can continue the
+// conversation with previous_response_id
+type StoredResponse struct {
+	gorm.Model
+
+	ResponseID string `gorm:"type:varchar(64);uniqueIndex;not null" json:"response_id"` // resp_xxx
+	UserID     uint   `gorm:"not null;index" json:"user_id"`
+	ProxyKeyID uint   `gorm:"not null;index" json:"proxy_key_id"`
+	ModelName  string `gorm:"column:model;type:varchar(100)" json:"model"`
+
+	// Messages is the JSON-encoded chat history up to and including this response's output
+	Messages string `gorm:"type:text" json:"-"`
+	// Body is the JSON-encoded response object returned to the client
+	Body string `gorm:"type:text" json:"-"`
+
+	// Relationships
+	ProxyKey *ProxyAPIKey `gorm:"foreignKey:ProxyKeyID;constraint:OnDelete:CASCADE" json:"proxy_key,omitempty"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -12,15 +12,23 @@ type UsageRecord struct {
 	ProxyKeyID uint   `gorm:"not null;index" json:"proxy_key_id"`
 	ProviderID uint   `gorm:"not null;index" json:"provider_id"`
 	ModelName  string `gorm:"column:model;type:varchar(100);index" json:"model"`
+	// Alias is the virtual model name the client asked for when it resolved to ModelName
+	Alias string `gorm:"type:varchar(100);index" json:"alias,omitempty"`
 
 	InputTokens  int `gorm:"default:0" json:"input_tokens"`
 	OutputTokens int `gorm:"default:0" json:"output_tokens"`
 	TotalTokens  int `gorm:"default:0" json:"total_tokens"`
 
+	// Non-token usage from the audio and image endpoints
+	AudioSeconds float64 `gorm:"default:0" json:"audio_seconds"` // Audio transcribed or translated
+	Characters   int     `gorm:"default:0" json:"characters"`    // Text synthesized to speech
+	Images       int     `gorm:"default:0" json:"images"`        // Images generated or edited
+
 	Cost            float64 `gorm:"default:0" json:"cost"`
 	RequestDuration int     `gorm:"default:0" json:"request_duration"` // milliseconds
 	StatusCode      int     `gorm:"default:0" json:"status_code"`
 	ErrorMessage    string  `gorm:"type:text" json:"error_message,omitempty"`
+	Attempts        int     `gorm:"default:1" json:"attempts"` // Requests sent to the provider, counting retries
 
 	// Relationships
 	ProxyKey *ProxyAPIKey `gorm:"foreignKey:ProxyKeyID;constraint:OnDelete:CASCADE" json:"proxy_key,omitempty"`
@@ -36,6 +44,14 @@ func (u *UsageRecord) CalculateCost(inputCostPerMillion, outputCostPerMillion fl
 	return inputCost + outputCost
 }
 
+// CalculateUnitCost computes the cost of non-token usage from per-unit provider rates
+func (u *UsageRecord) CalculateUnitCost(audioCostPerMinute, characterCostPerMillion, imageCostPerImage float64) float64 {
+	audioCost := u.AudioSeconds / 60.0 * audioCostPerMinute
+	characterCost := float64(u.Characters) / 1000000.0 * characterCostPerMillion
+	imageCost := float64(u.Images) * imageCostPerImage
+	return audioCost + characterCost + imageCost
+}
+
 // IsError checks if the request resulted in an error
 func (u *UsageRecord) IsError() bool {
 	return u.StatusCode >= 400 || u.ErrorMessage != ""
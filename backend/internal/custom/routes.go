@@ -1,6 +1,7 @@
 package custom
 
 import (
+	"context"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +18,9 @@ type Dependencies struct {
 	Config *config.Config
 	JWT    *auth.JWTService
 	RBAC   *rbac.Middleware
+	// LoadBalancer is shared by the providers API and the proxy, so the API reports the circuit
+	// breaker state the proxy routes by
+	LoadBalancer *services.LoadBalancer
 }
 
 // RegisterRoutes lets downstream projects add routes without touching core wiring.
@@ -27,9 +31,10 @@ func RegisterRoutes(v1 *gin.RouterGroup, deps Dependencies) {
 	}
 
 	// Initialize services
-	providerService := services.NewProviderService(deps.DB)
+	providerService := services.NewProviderService(deps.DB, deps.LoadBalancer)
 	keyService := services.NewKeyService(deps.DB)
 	usageService := services.NewUsageService(deps.DB)
+	aliasService := services.NewAliasService(deps.DB)
 	oauthService := services.NewOAuthService(deps.DB, providerService, deps.Config.FrontendURL)
 
 	// Wire up OAuth service to provider service (for token refresh on create)
@@ -39,6 +44,7 @@ func RegisterRoutes(v1 *gin.RouterGroup, deps Dependencies) {
 	providerHandler := handlers.NewProviderHandler(providerService)
 	keyHandler := handlers.NewKeyHandler(keyService)
 	usageHandler := handlers.NewUsageHandler(usageService)
+	aliasHandler := handlers.NewAliasHandler(aliasService)
 	oauthHandler := handlers.NewOAuthHandler(oauthService, deps.Config.FrontendURL)
 
 	// Provider routes (protected with JWT)
@@ -80,6 +86,16 @@ func RegisterRoutes(v1 *gin.RouterGroup, deps Dependencies) {
 		keys.POST("/:id/revoke", keyHandler.RevokeKey)
 	}
 
+	// Model alias routes (protected with JWT)
+	aliases := v1.Group("/aliases")
+	aliases.Use(auth.AuthMiddleware(deps.JWT))
+	{
+		aliases.GET("", aliasHandler.ListAliases)
+		aliases.POST("", aliasHandler.CreateAlias)
+		aliases.PUT("/:id", aliasHandler.UpdateAlias)
+		aliases.DELETE("/:id", aliasHandler.DeleteAlias)
+	}
+
 	// Usage routes (protected with JWT)
 	usage := v1.Group("/usage")
 	usage.Use(auth.AuthMiddleware(deps.JWT))
@@ -96,31 +112,93 @@ func RegisterRoutes(v1 *gin.RouterGroup, deps Dependencies) {
 // RegisterProxyRoutes registers the LLM proxy routes at /v1 (outside /api/v1 group)
 // This is necessary for OpenAI API compatibility - clients expect /v1/chat/completions
 // These routes use proxy API key authentication, not JWT
-func RegisterProxyRoutes(router *gin.Engine, deps Dependencies) {
+// Background work (queued batches, file cleanup) runs until ctx is cancelled; the returned func
+// waits for it to stop
+func RegisterProxyRoutes(ctx context.Context, router *gin.Engine, deps Dependencies) func() {
 	// Initialize services
-	providerService := services.NewProviderService(deps.DB)
+	providerService := services.NewProviderService(deps.DB, deps.LoadBalancer)
 	keyService := services.NewKeyService(deps.DB)
 	usageService := services.NewUsageService(deps.DB)
 	oauthService := services.NewOAuthService(deps.DB, providerService, deps.Config.FrontendURL)
-	proxyService := services.NewProxyService(keyService, providerService, usageService, oauthService)
+	responseService := services.NewResponseService(deps.DB)
+	proxyService := services.NewProxyService(keyService, providerService, usageService, oauthService, responseService, deps.LoadBalancer)
+	fileService := services.NewFileService(deps.DB, deps.Config.FilesPath, deps.Config.FilesMaxSize)
+	batchService := services.NewBatchService(deps.DB, keyService, proxyService, deps.Config.BatchConcurrency)
+
+	// Batches read input_file_id from and write their result files to the file store
+	batchService.SetFileStore(fileService)
+
+	if err := fileService.EnsureStorageDirectory(); err != nil {
+		log.Fatalf("Failed to create file storage directory: %v", err)
+	}
 
-	// Initialize proxy handler
+	// Run queued batches and expired file and response cleanup in the background until shutdown
+	batchService.Start(ctx)
+	fileService.StartCleanup(ctx)
+	responseService.StartCleanup(ctx)
+
+	// Initialize proxy handlers
 	proxyHandler := handlers.NewProxyHandler(proxyService)
+	batchHandler := handlers.NewBatchHandler(proxyService, batchService)
+	fileHandler := handlers.NewFileHandler(proxyService, fileService)
 
 	// Proxy routes at /v1 (OpenAI-compatible and Anthropic-compatible endpoints)
-	// These use proxy API key authentication (Bearer sk-smoothllm-xxx), not JWT
+	// These use proxy API key authentication (Bearer sk-smoothllm-xxx, x-api-key or api-key), not JWT
 	v1Proxy := router.Group("/v1")
 	{
 		// OpenAI-compatible chat completions endpoint
 		v1Proxy.POST("/chat/completions", proxyHandler.ChatCompletions)
 
 		// OpenAI-compatible completions endpoint (legacy)
-		v1Proxy.POST("/completions", proxyHandler.ChatCompletions)
+		v1Proxy.POST("/completions", proxyHandler.Completions)
+
+		// OpenAI-compatible embeddings endpoint
+		v1Proxy.POST("/embeddings", proxyHandler.Embeddings)
+
+		// Audio transcription, translation and speech (OpenAI-compatible providers)
+		v1Proxy.POST("/audio/transcriptions", proxyHandler.AudioTranscriptions)
+		v1Proxy.POST("/audio/translations", proxyHandler.AudioTranslations)
+		v1Proxy.POST("/audio/speech", proxyHandler.AudioSpeech)
+
+		// Image generation and edits (OpenAI-compatible providers)
+		v1Proxy.POST("/images/generations", proxyHandler.ImageGenerations)
+		v1Proxy.POST("/images/edits", proxyHandler.ImageEdits)
+
+		// OpenAI Responses API (stored responses back previous_response_id for translated providers)
+		v1Proxy.POST("/responses", proxyHandler.Responses)
+		v1Proxy.GET("/responses/:id", proxyHandler.GetResponse)
+		v1Proxy.DELETE("/responses/:id", proxyHandler.DeleteResponse)
 
 		// OpenAI-compatible models list endpoint
 		v1Proxy.GET("/models", proxyHandler.ListModels)
 
 		// Anthropic-compatible messages endpoint (for Claude Code and other Anthropic SDK clients)
 		v1Proxy.POST("/messages", proxyHandler.Messages)
+		v1Proxy.POST("/messages/count_tokens", proxyHandler.CountTokens)
+
+		// OpenAI-compatible files API (stored on local disk, scoped to the key's user)
+		v1Proxy.POST("/files", fileHandler.UploadFile)
+		v1Proxy.GET("/files", fileHandler.ListFiles)
+		v1Proxy.GET("/files/:id", fileHandler.GetFile)
+		v1Proxy.GET("/files/:id/content", fileHandler.GetFileContent)
+		v1Proxy.DELETE("/files/:id", fileHandler.DeleteFile)
+
+		// OpenAI batch API (input uploaded with the batch or referenced by input_file_id)
+		v1Proxy.POST("/batches", batchHandler.CreateBatch)
+		v1Proxy.GET("/batches", batchHandler.ListBatches)
+		v1Proxy.GET("/batches/:id", batchHandler.GetBatch)
+		v1Proxy.POST("/batches/:id/cancel", batchHandler.CancelBatch)
+		v1Proxy.GET("/batches/:id/output", batchHandler.GetBatchOutput)
+		v1Proxy.GET("/batches/:id/errors", batchHandler.GetBatchErrors)
+
+		// Anthropic message batches API
+		v1Proxy.POST("/messages/batches", batchHandler.CreateMessageBatch)
+		v1Proxy.GET("/messages/batches", batchHandler.ListMessageBatches)
+		v1Proxy.GET("/messages/batches/:id", batchHandler.GetMessageBatch)
+		v1Proxy.DELETE("/messages/batches/:id", batchHandler.DeleteMessageBatch)
+		v1Proxy.POST("/messages/batches/:id/cancel", batchHandler.CancelMessageBatch)
+		v1Proxy.GET("/messages/batches/:id/results", batchHandler.GetMessageBatchResults)
 	}
+
+	return batchService.Wait
 }
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// AliasService handles model alias CRUD operations
+type AliasService struct {
+	db *gorm.DB
+}
+
+// NewAliasService creates a new AliasService instance
+func NewAliasService(db *gorm.DB) *AliasService {
+	return &AliasService{db: db}
+}
+
+// AliasResponse represents the alias data returned to clients
+type AliasResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model"`
+	Fallbacks   []string  `json:"fallbacks"`
+	Global      bool      `json:"global"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CreateAliasRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Provider    string   `json:"provider"`
+	Model       string   `json:"model" binding:"required"`
+	Fallbacks   []string `json:"fallbacks"`
+	Global      bool     `json:"global"` // Admins only: applies to every user's keys
+}
+
+type UpdateAliasRequest struct {
+	Description *string  `json:"description,omitempty"`
+	Provider    *string  `json:"provider,omitempty"`
+	Model       *string  `json:"model,omitempty"`
+	Fallbacks   []string `json:"fallbacks,omitempty"` // Replaces the whole list; send [] to clear
+}
+
+// ListAliases returns the user's own aliases followed by the global ones
+func (s *AliasService) ListAliases(userID uint) ([]AliasResponse, error) {
+	var aliases []models.ModelAlias
+	if err := s.db.Where("user_id = ? OR user_id = 0", userID).Order("user_id DESC, name").Find(&aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+
+	responses := make([]AliasResponse, len(aliases))
+	for i := range aliases {
+		responses[i] = buildAliasResponse(&aliases[i])
+	}
+	return responses, nil
+}
+
+// CreateAlias creates an alias owned by the user, or a global alias when an admin asks for one
+func (s *AliasService) CreateAlias(userID uint, isAdmin bool, req *CreateAliasRequest) (*AliasResponse, error) {
+	if req.Global && !isAdmin {
+		return nil, fmt.Errorf("only admins can create global aliases")
+	}
+
+	alias := models.ModelAlias{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Provider:    strings.TrimSpace(req.Provider),
+		ModelName:   strings.TrimSpace(req.Model),
+		Fallbacks:   req.Fallbacks,
+	}
+	if req.Global {
+		alias.UserID = 0
+	}
+
+	if err := validateAlias(&alias); err != nil {
+		return nil, err
+	}
+
+	// Names are unique per owner; a user alias may shadow a global one
+	var count int64
+	if err := s.db.Model(&models.ModelAlias{}).Where("user_id = ? AND name = ?", alias.UserID, alias.Name).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check alias name: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("alias %s already exists", alias.Name)
+	}
+
+	if err := s.db.Create(&alias).Error; err != nil {
+		return nil, fmt.Errorf("failed to create alias: %w", err)
+	}
+
+	response := buildAliasResponse(&alias)
+	return &response, nil
+}
+
+// UpdateAlias updates an alias the user owns, or a global alias when the user is an admin
+func (s *AliasService) UpdateAlias(userID uint, isAdmin bool, aliasID uint, req *UpdateAliasRequest) (*AliasResponse, error) {
+	alias, err := s.getEditableAlias(userID, isAdmin, aliasID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Description != nil {
+		alias.Description = *req.Description
+		updates["description"] = alias.Description
+	}
+	if req.Provider != nil {
+		alias.Provider = strings.TrimSpace(*req.Provider)
+		updates["provider"] = alias.Provider
+	}
+	if req.Model != nil {
+		alias.ModelName = strings.TrimSpace(*req.Model)
+		updates["model"] = alias.ModelName
+	}
+	if req.Fallbacks != nil {
+		alias.Fallbacks = req.Fallbacks
+		// Map updates bypass the field's JSON serializer, so encode it here
+		fallbacks, err := json.Marshal(req.Fallbacks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode fallbacks: %w", err)
+		}
+		updates["fallbacks"] = string(fallbacks)
+	}
+
+	if err := validateAlias(alias); err != nil {
+		return nil, err
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(alias).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update alias: %w", err)
+		}
+	}
+
+	response := buildAliasResponse(alias)
+	return &response, nil
+}
+
+// DeleteAlias deletes an alias the user owns, or a global alias when the user is an admin
+func (s *AliasService) DeleteAlias(userID uint, isAdmin bool, aliasID uint) error {
+	alias, err := s.getEditableAlias(userID, isAdmin, aliasID)
+	if err != nil {
+		return err
+	}
+
+	// Delete for real so the name can be reused
+	if err := s.db.Unscoped().Delete(alias).Error; err != nil {
+		return fmt.Errorf("failed to delete alias: %w", err)
+	}
+	return nil
+}
+
+// getEditableAlias loads an alias the user is allowed to change
+func (s *AliasService) getEditableAlias(userID uint, isAdmin bool, aliasID uint) (*models.ModelAlias, error) {
+	var alias models.ModelAlias
+	if err := s.db.First(&alias, aliasID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("alias not found")
+		}
+		return nil, fmt.Errorf("failed to get alias: %w", err)
+	}
+
+	switch {
+	case alias.UserID == userID:
+		return &alias, nil
+	case alias.IsGlobal() && isAdmin:
+		return &alias, nil
+	case alias.IsGlobal():
+		return nil, fmt.Errorf("only admins can change global aliases")
+	default:
+		return nil, fmt.Errorf("alias not found")
+	}
+}
+
+// validateAlias checks an alias's name, target and fallbacks
+func validateAlias(alias *models.ModelAlias) error {
+	if alias.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(alias.Name) > 100 {
+		return fmt.Errorf("name must be 100 characters or less")
+	}
+	// A slash would read as a provider prefix, and the name travels in URLs and JSON as-is
+	if strings.ContainsAny(alias.Name, "/ \t\r\n") {
+		return fmt.Errorf("name must not contain slashes or whitespace")
+	}
+	if alias.ModelName == "" {
+		return fmt.Errorf("model is required")
+	}
+	if alias.Target() == alias.Name {
+		return fmt.Errorf("alias %s cannot point to itself", alias.Name)
+	}
+	for _, fallback := range alias.Fallbacks {
+		if strings.TrimSpace(fallback) == "" {
+			return fmt.Errorf("fallbacks must not be empty")
+		}
+		if fallback == alias.Name {
+			return fmt.Errorf("alias %s cannot fall back to itself", alias.Name)
+		}
+	}
+	return nil
+}
+
+// buildAliasResponse creates an AliasResponse from a ModelAlias model
+func buildAliasResponse(alias *models.ModelAlias) AliasResponse {
+	fallbacks := alias.Fallbacks
+	if fallbacks == nil {
+		fallbacks = []string{}
+	}
+	return AliasResponse{
+		ID:          alias.ID,
+		Name:        alias.Name,
+		Description: alias.Description,
+		Provider:    alias.Provider,
+		Model:       alias.ModelName,
+		Fallbacks:   fallbacks,
+		Global:      alias.IsGlobal(),
+		CreatedAt:   alias.CreatedAt,
+		UpdatedAt:   alias.UpdatedAt,
+	}
+}
+
+// loadModelAliases returns the aliases visible to a user by name; the user's own aliases
+// take precedence over global ones of the same name
+func loadModelAliases(db *gorm.DB, userID uint) (map[string]*models.ModelAlias, error) {
+	var aliases []models.ModelAlias
+	if err := db.Where("user_id = ? OR user_id = 0", userID).Order("user_id").Find(&aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to load model aliases: %w", err)
+	}
+
+	byName := make(map[string]*models.ModelAlias, len(aliases))
+	for i := range aliases {
+		byName[aliases[i].Name] = &aliases[i]
+	}
+	return byName, nil
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+func TestAliasService_CRUD(t *testing.T) {
+	db := setupKeyTestDB(t)
+	service := NewAliasService(db)
+
+	t.Run("creates and lists a user alias", func(t *testing.T) {
+		alias, err := service.CreateAlias(1, false, &CreateAliasRequest{
+			Name:      "fast",
+			Provider:  "openai",
+			Model:     "gpt-4o-mini",
+			Fallbacks: []string{"anthropic/claude-haiku-4-5"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "fast", alias.Name)
+		assert.False(t, alias.Global)
+
+		aliases, err := service.ListAliases(1)
+		require.NoError(t, err)
+		require.Len(t, aliases, 1)
+		assert.Equal(t, []string{"anthropic/claude-haiku-4-5"}, aliases[0].Fallbacks)
+
+		// Other users don't see it
+		aliases, err = service.ListAliases(2)
+		require.NoError(t, err)
+		assert.Empty(t, aliases)
+	})
+
+	t.Run("rejects duplicate and invalid aliases", func(t *testing.T) {
+		_, err := service.CreateAlias(1, false, &CreateAliasRequest{Name: "fast", Model: "gpt-4o"})
+		assert.ErrorContains(t, err, "already exists")
+
+		_, err = service.CreateAlias(1, false, &CreateAliasRequest{Name: "openai/fast", Model: "gpt-4o"})
+		assert.ErrorContains(t, err, "must not contain slashes")
+
+		_, err = service.CreateAlias(1, false, &CreateAliasRequest{Name: "loop", Model: "loop"})
+		assert.ErrorContains(t, err, "cannot point to itself")
+
+		_, err = service.CreateAlias(1, false, &CreateAliasRequest{Name: "loop", Model: "gpt-4o", Fallbacks: []string{"loop"}})
+		assert.ErrorContains(t, err, "cannot fall back to itself")
+	})
+
+	t.Run("only admins manage global aliases", func(t *testing.T) {
+		_, err := service.CreateAlias(1, false, &CreateAliasRequest{Name: "code", Model: "gpt-4o", Global: true})
+		assert.ErrorContains(t, err, "only admins")
+
+		global, err := service.CreateAlias(9, true, &CreateAliasRequest{Name: "code", Model: "gpt-4o", Global: true})
+		require.NoError(t, err)
+		assert.True(t, global.Global)
+
+		model := "gpt-4.1"
+		_, err = service.UpdateAlias(1, false, global.ID, &UpdateAliasRequest{Model: &model})
+		assert.ErrorContains(t, err, "only admins")
+		assert.ErrorContains(t, service.DeleteAlias(1, false, global.ID), "only admins")
+
+		updated, err := service.UpdateAlias(9, true, global.ID, &UpdateAliasRequest{Model: &model})
+		require.NoError(t, err)
+		assert.Equal(t, "gpt-4.1", updated.Model)
+
+		// Every user sees global aliases after their own
+		aliases, err := service.ListAliases(1)
+		require.NoError(t, err)
+		require.Len(t, aliases, 2)
+		assert.Equal(t, "fast", aliases[0].Name)
+		assert.Equal(t, "code", aliases[1].Name)
+	})
+
+	t.Run("updates fallbacks and deletes a user alias", func(t *testing.T) {
+		aliases, err := service.ListAliases(1)
+		require.NoError(t, err)
+		id := aliases[0].ID
+
+		// Another user's alias is invisible to them
+		_, err = service.UpdateAlias(2, false, id, &UpdateAliasRequest{Fallbacks: []string{}})
+		assert.ErrorContains(t, err, "alias not found")
+
+		updated, err := service.UpdateAlias(1, false, id, &UpdateAliasRequest{Fallbacks: []string{}})
+		require.NoError(t, err)
+		assert.Empty(t, updated.Fallbacks)
+
+		var stored models.ModelAlias
+		require.NoError(t, db.First(&stored, id).Error)
+		assert.Empty(t, stored.Fallbacks)
+
+		require.NoError(t, service.DeleteAlias(1, false, id))
+		_, err = service.CreateAlias(1, false, &CreateAliasRequest{Name: "fast", Model: "gpt-4o"})
+		assert.NoError(t, err, "a deleted alias's name can be reused")
+	})
+}
+
+func TestLoadModelAliases(t *testing.T) {
+	db := setupKeyTestDB(t)
+	service := NewAliasService(db)
+
+	_, err := service.CreateAlias(9, true, &CreateAliasRequest{Name: "fast", Model: "gpt-4o-mini", Global: true})
+	require.NoError(t, err)
+	_, err = service.CreateAlias(9, true, &CreateAliasRequest{Name: "code", Model: "gpt-4o", Global: true})
+	require.NoError(t, err)
+	_, err = service.CreateAlias(1, false, &CreateAliasRequest{Name: "fast", Provider: "anthropic", Model: "claude-haiku-4-5"})
+	require.NoError(t, err)
+
+	aliases, err := loadModelAliases(db, 1)
+	require.NoError(t, err)
+	require.Len(t, aliases, 2)
+	assert.Equal(t, "anthropic/claude-haiku-4-5", aliases["fast"].Target(), "user aliases shadow global ones")
+	assert.Equal(t, "gpt-4o", aliases["code"].Target())
+
+	aliases, err = loadModelAliases(db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", aliases["fast"].Target())
+}
+
+// modelCapturingUpstream answers chat requests with body and records the model of each request
+func modelCapturingUpstream(t *testing.T, status int, body string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &req)
+		mu.Lock()
+		seen = append(seen, req.Model)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+}
+
+func TestProxyService_ModelAliases(t *testing.T) {
+	okBody := `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`
+
+	t.Run("resolves an alias and records both names in usage", func(t *testing.T) {
+		upstream, seen := modelCapturingUpstream(t, http.StatusOK, okBody)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		_, err := NewAliasService(db).CreateAlias(1, false, &CreateAliasRequest{Name: "fast", Provider: "openai", Model: "gpt-4o-mini"})
+		require.NoError(t, err)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "OpenAI", ProviderType: models.ProviderTypeOpenAI, BaseURL: upstream.URL},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"fast","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []string{"gpt-4o-mini"}, seen())
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			err := db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error
+			return err == nil && record.Alias == "fast" && record.ModelName == "openai/gpt-4o-mini"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("falls back along the alias's fallbacks", func(t *testing.T) {
+		failing, failingSeen := modelCapturingUpstream(t, http.StatusServiceUnavailable, `{"error":{"message":"down"}}`)
+		healthy, healthySeen := modelCapturingUpstream(t, http.StatusOK, okBody)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		_, err := NewAliasService(db).CreateAlias(1, false, &CreateAliasRequest{
+			Name:      "smart",
+			Provider:  "Primary",
+			Model:     "gpt-4o",
+			Fallbacks: []string{"Backup/gpt-4o-mini"},
+		})
+		require.NoError(t, err)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Primary", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL},
+			&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeOpenAI, BaseURL: healthy.URL},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"smart","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Backup", resp.Header.Get(ProviderHeader))
+		assert.Equal(t, []string{"gpt-4o"}, failingSeen())
+		assert.Equal(t, []string{"gpt-4o-mini"}, healthySeen())
+
+		// Both attempts keep the alias the client asked for
+		assert.Eventually(t, func() bool {
+			var records []models.UsageRecord
+			db.Where("proxy_key_id = ?", proxyKey.ID).Order("status_code").Find(&records)
+			return len(records) == 2 &&
+				records[0].Alias == "smart" && records[0].ModelName == "Backup/gpt-4o-mini" &&
+				records[1].Alias == "smart" && records[1].ModelName == "Primary/gpt-4o"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("lists aliases whose target the key can reach", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		aliasService := NewAliasService(db)
+		_, err := aliasService.CreateAlias(1, false, &CreateAliasRequest{Name: "fast", Provider: "openai", Model: "gpt-4o-mini"})
+		require.NoError(t, err)
+		_, err = aliasService.CreateAlias(1, false, &CreateAliasRequest{Name: "gemini", Provider: "gemini", Model: "gemini-2.5-pro"})
+		require.NoError(t, err)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "OpenAI", ProviderType: models.ProviderTypeOpenAI},
+		)
+
+		list, err := service.ListModelsForKey(proxyKey)
+		require.NoError(t, err)
+		raw, err := json.Marshal(list)
+		require.NoError(t, err)
+
+		var body struct {
+			Data []struct {
+				ID      string `json:"id"`
+				OwnedBy string `json:"owned_by"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(raw, &body))
+		ids := make(map[string]string)
+		for _, m := range body.Data {
+			ids[m.ID] = m.OwnedBy
+		}
+		assert.Equal(t, "smoothllm", ids["fast"])
+		assert.NotContains(t, ids, "gemini")
+	})
+}
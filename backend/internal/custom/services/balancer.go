@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// latencySmoothing is the weight of the newest sample in a provider's latency average
+const latencySmoothing = 0.3
+
+// Circuit breaker states reported for a provider
+const (
+	CircuitClosed   = "closed"    // Requests flow normally
+	CircuitOpen     = "open"      // Failing; skipped in routing until the cooldown passes
+	CircuitHalfOpen = "half_open" // Cooldown passed; the next request probes the provider
+)
+
+// ErrCircuitOpen is returned when every provider that serves a model has its circuit open
+var ErrCircuitOpen = errors.New("provider circuit open")
+
+// attemptOutcome is what an upstream attempt says about the health of its provider
+type attemptOutcome int
+
+const (
+	attemptSucceeded attemptOutcome = iota // The provider answered
+	attemptFailed                          // Connection error, timeout, 429 or 5xx
+	attemptSkipped                         // Says nothing: the client went away, or nothing reached the provider
+)
+
+// providerStats is the in-memory health and latency picture of one provider
+type providerStats struct {
+	inFlight            int
+	latency             time.Duration // Moving average of the time to response headers
+	consecutiveFailures int
+	openedAt            time.Time // When the circuit last opened
+	probing             bool      // A half-open probe is in flight
+}
+
+// ProviderHealth is a snapshot of a provider's circuit breaker and load, as seen by this process
+type ProviderHealth struct {
+	State               string     `json:"state"` // closed, open or half_open
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"` // Set while the circuit is not closed
+	RetryAt             *time.Time `json:"retry_at,omitempty"`  // When an open circuit lets a probe through
+	InFlight            int        `json:"in_flight"`
+	LatencyMs           int64      `json:"latency_ms"` // Moving average; 0 until measured
+}
+
+// LoadBalancer orders the providers that serve a model according to the key's routing strategy,
+// and tracks the in-flight requests, latency and circuit breaker of each provider to do so. State
+// lives in memory only and starts empty on every restart.
+type LoadBalancer struct {
+	mu       sync.Mutex
+	stats    map[uint]*providerStats
+	turns    map[string]int          // Round-robin position per key and model
+	weighted map[string]map[uint]int // Smooth weighted round-robin state per key and model
+	now      func() time.Time
+}
+
+// NewLoadBalancer creates a LoadBalancer with no recorded state
+func NewLoadBalancer() *LoadBalancer {
+	return &LoadBalancer{
+		stats:    make(map[uint]*providerStats),
+		turns:    make(map[string]int),
+		weighted: make(map[string]map[uint]int),
+		now:      time.Now,
+	}
+}
+
+// order returns providers (all serving model for proxyKey, in key order) in the order to try them,
+// leaving out those whose circuit is open. The rest are ordered by the routing strategy.
+func (b *LoadBalancer) order(proxyKey *models.ProxyAPIKey, model string, providers []*models.Provider) []*models.Provider {
+	if b == nil {
+		return providers
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var healthy []*models.Provider
+	for _, provider := range providers {
+		if b.admits(provider) {
+			healthy = append(healthy, provider)
+		}
+	}
+	if len(healthy) < 2 {
+		return healthy
+	}
+
+	stateKey := fmt.Sprintf("%d/%s", proxyKey.ID, model)
+	switch proxyKey.RoutingStrategyFor(model) {
+	case models.RoutingStrategyRoundRobin:
+		turn := b.turns[stateKey] % len(healthy)
+		b.turns[stateKey] = turn + 1
+		healthy = append(append([]*models.Provider{}, healthy[turn:]...), healthy[:turn]...)
+	case models.RoutingStrategyWeighted:
+		healthy = b.pickWeighted(stateKey, proxyKey, healthy)
+	case models.RoutingStrategyLeastInFlight:
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return b.statsFor(healthy[i].ID).inFlight < b.statsFor(healthy[j].ID).inFlight
+		})
+	case models.RoutingStrategyLowestLatency:
+		// Providers without samples sort first, so every provider gets measured
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return b.statsFor(healthy[i].ID).latency < b.statsFor(healthy[j].ID).latency
+		})
+	}
+
+	return healthy
+}
+
+// pickWeighted moves the provider chosen by smooth weighted round-robin to the front and orders
+// the rest by weight. Over any window of sum(weights) requests each provider is picked exactly
+// weight times, interleaved rather than in bursts.
+func (b *LoadBalancer) pickWeighted(stateKey string, proxyKey *models.ProxyAPIKey, providers []*models.Provider) []*models.Provider {
+	weights := make(map[uint]int, len(providers))
+	for _, ap := range proxyKey.AllowedProviders {
+		weights[ap.ProviderID] = ap.Weight
+	}
+	weightOf := func(provider *models.Provider) int {
+		if w := weights[provider.ID]; w > 0 {
+			return w
+		}
+		return 1
+	}
+
+	current := b.weighted[stateKey]
+	if current == nil {
+		current = make(map[uint]int)
+		b.weighted[stateKey] = current
+	}
+
+	total, best := 0, 0
+	for i, provider := range providers {
+		current[provider.ID] += weightOf(provider)
+		total += weightOf(provider)
+		if current[provider.ID] > current[providers[best].ID] {
+			best = i
+		}
+	}
+	current[providers[best].ID] -= total
+
+	rest := make([]*models.Provider, 0, len(providers)-1)
+	rest = append(rest, providers[:best]...)
+	rest = append(rest, providers[best+1:]...)
+	sort.SliceStable(rest, func(i, j int) bool { return weightOf(rest[i]) > weightOf(rest[j]) })
+	return append([]*models.Provider{providers[best]}, rest...)
+}
+
+// begin counts a request to the provider as in flight until the returned func is called. A request
+// to a provider whose circuit is half-open is its probe; no other request is routed there meanwhile.
+// The probe is claimed here rather than in order, so of the requests that were routed to the
+// provider before its probe started only one gets through: begin returns false for the others.
+func (b *LoadBalancer) begin(provider *models.Provider) (func(), bool) {
+	if b == nil {
+		return func() {}, true
+	}
+
+	b.mu.Lock()
+	stats := b.statsFor(provider.ID)
+	probe := b.circuitState(provider) == CircuitHalfOpen
+	if probe {
+		if stats.probing {
+			b.mu.Unlock()
+			return nil, false
+		}
+		stats.probing = true
+	}
+	stats.inFlight++
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			stats.inFlight--
+			if probe {
+				stats.probing = false
+			}
+			b.mu.Unlock()
+		})
+	}, true
+}
+
+// observe records how an upstream attempt went. The latency of a successful response feeds the
+// provider's average and closes its circuit; failures count towards opening it, and a failure
+// at or past the threshold (including a failed probe) opens it for another cooldown. Skipped
+// attempts leave the provider's record as it was.
+func (b *LoadBalancer) observe(provider *models.Provider, latency time.Duration, outcome attemptOutcome) {
+	if b == nil || outcome == attemptSkipped {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.statsFor(provider.ID)
+	if outcome == attemptFailed {
+		stats.consecutiveFailures++
+		if stats.consecutiveFailures >= provider.GetCircuitBreaker().FailureThreshold {
+			stats.openedAt = b.now()
+		}
+		return
+	}
+
+	stats.consecutiveFailures = 0
+	if stats.latency == 0 {
+		stats.latency = latency
+	} else {
+		stats.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(stats.latency))
+	}
+}
+
+// available reports whether a request may be sent to the provider right now
+func (b *LoadBalancer) available(provider *models.Provider) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.admits(provider)
+}
+
+// Health returns a snapshot of the provider's circuit breaker and load
+func (b *LoadBalancer) Health(provider *models.Provider) *ProviderHealth {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := &ProviderHealth{State: b.circuitState(provider)}
+	if stats := b.stats[provider.ID]; stats != nil {
+		health.ConsecutiveFailures = stats.consecutiveFailures
+		health.InFlight = stats.inFlight
+		health.LatencyMs = stats.latency.Milliseconds()
+		if health.State != CircuitClosed {
+			openedAt := stats.openedAt
+			retryAt := openedAt.Add(provider.GetCircuitBreaker().Cooldown())
+			health.OpenedAt, health.RetryAt = &openedAt, &retryAt
+		}
+	}
+	return health
+}
+
+// circuitState returns the state of a provider's circuit; callers hold b.mu
+func (b *LoadBalancer) circuitState(provider *models.Provider) string {
+	stats := b.stats[provider.ID]
+	cfg := provider.GetCircuitBreaker()
+	switch {
+	case stats == nil || stats.consecutiveFailures < cfg.FailureThreshold:
+		return CircuitClosed
+	case b.now().Sub(stats.openedAt) < cfg.Cooldown():
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+// admits reports whether routing may pick a provider: its circuit is closed, or half-open with no
+// probe in flight yet; callers hold b.mu
+func (b *LoadBalancer) admits(provider *models.Provider) bool {
+	switch b.circuitState(provider) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		return !b.stats[provider.ID].probing
+	default:
+		return false
+	}
+}
+
+// statsFor returns a provider's stats, creating them on first use; callers hold b.mu
+func (b *LoadBalancer) statsFor(providerID uint) *providerStats {
+	stats := b.stats[providerID]
+	if stats == nil {
+		stats = &providerStats{}
+		b.stats[providerID] = stats
+	}
+	return stats
+}
+
+// trackedBody runs done once the response body is closed, so a request stays in flight
+// until its response (or stream) has been fully handled
+type trackedBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
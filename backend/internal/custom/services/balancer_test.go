@@ -0,0 +1,456 @@
+package services
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// newBalancerTestKey builds a key allowing the given providers with the given weights
+func newBalancerTestKey(strategy string, weights ...int) (*models.ProxyAPIKey, []*models.Provider) {
+	proxyKey := &models.ProxyAPIKey{RoutingStrategy: strategy}
+	proxyKey.ID = 1
+	providers := make([]*models.Provider, len(weights))
+	for i, weight := range weights {
+		providers[i] = &models.Provider{Name: string(rune('A' + i)), IsActive: true}
+		providers[i].ID = uint(i + 1)
+		proxyKey.AllowedProviders = append(proxyKey.AllowedProviders, models.KeyAllowedProvider{
+			ProviderID: providers[i].ID,
+			Weight:     weight,
+			Provider:   providers[i],
+		})
+	}
+	return proxyKey, providers
+}
+
+// pickCounts routes n requests and counts how often each provider is picked first
+func pickCounts(b *LoadBalancer, proxyKey *models.ProxyAPIKey, providers []*models.Provider, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[b.order(proxyKey, "gpt-4o", providers)[0].Name]++
+	}
+	return counts
+}
+
+func TestLoadBalancer_Order(t *testing.T) {
+	t.Run("priority always picks the first provider", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey("", 1, 1, 1)
+
+		counts := pickCounts(NewLoadBalancer(), proxyKey, providers, 30)
+		assert.Equal(t, map[string]int{"A": 30}, counts)
+	})
+
+	t.Run("round robin spreads requests evenly", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey(models.RoutingStrategyRoundRobin, 1, 1, 1)
+		b := NewLoadBalancer()
+
+		order := b.order(proxyKey, "gpt-4o", providers)
+		assert.Equal(t, []string{"A", "B", "C"}, providerNames(order))
+		order = b.order(proxyKey, "gpt-4o", providers)
+		assert.Equal(t, []string{"B", "C", "A"}, providerNames(order))
+
+		counts := pickCounts(NewLoadBalancer(), proxyKey, providers, 300)
+		assert.Equal(t, map[string]int{"A": 100, "B": 100, "C": 100}, counts)
+	})
+
+	t.Run("round robin keeps separate positions per model", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey(models.RoutingStrategyRoundRobin, 1, 1)
+		b := NewLoadBalancer()
+
+		assert.Equal(t, "A", b.order(proxyKey, "gpt-4o", providers)[0].Name)
+		assert.Equal(t, "A", b.order(proxyKey, "gpt-4o-mini", providers)[0].Name)
+		assert.Equal(t, "B", b.order(proxyKey, "gpt-4o", providers)[0].Name)
+	})
+
+	t.Run("weighted follows the weights and interleaves picks", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey(models.RoutingStrategyWeighted, 3, 1)
+		b := NewLoadBalancer()
+
+		var sequence []string
+		for i := 0; i < 4; i++ {
+			sequence = append(sequence, b.order(proxyKey, "gpt-4o", providers)[0].Name)
+		}
+		assert.Equal(t, []string{"A", "A", "B", "A"}, sequence)
+
+		counts := pickCounts(b, proxyKey, providers, 400)
+		assert.Equal(t, map[string]int{"A": 300, "B": 100}, counts)
+	})
+
+	t.Run("least in flight prefers idle providers", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey(models.RoutingStrategyLeastInFlight, 1, 1, 1)
+		b := NewLoadBalancer()
+
+		doneA, _ := b.begin(providers[0])
+		b.begin(providers[0])
+		b.begin(providers[1])
+		assert.Equal(t, []string{"C", "B", "A"}, providerNames(b.order(proxyKey, "gpt-4o", providers)))
+
+		doneA()
+		doneA() // Finishing twice counts once
+		assert.Equal(t, []string{"C", "A", "B"}, providerNames(b.order(proxyKey, "gpt-4o", providers)))
+	})
+
+	t.Run("lowest latency prefers fast providers and measures new ones first", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey(models.RoutingStrategyLowestLatency, 1, 1, 1)
+		b := NewLoadBalancer()
+
+		b.observe(providers[0], 800*time.Millisecond, attemptSucceeded)
+		b.observe(providers[1], 200*time.Millisecond, attemptSucceeded)
+		assert.Equal(t, []string{"C", "B", "A"}, providerNames(b.order(proxyKey, "gpt-4o", providers)))
+
+		b.observe(providers[2], 500*time.Millisecond, attemptSucceeded)
+		assert.Equal(t, []string{"B", "C", "A"}, providerNames(b.order(proxyKey, "gpt-4o", providers)))
+
+		// The average moves towards new samples
+		for i := 0; i < 10; i++ {
+			b.observe(providers[1], 2*time.Second, attemptSucceeded)
+		}
+		assert.Equal(t, []string{"C", "A", "B"}, providerNames(b.order(proxyKey, "gpt-4o", providers)))
+	})
+
+	t.Run("takes failing providers out of rotation until the cooldown passes", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey(models.RoutingStrategyRoundRobin, 1, 1)
+		b := NewLoadBalancer()
+		now := time.Now()
+		b.now = func() time.Time { return now }
+
+		for i := 0; i < models.DefaultCircuitFailureThreshold; i++ {
+			b.observe(providers[0], 0, attemptFailed)
+		}
+		counts := pickCounts(b, proxyKey, providers, 10)
+		assert.Equal(t, map[string]int{"B": 10}, counts)
+		assert.Equal(t, []string{"B"}, providerNames(b.order(proxyKey, "gpt-4o", providers)))
+
+		now = now.Add(models.DefaultCircuitCooldownSeconds * time.Second)
+		counts = pickCounts(b, proxyKey, providers, 10)
+		assert.Equal(t, map[string]int{"A": 5, "B": 5}, counts)
+	})
+
+	t.Run("uses a key's per-model strategy", func(t *testing.T) {
+		proxyKey, providers := newBalancerTestKey("", 1, 1)
+		proxyKey.ModelRoutingStrategies = map[string]string{"gpt-4o": models.RoutingStrategyRoundRobin}
+
+		counts := pickCounts(NewLoadBalancer(), proxyKey, providers, 10)
+		assert.Equal(t, map[string]int{"A": 5, "B": 5}, counts)
+	})
+}
+
+func TestLoadBalancer_CircuitBreaker(t *testing.T) {
+	newBreaker := func() (*LoadBalancer, *models.Provider, func(time.Duration)) {
+		b := NewLoadBalancer()
+		now := time.Now()
+		b.now = func() time.Time { return now }
+		provider := &models.Provider{Name: "A", CircuitBreaker: &models.CircuitBreakerConfig{FailureThreshold: 2, CooldownSeconds: 10}}
+		provider.ID = 1
+		return b, provider, func(d time.Duration) { now = now.Add(d) }
+	}
+
+	t.Run("opens after the configured number of consecutive failures", func(t *testing.T) {
+		b, provider, _ := newBreaker()
+
+		b.observe(provider, 0, attemptFailed)
+		b.observe(provider, 0, attemptSucceeded) // A success resets the count
+		b.observe(provider, 0, attemptFailed)
+		assert.Equal(t, CircuitClosed, b.Health(provider).State)
+		assert.True(t, b.available(provider))
+
+		b.observe(provider, 0, attemptFailed)
+		health := b.Health(provider)
+		assert.Equal(t, CircuitOpen, health.State)
+		assert.Equal(t, 2, health.ConsecutiveFailures)
+		require.NotNil(t, health.RetryAt)
+		assert.Equal(t, 10*time.Second, health.RetryAt.Sub(*health.OpenedAt))
+		assert.False(t, b.available(provider))
+	})
+
+	t.Run("ignores skipped attempts", func(t *testing.T) {
+		b, provider, _ := newBreaker()
+
+		b.observe(provider, 300*time.Millisecond, attemptSucceeded)
+		b.observe(provider, 0, attemptFailed)
+		b.observe(provider, 0, attemptSkipped) // Doesn't reset the count or feed the latency
+		assert.Equal(t, 1, b.Health(provider).ConsecutiveFailures)
+		assert.Equal(t, int64(300), b.Health(provider).LatencyMs)
+
+		b.observe(provider, 0, attemptFailed)
+		assert.Equal(t, CircuitOpen, b.Health(provider).State)
+	})
+
+	t.Run("lets a single probe through once the cooldown passes", func(t *testing.T) {
+		b, provider, advance := newBreaker()
+		b.observe(provider, 0, attemptFailed)
+		b.observe(provider, 0, attemptFailed)
+		advance(10 * time.Second)
+
+		assert.Equal(t, CircuitHalfOpen, b.Health(provider).State)
+		assert.True(t, b.available(provider))
+
+		done, ok := b.begin(provider)
+		require.True(t, ok)
+		assert.False(t, b.available(provider), "only one probe at a time")
+		done()
+		assert.True(t, b.available(provider), "a probe that never reported frees the slot")
+	})
+
+	t.Run("admits a single probe among concurrent requests", func(t *testing.T) {
+		b, provider, advance := newBreaker()
+		b.observe(provider, 0, attemptFailed)
+		b.observe(provider, 0, attemptFailed)
+		advance(10 * time.Second)
+		proxyKey := &models.ProxyAPIKey{}
+
+		// Every request is routed before any of them starts, so all of them see the slot free
+		const requests = 50
+		var routed, admitted int32
+		var routing, started sync.WaitGroup
+		routing.Add(requests)
+		started.Add(requests)
+		for i := 0; i < requests; i++ {
+			go func() {
+				defer started.Done()
+				providers := b.order(proxyKey, "gpt-4o", []*models.Provider{provider})
+				routing.Done()
+				routing.Wait()
+				if len(providers) == 0 {
+					return
+				}
+				atomic.AddInt32(&routed, 1)
+				if _, ok := b.begin(provider); ok {
+					atomic.AddInt32(&admitted, 1)
+				}
+			}()
+		}
+		started.Wait()
+
+		assert.Equal(t, int32(requests), atomic.LoadInt32(&routed))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&admitted))
+	})
+
+	t.Run("closes when the probe succeeds", func(t *testing.T) {
+		b, provider, advance := newBreaker()
+		b.observe(provider, 0, attemptFailed)
+		b.observe(provider, 0, attemptFailed)
+		advance(10 * time.Second)
+
+		done, ok := b.begin(provider)
+		require.True(t, ok)
+		b.observe(provider, 100*time.Millisecond, attemptSucceeded)
+		done()
+
+		health := b.Health(provider)
+		assert.Equal(t, CircuitClosed, health.State)
+		assert.Zero(t, health.ConsecutiveFailures)
+		assert.Nil(t, health.OpenedAt)
+		assert.Equal(t, int64(100), health.LatencyMs)
+	})
+
+	t.Run("reopens for another cooldown when the probe fails", func(t *testing.T) {
+		b, provider, advance := newBreaker()
+		b.observe(provider, 0, attemptFailed)
+		b.observe(provider, 0, attemptFailed)
+		advance(10 * time.Second)
+
+		done, ok := b.begin(provider)
+		require.True(t, ok)
+		b.observe(provider, 0, attemptFailed)
+		done()
+		assert.Equal(t, CircuitOpen, b.Health(provider).State)
+
+		advance(9 * time.Second)
+		assert.False(t, b.available(provider))
+		advance(time.Second)
+		assert.True(t, b.available(provider))
+	})
+
+	t.Run("reports an unused provider as closed", func(t *testing.T) {
+		b, provider, _ := newBreaker()
+		assert.Equal(t, &ProviderHealth{State: CircuitClosed}, b.Health(provider))
+
+		var none *LoadBalancer
+		assert.Nil(t, none.Health(provider))
+	})
+}
+
+func providerNames(providers []*models.Provider) []string {
+	names := make([]string, len(providers))
+	for i, provider := range providers {
+		names[i] = provider.Name
+	}
+	return names
+}
+
+func TestProxyService_LoadBalancing(t *testing.T) {
+	okBody := `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`
+
+	t.Run("spreads chat requests across providers serving the model", func(t *testing.T) {
+		var callsA, callsB, callsC int32
+		upstreamA := countingUpstream(t, http.StatusOK, okBody, &callsA)
+		upstreamB := countingUpstream(t, http.StatusOK, okBody, &callsB)
+		upstreamC := countingUpstream(t, http.StatusOK, okBody, &callsC)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "vllm-1", ProviderType: models.ProviderTypeVLLM, BaseURL: upstreamA.URL},
+			&models.Provider{Name: "vllm-2", ProviderType: models.ProviderTypeVLLM, BaseURL: upstreamB.URL},
+			&models.Provider{Name: "vllm-3", ProviderType: models.ProviderTypeVLLM, BaseURL: upstreamC.URL},
+		)
+		proxyKey.RoutingStrategy = models.RoutingStrategyRoundRobin
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		for i := 0; i < 9; i++ {
+			resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+				strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		assert.Equal(t, int32(3), atomic.LoadInt32(&callsA))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&callsB))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&callsC))
+
+		// Every request has finished, so nothing is left in flight and every provider was measured
+		assert.Eventually(t, func() bool {
+			service.balancer.mu.Lock()
+			defer service.balancer.mu.Unlock()
+			for _, ap := range proxyKey.AllowedProviders {
+				stats := service.balancer.stats[ap.ProviderID]
+				if stats.inFlight != 0 || stats.latency <= 0 {
+					return false
+				}
+			}
+			return true
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("routes around a failing provider", func(t *testing.T) {
+		var failedCalls, okCalls int32
+		failing := countingUpstream(t, http.StatusInternalServerError, `{"error":{"message":"down"}}`, &failedCalls)
+		healthy := countingUpstream(t, http.StatusOK, okBody, &okCalls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, true, nil,
+			&models.Provider{Name: "Broken", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL},
+			&models.Provider{Name: "Healthy", ProviderType: models.ProviderTypeOpenAI, BaseURL: healthy.URL},
+		)
+		proxyKey.RoutingStrategy = models.RoutingStrategyRoundRobin
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		for i := 0; i < 10; i++ {
+			resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+				strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		// Once it has failed often enough the broken provider is no longer tried first
+		assert.Equal(t, int32(models.DefaultCircuitFailureThreshold), atomic.LoadInt32(&failedCalls))
+		assert.Equal(t, int32(10), atomic.LoadInt32(&okCalls))
+	})
+}
+
+func TestProxyService_CircuitBreaker(t *testing.T) {
+	chatBody := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`
+
+	t.Run("fails fast while every provider's circuit is open", func(t *testing.T) {
+		var calls int32
+		failing := countingUpstream(t, http.StatusInternalServerError, `{"error":{"message":"down"}}`, &calls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Broken", ProviderType: models.ProviderTypeAnthropic, BaseURL: failing.URL},
+		)
+		server := newProxyTestServer(t, "/v1/messages", func(c *gin.Context) {
+			// Stand in for the handler, which writes routing errors
+			if result, err := service.ProxyAnthropicPassthrough(c, proxyKey); err != nil && !c.Writer.Written() {
+				c.Status(result.StatusCode)
+			}
+		})
+
+		for i := 0; i < models.DefaultCircuitFailureThreshold+2; i++ {
+			resp, err := http.Post(server.URL+"/v1/messages", "application/json",
+				strings.NewReader(`{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"Hi"}]}`))
+			require.NoError(t, err)
+			resp.Body.Close()
+			if i < models.DefaultCircuitFailureThreshold {
+				assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+			} else {
+				assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+			}
+		}
+		assert.Equal(t, int32(models.DefaultCircuitFailureThreshold), atomic.LoadInt32(&calls))
+
+		_, err := service.GetProviderForModel(proxyKey, "claude-sonnet-4-5")
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+
+	t.Run("stops retrying once the circuit opens", func(t *testing.T) {
+		var calls int32
+		failing := countingUpstream(t, http.StatusServiceUnavailable, `{"error":{"message":"down"}}`, &calls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Broken", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL,
+				RetryPolicy:    &models.RetryPolicy{MaxAttempts: 5, InitialBackoffMs: 1, MaxBackoffMs: 10},
+				CircuitBreaker: &models.CircuitBreakerConfig{FailureThreshold: 2}},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(chatBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("reports circuit state through the providers API", func(t *testing.T) {
+		var calls int32
+		failing := countingUpstream(t, http.StatusInternalServerError, `{"error":{"message":"down"}}`, &calls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Broken", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL,
+				CircuitBreaker: &models.CircuitBreakerConfig{FailureThreshold: 1}},
+		)
+		providerService := NewProviderService(db, service.balancer)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		providerID := proxyKey.AllowedProviders[0].ProviderID
+		before, err := providerService.GetProvider(1, providerID)
+		require.NoError(t, err)
+		require.NotNil(t, before.Health)
+		assert.Equal(t, CircuitClosed, before.Health.State)
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(chatBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Eventually(t, func() bool {
+			after, err := providerService.GetProvider(1, providerID)
+			return err == nil && after.Health.State == CircuitOpen && after.Health.ConsecutiveFailures == 1 && after.Health.RetryAt != nil
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// batchKeyContextKey carries the batch owner's proxy key to the batch router
+type batchKeyContextKey struct{}
+
+// batchProxyFunc is a ProxyService method serving one proxy endpoint
+type batchProxyFunc func(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error)
+
+// newBatchRouter routes batch items to the same ProxyService methods as the public endpoints, so
+// items get the normal model routing, translation and usage tracking
+func newBatchRouter(proxyService *ProxyService) *gin.Engine {
+	router := gin.New()
+	router.POST("/v1/chat/completions", batchHandler(proxyService.ProxyRequest, false))
+	router.POST("/v1/completions", batchHandler(proxyService.ProxyCompletions, false))
+	router.POST("/v1/embeddings", batchHandler(proxyService.ProxyEmbeddings, false))
+	router.POST("/v1/responses", batchHandler(proxyService.ProxyResponses, false))
+	router.POST("/v1/messages", batchHandler(proxyService.ProxyAnthropicPassthrough, true))
+	return router
+}
+
+// batchHandler adapts a proxy method to the batch router, writing proxy errors in the
+// endpoint's error shape when the method did not write a response itself
+func batchHandler(proxy batchProxyFunc, anthropic bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		proxyKey, _ := c.Request.Context().Value(batchKeyContextKey{}).(*models.ProxyAPIKey)
+		result, err := proxy(c, proxyKey)
+		if err == nil || c.Writer.Written() {
+			return
+		}
+
+		statusCode := http.StatusBadGateway
+		if result != nil && result.StatusCode > 0 {
+			statusCode = result.StatusCode
+		}
+
+		if anthropic {
+			c.JSON(statusCode, gin.H{
+				"type":  "error",
+				"error": gin.H{"type": AnthropicErrorType(statusCode), "message": err.Error()},
+			})
+			return
+		}
+		c.JSON(statusCode, gin.H{
+			"error": gin.H{"message": err.Error(), "type": "api_error", "code": "proxy_error"},
+		})
+	}
+}
+
+// batchResponseWriter buffers the response to a batch item
+type batchResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBatchResponseWriter() *batchResponseWriter {
+	return &batchResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+}
+
+// Header implements http.ResponseWriter
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// Write implements http.ResponseWriter
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *batchResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+// Flush implements http.Flusher; the whole response is buffered anyway
+func (w *batchResponseWriter) Flush() {}
+
+// Start launches the background workers that run queued batches until ctx is cancelled, each
+// taking the oldest batch no other worker is running. Cancelling ctx aborts the items in flight,
+// which stay pending; batches interrupted that way or by a restart resume with their unfinished
+// items.
+func (s *BatchService) Start(ctx context.Context) {
+	s.workers.Add(batchWorkers)
+	for i := 0; i < batchWorkers; i++ {
+		go func() {
+			defer s.workers.Done()
+			s.run(ctx)
+		}()
+	}
+}
+
+// Wait blocks until the workers started by Start have stopped
+func (s *BatchService) Wait() {
+	s.workers.Wait()
+}
+
+// run processes queued batches one at a time, then waits for new work
+func (s *BatchService) run(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && s.processNextBatch(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// notify wakes an idle worker without blocking
+func (s *BatchService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// processNextBatch runs the oldest unfinished batch no other worker is running, and reports
+// whether there was one and it ran without error
+func (s *BatchService) processNextBatch(ctx context.Context) bool {
+	batch := s.claimNextBatch()
+	if batch == nil {
+		return false
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, batch.ID)
+		s.mu.Unlock()
+	}()
+
+	if err := s.processBatch(ctx, batch); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to process batch %s: %v", batch.BatchID, err)
+		}
+		return false
+	}
+	return true
+}
+
+// claimNextBatch marks the oldest unfinished batch no worker is running as running and returns it,
+// or nil if there is none
+func (s *BatchService) claimNextBatch() *models.Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := s.db.Where("status IN ?", []string{
+		models.BatchStatusValidating,
+		models.BatchStatusInProgress,
+		models.BatchStatusFinalizing,
+		models.BatchStatusCancelling,
+	})
+	if len(s.running) > 0 {
+		running := make([]uint, 0, len(s.running))
+		for id := range s.running {
+			running = append(running, id)
+		}
+		query = query.Where("id NOT IN ?", running)
+	}
+
+	var batch models.Batch
+	if err := query.Order("id").First(&batch).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to load queued batch: %v", err)
+		}
+		return nil
+	}
+	s.running[batch.ID] = true
+	return &batch
+}
+
+// processBatch runs a batch's pending items in chunks until none remain, the batch is cancelled
+// or its completion window expires
+func (s *BatchService) processBatch(ctx context.Context, batch *models.Batch) error {
+	if batch.Status == models.BatchStatusValidating {
+		if err := s.db.Model(batch).Updates(map[string]interface{}{
+			"status":         models.BatchStatusInProgress,
+			"in_progress_at": time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to start batch: %w", err)
+		}
+	}
+
+	for {
+		// Pick up cancellations made while the previous chunk ran
+		if err := s.db.First(batch, batch.ID).Error; err != nil {
+			return fmt.Errorf("failed to reload batch: %w", err)
+		}
+		switch {
+		case batch.Status == models.BatchStatusCancelling:
+			return s.finishBatch(batch, models.BatchStatusCancelled)
+		case time.Now().After(batch.ExpiresAt):
+			return s.finishBatch(batch, models.BatchStatusExpired)
+		}
+
+		var items []models.BatchItem
+		if err := s.db.Where("batch_id = ? AND status = ?", batch.ID, models.BatchItemStatusPending).
+			Order("position").Limit(batchItemChunkSize).Find(&items).Error; err != nil {
+			return fmt.Errorf("failed to load batch items: %w", err)
+		}
+		if len(items) == 0 {
+			return s.finishBatch(batch, models.BatchStatusCompleted)
+		}
+
+		// The key is checked for every chunk, so revoking it stops the batch
+		proxyKey, err := s.keyService.GetActiveKey(batch.ProxyKeyID)
+		if err != nil {
+			return s.failBatch(batch, err.Error())
+		}
+
+		s.runItems(ctx, batch, proxyKey, items)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// runItems runs items with at most s.concurrency in flight
+func (s *BatchService) runItems(ctx context.Context, batch *models.Batch, proxyKey *models.ProxyAPIKey, items []models.BatchItem) {
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+
+	for i := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item *models.BatchItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.runItem(ctx, batch, proxyKey, item)
+		}(&items[i])
+	}
+
+	wg.Wait()
+}
+
+// runItem sends one item through the proxy and stores its result
+func (s *BatchService) runItem(ctx context.Context, batch *models.Batch, proxyKey *models.ProxyAPIKey, item *models.BatchItem) {
+	itemCtx, cancel := context.WithTimeout(context.WithValue(ctx, batchKeyContextKey{}, proxyKey), batchItemTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(itemCtx, item.Method, item.URL, bytes.NewReader([]byte(item.Body)))
+	if err != nil {
+		s.completeItem(batch, item, models.BatchItemStatusFailed, 0, "", err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	w := newBatchResponseWriter()
+	s.router.ServeHTTP(w, req)
+
+	// Items interrupted by shutdown stay pending and run again on restart
+	if ctx.Err() != nil {
+		return
+	}
+
+	status := models.BatchItemStatusSucceeded
+	if w.statusCode < 200 || w.statusCode >= 300 {
+		status = models.BatchItemStatusFailed
+	}
+	s.completeItem(batch, item, status, w.statusCode, w.body.String(), "")
+}
+
+// completeItem stores an item's result and bumps the batch's counters
+func (s *BatchService) completeItem(batch *models.Batch, item *models.BatchItem, status string, statusCode int, response, errorMessage string) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(item).Updates(map[string]interface{}{
+			"status":        status,
+			"status_code":   statusCode,
+			"response":      response,
+			"error_message": errorMessage,
+		}).Error; err != nil {
+			return err
+		}
+
+		counter := "completed_count"
+		if status != models.BatchItemStatusSucceeded {
+			counter = "failed_count"
+		}
+		return tx.Model(&models.Batch{}).Where("id = ?", batch.ID).
+			UpdateColumn(counter, gorm.Expr(counter+" + 1")).Error
+	})
+	if err != nil {
+		log.Printf("Failed to store result for batch %s item %s: %v", batch.BatchID, item.CustomID, err)
+	}
+}
+
+// failBatch ends a batch that cannot run (for example because its key was revoked)
+func (s *BatchService) failBatch(batch *models.Batch, message string) error {
+	if err := s.db.Model(batch).Updates(map[string]interface{}{
+		"error_message": message,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to fail batch: %w", err)
+	}
+	return s.finishBatch(batch, models.BatchStatusFailed)
+}
+
+// finishBatch closes any items that did not run, writes OpenAI result files when a file store is
+// configured and moves the batch to its final status
+func (s *BatchService) finishBatch(batch *models.Batch, status string) error {
+	// Items that never ran are cancelled, or expired if the completion window passed
+	itemStatus, counter := models.BatchItemStatusCancelled, "cancelled_count"
+	if status == models.BatchStatusExpired {
+		itemStatus, counter = models.BatchItemStatusExpired, "expired_count"
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		closed := tx.Model(&models.BatchItem{}).
+			Where("batch_id = ? AND status = ?", batch.ID, models.BatchItemStatusPending).
+			Update("status", itemStatus)
+		if closed.Error != nil {
+			return closed.Error
+		}
+		if closed.RowsAffected == 0 {
+			return nil
+		}
+		return tx.Model(&models.Batch{}).Where("id = ?", batch.ID).
+			UpdateColumn(counter, gorm.Expr(fmt.Sprintf("%s + %d", counter, closed.RowsAffected))).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to close batch items: %w", err)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case models.BatchStatusCompleted:
+		updates["completed_at"] = now
+	case models.BatchStatusExpired:
+		updates["expired_at"] = now
+	case models.BatchStatusCancelled:
+		updates["cancelled_at"] = now
+	case models.BatchStatusFailed:
+		updates["failed_at"] = now
+	}
+
+	// The files are written before the status changes, so a batch whose files could not be stored
+	// keeps its status and reaches the same outcome when it is finished again on a later pass
+	if batch.Format == models.BatchFormatOpenAI && s.files != nil && status != models.BatchStatusFailed {
+		updates["finalizing_at"] = now
+		if err := s.writeResultFiles(batch, updates); err != nil {
+			return s.finalizeFailed(batch, err)
+		}
+	}
+
+	if err := s.db.Model(batch).Updates(updates).Error; err != nil {
+		s.discardResultFiles(batch, updates)
+		return fmt.Errorf("failed to finish batch: %w", err)
+	}
+
+	s.mu.Lock()
+	delete(s.finalizeFailures, batch.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+// finalizeFailed handles a failure to store a batch's result files. The batch is finished again on
+// a later pass, until maxBatchFinalizeAttempts have failed and it is failed instead.
+func (s *BatchService) finalizeFailed(batch *models.Batch, err error) error {
+	s.mu.Lock()
+	s.finalizeFailures[batch.ID]++
+	attempts := s.finalizeFailures[batch.ID]
+	if attempts >= maxBatchFinalizeAttempts {
+		delete(s.finalizeFailures, batch.ID)
+	}
+	s.mu.Unlock()
+
+	if attempts < maxBatchFinalizeAttempts {
+		return err
+	}
+	return s.failBatch(batch, err.Error())
+}
+
+// writeResultFiles stores an OpenAI batch's output and error files, adding their IDs to updates.
+// On failure no file is left behind, so a later attempt starts afresh.
+func (s *BatchService) writeResultFiles(batch *models.Batch, updates map[string]interface{}) error {
+	output, errorOutput, err := s.buildOpenAIBatchOutput(batch)
+	if err != nil {
+		return err
+	}
+
+	if len(output) > 0 {
+		fileID, err := s.files.CreateFile(batch.UserID, batch.BatchID+"_output.jsonl", "batch_output", output)
+		if err != nil {
+			return fmt.Errorf("failed to store batch output: %w", err)
+		}
+		updates["output_file_id"] = fileID
+	}
+	if len(errorOutput) > 0 {
+		fileID, err := s.files.CreateFile(batch.UserID, batch.BatchID+"_error.jsonl", "batch_output", errorOutput)
+		if err != nil {
+			s.discardResultFiles(batch, updates)
+			return fmt.Errorf("failed to store batch errors: %w", err)
+		}
+		updates["error_file_id"] = fileID
+	}
+	return nil
+}
+
+// discardResultFiles deletes the result files whose IDs writeResultFiles added to updates, for a
+// batch that could not be finished with them
+func (s *BatchService) discardResultFiles(batch *models.Batch, updates map[string]interface{}) {
+	for _, field := range []string{"output_file_id", "error_file_id"} {
+		fileID, ok := updates[field].(string)
+		if !ok {
+			continue
+		}
+		if err := s.files.DeleteFile(batch.UserID, fileID); err != nil {
+			log.Printf("Failed to delete result file %s of batch %s: %v", fileID, batch.BatchID, err)
+		}
+		delete(updates, field)
+	}
+}
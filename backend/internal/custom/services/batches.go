@@ -0,0 +1,706 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// Batch limits
+const (
+	DefaultBatchConcurrency   = 4                // Items run in parallel per batch
+	batchWorkers              = 4                // Batches run in parallel
+	MaxBatchInputSize         = 200 << 20        // 200MB, matching OpenAI's input file limit
+	maxOpenAIBatchRequests    = 50000            // OpenAI's per-batch request limit
+	maxAnthropicBatchRequests = 100000           // Anthropic's per-batch request limit
+	batchCompletionWindow     = "24h"            // The only completion window either API accepts
+	batchExpiry               = 24 * time.Hour   // Unfinished items expire after the completion window
+	batchPollInterval         = 5 * time.Second  // How often the worker checks for new batches
+	batchItemChunkSize        = 100              // Pending items loaded per round
+	batchItemTimeout          = 10 * time.Minute // Upper bound for a single item
+	maxBatchFinalizeAttempts  = 3                // Tries at storing a batch's result files before it fails
+)
+
+var (
+	// ErrBatchNotFound is returned when a batch does not exist or belongs to another key
+	ErrBatchNotFound = errors.New("batch not found")
+	// ErrInvalidBatch is wrapped by errors caused by the client's batch request
+	ErrInvalidBatch = errors.New("invalid batch")
+)
+
+// openAIBatchEndpoints are the endpoints an OpenAI batch may target
+var openAIBatchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+	"/v1/responses":        true,
+}
+
+// anthropicCustomIDPattern matches the custom_id values Anthropic accepts
+var anthropicCustomIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// BatchFileStore reads batch input files and stores result files. Without one, OpenAI batches
+// must be uploaded with the request and their results are served from the batch itself.
+type BatchFileStore interface {
+	ReadFile(userID uint, fileID string) ([]byte, error)
+	CreateFile(userID uint, filename, purpose string, content []byte) (string, error)
+	DeleteFile(userID uint, fileID string) error
+}
+
+// BatchService stores batch jobs and runs them in the background through the proxy
+type BatchService struct {
+	db           *gorm.DB
+	keyService   *KeyService
+	files        BatchFileStore
+	router       *gin.Engine // Dispatches batch items to the proxy endpoints
+	concurrency  int
+	pollInterval time.Duration
+	wake         chan struct{}
+
+	workers          sync.WaitGroup
+	mu               sync.Mutex
+	running          map[uint]bool // Batches a worker is running
+	finalizeFailures map[uint]int  // Failed attempts at storing result files, per batch
+}
+
+// NewBatchService creates a new BatchService instance; concurrency limits the items run in parallel
+func NewBatchService(db *gorm.DB, keyService *KeyService, proxyService *ProxyService, concurrency int) *BatchService {
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	return &BatchService{
+		db:           db,
+		keyService:   keyService,
+		router:       newBatchRouter(proxyService),
+		concurrency:  concurrency,
+		pollInterval: batchPollInterval,
+		wake:         make(chan struct{}, batchWorkers),
+
+		running:          make(map[uint]bool),
+		finalizeFailures: make(map[uint]int),
+	}
+}
+
+// SetFileStore sets the file store used for input_file_id and result files
+func (s *BatchService) SetFileStore(files BatchFileStore) {
+	s.files = files
+}
+
+// CreateBatchRequest represents an OpenAI batch creation request
+type CreateBatchRequest struct {
+	InputFileID      string            `json:"input_file_id" form:"input_file_id"`
+	Endpoint         string            `json:"endpoint" form:"endpoint"`
+	CompletionWindow string            `json:"completion_window" form:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// BatchInputLine is a single request line in an OpenAI batch input file
+type BatchInputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// AnthropicBatchRequest represents an Anthropic message batch creation request
+type AnthropicBatchRequest struct {
+	Requests []AnthropicBatchRequestItem `json:"requests"`
+}
+
+// AnthropicBatchRequestItem is a single Messages request in an Anthropic batch
+type AnthropicBatchRequestItem struct {
+	CustomID string          `json:"custom_id"`
+	Params   json.RawMessage `json:"params"`
+}
+
+// CreateOpenAIBatch queues an OpenAI batch. The JSONL input is either uploaded with the request
+// (input) or read from the file store by input_file_id.
+func (s *BatchService) CreateOpenAIBatch(proxyKey *models.ProxyAPIKey, req *CreateBatchRequest, input []byte) (*models.Batch, error) {
+	if !openAIBatchEndpoints[req.Endpoint] {
+		return nil, fmt.Errorf("%w: unsupported endpoint %q", ErrInvalidBatch, req.Endpoint)
+	}
+	if req.CompletionWindow == "" {
+		req.CompletionWindow = batchCompletionWindow
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		return nil, fmt.Errorf("%w: completion_window must be %s", ErrInvalidBatch, batchCompletionWindow)
+	}
+
+	if input == nil {
+		if req.InputFileID == "" {
+			return nil, fmt.Errorf("%w: input_file_id or an uploaded file is required", ErrInvalidBatch)
+		}
+		if s.files == nil {
+			return nil, fmt.Errorf("%w: input_file_id is not supported; upload the input file with the batch", ErrInvalidBatch)
+		}
+		content, err := s.files.ReadFile(proxyKey.UserID, req.InputFileID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+		}
+		input = content
+	}
+
+	items, err := parseOpenAIBatchInput(input, req.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &models.Batch{
+		BatchID:          newResponsesID("batch_"),
+		UserID:           proxyKey.UserID,
+		ProxyKeyID:       proxyKey.ID,
+		Format:           models.BatchFormatOpenAI,
+		Endpoint:         req.Endpoint,
+		CompletionWindow: req.CompletionWindow,
+		InputFileID:      req.InputFileID,
+		Metadata:         req.Metadata,
+	}
+	return s.queueBatch(batch, items)
+}
+
+// CreateAnthropicBatch queues an Anthropic message batch
+func (s *BatchService) CreateAnthropicBatch(proxyKey *models.ProxyAPIKey, req *AnthropicBatchRequest) (*models.Batch, error) {
+	if len(req.Requests) == 0 {
+		return nil, fmt.Errorf("%w: requests must not be empty", ErrInvalidBatch)
+	}
+	if len(req.Requests) > maxAnthropicBatchRequests {
+		return nil, fmt.Errorf("%w: a batch may contain at most %d requests", ErrInvalidBatch, maxAnthropicBatchRequests)
+	}
+
+	items := make([]models.BatchItem, 0, len(req.Requests))
+	seen := make(map[string]bool, len(req.Requests))
+	for i, request := range req.Requests {
+		if !anthropicCustomIDPattern.MatchString(request.CustomID) {
+			return nil, fmt.Errorf("%w: requests[%d].custom_id must be 1-64 letters, digits, underscores or hyphens", ErrInvalidBatch, i)
+		}
+		if seen[request.CustomID] {
+			return nil, fmt.Errorf("%w: duplicate custom_id %q", ErrInvalidBatch, request.CustomID)
+		}
+		seen[request.CustomID] = true
+
+		if err := validateBatchBody(request.Params); err != nil {
+			return nil, fmt.Errorf("%w: requests[%d].params %v", ErrInvalidBatch, i, err)
+		}
+
+		items = append(items, models.BatchItem{
+			Position: i,
+			CustomID: request.CustomID,
+			Method:   "POST",
+			URL:      "/v1/messages",
+			Body:     string(request.Params),
+		})
+	}
+
+	batch := &models.Batch{
+		BatchID:          newResponsesID("msgbatch_"),
+		UserID:           proxyKey.UserID,
+		ProxyKeyID:       proxyKey.ID,
+		Format:           models.BatchFormatAnthropic,
+		Endpoint:         "/v1/messages",
+		CompletionWindow: batchCompletionWindow,
+	}
+	return s.queueBatch(batch, items)
+}
+
+// queueBatch stores a new batch with its items and wakes the worker
+func (s *BatchService) queueBatch(batch *models.Batch, items []models.BatchItem) (*models.Batch, error) {
+	batch.Status = models.BatchStatusValidating
+	batch.TotalCount = len(items)
+	batch.ExpiresAt = time.Now().Add(batchExpiry)
+	for i := range items {
+		items[i].Status = models.BatchItemStatusPending
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BatchID = batch.ID
+		}
+		return tx.CreateInBatches(items, batchItemChunkSize).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	s.notify()
+	return batch, nil
+}
+
+// parseOpenAIBatchInput parses and validates a JSONL batch input file
+func parseOpenAIBatchInput(input []byte, endpoint string) ([]models.BatchItem, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxBatchInputSize)
+
+	var items []models.BatchItem
+	seen := make(map[string]bool)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var request BatchInputLine
+		if err := json.Unmarshal(line, &request); err != nil {
+			return nil, fmt.Errorf("%w: line %d is not valid JSON", ErrInvalidBatch, lineNumber)
+		}
+		if request.CustomID == "" {
+			return nil, fmt.Errorf("%w: line %d is missing custom_id", ErrInvalidBatch, lineNumber)
+		}
+		if seen[request.CustomID] {
+			return nil, fmt.Errorf("%w: duplicate custom_id %q on line %d", ErrInvalidBatch, request.CustomID, lineNumber)
+		}
+		seen[request.CustomID] = true
+
+		if !strings.EqualFold(request.Method, "POST") {
+			return nil, fmt.Errorf("%w: line %d method must be POST", ErrInvalidBatch, lineNumber)
+		}
+		if request.URL != endpoint {
+			return nil, fmt.Errorf("%w: line %d url %q does not match the batch endpoint %s", ErrInvalidBatch, lineNumber, request.URL, endpoint)
+		}
+		if err := validateBatchBody(request.Body); err != nil {
+			return nil, fmt.Errorf("%w: line %d body %v", ErrInvalidBatch, lineNumber, err)
+		}
+
+		items = append(items, models.BatchItem{
+			Position: len(items),
+			CustomID: request.CustomID,
+			Method:   "POST",
+			URL:      request.URL,
+			Body:     string(request.Body),
+		})
+		if len(items) > maxOpenAIBatchRequests {
+			return nil, fmt.Errorf("%w: a batch may contain at most %d requests", ErrInvalidBatch, maxOpenAIBatchRequests)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: failed to read input file: %v", ErrInvalidBatch, err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: input file contains no requests", ErrInvalidBatch)
+	}
+
+	return items, nil
+}
+
+// validateBatchBody checks that a batch request body is a JSON object with a model and no streaming
+func validateBatchBody(body json.RawMessage) error {
+	var fields struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &fields) != nil {
+		return fmt.Errorf("must be a JSON object")
+	}
+	if fields.Model == "" {
+		return fmt.Errorf("must include a model")
+	}
+	if fields.Stream {
+		return fmt.Errorf("must not enable streaming")
+	}
+	return nil
+}
+
+// GetBatch retrieves a batch, ensuring it was created with the same proxy key
+func (s *BatchService) GetBatch(proxyKey *models.ProxyAPIKey, format, batchID string) (*models.Batch, error) {
+	var batch models.Batch
+	if err := s.db.Where("batch_id = ? AND proxy_key_id = ? AND format = ?", batchID, proxyKey.ID, format).First(&batch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBatchNotFound
+		}
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	return &batch, nil
+}
+
+// ListBatches lists a key's batches newest first. afterID continues from a previous page; the
+// boolean result reports whether more batches follow.
+func (s *BatchService) ListBatches(proxyKey *models.ProxyAPIKey, format, afterID string, limit int) ([]models.Batch, bool, error) {
+	query := s.db.Where("proxy_key_id = ? AND format = ?", proxyKey.ID, format)
+	if afterID != "" {
+		after, err := s.GetBatch(proxyKey, format, afterID)
+		if err != nil {
+			return nil, false, err
+		}
+		query = query.Where("id < ?", after.ID)
+	}
+
+	var batches []models.Batch
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&batches).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to list batches: %w", err)
+	}
+
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+	return batches, hasMore, nil
+}
+
+// CancelBatch asks the worker to stop a batch; items already run keep their results
+func (s *BatchService) CancelBatch(proxyKey *models.ProxyAPIKey, format, batchID string) (*models.Batch, error) {
+	batch, err := s.GetBatch(proxyKey, format, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch batch.Status {
+	case models.BatchStatusValidating, models.BatchStatusInProgress:
+	case models.BatchStatusCancelling:
+		return batch, nil
+	default:
+		return nil, fmt.Errorf("%w: a %s batch cannot be cancelled", ErrInvalidBatch, batch.Status)
+	}
+
+	now := time.Now()
+	if err := s.db.Model(batch).Updates(map[string]interface{}{
+		"status":        models.BatchStatusCancelling,
+		"cancelling_at": now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to cancel batch: %w", err)
+	}
+
+	s.notify()
+	return batch, nil
+}
+
+// DeleteBatch deletes a finished batch and its results
+func (s *BatchService) DeleteBatch(proxyKey *models.ProxyAPIKey, format, batchID string) error {
+	batch, err := s.GetBatch(proxyKey, format, batchID)
+	if err != nil {
+		return err
+	}
+	if !batch.IsFinished() {
+		return fmt.Errorf("%w: a batch must finish processing or be cancelled before it can be deleted", ErrInvalidBatch)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("batch_id = ?", batch.ID).Delete(&models.BatchItem{}).Error; err != nil {
+			return fmt.Errorf("failed to delete batch items: %w", err)
+		}
+		if err := tx.Delete(batch).Error; err != nil {
+			return fmt.Errorf("failed to delete batch: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetOpenAIBatchOutput returns the JSONL output file content for an OpenAI batch, or its error
+// file content when errorFile is set
+func (s *BatchService) GetOpenAIBatchOutput(proxyKey *models.ProxyAPIKey, batchID string, errorFile bool) ([]byte, error) {
+	batch, err := s.GetBatch(proxyKey, models.BatchFormatOpenAI, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if !batch.IsFinished() {
+		return nil, fmt.Errorf("%w: batch results are not available until the batch has finished", ErrInvalidBatch)
+	}
+
+	output, errorOutput, err := s.buildOpenAIBatchOutput(batch)
+	if err != nil {
+		return nil, err
+	}
+	if errorFile {
+		return errorOutput, nil
+	}
+	return output, nil
+}
+
+// GetAnthropicBatchResults returns the JSONL results of an ended Anthropic batch
+func (s *BatchService) GetAnthropicBatchResults(proxyKey *models.ProxyAPIKey, batchID string) ([]byte, error) {
+	batch, err := s.GetBatch(proxyKey, models.BatchFormatAnthropic, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if !batch.IsFinished() {
+		return nil, fmt.Errorf("%w: batch results are not available until processing has ended", ErrInvalidBatch)
+	}
+
+	var buf bytes.Buffer
+	err = s.eachBatchItem(batch, func(item *models.BatchItem) error {
+		result := map[string]interface{}{}
+		switch item.Status {
+		case models.BatchItemStatusSucceeded:
+			result["type"] = "succeeded"
+			result["message"] = batchResponseJSON(item.Response)
+		case models.BatchItemStatusFailed:
+			result["type"] = "errored"
+			if item.Response != "" && json.Valid([]byte(item.Response)) {
+				result["error"] = json.RawMessage(item.Response)
+			} else {
+				result["error"] = map[string]interface{}{
+					"type":  "error",
+					"error": map[string]string{"type": "api_error", "message": item.ErrorMessage},
+				}
+			}
+		case models.BatchItemStatusCancelled:
+			result["type"] = "canceled"
+		default:
+			result["type"] = "expired"
+		}
+		return writeJSONLine(&buf, map[string]interface{}{"custom_id": item.CustomID, "result": result})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// openAIBatchOutputLine is a line in an OpenAI batch output or error file
+type openAIBatchOutputLine struct {
+	ID       string                     `json:"id"`
+	CustomID string                     `json:"custom_id"`
+	Response *openAIBatchOutputResponse `json:"response"`
+	Error    *OpenAIBatchError          `json:"error"`
+}
+
+// openAIBatchOutputResponse is the response recorded for a batch request
+type openAIBatchOutputResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// buildOpenAIBatchOutput renders the output file (successful requests) and error file (everything
+// else) for an OpenAI batch
+func (s *BatchService) buildOpenAIBatchOutput(batch *models.Batch) ([]byte, []byte, error) {
+	var output, errorOutput bytes.Buffer
+	err := s.eachBatchItem(batch, func(item *models.BatchItem) error {
+		line := openAIBatchOutputLine{
+			ID:       fmt.Sprintf("batch_req_%d", item.ID),
+			CustomID: item.CustomID,
+		}
+		if item.StatusCode > 0 {
+			line.Response = &openAIBatchOutputResponse{
+				StatusCode: item.StatusCode,
+				RequestID:  fmt.Sprintf("%s-%d", batch.BatchID, item.Position),
+				Body:       batchResponseJSON(item.Response),
+			}
+		}
+
+		switch item.Status {
+		case models.BatchItemStatusSucceeded:
+			return writeJSONLine(&output, line)
+		case models.BatchItemStatusCancelled:
+			line.Error = &OpenAIBatchError{Code: "batch_cancelled", Message: "This request was cancelled before it ran."}
+		case models.BatchItemStatusExpired:
+			line.Error = &OpenAIBatchError{Code: "batch_expired", Message: "This request could not be executed before the completion window expired."}
+		default:
+			if line.Response == nil {
+				line.Error = &OpenAIBatchError{Code: "request_failed", Message: item.ErrorMessage}
+			}
+		}
+		return writeJSONLine(&errorOutput, line)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return output.Bytes(), errorOutput.Bytes(), nil
+}
+
+// eachBatchItem calls fn for every item of a batch in input order, loading them in chunks
+func (s *BatchService) eachBatchItem(batch *models.Batch, fn func(item *models.BatchItem) error) error {
+	var items []models.BatchItem
+	return s.db.Where("batch_id = ?", batch.ID).Order("position").FindInBatches(&items, batchItemChunkSize, func(tx *gorm.DB, _ int) error {
+		for i := range items {
+			if err := fn(&items[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// batchResponseJSON returns a stored response body as JSON, quoting it if the upstream sent text
+func batchResponseJSON(body string) json.RawMessage {
+	if json.Valid([]byte(body)) {
+		return json.RawMessage(body)
+	}
+	quoted, _ := json.Marshal(body)
+	return quoted
+}
+
+// writeJSONLine appends v to buf as a single JSONL line
+func writeJSONLine(buf *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch result: %w", err)
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// OpenAIBatch is a batch in the OpenAI /v1/batches shape
+type OpenAIBatch struct {
+	ID               string                   `json:"id"`
+	Object           string                   `json:"object"`
+	Endpoint         string                   `json:"endpoint"`
+	Errors           *OpenAIBatchErrors       `json:"errors"`
+	InputFileID      string                   `json:"input_file_id"`
+	CompletionWindow string                   `json:"completion_window"`
+	Status           string                   `json:"status"`
+	OutputFileID     *string                  `json:"output_file_id"`
+	ErrorFileID      *string                  `json:"error_file_id"`
+	CreatedAt        int64                    `json:"created_at"`
+	InProgressAt     *int64                   `json:"in_progress_at"`
+	ExpiresAt        *int64                   `json:"expires_at"`
+	FinalizingAt     *int64                   `json:"finalizing_at"`
+	CompletedAt      *int64                   `json:"completed_at"`
+	FailedAt         *int64                   `json:"failed_at"`
+	ExpiredAt        *int64                   `json:"expired_at"`
+	CancellingAt     *int64                   `json:"cancelling_at"`
+	CancelledAt      *int64                   `json:"cancelled_at"`
+	RequestCounts    OpenAIBatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string        `json:"metadata"`
+}
+
+// OpenAIBatchErrors lists the errors that failed a batch
+type OpenAIBatchErrors struct {
+	Object string             `json:"object"`
+	Data   []OpenAIBatchError `json:"data"`
+}
+
+// OpenAIBatchError is a batch or batch request error
+type OpenAIBatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// OpenAIBatchRequestCounts reports batch progress in the OpenAI shape
+type OpenAIBatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// NewOpenAIBatch converts a stored batch to the OpenAI batch object
+func NewOpenAIBatch(batch *models.Batch) *OpenAIBatch {
+	expiresAt := batch.ExpiresAt.Unix()
+	obj := &OpenAIBatch{
+		ID:               batch.BatchID,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileID:      batch.InputFileID,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.Status,
+		OutputFileID:     optionalString(batch.OutputFileID),
+		ErrorFileID:      optionalString(batch.ErrorFileID),
+		CreatedAt:        batch.CreatedAt.Unix(),
+		InProgressAt:     unixTime(batch.InProgressAt),
+		ExpiresAt:        &expiresAt,
+		FinalizingAt:     unixTime(batch.FinalizingAt),
+		CompletedAt:      unixTime(batch.CompletedAt),
+		FailedAt:         unixTime(batch.FailedAt),
+		ExpiredAt:        unixTime(batch.ExpiredAt),
+		CancellingAt:     unixTime(batch.CancellingAt),
+		CancelledAt:      unixTime(batch.CancelledAt),
+		RequestCounts: OpenAIBatchRequestCounts{
+			Total:     batch.TotalCount,
+			Completed: batch.CompletedCount,
+			Failed:    batch.FailedCount,
+		},
+		Metadata: batch.Metadata,
+	}
+	if batch.ErrorMessage != "" {
+		obj.Errors = &OpenAIBatchErrors{
+			Object: "list",
+			Data:   []OpenAIBatchError{{Code: "batch_failed", Message: batch.ErrorMessage}},
+		}
+	}
+	return obj
+}
+
+// AnthropicMessageBatch is a batch in the Anthropic /v1/messages/batches shape
+type AnthropicMessageBatch struct {
+	ID                string                      `json:"id"`
+	Type              string                      `json:"type"`
+	ProcessingStatus  string                      `json:"processing_status"` // in_progress, canceling, ended
+	RequestCounts     AnthropicBatchRequestCounts `json:"request_counts"`
+	EndedAt           *time.Time                  `json:"ended_at"`
+	CreatedAt         time.Time                   `json:"created_at"`
+	ExpiresAt         time.Time                   `json:"expires_at"`
+	ArchivedAt        *time.Time                  `json:"archived_at"`
+	CancelInitiatedAt *time.Time                  `json:"cancel_initiated_at"`
+	ResultsURL        *string                     `json:"results_url"`
+}
+
+// AnthropicBatchRequestCounts reports batch progress in the Anthropic shape
+type AnthropicBatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// NewAnthropicMessageBatch converts a stored batch to the Anthropic message batch object;
+// resultsURL is reported once the batch has ended
+func NewAnthropicMessageBatch(batch *models.Batch, resultsURL string) *AnthropicMessageBatch {
+	obj := &AnthropicMessageBatch{
+		ID:               batch.BatchID,
+		Type:             "message_batch",
+		ProcessingStatus: "in_progress",
+		RequestCounts: AnthropicBatchRequestCounts{
+			Succeeded: batch.CompletedCount,
+			Errored:   batch.FailedCount,
+			Canceled:  batch.CancelledCount,
+			Expired:   batch.ExpiredCount,
+		},
+		CreatedAt:         batch.CreatedAt.UTC(),
+		ExpiresAt:         batch.ExpiresAt.UTC(),
+		CancelInitiatedAt: batch.CancellingAt,
+	}
+	counts := &obj.RequestCounts
+	counts.Processing = batch.TotalCount - counts.Succeeded - counts.Errored - counts.Canceled - counts.Expired
+
+	switch {
+	case batch.IsFinished():
+		obj.ProcessingStatus = "ended"
+		obj.EndedAt = batch.CompletedAt
+		for _, endedAt := range []*time.Time{batch.CancelledAt, batch.ExpiredAt, batch.FailedAt} {
+			if endedAt != nil {
+				obj.EndedAt = endedAt
+			}
+		}
+		obj.ResultsURL = &resultsURL
+	case batch.Status == models.BatchStatusCancelling:
+		obj.ProcessingStatus = "canceling"
+	}
+	return obj
+}
+
+// optionalString returns nil for an empty string
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// unixTime converts an optional time to optional Unix seconds
+func unixTime(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	unix := t.Unix()
+	return &unix
+}
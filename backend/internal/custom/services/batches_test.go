@@ -0,0 +1,537 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// setupBatchTestDB creates an in-memory database with the batch tables. Batch items run
+// concurrently, so the pool is limited to the single connection that owns the in-memory database.
+func setupBatchTestDB(t *testing.T) *gorm.DB {
+	db := setupProxyTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Batch{}, &models.BatchItem{}))
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	return db
+}
+
+// memoryFileStore is a BatchFileStore backed by a map. Writes fail with writeErr when it is set,
+// only for filenames ending in failSuffix if that is set too.
+type memoryFileStore struct {
+	files      map[string][]byte
+	writeErr   error
+	failSuffix string
+	created    int
+}
+
+func (m *memoryFileStore) ReadFile(userID uint, fileID string) ([]byte, error) {
+	content, ok := m.files[fileID]
+	if !ok {
+		return nil, fmt.Errorf("file not found")
+	}
+	return content, nil
+}
+
+func (m *memoryFileStore) CreateFile(userID uint, filename, purpose string, content []byte) (string, error) {
+	if m.writeErr != nil && strings.HasSuffix(filename, m.failSuffix) {
+		return "", m.writeErr
+	}
+	m.created++
+	fileID := fmt.Sprintf("file-%d", m.created)
+	m.files[fileID] = content
+	return fileID, nil
+}
+
+func (m *memoryFileStore) DeleteFile(userID uint, fileID string) error {
+	delete(m.files, fileID)
+	return nil
+}
+
+func batchLine(customID, url, body string) string {
+	return fmt.Sprintf(`{"custom_id":%q,"method":"POST","url":%q,"body":%s}`, customID, url, body) + "\n"
+}
+
+func TestParseOpenAIBatchInput(t *testing.T) {
+	chat := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+
+	tests := []struct {
+		name      string
+		input     string
+		wantItems int
+		wantErr   string
+	}{
+		{name: "valid", input: batchLine("a", "/v1/chat/completions", chat) + "\n" + batchLine("b", "/v1/chat/completions", chat), wantItems: 2},
+		{name: "empty", input: "\n", wantErr: "no requests"},
+		{name: "invalid json", input: "{not json}\n", wantErr: "line 1 is not valid JSON"},
+		{name: "duplicate custom_id", input: batchLine("a", "/v1/chat/completions", chat) + batchLine("a", "/v1/chat/completions", chat), wantErr: "duplicate custom_id"},
+		{name: "endpoint mismatch", input: batchLine("a", "/v1/embeddings", chat), wantErr: "does not match"},
+		{name: "streaming", input: batchLine("a", "/v1/chat/completions", `{"model":"gpt-4o","stream":true}`), wantErr: "streaming"},
+		{name: "missing model", input: batchLine("a", "/v1/chat/completions", `{"messages":[]}`), wantErr: "model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := parseOpenAIBatchInput([]byte(tt.input), "/v1/chat/completions")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, ErrInvalidBatch)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, items, tt.wantItems)
+		})
+	}
+}
+
+func TestBatchService(t *testing.T) {
+	// newOpenAIUpstream answers chat completions, failing any request whose message is "fail"
+	newOpenAIUpstream := func(t *testing.T) *httptest.Server {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var chatReq OpenAIChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chatReq))
+
+			w.Header().Set("Content-Type", "application/json")
+			if chatReq.Messages[0].GetContentString() == "fail" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":{"message":"bad request","type":"invalid_request_error"}}`)
+				return
+			}
+			io.WriteString(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`)
+		}))
+		t.Cleanup(upstream.Close)
+		return upstream
+	}
+
+	chatLine := func(customID, content string) string {
+		return batchLine(customID, "/v1/chat/completions", fmt.Sprintf(`{"model":"gpt-4o","messages":[{"role":"user","content":%q}]}`, content))
+	}
+
+	t.Run("runs an uploaded OpenAI batch and records usage per item", func(t *testing.T) {
+		upstream := newOpenAIUpstream(t)
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		service := NewBatchService(db, NewKeyService(db), proxyService, 2)
+
+		input := chatLine("r1", "hello") + chatLine("r2", "fail") + chatLine("r3", "again")
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(input))
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusValidating, batch.Status)
+		assert.Equal(t, 3, batch.TotalCount)
+
+		// Results are not available until the batch finishes
+		_, err = service.GetOpenAIBatchOutput(proxyKey, batch.BatchID, false)
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+
+		require.True(t, service.processNextBatch(context.Background()))
+		assert.False(t, service.processNextBatch(context.Background()))
+
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+		assert.Equal(t, 2, batch.CompletedCount)
+		assert.Equal(t, 1, batch.FailedCount)
+		assert.NotNil(t, batch.CompletedAt)
+
+		output, err := service.GetOpenAIBatchOutput(proxyKey, batch.BatchID, false)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		require.Len(t, lines, 2)
+
+		var first openAIBatchOutputLine
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Equal(t, "r1", first.CustomID)
+		require.NotNil(t, first.Response)
+		assert.Equal(t, http.StatusOK, first.Response.StatusCode)
+		assert.Contains(t, string(first.Response.Body), `"content":"ok"`)
+
+		errorOutput, err := service.GetOpenAIBatchOutput(proxyKey, batch.BatchID, true)
+		require.NoError(t, err)
+		var failed openAIBatchOutputLine
+		require.NoError(t, json.Unmarshal(errorOutput, &failed))
+		assert.Equal(t, "r2", failed.CustomID)
+		assert.Equal(t, http.StatusBadRequest, failed.Response.StatusCode)
+
+		assert.Eventually(t, func() bool {
+			var count int64
+			db.Model(&models.UsageRecord{}).Where("proxy_key_id = ?", proxyKey.ID).Count(&count)
+			return count == 3
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("reads input_file_id from the file store and stores result files", func(t *testing.T) {
+		upstream := newOpenAIUpstream(t)
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+
+		_, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{InputFileID: "file-input", Endpoint: "/v1/chat/completions"}, nil)
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+
+		store := &memoryFileStore{files: map[string][]byte{"file-input": []byte(chatLine("r1", "hello"))}}
+		service.SetFileStore(store)
+
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{InputFileID: "file-input", Endpoint: "/v1/chat/completions"}, nil)
+		require.NoError(t, err)
+		require.True(t, service.processNextBatch(context.Background()))
+
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+		assert.NotEmpty(t, batch.OutputFileID)
+		assert.Empty(t, batch.ErrorFileID)
+		assert.Contains(t, string(store.files[batch.OutputFileID]), `"custom_id":"r1"`)
+
+		obj := NewOpenAIBatch(batch)
+		require.NotNil(t, obj.OutputFileID)
+		assert.Equal(t, batch.OutputFileID, *obj.OutputFileID)
+		assert.Nil(t, obj.ErrorFileID)
+	})
+
+	t.Run("cancels the items that have not run", func(t *testing.T) {
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://127.0.0.1:1")
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"},
+			[]byte(chatLine("r1", "a")+chatLine("r2", "b")))
+		require.NoError(t, err)
+
+		_, err = service.CancelBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		require.True(t, service.processNextBatch(context.Background()))
+
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCancelled, batch.Status)
+		assert.Equal(t, 2, batch.CancelledCount)
+
+		errorOutput, err := service.GetOpenAIBatchOutput(proxyKey, batch.BatchID, true)
+		require.NoError(t, err)
+		assert.Contains(t, string(errorOutput), `"code":"batch_cancelled"`)
+
+		// Finished batches cannot be cancelled again
+		_, err = service.CancelBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+	})
+
+	t.Run("keeps a batch's outcome while its result files can't be stored", func(t *testing.T) {
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://127.0.0.1:1")
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+		store := &memoryFileStore{files: map[string][]byte{}, writeErr: fmt.Errorf("disk full")}
+		service.SetFileStore(store)
+
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(chatLine("r1", "a")))
+		require.NoError(t, err)
+		_, err = service.CancelBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+
+		assert.False(t, service.processNextBatch(context.Background()))
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCancelling, batch.Status)
+
+		// The next pass finishes it as cancelled, not completed
+		store.writeErr = nil
+		require.True(t, service.processNextBatch(context.Background()))
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCancelled, batch.Status)
+		assert.NotEmpty(t, batch.ErrorFileID)
+	})
+
+	t.Run("leaves no output file behind when the error file can't be stored", func(t *testing.T) {
+		upstream := newOpenAIUpstream(t)
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+		store := &memoryFileStore{files: map[string][]byte{}, writeErr: fmt.Errorf("disk full"), failSuffix: "_error.jsonl"}
+		service.SetFileStore(store)
+
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"},
+			[]byte(chatLine("r1", "hello")+chatLine("r2", "fail")))
+		require.NoError(t, err)
+
+		assert.False(t, service.processNextBatch(context.Background()))
+		assert.Empty(t, store.files)
+
+		store.writeErr = nil
+		require.True(t, service.processNextBatch(context.Background()))
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+		assert.Len(t, store.files, 2, "one output and one error file")
+	})
+
+	t.Run("fails a batch whose result files keep failing to store", func(t *testing.T) {
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://127.0.0.1:1")
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+		service.SetFileStore(&memoryFileStore{files: map[string][]byte{}, writeErr: fmt.Errorf("disk full")})
+
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(chatLine("r1", "a")))
+		require.NoError(t, err)
+		_, err = service.CancelBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+
+		for i := 1; i < maxBatchFinalizeAttempts; i++ {
+			assert.False(t, service.processNextBatch(context.Background()))
+		}
+		require.True(t, service.processNextBatch(context.Background()))
+		assert.False(t, service.processNextBatch(context.Background()), "the batch is no longer queued")
+
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusFailed, batch.Status)
+		assert.Contains(t, batch.ErrorMessage, "disk full")
+	})
+
+	t.Run("runs batches side by side", func(t *testing.T) {
+		release := make(chan struct{})
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var chatReq OpenAIChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chatReq))
+			if chatReq.Messages[0].GetContentString() == "slow" {
+				<-release
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+
+		slow, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(chatLine("r1", "slow")))
+		require.NoError(t, err)
+		fast, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(chatLine("r1", "fast")))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		service.Start(ctx)
+
+		statusOf := func(batchID string) string {
+			batch, err := service.GetBatch(proxyKey, models.BatchFormatOpenAI, batchID)
+			require.NoError(t, err)
+			return batch.Status
+		}
+
+		// The newer batch finishes while the older one is still waiting on its item
+		assert.Eventually(t, func() bool { return statusOf(fast.BatchID) == models.BatchStatusCompleted }, 2*time.Second, 20*time.Millisecond)
+		assert.Equal(t, models.BatchStatusInProgress, statusOf(slow.BatchID))
+
+		close(release)
+		assert.Eventually(t, func() bool { return statusOf(slow.BatchID) == models.BatchStatusCompleted }, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("leaves interrupted items pending on shutdown", func(t *testing.T) {
+		// Only the first attempt hangs, until the worker gives up on it
+		received := make(chan struct{})
+		var first sync.Once
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hang := false
+			first.Do(func() { hang = true })
+			if hang {
+				// The server notices the client going away only once the body is read
+				io.Copy(io.Discard, r.Body)
+				close(received)
+				<-r.Context().Done()
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(chatLine("r1", "hello")))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		service.Start(ctx)
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatal("the batch item never reached the upstream")
+		}
+		cancel()
+		service.Wait()
+
+		var item models.BatchItem
+		require.NoError(t, db.Where("batch_id = ?", batch.ID).First(&item).Error)
+		assert.Equal(t, models.BatchItemStatusPending, item.Status)
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusInProgress, batch.Status)
+
+		// The next run picks the item up again
+		require.True(t, service.processNextBatch(context.Background()))
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+		assert.Equal(t, 1, batch.CompletedCount)
+	})
+
+	t.Run("stops a batch once its key is revoked", func(t *testing.T) {
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+
+		// The key is revoked while the first chunk runs
+		var proxyKey *models.ProxyAPIKey
+		var revoke sync.Once
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			revoke.Do(func() {
+				require.NoError(t, db.Model(&models.ProxyAPIKey{}).Where("id = ?", proxyKey.ID).Update("is_active", false).Error)
+			})
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`)
+		}))
+		defer upstream.Close()
+		proxyKey = createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		service := NewBatchService(db, NewKeyService(db), proxyService, 1)
+
+		var input strings.Builder
+		for i := 0; i < batchItemChunkSize+1; i++ {
+			input.WriteString(chatLine(fmt.Sprintf("r%d", i), "hello"))
+		}
+		batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(input.String()))
+		require.NoError(t, err)
+
+		require.True(t, service.processNextBatch(context.Background()))
+
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusFailed, batch.Status)
+		assert.Equal(t, batchItemChunkSize, batch.CompletedCount)
+		assert.Equal(t, 1, batch.CancelledCount)
+		assert.Contains(t, batch.ErrorMessage, "inactive")
+	})
+
+	t.Run("runs an Anthropic message batch", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/messages", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn","usage":{"input_tokens":4,"output_tokens":2}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupBatchTestDB(t)
+		proxyService := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, upstream.URL)
+		service := NewBatchService(db, NewKeyService(db), proxyService, 2)
+
+		var req AnthropicBatchRequest
+		require.NoError(t, json.Unmarshal([]byte(`{"requests":[
+			{"custom_id":"q1","params":{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"Hi"}]}},
+			{"custom_id":"q2","params":{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"Yo"}]}}
+		]}`), &req))
+
+		batch, err := service.CreateAnthropicBatch(proxyKey, &req)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(batch.BatchID, "msgbatch_"))
+
+		obj := NewAnthropicMessageBatch(batch, "http://proxy/results")
+		assert.Equal(t, "in_progress", obj.ProcessingStatus)
+		assert.Equal(t, 2, obj.RequestCounts.Processing)
+		assert.Nil(t, obj.ResultsURL)
+
+		require.True(t, service.processNextBatch(context.Background()))
+
+		batch, err = service.GetBatch(proxyKey, models.BatchFormatAnthropic, batch.BatchID)
+		require.NoError(t, err)
+		obj = NewAnthropicMessageBatch(batch, "http://proxy/results")
+		assert.Equal(t, "ended", obj.ProcessingStatus)
+		assert.Equal(t, 2, obj.RequestCounts.Succeeded)
+		assert.Equal(t, 0, obj.RequestCounts.Processing)
+		require.NotNil(t, obj.ResultsURL)
+
+		results, err := service.GetAnthropicBatchResults(proxyKey, batch.BatchID)
+		require.NoError(t, err)
+		var line struct {
+			CustomID string `json:"custom_id"`
+			Result   struct {
+				Type    string            `json:"type"`
+				Message AnthropicResponse `json:"message"`
+			} `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(strings.Split(string(results), "\n")[0]), &line))
+		assert.Equal(t, "q1", line.CustomID)
+		assert.Equal(t, "succeeded", line.Result.Type)
+		assert.Equal(t, "msg_1", line.Result.Message.ID)
+
+		// OpenAI and Anthropic batches are listed separately
+		_, err = service.GetBatch(proxyKey, models.BatchFormatOpenAI, batch.BatchID)
+		assert.ErrorIs(t, err, ErrBatchNotFound)
+
+		require.NoError(t, service.DeleteBatch(proxyKey, models.BatchFormatAnthropic, batch.BatchID))
+		_, err = service.GetBatch(proxyKey, models.BatchFormatAnthropic, batch.BatchID)
+		assert.ErrorIs(t, err, ErrBatchNotFound)
+	})
+
+	t.Run("rejects invalid Anthropic requests", func(t *testing.T) {
+		db := setupBatchTestDB(t)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, "http://127.0.0.1:1")
+		service := NewBatchService(db, NewKeyService(db), createProxyTestServices(t, db), 1)
+
+		_, err := service.CreateAnthropicBatch(proxyKey, &AnthropicBatchRequest{Requests: []AnthropicBatchRequestItem{
+			{CustomID: "bad id!", Params: json.RawMessage(`{"model":"claude-sonnet-4"}`)},
+		}})
+		assert.ErrorIs(t, err, ErrInvalidBatch)
+	})
+
+	t.Run("lists batches newest first with paging", func(t *testing.T) {
+		db := setupBatchTestDB(t)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://127.0.0.1:1")
+		service := NewBatchService(db, NewKeyService(db), createProxyTestServices(t, db), 1)
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			batch, err := service.CreateOpenAIBatch(proxyKey, &CreateBatchRequest{Endpoint: "/v1/chat/completions"}, []byte(chatLine("r", "a")))
+			require.NoError(t, err)
+			ids = append(ids, batch.BatchID)
+		}
+
+		page, hasMore, err := service.ListBatches(proxyKey, models.BatchFormatOpenAI, "", 2)
+		require.NoError(t, err)
+		assert.True(t, hasMore)
+		require.Len(t, page, 2)
+		assert.Equal(t, ids[2], page[0].BatchID)
+
+		page, hasMore, err = service.ListBatches(proxyKey, models.BatchFormatOpenAI, page[1].BatchID, 2)
+		require.NoError(t, err)
+		assert.False(t, hasMore)
+		require.Len(t, page, 1)
+		assert.Equal(t, ids[0], page[0].BatchID)
+	})
+}
@@ -0,0 +1,523 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// bedrockSigningName is the SigV4 service name for both the Bedrock runtime and control plane
+const bedrockSigningName = "bedrock"
+
+// bedrockConverseRequest represents a Converse / ConverseStream request. The Converse API is
+// used rather than InvokeModel because it takes the same shape for every model family.
+type bedrockConverseRequest struct {
+	Messages        []bedrockMessage        `json:"messages"`
+	System          []bedrockContentBlock   `json:"system,omitempty"`
+	InferenceConfig *bedrockInferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig      *bedrockToolConfig      `json:"toolConfig,omitempty"`
+}
+
+// bedrockMessage is one turn of a Converse conversation; the role is "user" or "assistant"
+type bedrockMessage struct {
+	Role    string                `json:"role"`
+	Content []bedrockContentBlock `json:"content"`
+}
+
+// bedrockContentBlock is a single piece of content; exactly one field is set
+type bedrockContentBlock struct {
+	Text             string             `json:"text,omitempty"`
+	Image            *bedrockImage      `json:"image,omitempty"`
+	ToolUse          *bedrockToolUse    `json:"toolUse,omitempty"`
+	ToolResult       *bedrockToolResult `json:"toolResult,omitempty"`
+	ReasoningContent json.RawMessage    `json:"reasoningContent,omitempty"` // Only in responses; not forwarded
+}
+
+// bedrockImage holds an inline image; bytes are base64 encoded
+type bedrockImage struct {
+	Format string `json:"format"` // png, jpeg, gif or webp
+	Source struct {
+		Bytes string `json:"bytes"`
+	} `json:"source"`
+}
+
+// bedrockToolUse is a tool call made by the model; input is a JSON object
+type bedrockToolUse struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+// bedrockToolResult carries a tool result back to the model
+type bedrockToolResult struct {
+	ToolUseID string                `json:"toolUseId"`
+	Content   []bedrockContentBlock `json:"content"`
+}
+
+// bedrockInferenceConfig holds the sampling parameters
+type bedrockInferenceConfig struct {
+	MaxTokens     *int     `json:"maxTokens,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+// bedrockToolConfig declares the tools offered to the model
+type bedrockToolConfig struct {
+	Tools      []bedrockTool      `json:"tools"`
+	ToolChoice *bedrockToolChoice `json:"toolChoice,omitempty"`
+}
+
+// bedrockTool wraps a tool specification
+type bedrockTool struct {
+	ToolSpec struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+		InputSchema struct {
+			JSON json.RawMessage `json:"json"`
+		} `json:"inputSchema"`
+	} `json:"toolSpec"`
+}
+
+// bedrockToolChoice forces tool use; exactly one field is set
+type bedrockToolChoice struct {
+	Auto *struct{} `json:"auto,omitempty"`
+	Any  *struct{} `json:"any,omitempty"`
+	Tool *struct {
+		Name string `json:"name"`
+	} `json:"tool,omitempty"`
+}
+
+// bedrockConverseResponse is a Converse response
+type bedrockConverseResponse struct {
+	Output struct {
+		Message *bedrockMessage `json:"message"`
+	} `json:"output"`
+	StopReason string        `json:"stopReason"`
+	Usage      *bedrockUsage `json:"usage"`
+}
+
+// bedrockUsage reports token counts. As with Anthropic, inputTokens excludes prompt tokens read
+// from or written to the cache.
+type bedrockUsage struct {
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	TotalTokens           int `json:"totalTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens"`
+}
+
+// bedrockStreamEvent is the payload of any ConverseStream event; which fields are set depends on
+// the event type
+type bedrockStreamEvent struct {
+	ContentBlockIndex int    `json:"contentBlockIndex"`
+	StopReason        string `json:"stopReason"`
+	Start             *struct {
+		ToolUse *bedrockToolUse `json:"toolUse"`
+	} `json:"start"`
+	Delta *struct {
+		Text    *string `json:"text"`
+		ToolUse *struct {
+			Input string `json:"input"`
+		} `json:"toolUse"`
+	} `json:"delta"`
+	Usage *bedrockUsage `json:"usage"`
+}
+
+// ToOpenAI converts Bedrock usage to OpenAI usage, counting cached tokens as prompt tokens
+func (u *bedrockUsage) ToOpenAI() *OpenAIUsage {
+	promptTokens := u.InputTokens + u.CacheReadInputTokens + u.CacheWriteInputTokens
+	usage := &OpenAIUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      promptTokens + u.OutputTokens,
+	}
+	if u.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &OpenAIPromptTokenDetails{CachedTokens: u.CacheReadInputTokens}
+	}
+	return usage
+}
+
+// bedrockConverseURL returns the Converse URL for a model, or ConverseStream when stream is set.
+// Model IDs such as "anthropic.claude-3-5-sonnet-20240620-v1:0" are escaped in full.
+func bedrockConverseURL(provider *models.Provider, modelName string, stream bool) string {
+	endpoint := strings.TrimSuffix(provider.GetBaseURL(), "/") + "/model/" + awsURIEncode(modelName)
+	if stream {
+		return endpoint + "/converse-stream"
+	}
+	return endpoint + "/converse"
+}
+
+// bedrockModelsURL returns the ListFoundationModels URL. The catalogue lives on the control plane
+// endpoint (bedrock.*) rather than the runtime (bedrock-runtime.*), unless a base URL overrides both.
+func bedrockModelsURL(provider *models.Provider) string {
+	if provider.BaseURL != "" {
+		return strings.TrimSuffix(provider.BaseURL, "/") + "/foundation-models"
+	}
+	return "https://bedrock." + provider.AWSRegion + ".amazonaws.com/foundation-models"
+}
+
+// signBedrockRequest signs a request with the provider's AWS credentials
+func signBedrockRequest(req *http.Request, payload []byte, provider *models.Provider) {
+	signAWSRequest(req, payload, awsCredentials{
+		AccessKeyID:     provider.AWSAccessKeyID,
+		SecretAccessKey: provider.APIKey,
+		Region:          provider.AWSRegion,
+		Service:         bedrockSigningName,
+	}, time.Now())
+}
+
+// transformToBedrock converts an OpenAI chat request into a Converse request.
+// The model and stream flag are part of the URL rather than the body.
+func (s *ProxyService) transformToBedrock(req *OpenAIChatRequest) ([]byte, error) {
+	var bedrockReq bedrockConverseRequest
+
+	config := bedrockInferenceConfig{
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	}
+	config.StopSequences = stopSequences(req.Stop)
+	if config.MaxTokens != nil || config.Temperature != nil || config.TopP != nil || len(config.StopSequences) > 0 {
+		bedrockReq.InferenceConfig = &config
+	}
+
+	// Bedrock has no "none" tool choice, so tools are left out instead
+	if toolChoice, _ := req.ToolChoice.(string); toolChoice != "none" {
+		var tools []bedrockTool
+		for _, tool := range req.Tools {
+			if tool.Type != "" && tool.Type != "function" {
+				continue
+			}
+			var spec bedrockTool
+			spec.ToolSpec.Name = tool.Function.Name
+			spec.ToolSpec.Description = tool.Function.Description
+			spec.ToolSpec.InputSchema.JSON = tool.Function.Parameters
+			if len(spec.ToolSpec.InputSchema.JSON) == 0 || string(spec.ToolSpec.InputSchema.JSON) == "null" {
+				spec.ToolSpec.InputSchema.JSON = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			tools = append(tools, spec)
+		}
+		if len(tools) > 0 {
+			bedrockReq.ToolConfig = &bedrockToolConfig{Tools: tools, ToolChoice: openAIToolChoiceToBedrock(req.ToolChoice)}
+		}
+	}
+
+	for _, msg := range req.Messages {
+		content := msg.GetContentString()
+		var role string
+		var blocks []bedrockContentBlock
+
+		switch msg.Role {
+		case "system", "developer":
+			if content != "" {
+				bedrockReq.System = append(bedrockReq.System, bedrockContentBlock{Text: content})
+			}
+			continue
+		case "assistant":
+			role = "assistant"
+			if content != "" {
+				blocks = append(blocks, bedrockContentBlock{Text: content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage(`{}`)
+				}
+				blocks = append(blocks, bedrockContentBlock{ToolUse: &bedrockToolUse{
+					ToolUseID: call.ID,
+					Name:      call.Function.Name,
+					Input:     input,
+				}})
+			}
+		case "tool":
+			role = "user"
+			blocks = []bedrockContentBlock{{ToolResult: &bedrockToolResult{
+				ToolUseID: msg.ToolCallID,
+				Content:   []bedrockContentBlock{{Text: content}},
+			}}}
+		default:
+			// user, and other roles mapped to user (e.g., "function" results)
+			role = "user"
+			var err error
+			if blocks, err = bedrockUserContent(msg.Content); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		// Converse requires alternating roles, so consecutive turns (e.g. several tool results)
+		// from the same side are merged
+		if last := len(bedrockReq.Messages) - 1; last >= 0 && bedrockReq.Messages[last].Role == role {
+			bedrockReq.Messages[last].Content = append(bedrockReq.Messages[last].Content, blocks...)
+			continue
+		}
+		bedrockReq.Messages = append(bedrockReq.Messages, bedrockMessage{Role: role, Content: blocks})
+	}
+
+	if len(bedrockReq.Messages) == 0 {
+		return nil, fmt.Errorf("at least one user or assistant message is required")
+	}
+
+	return json.Marshal(bedrockReq)
+}
+
+// bedrockUserContent converts user message content (a string or an array of text and image_url
+// parts) into Converse content blocks. Bedrock cannot fetch URLs, so images must be data URIs.
+func bedrockUserContent(content interface{}) ([]bedrockContentBlock, error) {
+	items, ok := content.([]interface{})
+	if !ok {
+		text, _ := content.(string)
+		if text == "" {
+			return nil, nil
+		}
+		return []bedrockContentBlock{{Text: text}}, nil
+	}
+
+	var blocks []bedrockContentBlock
+	for _, item := range items {
+		p, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		switch p["type"] {
+		case "text":
+			if text, _ := p["text"].(string); text != "" {
+				blocks = append(blocks, bedrockContentBlock{Text: text})
+			}
+		case "image_url":
+			var imageURL string
+			switch v := p["image_url"].(type) {
+			case string:
+				imageURL = v
+			case map[string]interface{}:
+				imageURL, _ = v["url"].(string)
+			}
+
+			mediaType, data, ok := parseImageDataURI(imageURL)
+			if !ok {
+				return nil, fmt.Errorf("bedrock providers only accept images as base64 data URIs")
+			}
+			image := &bedrockImage{Format: strings.TrimPrefix(mediaType, "image/")}
+			if image.Format == "jpg" {
+				image.Format = "jpeg"
+			}
+			image.Source.Bytes = data
+			blocks = append(blocks, bedrockContentBlock{Image: image})
+		}
+	}
+	return blocks, nil
+}
+
+// openAIToolChoiceToBedrock maps OpenAI tool_choice to a Converse tool choice
+func openAIToolChoiceToBedrock(toolChoice interface{}) *bedrockToolChoice {
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			return &bedrockToolChoice{Auto: &struct{}{}}
+		case "required":
+			return &bedrockToolChoice{Any: &struct{}{}}
+		}
+	case map[string]interface{}:
+		if fn, ok := v["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return &bedrockToolChoice{Tool: &struct {
+					Name string `json:"name"`
+				}{Name: name}}
+			}
+		}
+	}
+	return nil
+}
+
+// bedrockStopReasonToOpenAI maps a Converse stopReason to an OpenAI finish_reason
+func bedrockStopReasonToOpenAI(stopReason string) string {
+	switch stopReason {
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens", "model_context_window_exceeded":
+		return "length"
+	case "guardrail_intervened", "content_filtered":
+		return "content_filter"
+	default:
+		// end_turn, stop_sequence
+		return "stop"
+	}
+}
+
+// transformBedrockResponse converts a Converse response into an OpenAI chat.completion
+func (s *ProxyService) transformBedrockResponse(body []byte) ([]byte, error) {
+	var bedrockResp bedrockConverseResponse
+	if err := json.Unmarshal(body, &bedrockResp); err != nil {
+		return nil, fmt.Errorf("invalid Bedrock response: %w", err)
+	}
+
+	var text strings.Builder
+	var toolCalls []OpenAIToolCall
+	if bedrockResp.Output.Message != nil {
+		for _, block := range bedrockResp.Output.Message.Content {
+			switch {
+			case block.ToolUse != nil:
+				arguments := string(block.ToolUse.Input)
+				if arguments == "" || arguments == "null" {
+					arguments = "{}"
+				}
+				toolCalls = append(toolCalls, OpenAIToolCall{
+					ID:       block.ToolUse.ToolUseID,
+					Type:     "function",
+					Function: OpenAIFunctionCall{Name: block.ToolUse.Name, Arguments: arguments},
+				})
+			case block.ReasoningContent == nil:
+				text.WriteString(block.Text)
+			}
+		}
+	}
+
+	message := OpenAIMessage{
+		Role:      "assistant",
+		Content:   text.String(),
+		ToolCalls: toolCalls,
+	}
+	if len(toolCalls) > 0 && text.Len() == 0 {
+		// OpenAI sends null content on pure tool-call messages
+		message.Content = nil
+	}
+
+	chatResp := OpenAIChatResponse{
+		ID:      newResponsesID("chatcmpl-"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Choices: []OpenAIChoice{{
+			Message:      message,
+			FinishReason: bedrockStopReasonToOpenAI(bedrockResp.StopReason),
+		}},
+	}
+	if bedrockResp.Usage != nil {
+		chatResp.Usage = bedrockResp.Usage.ToOpenAI()
+	}
+
+	return json.Marshal(chatResp)
+}
+
+// transformBedrockError converts a Bedrock error body ({"message": ...}) into the OpenAI error
+// shape. Bodies that are not Bedrock errors are returned unchanged.
+func transformBedrockError(statusCode int, body []byte) []byte {
+	var bedrockErr struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &bedrockErr); err != nil || bedrockErr.Message == "" {
+		return body
+	}
+	return openAIErrorPayload(statusCode, "", bedrockErr.Message)
+}
+
+// accumulateBedrockUsage reads usage from a Converse response or a ConverseStream metadata event
+func accumulateBedrockUsage(data []byte, result *ProxyResult) {
+	var resp struct {
+		Usage *bedrockUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Usage == nil {
+		return
+	}
+
+	usage := resp.Usage.ToOpenAI()
+	if usage.TotalTokens == 0 {
+		return
+	}
+	result.InputTokens = usage.PromptTokens
+	result.OutputTokens = usage.CompletionTokens
+	result.TotalTokens = usage.TotalTokens
+}
+
+// bedrockStreamTranslator converts ConverseStream events (decoded from AWS event-stream framing)
+// into OpenAI chat.completion.chunk events terminated by data: [DONE]. Usage arrives in the
+// metadata event after messageStop, so the usage chunk and [DONE] are sent by Finish once the
+// upstream stream closes.
+type bedrockStreamTranslator struct {
+	chatChunkEmitter
+
+	toolIndexes map[int]int // contentBlockIndex -> tool call index
+}
+
+// newBedrockStreamTranslator creates a translator; includeUsage adds a final usage chunk
+// as requested by the client's stream_options.include_usage
+func newBedrockStreamTranslator(includeUsage bool) *bedrockStreamTranslator {
+	return &bedrockStreamTranslator{
+		chatChunkEmitter: newChatChunkEmitter(includeUsage),
+		toolIndexes:      make(map[int]int),
+	}
+}
+
+// Translate implements streamTranslator
+func (t *bedrockStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	if t.done || ev.Data == "" {
+		return nil
+	}
+
+	if ev.Event == "exception" {
+		// Surface the upstream error in the OpenAI shape, then end the stream
+		var exception struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal([]byte(ev.Data), &exception)
+		t.done = true
+		return []sseEvent{{Data: string(openAIErrorPayload(http.StatusInternalServerError, exception.Type, exception.Message))}, {Data: "[DONE]"}}
+	}
+
+	var event bedrockStreamEvent
+	if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+		return nil
+	}
+
+	switch ev.Event {
+	case "messageStart":
+		empty := ""
+		return t.chunk(OpenAIChunkDelta{Role: "assistant", Content: &empty}, nil)
+	case "contentBlockStart":
+		if event.Start == nil || event.Start.ToolUse == nil {
+			return nil
+		}
+		index := len(t.toolIndexes)
+		t.toolIndexes[event.ContentBlockIndex] = index
+		return t.chunk(OpenAIChunkDelta{ToolCalls: []OpenAIToolCall{{
+			Index:    &index,
+			ID:       event.Start.ToolUse.ToolUseID,
+			Type:     "function",
+			Function: OpenAIFunctionCall{Name: event.Start.ToolUse.Name},
+		}}}, nil)
+	case "contentBlockDelta":
+		if event.Delta == nil {
+			return nil
+		}
+		if event.Delta.Text != nil && *event.Delta.Text != "" {
+			return t.chunk(OpenAIChunkDelta{Content: event.Delta.Text}, nil)
+		}
+		if event.Delta.ToolUse != nil {
+			index, ok := t.toolIndexes[event.ContentBlockIndex]
+			if !ok {
+				return nil
+			}
+			return t.chunk(OpenAIChunkDelta{ToolCalls: []OpenAIToolCall{{
+				Index:    &index,
+				Function: OpenAIFunctionCall{Arguments: event.Delta.ToolUse.Input},
+			}}}, nil)
+		}
+	case "messageStop":
+		finishReason := bedrockStopReasonToOpenAI(event.StopReason)
+		return t.chunk(OpenAIChunkDelta{}, &finishReason)
+	case "metadata":
+		if event.Usage != nil {
+			t.usage = event.Usage.ToOpenAI()
+		}
+	}
+	return nil
+}
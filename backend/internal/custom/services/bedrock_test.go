@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestProxyService_TransformToBedrock(t *testing.T) {
+	service := &ProxyService{}
+
+	t.Run("maps roles, system prompt, images and inference config", func(t *testing.T) {
+		maxTokens := 256
+		req := &OpenAIChatRequest{
+			MaxTokens: &maxTokens,
+			Stop:      []interface{}{"END"},
+			Messages: []OpenAIMessage{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": "What is this?"},
+					map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0K"}},
+				}},
+				{Role: "assistant", Content: "A cat."},
+			},
+		}
+
+		body, err := service.transformToBedrock(req)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"messages": [
+				{"role": "user", "content": [{"text": "What is this?"}, {"image": {"format": "png", "source": {"bytes": "iVBORw0K"}}}]},
+				{"role": "assistant", "content": [{"text": "A cat."}]}
+			],
+			"system": [{"text": "Be brief."}],
+			"inferenceConfig": {"maxTokens": 256, "stopSequences": ["END"]}
+		}`, string(body))
+	})
+
+	t.Run("maps tools, tool choice and merged tool results", func(t *testing.T) {
+		req := &OpenAIChatRequest{
+			Tools: []OpenAITool{{
+				Type: "function",
+				Function: OpenAIFunctionDefinition{
+					Name:       "get_weather",
+					Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+				},
+			}},
+			ToolChoice: "required",
+			Messages: []OpenAIMessage{
+				{Role: "user", Content: "Weather in Paris and Rome?"},
+				{Role: "assistant", ToolCalls: []OpenAIToolCall{
+					{ID: "tooluse_1", Type: "function", Function: OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+					{ID: "tooluse_2", Type: "function", Function: OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+				}},
+				{Role: "tool", ToolCallID: "tooluse_1", Content: "18C"},
+				{Role: "tool", ToolCallID: "tooluse_2", Content: "24C"},
+			},
+		}
+
+		body, err := service.transformToBedrock(req)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"messages": [
+				{"role": "user", "content": [{"text": "Weather in Paris and Rome?"}]},
+				{"role": "assistant", "content": [
+					{"toolUse": {"toolUseId": "tooluse_1", "name": "get_weather", "input": {"city": "Paris"}}},
+					{"toolUse": {"toolUseId": "tooluse_2", "name": "get_weather", "input": {"city": "Rome"}}}
+				]},
+				{"role": "user", "content": [
+					{"toolResult": {"toolUseId": "tooluse_1", "content": [{"text": "18C"}]}},
+					{"toolResult": {"toolUseId": "tooluse_2", "content": [{"text": "24C"}]}}
+				]}
+			],
+			"toolConfig": {
+				"tools": [{"toolSpec": {"name": "get_weather", "inputSchema": {"json": {"type": "object", "properties": {"city": {"type": "string"}}}}}}],
+				"toolChoice": {"any": {}}
+			}
+		}`, string(body))
+	})
+
+	t.Run("drops tools when tool_choice is none", func(t *testing.T) {
+		req := &OpenAIChatRequest{
+			Tools:      []OpenAITool{{Type: "function", Function: OpenAIFunctionDefinition{Name: "get_weather"}}},
+			ToolChoice: "none",
+			Messages:   []OpenAIMessage{{Role: "user", Content: "Hi"}},
+		}
+
+		body, err := service.transformToBedrock(req)
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), "toolConfig")
+	})
+
+	t.Run("rejects remote image URLs", func(t *testing.T) {
+		req := &OpenAIChatRequest{
+			Messages: []OpenAIMessage{{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/cat.png"}},
+			}}},
+		}
+
+		_, err := service.transformToBedrock(req)
+		assert.ErrorContains(t, err, "data URIs")
+	})
+}
+
+func TestProxyService_TransformBedrockResponse(t *testing.T) {
+	service := &ProxyService{}
+
+	t.Run("converts text and usage", func(t *testing.T) {
+		body, err := service.transformBedrockResponse([]byte(`{
+			"output": {"message": {"role": "assistant", "content": [{"reasoningContent": {"reasoningText": {"text": "hmm"}}}, {"text": "Hello!"}]}},
+			"stopReason": "end_turn",
+			"usage": {"inputTokens": 6, "outputTokens": 3, "totalTokens": 13, "cacheReadInputTokens": 4}
+		}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "Hello!", chatResp.Choices[0].Message.Content)
+		assert.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+		assert.Equal(t, 10, chatResp.Usage.PromptTokens)
+		assert.Equal(t, 3, chatResp.Usage.CompletionTokens)
+		assert.Equal(t, 13, chatResp.Usage.TotalTokens)
+		assert.Equal(t, 4, chatResp.Usage.PromptTokensDetails.CachedTokens)
+	})
+
+	t.Run("converts tool use", func(t *testing.T) {
+		body, err := service.transformBedrockResponse([]byte(`{
+			"output": {"message": {"role": "assistant", "content": [{"toolUse": {"toolUseId": "tooluse_1", "name": "get_weather", "input": {"city": "Paris"}}}]}},
+			"stopReason": "tool_use"
+		}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		assert.Nil(t, chatResp.Choices[0].Message.Content)
+		assert.Equal(t, "tool_calls", chatResp.Choices[0].FinishReason)
+		require.Len(t, chatResp.Choices[0].Message.ToolCalls, 1)
+		assert.Equal(t, "tooluse_1", chatResp.Choices[0].Message.ToolCalls[0].ID)
+		assert.JSONEq(t, `{"city":"Paris"}`, chatResp.Choices[0].Message.ToolCalls[0].Function.Arguments)
+	})
+
+	t.Run("converts errors to the OpenAI shape", func(t *testing.T) {
+		body := transformBedrockError(http.StatusBadRequest, []byte(`{"message":"The provided model identifier is invalid."}`))
+		assert.JSONEq(t, `{"error":{"message":"The provided model identifier is invalid.","type":"invalid_request_error","code":null}}`, string(body))
+	})
+}
+
+func TestBedrockStreamTranslator(t *testing.T) {
+	translate := func(translator *bedrockStreamTranslator, eventType, data string) string {
+		var out strings.Builder
+		for _, ev := range translator.Translate(&sseEvent{Event: eventType, Data: data}) {
+			out.WriteString(ev.Data + "\n")
+		}
+		return out.String()
+	}
+
+	t.Run("streams tool calls with their arguments", func(t *testing.T) {
+		translator := newBedrockStreamTranslator(false)
+		translate(translator, "messageStart", `{"role":"assistant"}`)
+		translate(translator, "contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Checking."}}`)
+
+		out := translate(translator, "contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"tooluse_1","name":"get_weather"}}}`)
+		assert.Contains(t, out, `"tool_calls":[{"index":0,"id":"tooluse_1","type":"function","function":{"name":"get_weather","arguments":""}}]`)
+
+		out = translate(translator, "contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"city\":"}}}`)
+		assert.Contains(t, out, `"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]`)
+
+		out = translate(translator, "messageStop", `{"stopReason":"tool_use"}`)
+		assert.Contains(t, out, `"finish_reason":"tool_calls"`)
+	})
+
+	t.Run("ends the stream on an exception", func(t *testing.T) {
+		translator := newBedrockStreamTranslator(true)
+		out := translate(translator, "exception", `{"type":"throttlingException","message":"Too many requests"}`)
+		assert.Contains(t, out, `"code":"throttlingException"`)
+		assert.True(t, strings.HasSuffix(out, "[DONE]\n"))
+		assert.Empty(t, translator.Finish())
+	})
+}
+
+func TestProxyService_BedrockChat(t *testing.T) {
+	// createBedrockTestKey points a Bedrock provider with test credentials at the upstream server
+	createBedrockTestKey := func(t *testing.T, db *gorm.DB, baseURL string) *models.ProxyAPIKey {
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeBedrock, baseURL)
+		provider := proxyKey.AllowedProviders[0].Provider
+		provider.AWSAccessKeyID = "AKIDEXAMPLE"
+		provider.AWSRegion = "us-west-2"
+		return proxyKey
+	}
+
+	// verifySignature re-signs the received request and compares the result with its Authorization header
+	verifySignature := func(t *testing.T, r *http.Request, body []byte) {
+		signedAt, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		require.NoError(t, err)
+
+		received := r.Header.Get("Authorization")
+		signAWSRequest(r, body, awsCredentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "upstream-key",
+			Region:          "us-west-2",
+			Service:         "bedrock",
+		}, signedAt)
+		assert.Equal(t, r.Header.Get("Authorization"), received)
+		assert.Contains(t, received, "Credential=AKIDEXAMPLE/"+signedAt.Format("20060102")+"/us-west-2/bedrock/aws4_request")
+	}
+
+	t.Run("signs a Converse request and translates the response", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/model/anthropic.claude-3-5-haiku-20241022-v1%3A0/converse", r.URL.EscapedPath())
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			verifySignature(t, r, body)
+			assert.Contains(t, string(body), `"messages":[{"role":"user","content":[{"text":"Hi"}]}]`)
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"output":{"message":{"role":"assistant","content":[{"text":"Hi!"}]}},"stopReason":"end_turn","usage":{"inputTokens":6,"outputTokens":2,"totalTokens":8}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createBedrockTestKey(t, db, upstream.URL)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"anthropic.claude-3-5-haiku-20241022-v1:0","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&chatResp))
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "Hi!", chatResp.Choices[0].Message.Content)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.InputTokens == 6 && record.OutputTokens == 2
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("decodes a ConverseStream event stream into SSE", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/model/meta.llama3-8b-instruct-v1%3A0/converse-stream", r.URL.EscapedPath())
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			verifySignature(t, r, body)
+
+			var stream bytes.Buffer
+			stream.Write(encodeBedrockEvent("messageStart", `{"role":"assistant"}`))
+			stream.Write(encodeBedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hel"}}`))
+			stream.Write(encodeBedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"lo"}}`))
+			stream.Write(encodeBedrockEvent("contentBlockStop", `{"contentBlockIndex":0}`))
+			stream.Write(encodeBedrockEvent("messageStop", `{"stopReason":"end_turn"}`))
+			stream.Write(encodeBedrockEvent("metadata", `{"usage":{"inputTokens":9,"outputTokens":2,"totalTokens":11},"metrics":{"latencyMs":120}}`))
+
+			w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+			w.Write(stream.Bytes())
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createBedrockTestKey(t, db, upstream.URL)
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"meta.llama3-8b-instruct-v1:0","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"object":"chat.completion.chunk"`)
+		assert.Contains(t, string(body), `"content":"Hel"`)
+		assert.Contains(t, string(body), `"finish_reason":"stop"`)
+		assert.Contains(t, string(body), `"total_tokens":11`)
+		assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+
+		result := <-results
+		assert.Equal(t, 9, result.InputTokens)
+		assert.Equal(t, 2, result.OutputTokens)
+	})
+}
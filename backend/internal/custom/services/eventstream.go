@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxEventStreamMessageSize bounds a single event-stream message (AWS caps them at 16 MB)
+const maxEventStreamMessageSize = 16 * 1024 * 1024
+
+// awsEventStreamReader decodes the binary application/vnd.amazon.eventstream framing that Bedrock
+// streams in. Each message becomes an event named by its :event-type header with the JSON payload
+// as Data. Exception messages become "exception" events whose Data is {"type": ..., "message": ...}.
+type awsEventStreamReader struct {
+	r io.Reader
+}
+
+// Next returns the next message, or io.EOF once the stream has ended
+func (r *awsEventStreamReader) Next() (*sseEvent, error) {
+	// Prelude: total length, headers length, prelude CRC (all big-endian uint32)
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r.r, prelude); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated event stream message")
+		}
+		return nil, err
+	}
+	totalLength := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("event stream prelude checksum mismatch")
+	}
+	if totalLength < 16 || totalLength > maxEventStreamMessageSize || headersLength > totalLength-16 {
+		return nil, fmt.Errorf("invalid event stream message length")
+	}
+
+	message := make([]byte, totalLength)
+	copy(message, prelude)
+	if _, err := io.ReadFull(r.r, message[12:]); err != nil {
+		return nil, fmt.Errorf("truncated event stream message")
+	}
+	if crc32.ChecksumIEEE(message[:totalLength-4]) != binary.BigEndian.Uint32(message[totalLength-4:]) {
+		return nil, fmt.Errorf("event stream message checksum mismatch")
+	}
+
+	headers, err := parseEventStreamHeaders(message[12 : 12+headersLength])
+	if err != nil {
+		return nil, err
+	}
+	payload := message[12+headersLength : totalLength-4]
+
+	switch headers[":message-type"] {
+	case "exception":
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(payload, &body)
+		return eventStreamException(headers[":exception-type"], body.Message, message), nil
+	case "error":
+		return eventStreamException(headers[":error-code"], headers[":error-message"], message), nil
+	default:
+		return &sseEvent{Event: headers[":event-type"], Data: string(payload), Raw: message}, nil
+	}
+}
+
+// eventStreamException builds the "exception" event for an exception or error message
+func eventStreamException(exceptionType, message string, raw []byte) *sseEvent {
+	data, _ := json.Marshal(map[string]string{"type": exceptionType, "message": message})
+	return &sseEvent{Event: "exception", Data: string(data), Raw: raw}
+}
+
+// parseEventStreamHeaders returns a message's string-valued headers; other value types are skipped
+func parseEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(b) > 0 {
+		nameLength := int(b[0])
+		if len(b) < 1+nameLength+1 {
+			return nil, fmt.Errorf("invalid event stream header")
+		}
+		name := string(b[1 : 1+nameLength])
+		valueType := b[1+nameLength]
+		b = b[2+nameLength:]
+
+		// Value sizes by type: bool true/false, byte, short, int, long, bytes, string, timestamp, uuid
+		var size int
+		switch valueType {
+		case 0, 1:
+			size = 0
+		case 2:
+			size = 1
+		case 3:
+			size = 2
+		case 4:
+			size = 4
+		case 5, 8:
+			size = 8
+		case 9:
+			size = 16
+		case 6, 7:
+			if len(b) < 2 {
+				return nil, fmt.Errorf("invalid event stream header")
+			}
+			size = 2 + int(binary.BigEndian.Uint16(b[:2]))
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d", valueType)
+		}
+		if len(b) < size {
+			return nil, fmt.Errorf("invalid event stream header")
+		}
+		if valueType == 7 {
+			headers[name] = string(b[2:size])
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
+
+// isAWSEventStream reports whether an upstream response uses AWS event-stream framing
+func isAWSEventStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "application/vnd.amazon.eventstream")
+}
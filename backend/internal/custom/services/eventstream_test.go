@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeEventStreamMessage frames a payload as an AWS event-stream message with string headers
+func encodeEventStreamMessage(headers map[string]string, payload string) []byte {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headerBytes bytes.Buffer
+	for _, name := range names {
+		headerBytes.WriteByte(byte(len(name)))
+		headerBytes.WriteString(name)
+		headerBytes.WriteByte(7)
+		binary.Write(&headerBytes, binary.BigEndian, uint16(len(headers[name])))
+		headerBytes.WriteString(headers[name])
+	}
+
+	totalLength := 12 + headerBytes.Len() + len(payload) + 4
+	message := make([]byte, 0, totalLength)
+	message = binary.BigEndian.AppendUint32(message, uint32(totalLength))
+	message = binary.BigEndian.AppendUint32(message, uint32(headerBytes.Len()))
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message[:8]))
+	message = append(message, headerBytes.Bytes()...)
+	message = append(message, payload...)
+	return binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+}
+
+// encodeBedrockEvent frames a ConverseStream event
+func encodeBedrockEvent(eventType, payload string) []byte {
+	return encodeEventStreamMessage(map[string]string{
+		":event-type":   eventType,
+		":content-type": "application/json",
+		":message-type": "event",
+	}, payload)
+}
+
+func TestAWSEventStreamReader(t *testing.T) {
+	t.Run("decodes events named by their event type", func(t *testing.T) {
+		var stream bytes.Buffer
+		stream.Write(encodeBedrockEvent("messageStart", `{"role":"assistant"}`))
+		stream.Write(encodeBedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hi"}}`))
+
+		reader := &awsEventStreamReader{r: &stream}
+
+		ev, err := reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "messageStart", ev.Event)
+		assert.Equal(t, `{"role":"assistant"}`, ev.Data)
+
+		ev, err = reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "contentBlockDelta", ev.Event)
+		assert.Equal(t, `{"contentBlockIndex":0,"delta":{"text":"Hi"}}`, ev.Data)
+
+		_, err = reader.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("turns exception messages into exception events", func(t *testing.T) {
+		message := encodeEventStreamMessage(map[string]string{
+			":exception-type": "throttlingException",
+			":content-type":   "application/json",
+			":message-type":   "exception",
+		}, `{"message":"Too many requests"}`)
+
+		ev, err := (&awsEventStreamReader{r: bytes.NewReader(message)}).Next()
+		require.NoError(t, err)
+		assert.Equal(t, "exception", ev.Event)
+		assert.JSONEq(t, `{"type":"throttlingException","message":"Too many requests"}`, ev.Data)
+	})
+
+	t.Run("rejects corrupted messages", func(t *testing.T) {
+		message := encodeBedrockEvent("messageStart", `{"role":"assistant"}`)
+		message[len(message)-6] ^= 0xff
+
+		_, err := (&awsEventStreamReader{r: bytes.NewReader(message)}).Next()
+		assert.ErrorContains(t, err, "checksum mismatch")
+	})
+
+	t.Run("rejects truncated messages", func(t *testing.T) {
+		message := encodeBedrockEvent("messageStart", `{"role":"assistant"}`)
+
+		_, err := (&awsEventStreamReader{r: bytes.NewReader(message[:len(message)-3])}).Next()
+		assert.ErrorContains(t, err, "truncated")
+	})
+}
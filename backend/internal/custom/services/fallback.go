@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// ProviderHeader names the provider that produced the response sent to the client
+const ProviderHeader = "X-SmoothLLM-Provider"
+
+// maxFallbackDrainSize bounds how much of a failed response is read before its connection is reused
+const maxFallbackDrainSize = 64 * 1024
+
+// providerTarget is one step of a fallback chain: a provider and the model to request from it
+type providerTarget struct {
+	Provider *models.Provider
+	Model    string
+}
+
+// GetProviderChain returns the providers to try for a model, in order. The provider picked by the
+// key's routing strategy comes first; with fallback enabled on the key the other eligible providers
+// follow, and then the same again for each fallback model: an alias's own fallbacks first, then
+// the key's fallback models for the requested model. Providers with an open circuit are left out.
+func (s *ProxyService) GetProviderChain(proxyKey *models.ProxyAPIKey, modelName string) ([]providerTarget, error) {
+	resolved, alias := s.ResolveModelAlias(proxyKey, modelName)
+	candidates := []string{resolved}
+	if alias != nil {
+		candidates = append(candidates, alias.Fallbacks...)
+	}
+	candidates = append(candidates, proxyKey.FallbackModels[modelName]...)
+
+	var chain []providerTarget
+	seen := make(map[providerTarget]bool)
+	circuitOpen := false
+	for _, model := range candidates {
+		eligible := s.eligibleProviders(proxyKey, model)
+		providers := s.balancer.order(proxyKey, model, eligible)
+		if len(providers) < len(eligible) {
+			circuitOpen = true
+		}
+		for _, provider := range providers {
+			target := providerTarget{Provider: provider, Model: model}
+			if !seen[target] {
+				seen[target] = true
+				chain = append(chain, target)
+			}
+			if !proxyKey.FallbackEnabled {
+				break
+			}
+		}
+	}
+
+	if len(chain) == 0 {
+		if circuitOpen {
+			return nil, fmt.Errorf("%w for model %s: every provider is failing, retry later", ErrCircuitOpen, modelName)
+		}
+		return nil, fmt.Errorf("no allowed provider found for model: %s", modelName)
+	}
+	return chain, nil
+}
+
+// sendWithFallback calls send for each target in turn until one answers with something other than
+// a connection error, a timeout, a 429 or a 5xx, after retrying each under its provider's retry
+// policy. Each failed target is recorded in usage against its own provider; the last target's
+// response (or error) is returned unchanged so the client sees the real upstream failure once the
+// chain is exhausted. result is reset between attempts.
+func (s *ProxyService) sendWithFallback(c *gin.Context, proxyKey *models.ProxyAPIKey, chain []providerTarget, result *ProxyResult, send func(target providerTarget) (*http.Response, error)) (*http.Response, *models.Provider, error) {
+	for i, target := range chain {
+		*result = ProxyResult{Model: target.Model, Alias: result.Alias}
+		attemptStart := time.Now()
+
+		resp, err := s.sendWithRetries(c, target.Provider, result, func() (*http.Response, error) {
+			return send(target)
+		})
+		failed := c.Request.Context().Err() == nil && isUpstreamFailure(resp, err, result)
+		// Nothing was sent if the provider's circuit closed to this request in the meantime
+		skipped := errors.Is(err, ErrCircuitOpen)
+
+		if i == len(chain)-1 || !(failed || skipped) {
+			if err != nil {
+				return nil, target.Provider, err
+			}
+			c.Header(ProviderHeader, target.Provider.Name)
+			return resp, target.Provider, nil
+		}
+
+		if skipped {
+			continue
+		}
+
+		// Record the failed attempt, then release its connection before trying the next provider
+		if err == nil {
+			result.StatusCode = resp.StatusCode
+			result.ErrorMessage = fmt.Sprintf("provider returned status %d, falling back", resp.StatusCode)
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxFallbackDrainSize))
+			resp.Body.Close()
+		}
+		result.RequestDuration = time.Since(attemptStart)
+		s.recordUsage(proxyKey, target.Provider, result)
+	}
+
+	// Unreachable with a non-empty chain
+	return nil, nil, fmt.Errorf("no provider to send the request to")
+}
+
+// unsupportedProviderError is returned by sendToChain when no provider in the chain supports the request
+type unsupportedProviderError string
+
+func (e unsupportedProviderError) Error() string { return string(e) }
+
+// sendToChain routes model to its provider chain and sends the request down it with
+// sendWithFallback. Providers for which supports returns false are left out of the chain; if that
+// leaves none, the request fails with 400 naming the first provider and what it can't do (feature).
+// send builds its request afresh for each target, since the body may differ between providers.
+func (s *ProxyService) sendToChain(c *gin.Context, proxyKey *models.ProxyAPIKey, model string, result *ProxyResult, supports func(*models.Provider) bool, feature string, send func(target providerTarget) (*http.Response, error)) (*http.Response, *models.Provider, error) {
+	chain, err := s.GetProviderChain(proxyKey, model)
+	if err != nil {
+		result.StatusCode = routingErrorStatus(err)
+		result.ErrorMessage = err.Error()
+		return nil, nil, err
+	}
+
+	if supports != nil {
+		supported := chain[:0:0]
+		for _, target := range chain {
+			if supports(target.Provider) {
+				supported = append(supported, target)
+			}
+		}
+		if len(supported) == 0 {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = fmt.Sprintf("provider %s does not support %s", chain[0].Provider.Name, feature)
+			return nil, chain[0].Provider, unsupportedProviderError(result.ErrorMessage)
+		}
+		chain = supported
+	}
+
+	return s.sendWithFallback(c, proxyKey, chain, result, send)
+}
+
+// isUpstreamFailure reports whether an attempt failed because of the provider, so that it may be
+// retried on another one. Requests the proxy could not build are not the provider's fault.
+func isUpstreamFailure(resp *http.Response, err error, result *ProxyResult) bool {
+	if err != nil {
+		// doChatRequest and friends report connection failures and timeouts as 502
+		return result.StatusCode == http.StatusBadGateway
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// createFallbackTestKey creates the providers and a proxy key allowed to use them, in order
+func createFallbackTestKey(t *testing.T, db *gorm.DB, fallbackEnabled bool, fallbackModels map[string][]string, providers ...*models.Provider) *models.ProxyAPIKey {
+	selections := make([]ProviderSelection, 0, len(providers))
+	for _, provider := range providers {
+		provider.UserID = 1
+		provider.IsActive = true
+		if provider.APIKey == "" {
+			provider.APIKey = "upstream-key"
+		}
+		require.NoError(t, db.Create(provider).Error)
+		selections = append(selections, ProviderSelection{ProviderID: provider.ID})
+	}
+
+	keyService := NewKeyService(db)
+	created, err := keyService.CreateKey(1, &CreateKeyRequest{
+		Name:             "Fallback Key",
+		AllowedProviders: selections,
+		FallbackEnabled:  fallbackEnabled,
+		FallbackModels:   fallbackModels,
+	})
+	require.NoError(t, err)
+
+	proxyKey, err := keyService.ValidateKey(created.Key)
+	require.NoError(t, err)
+	return proxyKey
+}
+
+// countingUpstream answers every request with status and body, counting the requests it sees
+func countingUpstream(t *testing.T, status int, body string, calls *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyService_GetProviderChain(t *testing.T) {
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+
+	primary := &models.Provider{Name: "Primary", ProviderType: models.ProviderTypeOpenAI}
+	secondary := &models.Provider{Name: "Secondary", ProviderType: models.ProviderTypeOpenAI}
+	claude := &models.Provider{Name: "Claude", ProviderType: models.ProviderTypeAnthropic}
+	proxyKey := createFallbackTestKey(t, db, false, map[string][]string{
+		"gpt-4o": {"anthropic/claude-sonnet-4-5"},
+	}, primary, secondary, claude)
+
+	t.Run("uses only the first provider per model without key fallback", func(t *testing.T) {
+		chain, err := service.GetProviderChain(proxyKey, "gpt-4o")
+		require.NoError(t, err)
+		require.Len(t, chain, 2)
+		assert.Equal(t, "Primary", chain[0].Provider.Name)
+		assert.Equal(t, "gpt-4o", chain[0].Model)
+		assert.Equal(t, "Claude", chain[1].Provider.Name)
+		assert.Equal(t, "anthropic/claude-sonnet-4-5", chain[1].Model)
+	})
+
+	t.Run("tries every eligible provider in key order with key fallback", func(t *testing.T) {
+		withFallback := *proxyKey
+		withFallback.FallbackEnabled = true
+
+		chain, err := service.GetProviderChain(&withFallback, "gpt-4o")
+		require.NoError(t, err)
+		names := make([]string, len(chain))
+		for i, target := range chain {
+			names[i] = target.Provider.Name + ":" + target.Model
+		}
+		assert.Equal(t, []string{
+			"Primary:gpt-4o",
+			"Secondary:gpt-4o",
+			"Claude:gpt-4o",
+			"Claude:anthropic/claude-sonnet-4-5",
+		}, names)
+	})
+
+	t.Run("fails when no provider serves the model", func(t *testing.T) {
+		_, err := service.GetProviderChain(proxyKey, "gemini/gemini-2.5-pro")
+		assert.ErrorContains(t, err, "no allowed provider found")
+	})
+}
+
+func TestProxyService_Fallback(t *testing.T) {
+	chatBody := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`
+	okBody := `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`
+
+	t.Run("retries on the next provider after a 5xx and names it in a header", func(t *testing.T) {
+		var failedCalls, okCalls int32
+		failing := countingUpstream(t, http.StatusServiceUnavailable, `{"error":{"message":"overloaded"}}`, &failedCalls)
+		healthy := countingUpstream(t, http.StatusOK, okBody, &okCalls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, true, nil,
+			&models.Provider{Name: "Primary", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL},
+			&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeOpenAI, BaseURL: healthy.URL},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(chatBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Backup", resp.Header.Get(ProviderHeader))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&failedCalls))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&okCalls))
+
+		// Both attempts are recorded against their own provider
+		assert.Eventually(t, func() bool {
+			var records []models.UsageRecord
+			db.Where("proxy_key_id = ?", proxyKey.ID).Order("status_code").Find(&records)
+			return len(records) == 2 &&
+				records[0].StatusCode == http.StatusOK && records[0].TotalTokens == 4 &&
+				records[1].StatusCode == http.StatusServiceUnavailable && records[0].ProviderID != records[1].ProviderID
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("retries on connection errors and 429s", func(t *testing.T) {
+		var limitedCalls, okCalls int32
+		unreachable := httptest.NewServer(http.NotFoundHandler())
+		unreachable.Close()
+		limited := countingUpstream(t, http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, &limitedCalls)
+		healthy := countingUpstream(t, http.StatusOK, okBody, &okCalls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, true, nil,
+			&models.Provider{Name: "Down", ProviderType: models.ProviderTypeOpenAI, BaseURL: unreachable.URL},
+			&models.Provider{Name: "Limited", ProviderType: models.ProviderTypeOpenAI, BaseURL: limited.URL},
+			&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeOpenAI, BaseURL: healthy.URL},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(chatBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Backup", resp.Header.Get(ProviderHeader))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&limitedCalls))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&okCalls))
+	})
+
+	t.Run("returns client errors without falling back", func(t *testing.T) {
+		var badCalls, okCalls int32
+		rejecting := countingUpstream(t, http.StatusBadRequest, `{"error":{"message":"bad request"}}`, &badCalls)
+		healthy := countingUpstream(t, http.StatusOK, okBody, &okCalls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, true, nil,
+			&models.Provider{Name: "Primary", ProviderType: models.ProviderTypeOpenAI, BaseURL: rejecting.URL},
+			&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeOpenAI, BaseURL: healthy.URL},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(chatBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "Primary", resp.Header.Get(ProviderHeader))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&okCalls))
+	})
+
+	t.Run("returns the last failure once the chain is exhausted", func(t *testing.T) {
+		var calls int32
+		failing := countingUpstream(t, http.StatusBadGateway, `{"error":{"message":"upstream down"}}`, &calls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, true, nil,
+			&models.Provider{Name: "Primary", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL},
+			&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(chatBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Contains(t, string(body), "upstream down")
+		assert.Equal(t, "Backup", resp.Header.Get(ProviderHeader))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("substitutes the fallback model for Messages requests", func(t *testing.T) {
+		var overloadedCalls int32
+		overloaded := countingUpstream(t, 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, &overloadedCalls)
+		var gotModel string
+		openai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			gotModel, _ = req["model"].(string)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, okBody)
+		}))
+		defer openai.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, map[string][]string{
+			"anthropic/claude-sonnet-4-5": {"openai/gpt-4o"},
+		},
+			&models.Provider{Name: "Claude", ProviderType: models.ProviderTypeAnthropic, BaseURL: overloaded.URL},
+			&models.Provider{Name: "OpenAI", ProviderType: models.ProviderTypeOpenAI, BaseURL: openai.URL},
+		)
+		server := newProxyTestServer(t, "/v1/messages", func(c *gin.Context) {
+			service.ProxyAnthropicPassthrough(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json",
+			strings.NewReader(`{"model":"anthropic/claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "OpenAI", resp.Header.Get(ProviderHeader))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&overloadedCalls))
+		assert.Equal(t, "gpt-4o", gotModel)
+		assert.Contains(t, string(body), `"type":"message"`)
+	})
+}
+
+func TestProxyService_FallbackEmbeddings(t *testing.T) {
+	var failedCalls int32
+	failing := countingUpstream(t, http.StatusServiceUnavailable, `{"error":{"message":"overloaded"}}`, &failedCalls)
+	var gotModel string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotModel, _ = req["model"].(string)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1,0.2]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":2,"total_tokens":2}}`)
+	}))
+	defer healthy.Close()
+
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+	proxyKey := createFallbackTestKey(t, db, true, nil,
+		&models.Provider{Name: "Primary", ProviderType: models.ProviderTypeOpenAI, BaseURL: failing.URL},
+		&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeOpenAI, BaseURL: healthy.URL},
+	)
+	server := newProxyTestServer(t, "/v1/embeddings", func(c *gin.Context) {
+		service.ProxyEmbeddings(c, proxyKey)
+	})
+
+	resp, err := http.Post(server.URL+"/v1/embeddings", "application/json",
+		strings.NewReader(`{"model":"openai/text-embedding-3-small","input":"Hi"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Backup", resp.Header.Get(ProviderHeader))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&failedCalls))
+	assert.Equal(t, "text-embedding-3-small", gotModel)
+	assert.Contains(t, string(body), `"embedding"`)
+}
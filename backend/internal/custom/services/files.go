@@ -0,0 +1,358 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// File store limits
+const (
+	DefaultFilesPath      = "./data/files"
+	DefaultMaxFileSize    = 512 << 20           // 512MB, OpenAI's per-file limit
+	minFileExpiry         = time.Hour           // Shortest expires_after OpenAI accepts
+	maxFileExpiry         = 30 * 24 * time.Hour // Longest expires_after OpenAI accepts
+	batchOutputFileExpiry = 30 * 24 * time.Hour // Batch results are kept for 30 days
+	fileCleanupInterval   = time.Hour
+	defaultFileListLimit  = 10000
+	maxFileListLimit      = 10000
+)
+
+var (
+	// ErrFileNotFound is returned when a file does not exist, has expired or belongs to another user
+	ErrFileNotFound = errors.New("file not found")
+	// ErrInvalidFile is wrapped by errors caused by the client's upload
+	ErrInvalidFile = errors.New("invalid file")
+	// ErrFileTooLarge is returned when an upload exceeds the size limit
+	ErrFileTooLarge = errors.New("file is too large")
+)
+
+// uploadPurposes are the purposes clients may upload files with; the rest are generated by the proxy
+var uploadPurposes = map[string]bool{
+	models.FilePurposeAssistants: true,
+	models.FilePurposeBatch:      true,
+	models.FilePurposeFineTune:   true,
+	models.FilePurposeVision:     true,
+	models.FilePurposeUserData:   true,
+	models.FilePurposeEvals:      true,
+}
+
+// FileService stores /v1/files uploads on local disk, scoped to the proxy key's user
+type FileService struct {
+	db          *gorm.DB
+	storagePath string // Base directory; files live in <storagePath>/<userID>/<fileID>
+	maxFileSize int64  // Maximum file size in bytes
+}
+
+// NewFileService creates a new FileService instance. An empty storagePath or non-positive
+// maxFileSize falls back to the defaults.
+func NewFileService(db *gorm.DB, storagePath string, maxFileSize int64) *FileService {
+	if storagePath == "" {
+		storagePath = DefaultFilesPath
+	}
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+
+	return &FileService{
+		db:          db,
+		storagePath: storagePath,
+		maxFileSize: maxFileSize,
+	}
+}
+
+// MaxFileSize returns the largest file an upload may contain, in bytes
+func (s *FileService) MaxFileSize() int64 {
+	return s.maxFileSize
+}
+
+// EnsureStorageDirectory creates the base storage directory
+func (s *FileService) EnsureStorageDirectory() error {
+	if err := os.MkdirAll(s.storagePath, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", s.storagePath, err)
+	}
+	return nil
+}
+
+// UploadFileRequest describes a client upload
+type UploadFileRequest struct {
+	UserID       uint
+	Filename     string
+	Purpose      string
+	Content      io.Reader
+	ExpiresAfter time.Duration // Zero keeps the file until it is deleted
+}
+
+// UploadFile validates and stores a client upload
+func (s *FileService) UploadFile(req *UploadFileRequest) (*models.StoredFile, error) {
+	if !uploadPurposes[req.Purpose] {
+		return nil, fmt.Errorf("%w: unsupported purpose %q", ErrInvalidFile, req.Purpose)
+	}
+	if req.Filename == "" {
+		return nil, fmt.Errorf("%w: filename is required", ErrInvalidFile)
+	}
+	if req.ExpiresAfter != 0 && (req.ExpiresAfter < minFileExpiry || req.ExpiresAfter > maxFileExpiry) {
+		return nil, fmt.Errorf("%w: expires_after must be between %d and %d seconds", ErrInvalidFile,
+			int(minFileExpiry.Seconds()), int(maxFileExpiry.Seconds()))
+	}
+
+	maxSize := s.maxFileSize
+	if req.Purpose == models.FilePurposeBatch {
+		if !strings.HasSuffix(strings.ToLower(req.Filename), ".jsonl") {
+			return nil, fmt.Errorf("%w: batch input files must be .jsonl", ErrInvalidFile)
+		}
+		if maxSize > MaxBatchInputSize {
+			maxSize = MaxBatchInputSize
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAfter > 0 {
+		t := time.Now().Add(req.ExpiresAfter)
+		expiresAt = &t
+	}
+
+	return s.storeFile(req.UserID, req.Filename, req.Purpose, req.Content, maxSize, expiresAt)
+}
+
+// CreateFile stores content generated by the proxy (implements BatchFileStore)
+func (s *FileService) CreateFile(userID uint, filename, purpose string, content []byte) (string, error) {
+	var expiresAt *time.Time
+	if purpose == models.FilePurposeBatchOutput {
+		t := time.Now().Add(batchOutputFileExpiry)
+		expiresAt = &t
+	}
+
+	file, err := s.storeFile(userID, filename, purpose, bytes.NewReader(content), int64(len(content)), expiresAt)
+	if err != nil {
+		return "", err
+	}
+	return file.FileID, nil
+}
+
+// storeFile writes content to disk, enforcing maxSize, and records it
+func (s *FileService) storeFile(userID uint, filename, purpose string, content io.Reader, maxSize int64, expiresAt *time.Time) (*models.StoredFile, error) {
+	userDir := filepath.Join(s.storagePath, fmt.Sprintf("%d", userID))
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	fileID := newResponsesID("file-")
+	filePath := filepath.Join(userDir, fileID)
+
+	out, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	// Read one byte past the limit so oversized uploads are detected without trusting headers
+	written, err := io.Copy(out, io.LimitReader(content, maxSize+1))
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+	if written > maxSize {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, maxSize)
+	}
+
+	file := &models.StoredFile{
+		FileID:    fileID,
+		UserID:    userID,
+		Filename:  filepath.Base(filename),
+		Purpose:   purpose,
+		Bytes:     written,
+		FilePath:  filePath,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.Create(file).Error; err != nil {
+		// Cleanup stored file if database save fails
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to save file record: %w", err)
+	}
+
+	return file, nil
+}
+
+// GetFile retrieves a file's metadata, ensuring it belongs to the user and has not expired
+func (s *FileService) GetFile(userID uint, fileID string) (*models.StoredFile, error) {
+	var file models.StoredFile
+	if err := s.db.Where("file_id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	if file.IsExpired() {
+		return nil, ErrFileNotFound
+	}
+
+	return &file, nil
+}
+
+// ReadFile returns a file's content (implements BatchFileStore)
+func (s *FileService) ReadFile(userID uint, fileID string) ([]byte, error) {
+	file, err := s.GetFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(file.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, nil
+}
+
+// ListFilesParams filters and pages a file listing
+type ListFilesParams struct {
+	Purpose string
+	After   string // File ID to continue after
+	Limit   int
+	Order   string // asc or desc (default) by creation time
+}
+
+// ListFiles lists a user's unexpired files; the boolean result reports whether more follow
+func (s *FileService) ListFiles(userID uint, params *ListFilesParams) ([]models.StoredFile, bool, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultFileListLimit
+	}
+	if limit > maxFileListLimit {
+		limit = maxFileListLimit
+	}
+
+	query := s.db.Where("user_id = ?", userID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	if params.Purpose != "" {
+		query = query.Where("purpose = ?", params.Purpose)
+	}
+
+	ascending := params.Order == "asc"
+	if params.After != "" {
+		after, err := s.GetFile(userID, params.After)
+		if err != nil {
+			return nil, false, err
+		}
+		if ascending {
+			query = query.Where("id > ?", after.ID)
+		} else {
+			query = query.Where("id < ?", after.ID)
+		}
+	}
+	if ascending {
+		query = query.Order("id ASC")
+	} else {
+		query = query.Order("id DESC")
+	}
+
+	var files []models.StoredFile
+	if err := query.Limit(limit + 1).Find(&files).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	return files, hasMore, nil
+}
+
+// DeleteFile removes a file from disk and the database
+func (s *FileService) DeleteFile(userID uint, fileID string) error {
+	file, err := s.GetFile(userID, fileID)
+	if err != nil {
+		return err
+	}
+	return s.removeFile(file)
+}
+
+// removeFile deletes a file's content and record
+func (s *FileService) removeFile(file *models.StoredFile) error {
+	if err := os.Remove(file.FilePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if err := s.db.Delete(file).Error; err != nil {
+		return fmt.Errorf("failed to delete file record: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredFiles removes every file whose expiry has passed and returns how many were removed
+func (s *FileService) DeleteExpiredFiles() (int, error) {
+	var files []models.StoredFile
+	if err := s.db.Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).Find(&files).Error; err != nil {
+		return 0, fmt.Errorf("failed to find expired files: %w", err)
+	}
+
+	removed := 0
+	for i := range files {
+		if err := s.removeFile(&files[i]); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// StartCleanup removes expired files periodically until ctx is cancelled
+func (s *FileService) StartCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(fileCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.DeleteExpiredFiles(); err != nil {
+				log.Printf("Failed to clean up expired files: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// OpenAIFile is a file in the OpenAI /v1/files shape
+type OpenAIFile struct {
+	ID            string  `json:"id"`
+	Object        string  `json:"object"`
+	Bytes         int64   `json:"bytes"`
+	CreatedAt     int64   `json:"created_at"`
+	ExpiresAt     *int64  `json:"expires_at"`
+	Filename      string  `json:"filename"`
+	Purpose       string  `json:"purpose"`
+	Status        string  `json:"status"`
+	StatusDetails *string `json:"status_details"`
+}
+
+// NewOpenAIFile converts a stored file to the OpenAI file object
+func NewOpenAIFile(file *models.StoredFile) *OpenAIFile {
+	return &OpenAIFile{
+		ID:        file.FileID,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt.Unix(),
+		ExpiresAt: unixTime(file.ExpiresAt),
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    "processed",
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+func setupFileTest(t *testing.T, maxFileSize int64) (*gorm.DB, *FileService) {
+	db := setupProxyTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.StoredFile{}))
+
+	service := NewFileService(db, t.TempDir(), maxFileSize)
+	require.NoError(t, service.EnsureStorageDirectory())
+	return db, service
+}
+
+func TestFileService_UploadFile(t *testing.T) {
+	t.Run("stores the file on disk scoped to the user", func(t *testing.T) {
+		_, service := setupFileTest(t, 0)
+
+		file, err := service.UploadFile(&UploadFileRequest{
+			UserID:   1,
+			Filename: "input.jsonl",
+			Purpose:  models.FilePurposeBatch,
+			Content:  strings.NewReader("{}\n"),
+		})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(file.FileID, "file-"))
+		assert.Equal(t, int64(3), file.Bytes)
+		assert.Nil(t, file.ExpiresAt)
+
+		content, err := service.ReadFile(1, file.FileID)
+		require.NoError(t, err)
+		assert.Equal(t, "{}\n", string(content))
+
+		// Other users cannot see the file
+		_, err = service.GetFile(2, file.FileID)
+		assert.ErrorIs(t, err, ErrFileNotFound)
+	})
+
+	t.Run("validates the upload", func(t *testing.T) {
+		_, service := setupFileTest(t, 8)
+
+		tests := []struct {
+			name    string
+			req     UploadFileRequest
+			wantErr error
+		}{
+			{name: "unknown purpose", req: UploadFileRequest{Filename: "a.txt", Purpose: "batch_output"}, wantErr: ErrInvalidFile},
+			{name: "batch input must be JSONL", req: UploadFileRequest{Filename: "a.txt", Purpose: models.FilePurposeBatch}, wantErr: ErrInvalidFile},
+			{name: "expiry too short", req: UploadFileRequest{Filename: "a.txt", Purpose: models.FilePurposeUserData, ExpiresAfter: time.Minute}, wantErr: ErrInvalidFile},
+			{name: "too large", req: UploadFileRequest{Filename: "a.txt", Purpose: models.FilePurposeUserData}, wantErr: ErrFileTooLarge},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.req.UserID = 1
+				tt.req.Content = strings.NewReader("more than eight bytes")
+				_, err := service.UploadFile(&tt.req)
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+
+		// Rejected uploads leave nothing behind
+		entries, err := os.ReadDir(filepath.Join(service.storagePath, "1"))
+		if err == nil {
+			assert.Empty(t, entries)
+		}
+	})
+}
+
+func TestFileService_ListAndDelete(t *testing.T) {
+	_, service := setupFileTest(t, 0)
+
+	var ids []string
+	for _, purpose := range []string{models.FilePurposeUserData, models.FilePurposeBatch, models.FilePurposeUserData} {
+		filename := "doc.txt"
+		if purpose == models.FilePurposeBatch {
+			filename = "input.jsonl"
+		}
+		file, err := service.UploadFile(&UploadFileRequest{UserID: 1, Filename: filename, Purpose: purpose, Content: strings.NewReader("x")})
+		require.NoError(t, err)
+		ids = append(ids, file.FileID)
+	}
+
+	files, hasMore, err := service.ListFiles(1, &ListFilesParams{Limit: 2})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, files, 2)
+	assert.Equal(t, ids[2], files[0].FileID)
+
+	files, _, err = service.ListFiles(1, &ListFilesParams{Purpose: models.FilePurposeUserData, Order: "asc"})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, ids[0], files[0].FileID)
+
+	stored, err := service.GetFile(1, ids[0])
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteFile(1, ids[0]))
+	_, err = os.Stat(stored.FilePath)
+	assert.True(t, os.IsNotExist(err))
+	assert.ErrorIs(t, service.DeleteFile(1, ids[0]), ErrFileNotFound)
+}
+
+func TestFileService_DeleteExpiredFiles(t *testing.T) {
+	db, service := setupFileTest(t, 0)
+
+	fileID, err := service.CreateFile(1, "batch_output.jsonl", models.FilePurposeBatchOutput, []byte("{}\n"))
+	require.NoError(t, err)
+
+	file, err := service.GetFile(1, fileID)
+	require.NoError(t, err)
+	require.NotNil(t, file.ExpiresAt, "batch output files expire")
+
+	// Nothing has expired yet
+	removed, err := service.DeleteExpiredFiles()
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, db.Model(file).Update("expires_at", past).Error)
+
+	_, err = service.GetFile(1, fileID)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	removed, err = service.DeleteExpiredFiles()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, err = os.Stat(file.FilePath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// GeminiSafetySetting overrides Gemini's blocking threshold for one harm category,
+// e.g. {"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_ONLY_HIGH"}
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// geminiRequest represents a generateContent / streamGenerateContent request
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []GeminiSafetySetting   `json:"safetySettings,omitempty"`
+}
+
+// geminiContent is one turn of a Gemini conversation; the role is "user" or "model"
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiPart is a single piece of content; exactly one field is set
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"` // Set on thinking summaries, which are not part of the answer
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// geminiBlob holds inline base64 data such as an image
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// geminiFunctionCall is a tool call made by the model; args is a JSON object
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// geminiFunctionResponse carries a tool result back to the model
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+// geminiTool groups the function declarations offered to the model
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// geminiFunctionDeclaration describes a callable function. parametersJsonSchema takes plain JSON
+// Schema, unlike "parameters" which only accepts Gemini's OpenAPI subset.
+type geminiFunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+// geminiToolConfig controls how the model uses the declared functions
+type geminiToolConfig struct {
+	FunctionCallingConfig geminiFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+// geminiFunctionCallingConfig sets the calling mode (AUTO, ANY, NONE) and optionally restricts the functions
+type geminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+// geminiGenerationConfig holds the sampling parameters
+type geminiGenerationConfig struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"topP,omitempty"`
+	MaxOutputTokens  *int     `json:"maxOutputTokens,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	CandidateCount   *int     `json:"candidateCount,omitempty"`
+	PresencePenalty  *float64 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty"`
+}
+
+// geminiResponse is a generateContent response, and also one event of a streamed one
+type geminiResponse struct {
+	Candidates     []geminiCandidate     `json:"candidates"`
+	PromptFeedback *geminiPromptFeedback `json:"promptFeedback"`
+	UsageMetadata  *geminiUsageMetadata  `json:"usageMetadata"`
+	ModelVersion   string                `json:"modelVersion"`
+	ResponseID     string                `json:"responseId"`
+	Error          *geminiError          `json:"error"`
+}
+
+// geminiCandidate is one generated answer
+type geminiCandidate struct {
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason"`
+	Index        int           `json:"index"`
+}
+
+// geminiPromptFeedback reports why a prompt was blocked before any candidate was generated
+type geminiPromptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+// geminiUsageMetadata reports token counts; thinking tokens are counted separately from the candidates
+type geminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// geminiError is the error body returned by the Gemini API
+type geminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"` // e.g. INVALID_ARGUMENT, RESOURCE_EXHAUSTED
+}
+
+// ToOpenAI converts Gemini usage to OpenAI usage. Thinking tokens are billed as output,
+// as OpenAI does for reasoning tokens.
+func (u *geminiUsageMetadata) ToOpenAI() *OpenAIUsage {
+	completionTokens := u.CandidatesTokenCount + u.ThoughtsTokenCount
+	usage := &OpenAIUsage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: completionTokens,
+		TotalTokens:      u.PromptTokenCount + completionTokens,
+	}
+	if u.CachedContentTokenCount > 0 {
+		usage.PromptTokensDetails = &OpenAIPromptTokenDetails{CachedTokens: u.CachedContentTokenCount}
+	}
+	return usage
+}
+
+// geminiEndpointURL joins a path (e.g. "/models") onto the provider's base URL, adding the
+// v1beta prefix unless the base URL already names an API version
+func geminiEndpointURL(provider *models.Provider, path string) string {
+	baseURL := strings.TrimSuffix(provider.GetBaseURL(), "/")
+	if strings.HasSuffix(baseURL, "/v1beta") || strings.HasSuffix(baseURL, "/v1") {
+		return baseURL + path
+	}
+	return baseURL + "/v1beta" + path
+}
+
+// geminiGenerateURL returns the generateContent URL for a model, or the SSE streaming
+// variant when stream is set
+func geminiGenerateURL(provider *models.Provider, modelName string, stream bool) string {
+	endpoint := geminiEndpointURL(provider, "/models/"+url.PathEscape(modelName))
+	if stream {
+		return endpoint + ":streamGenerateContent?alt=sse"
+	}
+	return endpoint + ":generateContent"
+}
+
+// transformToGemini converts an OpenAI chat request into a Gemini generateContent request.
+// The model and stream flag are part of the URL rather than the body.
+func (s *ProxyService) transformToGemini(req *OpenAIChatRequest) ([]byte, error) {
+	geminiReq := geminiRequest{
+		SafetySettings: req.SafetySettings,
+	}
+
+	config := geminiGenerationConfig{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		MaxOutputTokens:  req.MaxTokens,
+		CandidateCount:   req.N,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	config.StopSequences = stopSequences(req.Stop)
+	if config.Temperature != nil || config.TopP != nil || config.MaxOutputTokens != nil || len(config.StopSequences) > 0 ||
+		config.CandidateCount != nil || config.PresencePenalty != nil || config.FrequencyPenalty != nil {
+		geminiReq.GenerationConfig = &config
+	}
+
+	// Translate tool definitions and tool choice
+	var declarations []geminiFunctionDeclaration
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if string(schema) == "null" {
+			schema = nil
+		}
+		declarations = append(declarations, geminiFunctionDeclaration{
+			Name:                 tool.Function.Name,
+			Description:          tool.Function.Description,
+			ParametersJSONSchema: schema,
+		})
+	}
+	if len(declarations) > 0 {
+		geminiReq.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+		geminiReq.ToolConfig = openAIToolChoiceToGemini(req.ToolChoice)
+	}
+
+	// Tool results only carry the call id; Gemini wants the function name instead
+	toolNames := make(map[string]string)
+
+	for _, msg := range req.Messages {
+		content := msg.GetContentString()
+		switch msg.Role {
+		case "system", "developer":
+			// Gemini takes system prompts as a separate systemInstruction
+			if geminiReq.SystemInstruction == nil {
+				geminiReq.SystemInstruction = &geminiContent{}
+			}
+			geminiReq.SystemInstruction.Parts = append(geminiReq.SystemInstruction.Parts, geminiPart{Text: content})
+		case "assistant":
+			var parts []geminiPart
+			if content != "" {
+				parts = append(parts, geminiPart{Text: content})
+			}
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				args := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(args) {
+					args = json.RawMessage(`{}`)
+				}
+				parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Function.Name, Args: args}})
+			}
+			if len(parts) == 0 {
+				parts = []geminiPart{{Text: ""}}
+			}
+			geminiReq.Contents = append(geminiReq.Contents, geminiContent{Role: "model", Parts: parts})
+		case "tool":
+			// The response must be a JSON object; wrap anything else
+			response := json.RawMessage(content)
+			if !strings.HasPrefix(strings.TrimSpace(content), "{") || !json.Valid(response) {
+				response, _ = json.Marshal(map[string]string{"result": content})
+			}
+			part := geminiPart{FunctionResponse: &geminiFunctionResponse{
+				Name:     toolNames[msg.ToolCallID],
+				Response: response,
+			}}
+
+			// Results for the same model turn go back together in a single user turn
+			if last := len(geminiReq.Contents) - 1; last >= 0 && isGeminiFunctionResponseContent(geminiReq.Contents[last]) {
+				geminiReq.Contents[last].Parts = append(geminiReq.Contents[last].Parts, part)
+				continue
+			}
+			geminiReq.Contents = append(geminiReq.Contents, geminiContent{Role: "user", Parts: []geminiPart{part}})
+		default:
+			// user, and other roles mapped to user (e.g., "function" results)
+			parts, err := geminiUserParts(msg.Content)
+			if err != nil {
+				return nil, err
+			}
+			geminiReq.Contents = append(geminiReq.Contents, geminiContent{Role: "user", Parts: parts})
+		}
+	}
+
+	if len(geminiReq.Contents) == 0 {
+		return nil, fmt.Errorf("at least one user or assistant message is required")
+	}
+
+	return json.Marshal(geminiReq)
+}
+
+// isGeminiFunctionResponseContent reports whether a translated turn holds only tool results
+func isGeminiFunctionResponseContent(content geminiContent) bool {
+	return content.Role == "user" && len(content.Parts) > 0 && content.Parts[0].FunctionResponse != nil
+}
+
+// geminiUserParts converts user message content (a string or an array of text and image_url
+// parts) into Gemini parts. Gemini cannot fetch arbitrary URLs, so images must be data URIs.
+func geminiUserParts(content interface{}) ([]geminiPart, error) {
+	items, ok := content.([]interface{})
+	if !ok {
+		text, _ := content.(string)
+		return []geminiPart{{Text: text}}, nil
+	}
+
+	var parts []geminiPart
+	for _, item := range items {
+		p, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		switch p["type"] {
+		case "text":
+			if text, _ := p["text"].(string); text != "" {
+				parts = append(parts, geminiPart{Text: text})
+			}
+		case "image_url":
+			var imageURL string
+			switch v := p["image_url"].(type) {
+			case string:
+				imageURL = v
+			case map[string]interface{}:
+				imageURL, _ = v["url"].(string)
+			}
+
+			mediaType, data, ok := parseImageDataURI(imageURL)
+			if !ok {
+				return nil, fmt.Errorf("gemini providers only accept images as base64 data URIs")
+			}
+			parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: mediaType, Data: data}})
+		}
+	}
+
+	if len(parts) == 0 {
+		parts = []geminiPart{{Text: ""}}
+	}
+	return parts, nil
+}
+
+// openAIToolChoiceToGemini maps OpenAI tool_choice to a Gemini function calling config
+func openAIToolChoiceToGemini(toolChoice interface{}) *geminiToolConfig {
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{Mode: "AUTO"}}
+		case "none":
+			return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{Mode: "NONE"}}
+		case "required":
+			return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{Mode: "ANY"}}
+		}
+	case map[string]interface{}:
+		if fn, ok := v["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return &geminiToolConfig{FunctionCallingConfig: geminiFunctionCallingConfig{
+					Mode:                 "ANY",
+					AllowedFunctionNames: []string{name},
+				}}
+			}
+		}
+	}
+	return nil
+}
+
+// geminiFinishReasonToOpenAI maps a Gemini finishReason to an OpenAI finish_reason
+func geminiFinishReasonToOpenAI(finishReason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	switch finishReason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	default:
+		// STOP, FINISH_REASON_UNSPECIFIED, OTHER, MALFORMED_FUNCTION_CALL
+		return "stop"
+	}
+}
+
+// geminiCandidateMessage splits a candidate's parts into answer text and tool calls, skipping
+// thinking summaries
+func geminiCandidateMessage(content geminiContent) (string, []OpenAIToolCall) {
+	var text strings.Builder
+	var toolCalls []OpenAIToolCall
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = newResponsesID("call_")
+			}
+			arguments := string(part.FunctionCall.Args)
+			if arguments == "" || arguments == "null" {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, OpenAIToolCall{
+				ID:       id,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: part.FunctionCall.Name, Arguments: arguments},
+			})
+		case !part.Thought:
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String(), toolCalls
+}
+
+// transformGeminiResponse converts a Gemini generateContent response into an OpenAI chat.completion
+func (s *ProxyService) transformGeminiResponse(body []byte) ([]byte, error) {
+	var geminiResp geminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return nil, fmt.Errorf("invalid Gemini response: %w", err)
+	}
+
+	chatResp := OpenAIChatResponse{
+		ID:      geminiResp.ResponseID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   geminiResp.ModelVersion,
+		Choices: []OpenAIChoice{},
+	}
+	if chatResp.ID == "" {
+		chatResp.ID = newResponsesID("chatcmpl-")
+	}
+	if geminiResp.UsageMetadata != nil {
+		chatResp.Usage = geminiResp.UsageMetadata.ToOpenAI()
+	}
+
+	for _, candidate := range geminiResp.Candidates {
+		text, toolCalls := geminiCandidateMessage(candidate.Content)
+		message := OpenAIMessage{
+			Role:      "assistant",
+			Content:   text,
+			ToolCalls: toolCalls,
+		}
+		if len(toolCalls) > 0 && text == "" {
+			// OpenAI sends null content on pure tool-call messages
+			message.Content = nil
+		}
+		chatResp.Choices = append(chatResp.Choices, OpenAIChoice{
+			Index:        candidate.Index,
+			Message:      message,
+			FinishReason: geminiFinishReasonToOpenAI(candidate.FinishReason, len(toolCalls) > 0),
+		})
+	}
+
+	// A blocked prompt produces no candidates at all
+	if len(chatResp.Choices) == 0 && geminiResp.PromptFeedback != nil && geminiResp.PromptFeedback.BlockReason != "" {
+		chatResp.Choices = append(chatResp.Choices, OpenAIChoice{
+			Message:      OpenAIMessage{Role: "assistant", Content: ""},
+			FinishReason: "content_filter",
+		})
+	}
+
+	return json.Marshal(chatResp)
+}
+
+// transformGeminiError converts a Gemini error body into the OpenAI error shape.
+// Bodies that are not Gemini errors are returned unchanged.
+func transformGeminiError(body []byte) []byte {
+	var geminiErr struct {
+		Error *geminiError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &geminiErr); err != nil || geminiErr.Error == nil {
+		return body
+	}
+	return openAIErrorPayload(geminiErr.Error.Code, strings.ToLower(geminiErr.Error.Status), geminiErr.Error.Message)
+}
+
+// accumulateGeminiUsage reads usageMetadata from a Gemini response or stream event.
+// Streamed events carry running totals, so the latest one wins.
+func accumulateGeminiUsage(data []byte, result *ProxyResult) {
+	var resp struct {
+		UsageMetadata *geminiUsageMetadata `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.UsageMetadata == nil {
+		return
+	}
+
+	usage := resp.UsageMetadata.ToOpenAI()
+	if usage.TotalTokens == 0 {
+		return
+	}
+	result.InputTokens = usage.PromptTokens
+	result.OutputTokens = usage.CompletionTokens
+	result.TotalTokens = usage.TotalTokens
+}
+
+// geminiStreamTranslator converts Gemini streamGenerateContent events into
+// OpenAI chat.completion.chunk events terminated by data: [DONE]. Gemini has no end-of-stream
+// event, so the usage chunk and [DONE] are sent by Finish once the upstream stream closes.
+type geminiStreamTranslator struct {
+	chatChunkEmitter
+
+	started   bool
+	toolCalls int
+}
+
+// newGeminiStreamTranslator creates a translator; includeUsage adds a final usage chunk
+// as requested by the client's stream_options.include_usage
+func newGeminiStreamTranslator(includeUsage bool) *geminiStreamTranslator {
+	return &geminiStreamTranslator{chatChunkEmitter: newChatChunkEmitter(includeUsage)}
+}
+
+// Translate implements streamTranslator
+func (t *geminiStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	if t.done || ev.Data == "" {
+		return nil
+	}
+
+	var event geminiResponse
+	if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+		return nil
+	}
+
+	if event.Error != nil {
+		// Surface the upstream error in the OpenAI shape, then end the stream
+		t.done = true
+		return []sseEvent{{Data: string(openAIErrorPayload(event.Error.Code, strings.ToLower(event.Error.Status), event.Error.Message))}, {Data: "[DONE]"}}
+	}
+
+	if event.UsageMetadata != nil {
+		t.usage = event.UsageMetadata.ToOpenAI()
+	}
+
+	var events []sseEvent
+	if !t.started {
+		t.started = true
+		if event.ResponseID != "" {
+			t.id = event.ResponseID
+		}
+		t.model = event.ModelVersion
+		empty := ""
+		events = append(events, t.chunk(OpenAIChunkDelta{Role: "assistant", Content: &empty}, nil)...)
+	}
+
+	if len(event.Candidates) == 0 {
+		if event.PromptFeedback != nil && event.PromptFeedback.BlockReason != "" {
+			finishReason := "content_filter"
+			events = append(events, t.chunk(OpenAIChunkDelta{}, &finishReason)...)
+		}
+		return events
+	}
+
+	candidate := event.Candidates[0]
+	text, calls := geminiCandidateMessage(candidate.Content)
+	if text != "" {
+		events = append(events, t.chunk(OpenAIChunkDelta{Content: &text}, nil)...)
+	}
+
+	// Gemini sends each function call whole, so id, name and arguments go out in a single delta
+	if len(calls) > 0 {
+		for i := range calls {
+			index := t.toolCalls
+			t.toolCalls++
+			calls[i].Index = &index
+		}
+		events = append(events, t.chunk(OpenAIChunkDelta{ToolCalls: calls}, nil)...)
+	}
+
+	if candidate.FinishReason != "" {
+		finishReason := geminiFinishReasonToOpenAI(candidate.FinishReason, t.toolCalls > 0)
+		events = append(events, t.chunk(OpenAIChunkDelta{}, &finishReason)...)
+	}
+
+	return events
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyService_TransformToGemini(t *testing.T) {
+	service := &ProxyService{}
+
+	t.Run("maps roles, system instruction, images and generation config", func(t *testing.T) {
+		maxTokens := 256
+		req := &OpenAIChatRequest{
+			MaxTokens: &maxTokens,
+			Stop:      "END",
+			SafetySettings: []GeminiSafetySetting{
+				{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"},
+			},
+			Messages: []OpenAIMessage{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": "What is this?"},
+					map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/jpeg;base64,/9j/4AAQ"}},
+				}},
+				{Role: "assistant", Content: "A cat."},
+			},
+		}
+
+		body, err := service.transformToGemini(req)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"contents": [
+				{"role": "user", "parts": [{"text": "What is this?"}, {"inlineData": {"mimeType": "image/jpeg", "data": "/9j/4AAQ"}}]},
+				{"role": "model", "parts": [{"text": "A cat."}]}
+			],
+			"systemInstruction": {"parts": [{"text": "Be brief."}]},
+			"generationConfig": {"maxOutputTokens": 256, "stopSequences": ["END"]},
+			"safetySettings": [{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_ONLY_HIGH"}]
+		}`, string(body))
+	})
+
+	t.Run("maps tools, tool choice and tool results", func(t *testing.T) {
+		req := &OpenAIChatRequest{
+			Tools: []OpenAITool{{
+				Type: "function",
+				Function: OpenAIFunctionDefinition{
+					Name:       "get_weather",
+					Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"additionalProperties":false}`),
+				},
+			}},
+			ToolChoice: map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather"}},
+			Messages: []OpenAIMessage{
+				{Role: "user", Content: "Weather in Paris and Rome?"},
+				{Role: "assistant", ToolCalls: []OpenAIToolCall{
+					{ID: "call_1", Type: "function", Function: OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+					{ID: "call_2", Type: "function", Function: OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+				}},
+				{Role: "tool", ToolCallID: "call_1", Content: "Sunny"},
+				{Role: "tool", ToolCallID: "call_2", Content: `{"forecast":"Rain"}`},
+			},
+		}
+
+		body, err := service.transformToGemini(req)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"contents": [
+				{"role": "user", "parts": [{"text": "Weather in Paris and Rome?"}]},
+				{"role": "model", "parts": [
+					{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}},
+					{"functionCall": {"name": "get_weather", "args": {"city": "Rome"}}}
+				]},
+				{"role": "user", "parts": [
+					{"functionResponse": {"name": "get_weather", "response": {"result": "Sunny"}}},
+					{"functionResponse": {"name": "get_weather", "response": {"forecast": "Rain"}}}
+				]}
+			],
+			"tools": [{"functionDeclarations": [{
+				"name": "get_weather",
+				"parametersJsonSchema": {"type":"object","properties":{"city":{"type":"string"}},"additionalProperties":false}
+			}]}],
+			"toolConfig": {"functionCallingConfig": {"mode": "ANY", "allowedFunctionNames": ["get_weather"]}}
+		}`, string(body))
+	})
+
+	t.Run("rejects remote image URLs", func(t *testing.T) {
+		req := &OpenAIChatRequest{
+			Messages: []OpenAIMessage{{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/cat.png"}},
+			}}},
+		}
+
+		_, err := service.transformToGemini(req)
+		assert.Error(t, err)
+	})
+}
+
+func TestProxyService_TransformGeminiResponse(t *testing.T) {
+	service := &ProxyService{}
+
+	t.Run("converts text, skipping thoughts, and usage", func(t *testing.T) {
+		body, err := service.transformGeminiResponse([]byte(`{
+			"candidates": [{"content": {"role": "model", "parts": [{"text": "thinking...", "thought": true}, {"text": "Hello!"}]}, "finishReason": "STOP", "index": 0}],
+			"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 3, "thoughtsTokenCount": 7, "cachedContentTokenCount": 4, "totalTokenCount": 20},
+			"modelVersion": "gemini-2.5-flash",
+			"responseId": "resp-1"
+		}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		assert.Equal(t, "resp-1", chatResp.ID)
+		assert.Equal(t, "gemini-2.5-flash", chatResp.Model)
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "Hello!", chatResp.Choices[0].Message.Content)
+		assert.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+		assert.Equal(t, 10, chatResp.Usage.PromptTokens)
+		assert.Equal(t, 10, chatResp.Usage.CompletionTokens)
+		assert.Equal(t, 20, chatResp.Usage.TotalTokens)
+		assert.Equal(t, 4, chatResp.Usage.PromptTokensDetails.CachedTokens)
+	})
+
+	t.Run("converts function calls", func(t *testing.T) {
+		body, err := service.transformGeminiResponse([]byte(`{
+			"candidates": [{"content": {"role": "model", "parts": [{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}]}, "finishReason": "STOP"}]
+		}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		choice := chatResp.Choices[0]
+		assert.Equal(t, "tool_calls", choice.FinishReason)
+		assert.Nil(t, choice.Message.Content)
+		require.Len(t, choice.Message.ToolCalls, 1)
+		assert.True(t, strings.HasPrefix(choice.Message.ToolCalls[0].ID, "call_"))
+		assert.JSONEq(t, `{"city":"Paris"}`, choice.Message.ToolCalls[0].Function.Arguments)
+	})
+
+	t.Run("reports a blocked prompt as content_filter", func(t *testing.T) {
+		body, err := service.transformGeminiResponse([]byte(`{"promptFeedback": {"blockReason": "SAFETY"}, "usageMetadata": {"promptTokenCount": 5, "totalTokenCount": 5}}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "content_filter", chatResp.Choices[0].FinishReason)
+	})
+
+	t.Run("converts errors to the OpenAI shape", func(t *testing.T) {
+		body := transformGeminiError([]byte(`{"error": {"code": 400, "message": "API key not valid.", "status": "INVALID_ARGUMENT"}}`))
+		assert.JSONEq(t, `{"error":{"message":"API key not valid.","type":"invalid_request_error","code":"invalid_argument"}}`, string(body))
+	})
+}
+
+// recorded Gemini streamGenerateContent?alt=sse events used by the stream translation tests
+const geminiStream = "data: {\"candidates\": [{\"content\": {\"parts\": [{\"text\": \"Hel\"}],\"role\": \"model\"},\"index\": 0}],\"usageMetadata\": {\"promptTokenCount\": 9,\"totalTokenCount\": 9},\"modelVersion\": \"gemini-2.5-flash\",\"responseId\": \"abc\"}\r\n\r\n" +
+	"data: {\"candidates\": [{\"content\": {\"parts\": [{\"text\": \"lo\"}],\"role\": \"model\"},\"finishReason\": \"STOP\",\"index\": 0}],\"usageMetadata\": {\"promptTokenCount\": 9,\"candidatesTokenCount\": 2,\"totalTokenCount\": 11},\"modelVersion\": \"gemini-2.5-flash\",\"responseId\": \"abc\"}\r\n\r\n"
+
+func TestProxyService_GeminiChat(t *testing.T) {
+	t.Run("translates a buffered response and records usage", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1beta/models/gemini-2.5-flash:generateContent", r.URL.Path)
+			assert.Equal(t, "upstream-key", r.Header.Get("x-goog-api-key"))
+			assert.Empty(t, r.Header.Get("Authorization"))
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hi!"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":6,"candidatesTokenCount":2,"totalTokenCount":8}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeGemini, upstream.URL)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gemini/gemini-2.5-flash","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&chatResp))
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "Hi!", chatResp.Choices[0].Message.Content)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.InputTokens == 6 && record.OutputTokens == 2
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("translates a stream", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1beta/models/gemini-2.5-flash:streamGenerateContent", r.URL.Path)
+			assert.Equal(t, "sse", r.URL.Query().Get("alt"))
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, geminiStream)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeGemini, upstream.URL)
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gemini-2.5-flash","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"object":"chat.completion.chunk"`)
+		assert.Contains(t, string(body), `"content":"Hel"`)
+		assert.Contains(t, string(body), `"finish_reason":"stop"`)
+		assert.Contains(t, string(body), `"total_tokens":11`)
+		assert.NotContains(t, string(body), "usageMetadata")
+		assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+
+		result := <-results
+		assert.Equal(t, 9, result.InputTokens)
+		assert.Equal(t, 2, result.OutputTokens)
+	})
+}
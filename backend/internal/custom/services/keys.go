@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -38,6 +39,12 @@ type KeyResponse struct {
 	UpdatedAt  time.Time  `json:"updated_at"`
 	// Allowed providers for this key
 	AllowedProviders []AllowedProviderResponse `json:"allowed_providers"`
+	// Fallback behaviour when a provider fails
+	FallbackEnabled bool                `json:"fallback_enabled"`
+	FallbackModels  map[string][]string `json:"fallback_models"`
+	// How requests are spread across providers serving the same model
+	RoutingStrategy        string            `json:"routing_strategy"`
+	ModelRoutingStrategies map[string]string `json:"model_routing_strategies"`
 }
 
 type AllowedProviderResponse struct {
@@ -45,6 +52,7 @@ type AllowedProviderResponse struct {
 	ProviderName string   `json:"provider_name"`
 	ProviderType string   `json:"provider_type"`
 	Models       []string `json:"models"`
+	Weight       int      `json:"weight"`
 }
 
 // KeyCreateResponse is returned when a key is created, includes the full key once
@@ -57,11 +65,17 @@ type CreateKeyRequest struct {
 	Name             string              `json:"name"`
 	ExpiresAt        *time.Time          `json:"expires_at,omitempty"`
 	AllowedProviders []ProviderSelection `json:"allowed_providers" binding:"required,min=1"`
+	FallbackEnabled  bool                `json:"fallback_enabled"`
+	FallbackModels   map[string][]string `json:"fallback_models,omitempty"`
+	RoutingStrategy  string              `json:"routing_strategy,omitempty"`
+	// Per-model overrides of RoutingStrategy
+	ModelRoutingStrategies map[string]string `json:"model_routing_strategies,omitempty"`
 }
 
 type ProviderSelection struct {
 	ProviderID uint     `json:"provider_id" binding:"required"`
-	Models     []string `json:"models"` // If empty, all models are allowed
+	Models     []string `json:"models"`           // If empty, all models are allowed
+	Weight     int      `json:"weight,omitempty"` // Share of traffic under weighted routing, default 1
 }
 
 type UpdateKeyRequest struct {
@@ -69,6 +83,11 @@ type UpdateKeyRequest struct {
 	IsActive         *bool               `json:"is_active,omitempty"`
 	ExpiresAt        *time.Time          `json:"expires_at,omitempty"`
 	AllowedProviders []ProviderSelection `json:"allowed_providers,omitempty"`
+	FallbackEnabled  *bool               `json:"fallback_enabled,omitempty"`
+	FallbackModels   map[string][]string `json:"fallback_models,omitempty"` // Replaces the whole map; send {} to clear
+	RoutingStrategy  *string             `json:"routing_strategy,omitempty"`
+	// Replaces the whole map; send {} to clear
+	ModelRoutingStrategies map[string]string `json:"model_routing_strategies,omitempty"`
 }
 
 func (s *KeyService) ListKeys(userID uint) ([]KeyResponse, error) {
@@ -128,6 +147,12 @@ func (s *KeyService) CreateKey(userID uint, req *CreateKeyRequest) (*KeyCreateRe
 		Name:      req.Name,
 		IsActive:  true,
 		ExpiresAt: req.ExpiresAt,
+
+		FallbackEnabled: req.FallbackEnabled,
+		FallbackModels:  req.FallbackModels,
+
+		RoutingStrategy:        req.RoutingStrategy,
+		ModelRoutingStrategies: req.ModelRoutingStrategies,
 	}
 
 	if err := s.db.Transaction(func(tx *gorm.DB) error {
@@ -141,6 +166,7 @@ func (s *KeyService) CreateKey(userID uint, req *CreateKeyRequest) (*KeyCreateRe
 				ProxyAPIKeyID: key.ID,
 				ProviderID:    ps.ProviderID,
 				Models:        ps.Models,
+				Weight:        providerWeight(ps.Weight),
 			}
 			if err := tx.Create(&ap).Error; err != nil {
 				return err
@@ -173,6 +199,10 @@ func (s *KeyService) UpdateKey(userID, keyID uint, req *UpdateKeyRequest) (*KeyR
 		return nil, err
 	}
 
+	if err := validateProviderWeights(req.AllowedProviders); err != nil {
+		return nil, err
+	}
+
 	// Build updates map
 	updates := make(map[string]interface{})
 	if req.Name != nil {
@@ -184,6 +214,39 @@ func (s *KeyService) UpdateKey(userID, keyID uint, req *UpdateKeyRequest) (*KeyR
 	if req.ExpiresAt != nil {
 		updates["expires_at"] = req.ExpiresAt
 	}
+	if req.FallbackEnabled != nil {
+		updates["fallback_enabled"] = *req.FallbackEnabled
+	}
+	if req.FallbackModels != nil {
+		if err := validateFallbackModels(req.FallbackModels); err != nil {
+			return nil, err
+		}
+		// Map updates bypass the field's JSON serializer, so encode it here
+		fallbackModels, err := json.Marshal(req.FallbackModels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode fallback models: %w", err)
+		}
+		updates["fallback_models"] = string(fallbackModels)
+	}
+	if req.RoutingStrategy != nil {
+		if err := validateRoutingStrategy(*req.RoutingStrategy); err != nil {
+			return nil, err
+		}
+		updates["routing_strategy"] = *req.RoutingStrategy
+	}
+	if req.ModelRoutingStrategies != nil {
+		for _, strategy := range req.ModelRoutingStrategies {
+			if err := validateRoutingStrategy(strategy); err != nil {
+				return nil, err
+			}
+		}
+		// Map updates bypass the field's JSON serializer, so encode it here
+		modelRoutingStrategies, err := json.Marshal(req.ModelRoutingStrategies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode model routing strategies: %w", err)
+		}
+		updates["model_routing_strategies"] = string(modelRoutingStrategies)
+	}
 
 	if len(updates) > 0 {
 		if err := s.db.Model(key).Updates(updates).Error; err != nil {
@@ -205,6 +268,7 @@ func (s *KeyService) UpdateKey(userID, keyID uint, req *UpdateKeyRequest) (*KeyR
 					ProxyAPIKeyID: keyID,
 					ProviderID:    ps.ProviderID,
 					Models:        ps.Models,
+					Weight:        providerWeight(ps.Weight),
 				}
 				if err := tx.Create(&ap).Error; err != nil {
 					return err
@@ -270,7 +334,7 @@ func (s *KeyService) ValidateKey(fullKey string) (*models.ProxyAPIKey, error) {
 
 	// Look up by hash
 	var key models.ProxyAPIKey
-	if err := s.db.Session(&gorm.Session{NewDB: true}).Preload("AllowedProviders.Provider").Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+	if err := s.db.Session(&gorm.Session{NewDB: true}).Preload("AllowedProviders", orderAllowedProviders).Preload("AllowedProviders.Provider").Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("invalid API key")
 		}
@@ -285,6 +349,13 @@ func (s *KeyService) ValidateKey(fullKey string) (*models.ProxyAPIKey, error) {
 		return nil, fmt.Errorf("API key is inactive")
 	}
 
+	// Aliases are resolved by the proxy when routing the key's requests
+	aliases, err := loadModelAliases(s.db.Session(&gorm.Session{NewDB: true}), key.UserID)
+	if err != nil {
+		return nil, err
+	}
+	key.ModelAliases = aliases
+
 	// Update last used timestamp directly in DB to avoid association side effects
 	key.UpdateLastUsed()
 	s.db.Table("proxy_api_keys").Where("id = ?", key.ID).Update("last_used_at", key.LastUsedAt)
@@ -305,6 +376,33 @@ func (s *KeyService) GetKeyByHash(keyHash string) (*models.ProxyAPIKey, error) {
 	return &key, nil
 }
 
+// GetActiveKey retrieves a valid key by ID with its providers, for background work (such as
+// batches) that runs on behalf of a key after the original request has finished
+func (s *KeyService) GetActiveKey(keyID uint) (*models.ProxyAPIKey, error) {
+	var key models.ProxyAPIKey
+	if err := s.db.Session(&gorm.Session{NewDB: true}).Preload("AllowedProviders", orderAllowedProviders).Preload("AllowedProviders.Provider").First(&key, keyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("key not found")
+		}
+		return nil, fmt.Errorf("failed to get key: %w", err)
+	}
+
+	if !key.IsValid() {
+		if key.IsExpired() {
+			return nil, fmt.Errorf("API key has expired")
+		}
+		return nil, fmt.Errorf("API key is inactive")
+	}
+
+	aliases, err := loadModelAliases(s.db.Session(&gorm.Session{NewDB: true}), key.UserID)
+	if err != nil {
+		return nil, err
+	}
+	key.ModelAliases = aliases
+
+	return &key, nil
+}
+
 // getKeyByID retrieves a key ensuring it belongs to the user
 func (s *KeyService) getKeyByID(userID, keyID uint) (*models.ProxyAPIKey, error) {
 	var key models.ProxyAPIKey
@@ -331,6 +429,11 @@ func (s *KeyService) buildKeyResponse(key *models.ProxyAPIKey) KeyResponse {
 		CreatedAt:        key.CreatedAt,
 		UpdatedAt:        key.UpdatedAt,
 		AllowedProviders: make([]AllowedProviderResponse, 0),
+		FallbackEnabled:  key.FallbackEnabled,
+		FallbackModels:   key.FallbackModels,
+
+		RoutingStrategy:        key.RoutingStrategy,
+		ModelRoutingStrategies: key.ModelRoutingStrategies,
 	}
 
 	// Include allowed providers info
@@ -338,6 +441,7 @@ func (s *KeyService) buildKeyResponse(key *models.ProxyAPIKey) KeyResponse {
 		apr := AllowedProviderResponse{
 			ProviderID: ap.ProviderID,
 			Models:     ap.Models,
+			Weight:     ap.Weight,
 		}
 		if ap.Provider != nil {
 			apr.ProviderName = ap.Provider.Name
@@ -377,9 +481,73 @@ func (s *KeyService) validateCreateRequest(userID uint, req *CreateKeyRequest) e
 		return fmt.Errorf("expiration date must be in the future")
 	}
 
+	if err := validateProviderWeights(req.AllowedProviders); err != nil {
+		return err
+	}
+	if err := validateRoutingStrategy(req.RoutingStrategy); err != nil {
+		return err
+	}
+	for _, strategy := range req.ModelRoutingStrategies {
+		if err := validateRoutingStrategy(strategy); err != nil {
+			return err
+		}
+	}
+
+	return validateFallbackModels(req.FallbackModels)
+}
+
+// validateRoutingStrategy checks a routing strategy name; empty selects the default
+func validateRoutingStrategy(strategy string) error {
+	switch strategy {
+	case "", models.RoutingStrategyPriority, models.RoutingStrategyRoundRobin, models.RoutingStrategyWeighted,
+		models.RoutingStrategyLeastInFlight, models.RoutingStrategyLowestLatency:
+		return nil
+	}
+	return fmt.Errorf("invalid routing strategy: %s", strategy)
+}
+
+// validateProviderWeights rejects negative weights; zero means the default weight
+func validateProviderWeights(selections []ProviderSelection) error {
+	for _, ps := range selections {
+		if ps.Weight < 0 {
+			return fmt.Errorf("weight for provider %d must not be negative", ps.ProviderID)
+		}
+	}
 	return nil
 }
 
+// providerWeight applies the default weight of 1 when none was given
+func providerWeight(weight int) int {
+	if weight <= 0 {
+		return 1
+	}
+	return weight
+}
+
+// validateFallbackModels checks that every fallback chain names real, distinct models
+func validateFallbackModels(fallbackModels map[string][]string) error {
+	for model, fallbacks := range fallbackModels {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("fallback models must be keyed by a model name")
+		}
+		for _, fallback := range fallbacks {
+			if strings.TrimSpace(fallback) == "" {
+				return fmt.Errorf("fallback models for %s must not be empty", model)
+			}
+			if fallback == model {
+				return fmt.Errorf("model %s cannot fall back to itself", model)
+			}
+		}
+	}
+	return nil
+}
+
+// orderAllowedProviders loads a key's providers in the order they were added, which is the
+// order the proxy tries them in
+func orderAllowedProviders(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
 // generateKey generates a new secure API key with the standard prefix
 func (s *KeyService) generateKey() (string, error) {
 	// Generate 32 random bytes (256 bits of entropy)
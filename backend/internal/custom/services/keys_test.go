@@ -21,7 +21,7 @@ func setupKeyTestDB(t *testing.T) *gorm.DB {
 	require.NoError(t, err)
 
 	// Auto-migrate the required tables
-	err = db.AutoMigrate(&models.Provider{}, &models.ProxyAPIKey{}, &models.KeyAllowedProvider{})
+	err = db.AutoMigrate(&models.Provider{}, &models.ProxyAPIKey{}, &models.KeyAllowedProvider{}, &models.ModelAlias{})
 	require.NoError(t, err)
 
 	return db
@@ -307,6 +307,69 @@ func TestKeyService_CreateKey(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, result.ExpiresAt)
 	})
+
+	t.Run("creates key with fallback chains", func(t *testing.T) {
+		db := setupKeyTestDB(t)
+		service := NewKeyService(db)
+		provider := createTestProvider(t, db, 1)
+
+		result, err := service.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders: []ProviderSelection{{ProviderID: provider.ID}},
+			FallbackEnabled:  true,
+			FallbackModels:   map[string][]string{"gpt-4o": {"gpt-4o-mini"}},
+		})
+		require.NoError(t, err)
+		assert.True(t, result.FallbackEnabled)
+		assert.Equal(t, []string{"gpt-4o-mini"}, result.FallbackModels["gpt-4o"])
+	})
+
+	t.Run("creates key with routing strategy and weights", func(t *testing.T) {
+		db := setupKeyTestDB(t)
+		service := NewKeyService(db)
+		provider := createTestProvider(t, db, 1)
+		other := createTestProvider(t, db, 1)
+
+		result, err := service.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders:       []ProviderSelection{{ProviderID: provider.ID, Weight: 3}, {ProviderID: other.ID}},
+			RoutingStrategy:        models.RoutingStrategyWeighted,
+			ModelRoutingStrategies: map[string]string{"gpt-4o-mini": models.RoutingStrategyRoundRobin},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, models.RoutingStrategyWeighted, result.RoutingStrategy)
+		assert.Equal(t, models.RoutingStrategyRoundRobin, result.ModelRoutingStrategies["gpt-4o-mini"])
+		require.Len(t, result.AllowedProviders, 2)
+		assert.Equal(t, 3, result.AllowedProviders[0].Weight)
+		assert.Equal(t, 1, result.AllowedProviders[1].Weight)
+	})
+
+	t.Run("fails for an unknown routing strategy", func(t *testing.T) {
+		db := setupKeyTestDB(t)
+		service := NewKeyService(db)
+		provider := createTestProvider(t, db, 1)
+
+		_, err := service.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders: []ProviderSelection{{ProviderID: provider.ID}},
+			RoutingStrategy:  "random",
+		})
+		assert.ErrorContains(t, err, "invalid routing strategy")
+
+		_, err = service.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders: []ProviderSelection{{ProviderID: provider.ID, Weight: -1}},
+		})
+		assert.ErrorContains(t, err, "must not be negative")
+	})
+
+	t.Run("fails for a model that falls back to itself", func(t *testing.T) {
+		db := setupKeyTestDB(t)
+		service := NewKeyService(db)
+		provider := createTestProvider(t, db, 1)
+
+		_, err := service.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders: []ProviderSelection{{ProviderID: provider.ID}},
+			FallbackModels:   map[string][]string{"gpt-4o": {"gpt-4o"}},
+		})
+		assert.ErrorContains(t, err, "cannot fall back to itself")
+	})
 }
 
 func TestKeyService_ValidateKey(t *testing.T) {
@@ -521,6 +584,28 @@ func TestKeyService_UpdateKey(t *testing.T) {
 		assert.False(t, updated.IsActive)
 	})
 
+	t.Run("updates fallback settings", func(t *testing.T) {
+		db := setupKeyTestDB(t)
+		service := NewKeyService(db)
+		provider := createTestProvider(t, db, 1)
+
+		created, err := service.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders: []ProviderSelection{{ProviderID: provider.ID}},
+			FallbackModels:   map[string][]string{"gpt-4o": {"gpt-4o-mini"}},
+		})
+		require.NoError(t, err)
+		assert.False(t, created.FallbackEnabled)
+
+		fallbackEnabled := true
+		updated, err := service.UpdateKey(1, created.ID, &UpdateKeyRequest{
+			FallbackEnabled: &fallbackEnabled,
+			FallbackModels:  map[string][]string{"o3": {"o4-mini", "gpt-4o"}},
+		})
+		require.NoError(t, err)
+		assert.True(t, updated.FallbackEnabled)
+		assert.Equal(t, map[string][]string{"o3": {"o4-mini", "gpt-4o"}}, updated.FallbackModels)
+	})
+
 	t.Run("fails for non-existent key", func(t *testing.T) {
 		db := setupKeyTestDB(t)
 		service := NewKeyService(db)
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Multipart upload handling shared by the audio and image endpoints
+const (
+	multipartFormMemory = 32 << 20 // Larger forms spill to temporary files
+	multipartFieldSlack = 1 << 20  // Room for text fields and part headers on top of the file size limit
+)
+
+// LimitMultipartBody caps a multipart request's body at maxSize bytes of files plus room for the
+// other fields, so that parsing a larger upload fails with *http.MaxBytesError
+func LimitMultipartBody(c *gin.Context, maxSize int64) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartFieldSlack)
+}
+
+// parseProxyMultipartForm parses a multipart upload whose files total at most maxSize bytes,
+// filling in result on failure. The caller must call RemoveAll on the returned form.
+func parseProxyMultipartForm(c *gin.Context, maxSize int64, result *ProxyResult) (*multipart.Form, error) {
+	LimitMultipartBody(c, maxSize)
+	if err := c.Request.ParseMultipartForm(multipartFormMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			result.StatusCode = http.StatusRequestEntityTooLarge
+			result.ErrorMessage = "upload is too large"
+			return nil, fmt.Errorf("upload is too large: the limit is %d bytes", maxSize)
+		}
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid multipart form"
+		return nil, fmt.Errorf("failed to parse multipart form: %w", err)
+	}
+	return c.Request.MultipartForm, nil
+}
+
+// rebuildMultipartForm re-encodes a parsed multipart form with the model field replaced
+func rebuildMultipartForm(form *multipart.Form, model string) (*bytes.Buffer, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	if err := writer.WriteField("model", model); err != nil {
+		return nil, "", fmt.Errorf("failed to write form field: %w", err)
+	}
+	for name, values := range form.Value {
+		if name == "model" {
+			continue
+		}
+		// Repeated fields such as timestamp_granularities[] keep every value
+		for _, value := range values {
+			if err := writer.WriteField(name, value); err != nil {
+				return nil, "", fmt.Errorf("failed to write form field: %w", err)
+			}
+		}
+	}
+
+	for name, headers := range form.File {
+		for _, header := range headers {
+			if err := copyFormFile(writer, name, header); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to finish form: %w", err)
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+// formQuoteEscaper escapes Content-Disposition parameters the way mime/multipart does
+var formQuoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// copyFormFile writes one uploaded file into a multipart writer, keeping its original part headers
+func copyFormFile(writer *multipart.Writer, name string, header *multipart.FileHeader) error {
+	src, err := header.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	partHeader := make(map[string][]string, len(header.Header))
+	for key, values := range header.Header {
+		partHeader[key] = values
+	}
+	partHeader["Content-Disposition"] = []string{
+		fmt.Sprintf(`form-data; name="%s"; filename="%s"`, formQuoteEscaper.Replace(name), formQuoteEscaper.Replace(header.Filename)),
+	}
+
+	part, err := writer.CreatePart(partHeader)
+	if err != nil {
+		return fmt.Errorf("failed to write form file: %w", err)
+	}
+	if _, err := io.Copy(part, src); err != nil {
+		return fmt.Errorf("failed to copy uploaded file: %w", err)
+	}
+	return nil
+}
+
+// firstFormValue returns the first value of a multipart form field, or ""
+func firstFormValue(form *multipart.Form, name string) string {
+	if values := form.Value[name]; len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
@@ -196,7 +196,7 @@ func (s *OAuthService) RefreshAccessToken(provider *models.Provider) error {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Transport: upstreamTransport, Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to refresh token: %w", err)
@@ -291,7 +291,7 @@ func (s *OAuthService) exchangeCodeForTokens(code, codeVerifier, redirectURI str
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Transport: upstreamTransport, Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
@@ -395,7 +395,7 @@ func (s *OAuthService) TestOAuthConnection(provider *models.Provider) error {
 	req.Header.Set("Authorization", "Bearer "+provider.AccessToken)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Transport: upstreamTransport, Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("connection failed: %w", err)
@@ -0,0 +1,450 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// ollamaChatRequest represents a request to Ollama's native /api/chat endpoint
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []OpenAITool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"` // Ollama streams unless told otherwise
+	Options  *ollamaOptions  `json:"options,omitempty"`
+}
+
+// ollamaOptions holds the sampling parameters Ollama accepts under "options"
+type ollamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+// ollamaMessage represents a chat message in Ollama's format
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"` // Raw base64, no data: prefix
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // Set on tool results
+}
+
+// ollamaToolCall is a tool call in Ollama's format; arguments are a JSON object, not a string
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaChatResponse is a non-streaming /api/chat response, and also one line of a streamed one
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// usage converts Ollama's evaluation counts to OpenAI usage
+func (r *ollamaChatResponse) usage() *OpenAIUsage {
+	return &OpenAIUsage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// ollamaEndpointURL joins a native API path (e.g. /api/chat) onto the provider's base URL
+func ollamaEndpointURL(provider *models.Provider, path string) string {
+	return strings.TrimSuffix(provider.GetBaseURL(), "/") + path
+}
+
+// transformToOllama converts an OpenAI chat request into an Ollama /api/chat request
+func (s *ProxyService) transformToOllama(req *OpenAIChatRequest, modelName string) ([]byte, error) {
+	ollamaReq := ollamaChatRequest{
+		Model:  modelName,
+		Stream: req.Stream != nil && *req.Stream,
+	}
+
+	options := ollamaOptions{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		NumPredict:       req.MaxTokens,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	options.Stop = stopSequences(req.Stop)
+	if options.Temperature != nil || options.TopP != nil || options.NumPredict != nil || len(options.Stop) > 0 ||
+		options.PresencePenalty != nil || options.FrequencyPenalty != nil {
+		ollamaReq.Options = &options
+	}
+
+	// Ollama has no tool_choice; "none" is honoured by not offering the tools at all
+	if choice, _ := req.ToolChoice.(string); choice != "none" {
+		for _, tool := range req.Tools {
+			if tool.Type != "" && tool.Type != "function" {
+				continue
+			}
+			if len(tool.Function.Parameters) == 0 || string(tool.Function.Parameters) == "null" {
+				tool.Function.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			tool.Type = "function"
+			ollamaReq.Tools = append(ollamaReq.Tools, tool)
+		}
+	}
+
+	// Tool results only carry the call id; Ollama wants the function name instead
+	toolNames := make(map[string]string)
+
+	for _, msg := range req.Messages {
+		converted := ollamaMessage{Role: msg.Role, Content: msg.GetContentString()}
+
+		switch msg.Role {
+		case "system", "developer":
+			converted.Role = "system"
+		case "user":
+			images, err := ollamaImages(msg.Content)
+			if err != nil {
+				return nil, err
+			}
+			converted.Images = images
+		case "assistant":
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+
+				var tc ollamaToolCall
+				tc.Function.Name = call.Function.Name
+				tc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+				if !json.Valid(tc.Function.Arguments) {
+					tc.Function.Arguments = json.RawMessage(`{}`)
+				}
+				converted.ToolCalls = append(converted.ToolCalls, tc)
+			}
+		case "tool":
+			converted.ToolName = toolNames[msg.ToolCallID]
+		default:
+			// Map other roles to user (e.g., "function" results)
+			converted.Role = "user"
+		}
+
+		ollamaReq.Messages = append(ollamaReq.Messages, converted)
+	}
+
+	if len(ollamaReq.Messages) == 0 {
+		return nil, fmt.Errorf("at least one message is required")
+	}
+
+	return json.Marshal(ollamaReq)
+}
+
+// ollamaImages extracts the base64 payloads of image_url parts. Ollama cannot fetch remote
+// images, so only data URIs are accepted.
+func ollamaImages(content interface{}) ([]string, error) {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var images []string
+	for _, part := range parts {
+		p, ok := part.(map[string]interface{})
+		if !ok || p["type"] != "image_url" {
+			continue
+		}
+
+		var url string
+		switch v := p["image_url"].(type) {
+		case string:
+			url = v
+		case map[string]interface{}:
+			url, _ = v["url"].(string)
+		}
+
+		_, data, ok := parseImageDataURI(url)
+		if !ok {
+			return nil, fmt.Errorf("ollama providers only accept images as base64 data URIs")
+		}
+		images = append(images, data)
+	}
+
+	return images, nil
+}
+
+// ollamaToolCallsToOpenAI converts Ollama tool calls, which have no ids, into OpenAI tool calls
+func ollamaToolCallsToOpenAI(calls []ollamaToolCall) []OpenAIToolCall {
+	var toolCalls []OpenAIToolCall
+	for _, call := range calls {
+		arguments := string(call.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		toolCalls = append(toolCalls, OpenAIToolCall{
+			ID:       newResponsesID("call_"),
+			Type:     "function",
+			Function: OpenAIFunctionCall{Name: call.Function.Name, Arguments: arguments},
+		})
+	}
+	return toolCalls
+}
+
+// ollamaDoneReasonToOpenAI maps an Ollama done_reason to an OpenAI finish_reason
+func ollamaDoneReasonToOpenAI(doneReason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	if doneReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// transformOllamaResponse converts an Ollama /api/chat response into an OpenAI chat.completion
+func (s *ProxyService) transformOllamaResponse(body []byte) ([]byte, error) {
+	var ollamaResp ollamaChatResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return nil, fmt.Errorf("invalid Ollama response: %w", err)
+	}
+
+	toolCalls := ollamaToolCallsToOpenAI(ollamaResp.Message.ToolCalls)
+	message := OpenAIMessage{
+		Role:      "assistant",
+		Content:   ollamaResp.Message.Content,
+		ToolCalls: toolCalls,
+	}
+	if len(toolCalls) > 0 && ollamaResp.Message.Content == "" {
+		// OpenAI sends null content on pure tool-call messages
+		message.Content = nil
+	}
+
+	chatResp := OpenAIChatResponse{
+		ID:      newResponsesID("chatcmpl-"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   ollamaResp.Model,
+		Choices: []OpenAIChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: ollamaDoneReasonToOpenAI(ollamaResp.DoneReason, len(toolCalls) > 0),
+			},
+		},
+		Usage: ollamaResp.usage(),
+	}
+
+	return json.Marshal(chatResp)
+}
+
+// transformOllamaError converts an Ollama {"error": "..."} body into the OpenAI error shape.
+// Bodies that are not Ollama errors are returned unchanged.
+func transformOllamaError(statusCode int, body []byte) []byte {
+	var ollamaErr struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &ollamaErr); err != nil || ollamaErr.Error == "" {
+		return body
+	}
+
+	return openAIErrorPayload(statusCode, "", ollamaErr.Error)
+}
+
+// accumulateOllamaUsage reads prompt_eval_count and eval_count, which Ollama only reports on
+// the final (done) response
+func accumulateOllamaUsage(data string, result *ProxyResult) {
+	var resp ollamaChatResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil || !resp.Done {
+		return
+	}
+
+	result.InputTokens = resp.PromptEvalCount
+	result.OutputTokens = resp.EvalCount
+	result.TotalTokens = resp.PromptEvalCount + resp.EvalCount
+}
+
+// ollamaStreamTranslator converts Ollama's newline-delimited /api/chat stream into
+// OpenAI chat.completion.chunk events terminated by data: [DONE]
+type ollamaStreamTranslator struct {
+	chatChunkEmitter
+
+	started   bool
+	toolCalls int
+}
+
+// newOllamaStreamTranslator creates a translator; includeUsage adds a final usage chunk
+// as requested by the client's stream_options.include_usage
+func newOllamaStreamTranslator(includeUsage bool) *ollamaStreamTranslator {
+	return &ollamaStreamTranslator{chatChunkEmitter: newChatChunkEmitter(includeUsage)}
+}
+
+// Translate implements streamTranslator
+func (t *ollamaStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	if t.done || ev.Data == "" {
+		return nil
+	}
+
+	var line ollamaChatResponse
+	if err := json.Unmarshal([]byte(ev.Data), &line); err != nil {
+		return nil
+	}
+
+	if line.Error != "" {
+		// Surface the upstream error in the OpenAI shape, then end the stream
+		t.done = true
+		return []sseEvent{{Data: string(openAIErrorPayload(http.StatusInternalServerError, "", line.Error))}, {Data: "[DONE]"}}
+	}
+
+	var events []sseEvent
+	if !t.started {
+		t.started = true
+		t.model = line.Model
+		empty := ""
+		events = append(events, t.chunk(OpenAIChunkDelta{Role: "assistant", Content: &empty}, nil)...)
+	}
+
+	if line.Message.Content != "" {
+		content := line.Message.Content
+		events = append(events, t.chunk(OpenAIChunkDelta{Content: &content}, nil)...)
+	}
+
+	// Ollama sends each tool call whole, so id, name and arguments go out in a single delta
+	if calls := ollamaToolCallsToOpenAI(line.Message.ToolCalls); len(calls) > 0 {
+		for i := range calls {
+			index := t.toolCalls
+			t.toolCalls++
+			calls[i].Index = &index
+		}
+		events = append(events, t.chunk(OpenAIChunkDelta{ToolCalls: calls}, nil)...)
+	}
+
+	if line.Done {
+		t.usage = line.usage()
+		finishReason := ollamaDoneReasonToOpenAI(line.DoneReason, t.toolCalls > 0)
+		events = append(events, t.chunk(OpenAIChunkDelta{}, &finishReason)...)
+		events = append(events, t.Finish()...)
+	}
+
+	return events
+}
+
+// sendOllamaEmbeddings sends an OpenAI embeddings request (already carrying the provider's model
+// name) to Ollama's /api/embed endpoint
+func (s *ProxyService) sendOllamaEmbeddings(c *gin.Context, provider *models.Provider, embeddingsReq map[string]interface{}, result *ProxyResult, startTime time.Time) (*http.Response, error) {
+	// /api/embed takes text only, not token arrays
+	input := embeddingsReq["input"]
+	if items, ok := input.([]interface{}); ok {
+		for _, item := range items {
+			if _, ok := item.(string); !ok {
+				result.StatusCode = http.StatusBadRequest
+				result.ErrorMessage = "ollama providers only accept text embeddings input"
+				return nil, fmt.Errorf("ollama providers only accept text embeddings input")
+			}
+		}
+	}
+
+	ollamaReq := map[string]interface{}{
+		"model": embeddingsReq["model"],
+		"input": input,
+	}
+	if dimensions, ok := embeddingsReq["dimensions"]; ok {
+		ollamaReq["dimensions"] = dimensions
+	}
+	requestBody, err := json.Marshal(ollamaReq)
+	if err != nil {
+		result.StatusCode = http.StatusInternalServerError
+		result.ErrorMessage = "failed to marshal request"
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	return s.sendOpenAIRequest(c, provider, ollamaEndpointURL(provider, "/api/embed"), "application/json", bytes.NewReader(requestBody), result, startTime)
+}
+
+// writeOllamaEmbeddings answers the client with Ollama's /api/embed response in the OpenAI
+// embeddings format
+func (s *ProxyService) writeOllamaEmbeddings(c *gin.Context, proxyKey *models.ProxyAPIKey, provider *models.Provider, embeddingsReq map[string]interface{}, resp *http.Response, result *ProxyResult, startTime time.Time) (*ProxyResult, error) {
+	result.StatusCode = resp.StatusCode
+
+	respBody, err := io.ReadAll(resp.Body)
+	result.RequestDuration = time.Since(startTime)
+	if err != nil {
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		s.recordUsage(proxyKey, provider, result)
+		c.Data(resp.StatusCode, "application/json", transformOllamaError(resp.StatusCode, respBody))
+		return result, nil
+	}
+
+	var ollamaResp struct {
+		Model           string      `json:"model"`
+		Embeddings      [][]float64 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+	}
+	if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
+		result.ErrorMessage = fmt.Sprintf("failed to transform response: %v", err)
+		return result, fmt.Errorf("failed to transform response: %w", err)
+	}
+
+	result.InputTokens = ollamaResp.PromptEvalCount
+	result.TotalTokens = ollamaResp.PromptEvalCount
+	s.recordUsage(proxyKey, provider, result)
+
+	base64Encoded := embeddingsReq["encoding_format"] == "base64"
+	data := make([]map[string]interface{}, 0, len(ollamaResp.Embeddings))
+	for i, embedding := range ollamaResp.Embeddings {
+		var value interface{} = embedding
+		if base64Encoded {
+			value = encodeEmbeddingBase64(embedding)
+		}
+		data = append(data, map[string]interface{}{
+			"object":    "embedding",
+			"index":     i,
+			"embedding": value,
+		})
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  ollamaResp.Model,
+		"usage": map[string]int{
+			"prompt_tokens": ollamaResp.PromptEvalCount,
+			"total_tokens":  ollamaResp.PromptEvalCount,
+		},
+	})
+	if err != nil {
+		result.ErrorMessage = fmt.Sprintf("failed to transform response: %v", err)
+		return result, fmt.Errorf("failed to transform response: %w", err)
+	}
+
+	c.Data(resp.StatusCode, "application/json", payload)
+	return result, nil
+}
+
+// encodeEmbeddingBase64 packs an embedding as little-endian float32s, as OpenAI does for
+// encoding_format=base64
+func encodeEmbeddingBase64(embedding []float64) string {
+	buf := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyService_TransformToOllama(t *testing.T) {
+	service := &ProxyService{}
+
+	t.Run("maps messages, options and tools", func(t *testing.T) {
+		maxTokens := 128
+		temperature := 0.2
+		req := &OpenAIChatRequest{
+			MaxTokens:   &maxTokens,
+			Temperature: &temperature,
+			Stop:        []interface{}{"END"},
+			Tools: []OpenAITool{{
+				Type:     "function",
+				Function: OpenAIFunctionDefinition{Name: "get_weather"},
+			}},
+			Messages: []OpenAIMessage{
+				{Role: "developer", Content: "Be brief."},
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": "What is this?"},
+					map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0K"}},
+				}},
+				{Role: "assistant", ToolCalls: []OpenAIToolCall{{
+					ID:       "call_1",
+					Type:     "function",
+					Function: OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+				}}},
+				{Role: "tool", ToolCallID: "call_1", Content: "Sunny"},
+			},
+		}
+
+		body, err := service.transformToOllama(req, "llama3.2")
+		require.NoError(t, err)
+
+		var ollamaReq map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &ollamaReq))
+		assert.Equal(t, "llama3.2", ollamaReq["model"])
+		assert.Equal(t, false, ollamaReq["stream"])
+
+		options := ollamaReq["options"].(map[string]interface{})
+		assert.Equal(t, float64(128), options["num_predict"])
+		assert.Equal(t, 0.2, options["temperature"])
+		assert.Equal(t, []interface{}{"END"}, options["stop"])
+
+		tools := ollamaReq["tools"].([]interface{})
+		require.Len(t, tools, 1)
+		params := tools[0].(map[string]interface{})["function"].(map[string]interface{})["parameters"]
+		assert.Equal(t, "object", params.(map[string]interface{})["type"])
+
+		messages := ollamaReq["messages"].([]interface{})
+		require.Len(t, messages, 4)
+		assert.Equal(t, "system", messages[0].(map[string]interface{})["role"])
+
+		user := messages[1].(map[string]interface{})
+		assert.Equal(t, "What is this?", user["content"])
+		assert.Equal(t, []interface{}{"iVBORw0K"}, user["images"])
+
+		call := messages[2].(map[string]interface{})["tool_calls"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"city": "Paris"}, call["function"].(map[string]interface{})["arguments"])
+
+		tool := messages[3].(map[string]interface{})
+		assert.Equal(t, "tool", tool["role"])
+		assert.Equal(t, "get_weather", tool["tool_name"])
+		assert.Equal(t, "Sunny", tool["content"])
+	})
+
+	t.Run("drops tools when tool_choice is none", func(t *testing.T) {
+		req := &OpenAIChatRequest{
+			ToolChoice: "none",
+			Tools:      []OpenAITool{{Type: "function", Function: OpenAIFunctionDefinition{Name: "f"}}},
+			Messages:   []OpenAIMessage{{Role: "user", Content: "Hi"}},
+		}
+
+		body, err := service.transformToOllama(req, "llama3.2")
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), `"tools"`)
+		assert.NotContains(t, string(body), `"options"`)
+	})
+
+	t.Run("rejects remote image URLs", func(t *testing.T) {
+		req := &OpenAIChatRequest{
+			Messages: []OpenAIMessage{{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/cat.png"}},
+			}}},
+		}
+
+		_, err := service.transformToOllama(req, "llava")
+		assert.Error(t, err)
+	})
+}
+
+func TestProxyService_TransformOllamaResponse(t *testing.T) {
+	service := &ProxyService{}
+
+	t.Run("converts text and usage", func(t *testing.T) {
+		body, err := service.transformOllamaResponse([]byte(`{"model":"llama3.2","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"Hello!"},"done":true,"done_reason":"stop","prompt_eval_count":26,"eval_count":5}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		assert.Equal(t, "chat.completion", chatResp.Object)
+		assert.Equal(t, "llama3.2", chatResp.Model)
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "Hello!", chatResp.Choices[0].Message.Content)
+		assert.Equal(t, "stop", chatResp.Choices[0].FinishReason)
+		assert.Equal(t, 26, chatResp.Usage.PromptTokens)
+		assert.Equal(t, 5, chatResp.Usage.CompletionTokens)
+		assert.Equal(t, 31, chatResp.Usage.TotalTokens)
+	})
+
+	t.Run("converts tool calls", func(t *testing.T) {
+		body, err := service.transformOllamaResponse([]byte(`{"model":"llama3.2","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":true,"done_reason":"stop"}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		choice := chatResp.Choices[0]
+		assert.Equal(t, "tool_calls", choice.FinishReason)
+		assert.Nil(t, choice.Message.Content)
+		require.Len(t, choice.Message.ToolCalls, 1)
+		assert.True(t, strings.HasPrefix(choice.Message.ToolCalls[0].ID, "call_"))
+		assert.Equal(t, "get_weather", choice.Message.ToolCalls[0].Function.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, choice.Message.ToolCalls[0].Function.Arguments)
+	})
+
+	t.Run("converts errors to the OpenAI shape", func(t *testing.T) {
+		body := transformOllamaError(http.StatusNotFound, []byte(`{"error":"model 'llama9' not found"}`))
+		assert.JSONEq(t, `{"error":{"message":"model 'llama9' not found","type":"invalid_request_error","code":null}}`, string(body))
+	})
+}
+
+// recorded Ollama /api/chat stream used by the stream translation tests
+const ollamaStream = `{"model":"llama3.2","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"Hel"},"done":false}
+{"model":"llama3.2","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"lo"},"done":false}
+{"model":"llama3.2","created_at":"2025-01-01T00:00:01Z","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":2}
+`
+
+func TestProxyService_OllamaChat(t *testing.T) {
+	t.Run("translates a buffered response and records usage", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/chat", r.URL.Path)
+			assert.Equal(t, "Bearer upstream-key", r.Header.Get("Authorization"))
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "llama3.2", body["model"])
+			assert.Equal(t, false, body["stream"])
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Hi!"},"done":true,"done_reason":"stop","prompt_eval_count":8,"eval_count":3}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOllama, upstream.URL)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"ollama/llama3.2","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&chatResp))
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "Hi!", chatResp.Choices[0].Message.Content)
+		assert.Equal(t, 11, chatResp.Usage.TotalTokens)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.InputTokens == 8 && record.OutputTokens == 3
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("converts an NDJSON stream to server-sent events", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, true, body["stream"])
+
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, ollamaStream)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOllama, upstream.URL)
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"llama3.2","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"object":"chat.completion.chunk"`)
+		assert.Contains(t, string(body), `"content":"Hel"`)
+		assert.Contains(t, string(body), `"finish_reason":"stop"`)
+		assert.Contains(t, string(body), `"prompt_tokens":12`)
+		assert.NotContains(t, string(body), "prompt_eval_count")
+		assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+
+		result := <-results
+		assert.Equal(t, 12, result.InputTokens)
+		assert.Equal(t, 2, result.OutputTokens)
+	})
+}
+
+func TestOllamaStreamTranslator(t *testing.T) {
+	t.Run("emits whole tool calls with indexes", func(t *testing.T) {
+		translator := newOllamaStreamTranslator(false)
+		events := translator.Translate(&sseEvent{Data: `{"model":"llama3.2","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"a","arguments":{}}},{"function":{"name":"b","arguments":{"x":1}}}]},"done":false}`})
+		events = append(events, translator.Translate(&sseEvent{Data: `{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`})...)
+
+		// role chunk, tool call chunk, finish chunk, [DONE]
+		require.Len(t, events, 4)
+		var chunk OpenAIChatChunk
+		require.NoError(t, json.Unmarshal([]byte(events[1].Data), &chunk))
+		calls := chunk.Choices[0].Delta.ToolCalls
+		require.Len(t, calls, 2)
+		assert.Equal(t, 1, *calls[1].Index)
+		assert.Equal(t, "b", calls[1].Function.Name)
+		assert.JSONEq(t, `{"x":1}`, calls[1].Function.Arguments)
+		assert.Contains(t, events[2].Data, `"finish_reason":"tool_calls"`)
+		assert.Equal(t, "[DONE]", events[3].Data)
+	})
+
+	t.Run("surfaces an error line and ends the stream", func(t *testing.T) {
+		translator := newOllamaStreamTranslator(true)
+		events := translator.Translate(&sseEvent{Data: `{"error":"model runner has unexpectedly stopped"}`})
+		require.Len(t, events, 2)
+		assert.Contains(t, events[0].Data, "model runner has unexpectedly stopped")
+		assert.Equal(t, "[DONE]", events[1].Data)
+		assert.Empty(t, translator.Finish())
+	})
+}
+
+func TestProxyService_OllamaEmbeddings(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "nomic-embed-text", body["model"])
+		assert.Equal(t, []interface{}{"a", "b"}, body["input"])
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"model":"nomic-embed-text","embeddings":[[0.5,-1],[0.25,0]],"prompt_eval_count":4}`)
+	}))
+	defer upstream.Close()
+
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+	proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOllama, upstream.URL)
+
+	t.Run("converts the response to an OpenAI list", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"nomic-embed-text","input":["a","b"]}`))
+
+		result, err := service.ProxyEmbeddings(c, proxyKey)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, 4, result.InputTokens)
+		assert.JSONEq(t, `{"object":"list","model":"nomic-embed-text",
+			"data":[{"object":"embedding","index":0,"embedding":[0.5,-1]},{"object":"embedding","index":1,"embedding":[0.25,0]}],
+			"usage":{"prompt_tokens":4,"total_tokens":4}}`, recorder.Body.String())
+	})
+
+	t.Run("encodes base64 when requested", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"nomic-embed-text","input":["a","b"],"encoding_format":"base64"}`))
+
+		_, err := service.ProxyEmbeddings(c, proxyKey)
+		require.NoError(t, err)
+		// 0.5 and -1 as little-endian float32
+		assert.Contains(t, recorder.Body.String(), `"embedding":"AAAAPwAAgL8="`)
+	})
+
+	t.Run("rejects token array input", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"nomic-embed-text","input":[[1,2,3]]}`))
+
+		result, err := service.ProxyEmbeddings(c, proxyKey)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,11 +19,13 @@ import (
 type ProviderService struct {
 	db           *gorm.DB
 	oauthService *OAuthService
+	balancer     *LoadBalancer
 }
 
-// NewProviderService creates a new ProviderService instance
-func NewProviderService(db *gorm.DB) *ProviderService {
-	return &ProviderService{db: db}
+// NewProviderService creates a new ProviderService instance. balancer supplies the circuit breaker
+// state reported with each provider; with nil, none is reported.
+func NewProviderService(db *gorm.DB, balancer *LoadBalancer) *ProviderService {
+	return &ProviderService{db: db, balancer: balancer}
 }
 
 // SetOAuthService sets the OAuth service (to avoid circular dependency)
@@ -33,45 +36,79 @@ func (s *ProviderService) SetOAuthService(oauthService *OAuthService) {
 // ProviderResponse represents the provider data returned to clients
 // Note: APIKey is never included in responses
 type ProviderResponse struct {
-	ID                   uint      `json:"id"`
-	UserID               uint      `json:"user_id"`
-	Name                 string    `json:"name"`
-	ProviderType         string    `json:"provider_type"`
-	BaseURL              string    `json:"base_url"`
-	IsActive             bool      `json:"is_active"`
-	Models               []string  `json:"models"`
-	DefaultModel         string    `json:"default_model"`
-	InputCostPerMillion  float64   `json:"input_cost_per_million"`
-	OutputCostPerMillion float64   `json:"output_cost_per_million"`
-	OAuthConnected       bool      `json:"oauth_connected"` // Whether OAuth is connected (for anthropic_max)
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                      uint                         `json:"id"`
+	UserID                  uint                         `json:"user_id"`
+	Name                    string                       `json:"name"`
+	ProviderType            string                       `json:"provider_type"`
+	BaseURL                 string                       `json:"base_url"`
+	IsActive                bool                         `json:"is_active"`
+	Models                  []string                     `json:"models"`
+	DefaultModel            string                       `json:"default_model"`
+	APIVersion              string                       `json:"api_version,omitempty"`       // Azure OpenAI only
+	Deployments             map[string]string            `json:"deployments,omitempty"`       // Azure OpenAI only
+	AWSAccessKeyID          string                       `json:"aws_access_key_id,omitempty"` // Bedrock only
+	AWSRegion               string                       `json:"aws_region,omitempty"`        // Bedrock only
+	CustomConfig            *models.CustomProviderConfig `json:"custom_config,omitempty"`     // Custom providers only
+	RetryPolicy             *models.RetryPolicy          `json:"retry_policy,omitempty"`
+	CircuitBreaker          *models.CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+	Timeouts                *models.ProviderTimeouts     `json:"timeouts,omitempty"`
+	Health                  *ProviderHealth              `json:"health,omitempty"` // Circuit breaker state and load in this process
+	InputCostPerMillion     float64                      `json:"input_cost_per_million"`
+	OutputCostPerMillion    float64                      `json:"output_cost_per_million"`
+	AudioCostPerMinute      float64                      `json:"audio_cost_per_minute"`
+	CharacterCostPerMillion float64                      `json:"character_cost_per_million"`
+	ImageCostPerImage       float64                      `json:"image_cost_per_image"`
+	OAuthConnected          bool                         `json:"oauth_connected"` // Whether OAuth is connected (for anthropic_max)
+	CreatedAt               time.Time                    `json:"created_at"`
+	UpdatedAt               time.Time                    `json:"updated_at"`
 }
 
 // CreateProviderRequest represents the request to create a provider
 type CreateProviderRequest struct {
-	Name                 string   `json:"name" binding:"required"`
-	ProviderType         string   `json:"provider_type" binding:"required"`
-	BaseURL              string   `json:"base_url"`
-	APIKey               string   `json:"api_key"` // Not required for OAuth providers (validated in validateCreateRequest)
-	IsActive             *bool    `json:"is_active"`
-	Models               []string `json:"models"`
-	DefaultModel         string   `json:"default_model"`
-	InputCostPerMillion  float64  `json:"input_cost_per_million"`
-	OutputCostPerMillion float64  `json:"output_cost_per_million"`
+	Name                    string                       `json:"name" binding:"required"`
+	ProviderType            string                       `json:"provider_type" binding:"required"`
+	BaseURL                 string                       `json:"base_url"`
+	APIKey                  string                       `json:"api_key"` // Not required for OAuth providers (validated in validateCreateRequest)
+	IsActive                *bool                        `json:"is_active"`
+	Models                  []string                     `json:"models"`
+	DefaultModel            string                       `json:"default_model"`
+	APIVersion              string                       `json:"api_version"`       // Azure OpenAI only
+	Deployments             map[string]string            `json:"deployments"`       // Azure OpenAI only
+	AWSAccessKeyID          string                       `json:"aws_access_key_id"` // Bedrock only; the secret access key goes in api_key
+	AWSRegion               string                       `json:"aws_region"`        // Bedrock only
+	CustomConfig            *models.CustomProviderConfig `json:"custom_config"`     // Custom providers only
+	RetryPolicy             *models.RetryPolicy          `json:"retry_policy"`
+	CircuitBreaker          *models.CircuitBreakerConfig `json:"circuit_breaker"`
+	Timeouts                *models.ProviderTimeouts     `json:"timeouts"`
+	InputCostPerMillion     float64                      `json:"input_cost_per_million"`
+	OutputCostPerMillion    float64                      `json:"output_cost_per_million"`
+	AudioCostPerMinute      float64                      `json:"audio_cost_per_minute"`
+	CharacterCostPerMillion float64                      `json:"character_cost_per_million"`
+	ImageCostPerImage       float64                      `json:"image_cost_per_image"`
 }
 
 // UpdateProviderRequest represents the request to update a provider
 type UpdateProviderRequest struct {
-	Name                 *string  `json:"name,omitempty"`
-	ProviderType         *string  `json:"provider_type,omitempty"`
-	BaseURL              *string  `json:"base_url,omitempty"`
-	APIKey               *string  `json:"api_key,omitempty"`
-	IsActive             *bool    `json:"is_active,omitempty"`
-	Models               []string `json:"models,omitempty"`
-	DefaultModel         *string  `json:"default_model,omitempty"`
-	InputCostPerMillion  *float64 `json:"input_cost_per_million,omitempty"`
-	OutputCostPerMillion *float64 `json:"output_cost_per_million,omitempty"`
+	Name                    *string                      `json:"name,omitempty"`
+	ProviderType            *string                      `json:"provider_type,omitempty"`
+	BaseURL                 *string                      `json:"base_url,omitempty"`
+	APIKey                  *string                      `json:"api_key,omitempty"`
+	IsActive                *bool                        `json:"is_active,omitempty"`
+	Models                  []string                     `json:"models,omitempty"`
+	DefaultModel            *string                      `json:"default_model,omitempty"`
+	APIVersion              *string                      `json:"api_version,omitempty"`
+	Deployments             map[string]string            `json:"deployments,omitempty"`
+	AWSAccessKeyID          *string                      `json:"aws_access_key_id,omitempty"`
+	AWSRegion               *string                      `json:"aws_region,omitempty"`
+	CustomConfig            *models.CustomProviderConfig `json:"custom_config,omitempty"`
+	RetryPolicy             *models.RetryPolicy          `json:"retry_policy,omitempty"` // max_attempts 1 turns retries off
+	CircuitBreaker          *models.CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+	Timeouts                *models.ProviderTimeouts     `json:"timeouts,omitempty"`
+	InputCostPerMillion     *float64                     `json:"input_cost_per_million,omitempty"`
+	OutputCostPerMillion    *float64                     `json:"output_cost_per_million,omitempty"`
+	AudioCostPerMinute      *float64                     `json:"audio_cost_per_minute,omitempty"`
+	CharacterCostPerMillion *float64                     `json:"character_cost_per_million,omitempty"`
+	ImageCostPerImage       *float64                     `json:"image_cost_per_image,omitempty"`
 }
 
 // ListProviders returns all providers for a user
@@ -114,16 +151,27 @@ func (s *ProviderService) CreateProvider(userID uint, req *CreateProviderRequest
 	}
 
 	provider := models.Provider{
-		UserID:               userID,
-		Name:                 req.Name,
-		ProviderType:         req.ProviderType,
-		BaseURL:              req.BaseURL,
-		APIKey:               req.APIKey,
-		IsActive:             isActive,
-		Models:               req.Models,
-		DefaultModel:         req.DefaultModel,
-		InputCostPerMillion:  req.InputCostPerMillion,
-		OutputCostPerMillion: req.OutputCostPerMillion,
+		UserID:                  userID,
+		Name:                    req.Name,
+		ProviderType:            req.ProviderType,
+		BaseURL:                 req.BaseURL,
+		APIKey:                  req.APIKey,
+		IsActive:                isActive,
+		Models:                  req.Models,
+		DefaultModel:            req.DefaultModel,
+		APIVersion:              req.APIVersion,
+		Deployments:             req.Deployments,
+		AWSAccessKeyID:          req.AWSAccessKeyID,
+		AWSRegion:               req.AWSRegion,
+		CustomConfig:            req.CustomConfig,
+		RetryPolicy:             req.RetryPolicy,
+		CircuitBreaker:          req.CircuitBreaker,
+		Timeouts:                req.Timeouts,
+		InputCostPerMillion:     req.InputCostPerMillion,
+		OutputCostPerMillion:    req.OutputCostPerMillion,
+		AudioCostPerMinute:      req.AudioCostPerMinute,
+		CharacterCostPerMillion: req.CharacterCostPerMillion,
+		ImageCostPerImage:       req.ImageCostPerImage,
 	}
 
 	// For anthropic_max, the API key is actually a refresh token
@@ -208,12 +256,66 @@ func (s *ProviderService) UpdateProvider(userID, providerID uint, req *UpdatePro
 	if req.DefaultModel != nil {
 		updates["default_model"] = *req.DefaultModel
 	}
+	if req.APIVersion != nil {
+		updates["api_version"] = *req.APIVersion
+	}
+	if req.Deployments != nil {
+		// Map updates bypass the field's JSON serializer, so encode it here
+		deployments, err := json.Marshal(req.Deployments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode deployments: %w", err)
+		}
+		updates["deployments"] = string(deployments)
+	}
+	if req.AWSAccessKeyID != nil {
+		updates["aws_access_key_id"] = *req.AWSAccessKeyID
+	}
+	if req.AWSRegion != nil {
+		updates["aws_region"] = *req.AWSRegion
+	}
+	if req.CustomConfig != nil {
+		customConfig, err := json.Marshal(req.CustomConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode custom_config: %w", err)
+		}
+		updates["custom_config"] = string(customConfig)
+	}
+	if req.RetryPolicy != nil {
+		retryPolicy, err := json.Marshal(req.RetryPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode retry_policy: %w", err)
+		}
+		updates["retry_policy"] = string(retryPolicy)
+	}
+	if req.CircuitBreaker != nil {
+		circuitBreaker, err := json.Marshal(req.CircuitBreaker)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode circuit_breaker: %w", err)
+		}
+		updates["circuit_breaker"] = string(circuitBreaker)
+	}
+	if req.Timeouts != nil {
+		timeouts, err := json.Marshal(req.Timeouts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode timeouts: %w", err)
+		}
+		updates["timeouts"] = string(timeouts)
+	}
 	if req.InputCostPerMillion != nil {
 		updates["input_cost_per_million"] = *req.InputCostPerMillion
 	}
 	if req.OutputCostPerMillion != nil {
 		updates["output_cost_per_million"] = *req.OutputCostPerMillion
 	}
+	if req.AudioCostPerMinute != nil {
+		updates["audio_cost_per_minute"] = *req.AudioCostPerMinute
+	}
+	if req.CharacterCostPerMillion != nil {
+		updates["character_cost_per_million"] = *req.CharacterCostPerMillion
+	}
+	if req.ImageCostPerImage != nil {
+		updates["image_cost_per_image"] = *req.ImageCostPerImage
+	}
 
 	if len(updates) > 0 {
 		if err := s.db.Model(provider).Updates(updates).Error; err != nil {
@@ -271,9 +373,15 @@ func (s *ProviderService) TestConnection(userID, providerID uint) error {
 // TestConnectionWithRequest tests connection with provided credentials (before saving)
 func (s *ProviderService) TestConnectionWithRequest(req *CreateProviderRequest) error {
 	provider := &models.Provider{
-		ProviderType: req.ProviderType,
-		BaseURL:      req.BaseURL,
-		APIKey:       req.APIKey,
+		ProviderType:   req.ProviderType,
+		BaseURL:        req.BaseURL,
+		APIKey:         req.APIKey,
+		APIVersion:     req.APIVersion,
+		Deployments:    req.Deployments,
+		AWSAccessKeyID: req.AWSAccessKeyID,
+		AWSRegion:      req.AWSRegion,
+		CustomConfig:   req.CustomConfig,
+		Timeouts:       req.Timeouts,
 	}
 
 	return s.testProviderConnection(provider)
@@ -292,9 +400,15 @@ func (s *ProviderService) FetchAvailableModels(userID, providerID uint) ([]strin
 // FetchAvailableModelsWithRequest fetches available models with provided credentials (before saving)
 func (s *ProviderService) FetchAvailableModelsWithRequest(req *CreateProviderRequest) ([]string, error) {
 	provider := &models.Provider{
-		ProviderType: req.ProviderType,
-		BaseURL:      req.BaseURL,
-		APIKey:       req.APIKey,
+		ProviderType:   req.ProviderType,
+		BaseURL:        req.BaseURL,
+		APIKey:         req.APIKey,
+		APIVersion:     req.APIVersion,
+		Deployments:    req.Deployments,
+		AWSAccessKeyID: req.AWSAccessKeyID,
+		AWSRegion:      req.AWSRegion,
+		CustomConfig:   req.CustomConfig,
+		Timeouts:       req.Timeouts,
 	}
 
 	return s.fetchModelsFromProvider(provider)
@@ -331,19 +445,31 @@ func (s *ProviderService) getProviderByID(userID, providerID uint) (*models.Prov
 // Note: APIKey is never included in the response
 func (s *ProviderService) buildProviderResponse(provider *models.Provider) ProviderResponse {
 	return ProviderResponse{
-		ID:                   provider.ID,
-		UserID:               provider.UserID,
-		Name:                 provider.Name,
-		ProviderType:         provider.ProviderType,
-		BaseURL:              provider.GetBaseURL(),
-		IsActive:             provider.IsActive,
-		Models:               provider.Models,
-		DefaultModel:         provider.DefaultModel,
-		InputCostPerMillion:  provider.InputCostPerMillion,
-		OutputCostPerMillion: provider.OutputCostPerMillion,
-		OAuthConnected:       provider.OAuthConnected,
-		CreatedAt:            provider.CreatedAt,
-		UpdatedAt:            provider.UpdatedAt,
+		ID:                      provider.ID,
+		UserID:                  provider.UserID,
+		Name:                    provider.Name,
+		ProviderType:            provider.ProviderType,
+		BaseURL:                 provider.GetBaseURL(),
+		IsActive:                provider.IsActive,
+		Models:                  provider.Models,
+		DefaultModel:            provider.DefaultModel,
+		APIVersion:              provider.APIVersion,
+		Deployments:             provider.Deployments,
+		AWSAccessKeyID:          provider.AWSAccessKeyID,
+		AWSRegion:               provider.AWSRegion,
+		CustomConfig:            provider.CustomConfig,
+		RetryPolicy:             provider.RetryPolicy,
+		CircuitBreaker:          provider.CircuitBreaker,
+		Timeouts:                provider.Timeouts,
+		Health:                  s.balancer.Health(provider),
+		InputCostPerMillion:     provider.InputCostPerMillion,
+		OutputCostPerMillion:    provider.OutputCostPerMillion,
+		AudioCostPerMinute:      provider.AudioCostPerMinute,
+		CharacterCostPerMillion: provider.CharacterCostPerMillion,
+		ImageCostPerImage:       provider.ImageCostPerImage,
+		OAuthConnected:          provider.OAuthConnected,
+		CreatedAt:               provider.CreatedAt,
+		UpdatedAt:               provider.UpdatedAt,
 	}
 }
 
@@ -369,8 +495,41 @@ func (s *ProviderService) validateCreateRequest(req *CreateProviderRequest) erro
 		}
 	}
 
-	// Validate API key / refresh token
-	if strings.TrimSpace(req.APIKey) == "" {
+	// Azure OpenAI endpoints are per-resource, so there is no default to fall back on
+	if req.ProviderType == models.ProviderTypeAzureOpenAI && req.BaseURL == "" {
+		return fmt.Errorf("base_url is required for Azure OpenAI providers")
+	}
+	if req.ProviderType == models.ProviderTypeCustom && req.BaseURL == "" {
+		return fmt.Errorf("base_url is required for custom providers")
+	}
+	if err := validateCustomConfig(req.CustomConfig); err != nil {
+		return err
+	}
+	if err := validateRetryPolicy(req.RetryPolicy); err != nil {
+		return err
+	}
+	if err := validateCircuitBreaker(req.CircuitBreaker); err != nil {
+		return err
+	}
+	if err := validateTimeouts(req.Timeouts); err != nil {
+		return err
+	}
+	if err := validateDeployments(req.Deployments); err != nil {
+		return err
+	}
+
+	// Bedrock signs with an access key pair and needs a region to sign for
+	if req.ProviderType == models.ProviderTypeBedrock {
+		if strings.TrimSpace(req.AWSAccessKeyID) == "" {
+			return fmt.Errorf("aws_access_key_id is required for Bedrock providers")
+		}
+		if strings.TrimSpace(req.AWSRegion) == "" {
+			return fmt.Errorf("aws_region is required for Bedrock providers")
+		}
+	}
+
+	// Validate API key / refresh token (Ollama servers don't authenticate by default)
+	if strings.TrimSpace(req.APIKey) == "" && req.ProviderType != models.ProviderTypeOllama {
 		if req.ProviderType == models.ProviderTypeAnthropicMax {
 			return fmt.Errorf("refresh_token is required for Claude Max providers")
 		}
@@ -384,6 +543,15 @@ func (s *ProviderService) validateCreateRequest(req *CreateProviderRequest) erro
 	if req.OutputCostPerMillion < 0 {
 		return fmt.Errorf("output_cost_per_million cannot be negative")
 	}
+	if req.AudioCostPerMinute < 0 {
+		return fmt.Errorf("audio_cost_per_minute cannot be negative")
+	}
+	if req.CharacterCostPerMillion < 0 {
+		return fmt.Errorf("character_cost_per_million cannot be negative")
+	}
+	if req.ImageCostPerImage < 0 {
+		return fmt.Errorf("image_cost_per_image cannot be negative")
+	}
 
 	return nil
 }
@@ -419,6 +587,30 @@ func (s *ProviderService) validateUpdateRequest(req *UpdateProviderRequest) erro
 		return fmt.Errorf("api_key cannot be empty")
 	}
 
+	if err := validateDeployments(req.Deployments); err != nil {
+		return err
+	}
+
+	if req.AWSAccessKeyID != nil && strings.TrimSpace(*req.AWSAccessKeyID) == "" {
+		return fmt.Errorf("aws_access_key_id cannot be empty")
+	}
+	if req.AWSRegion != nil && strings.TrimSpace(*req.AWSRegion) == "" {
+		return fmt.Errorf("aws_region cannot be empty")
+	}
+
+	if err := validateCustomConfig(req.CustomConfig); err != nil {
+		return err
+	}
+	if err := validateRetryPolicy(req.RetryPolicy); err != nil {
+		return err
+	}
+	if err := validateCircuitBreaker(req.CircuitBreaker); err != nil {
+		return err
+	}
+	if err := validateTimeouts(req.Timeouts); err != nil {
+		return err
+	}
+
 	// Validate cost values if provided
 	if req.InputCostPerMillion != nil && *req.InputCostPerMillion < 0 {
 		return fmt.Errorf("input_cost_per_million cannot be negative")
@@ -426,6 +618,15 @@ func (s *ProviderService) validateUpdateRequest(req *UpdateProviderRequest) erro
 	if req.OutputCostPerMillion != nil && *req.OutputCostPerMillion < 0 {
 		return fmt.Errorf("output_cost_per_million cannot be negative")
 	}
+	if req.AudioCostPerMinute != nil && *req.AudioCostPerMinute < 0 {
+		return fmt.Errorf("audio_cost_per_minute cannot be negative")
+	}
+	if req.CharacterCostPerMillion != nil && *req.CharacterCostPerMillion < 0 {
+		return fmt.Errorf("character_cost_per_million cannot be negative")
+	}
+	if req.ImageCostPerImage != nil && *req.ImageCostPerImage < 0 {
+		return fmt.Errorf("image_cost_per_image cannot be negative")
+	}
 
 	return nil
 }
@@ -440,6 +641,11 @@ func (s *ProviderService) validateProviderType(providerType string) error {
 		models.ProviderTypeLocal,
 		models.ProviderTypeZai,
 		models.ProviderTypeZaiInternational,
+		models.ProviderTypeOllama,
+		models.ProviderTypeGemini,
+		models.ProviderTypeAzureOpenAI,
+		models.ProviderTypeBedrock,
+		models.ProviderTypeCustom,
 	}
 	for _, vt := range validTypes {
 		if providerType == vt {
@@ -449,6 +655,118 @@ func (s *ProviderService) validateProviderType(providerType string) error {
 	return fmt.Errorf("invalid provider_type: must be one of %v", validTypes)
 }
 
+// validateDeployments checks that an Azure model -> deployment mapping has no blank entries
+func validateDeployments(deployments map[string]string) error {
+	for model, deployment := range deployments {
+		if strings.TrimSpace(model) == "" || strings.TrimSpace(deployment) == "" {
+			return fmt.Errorf("deployments must map model names to non-empty deployment names")
+		}
+	}
+	return nil
+}
+
+// validateCustomConfig checks a custom provider's header names and path templates
+func validateCustomConfig(cfg *models.CustomProviderConfig) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.AuthHeader != "" && !isValidHeaderName(cfg.AuthHeader) {
+		return fmt.Errorf("custom_config.auth_header is not a valid header name")
+	}
+	for field, path := range map[string]string{
+		"chat_path":       cfg.ChatPath,
+		"models_path":     cfg.ModelsPath,
+		"embeddings_path": cfg.EmbeddingsPath,
+	} {
+		if path != "" && !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("custom_config.%s must start with /", field)
+		}
+	}
+	for name, value := range cfg.ExtraHeaders {
+		if !isValidHeaderName(name) {
+			return fmt.Errorf("custom_config.extra_headers has an invalid header name: %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("custom_config.extra_headers value for %s cannot contain line breaks", name)
+		}
+	}
+	return nil
+}
+
+// maxRetryAttempts bounds how many times one request may be sent to a provider
+const maxRetryAttempts = 10
+
+// validateRetryPolicy checks a provider's retry limits and status codes
+func validateRetryPolicy(policy *models.RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MaxAttempts < 0 || policy.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("retry_policy.max_attempts must be between 1 and %d", maxRetryAttempts)
+	}
+	if policy.InitialBackoffMs < 0 || policy.MaxBackoffMs < 0 {
+		return fmt.Errorf("retry_policy backoff values cannot be negative")
+	}
+	if policy.InitialBackoffMs > 0 && policy.MaxBackoffMs > 0 && policy.InitialBackoffMs > policy.MaxBackoffMs {
+		return fmt.Errorf("retry_policy.initial_backoff_ms cannot exceed max_backoff_ms")
+	}
+	for _, code := range policy.RetryStatusCodes {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("retry_policy.retry_status_codes must be 4xx or 5xx codes, got %d", code)
+		}
+	}
+	return nil
+}
+
+// validateCircuitBreaker checks a provider's failure threshold and cooldown; zero keeps the default
+func validateCircuitBreaker(cfg *models.CircuitBreakerConfig) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.FailureThreshold < 0 {
+		return fmt.Errorf("circuit_breaker.failure_threshold cannot be negative")
+	}
+	if cfg.CooldownSeconds < 0 {
+		return fmt.Errorf("circuit_breaker.cooldown_seconds cannot be negative")
+	}
+	return nil
+}
+
+// validateTimeouts checks a provider's timeouts; zero keeps the default
+func validateTimeouts(timeouts *models.ProviderTimeouts) error {
+	if timeouts == nil {
+		return nil
+	}
+
+	if timeouts.ConnectMs < 0 || timeouts.FirstByteMs < 0 || timeouts.StreamIdleMs < 0 || timeouts.TotalMs < 0 {
+		return fmt.Errorf("timeouts cannot be negative")
+	}
+	total := timeouts.TotalMs
+	if total == 0 {
+		total = models.DefaultTotalTimeoutMs
+	}
+	if timeouts.ConnectMs > total || timeouts.FirstByteMs > total {
+		return fmt.Errorf("timeouts.connect_ms and timeouts.first_byte_ms cannot exceed the total timeout (%dms)", total)
+	}
+	return nil
+}
+
+// isValidHeaderName reports whether name is a non-empty HTTP header token
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
+
 // validateBaseURL validates a base URL
 func (s *ProviderService) validateBaseURL(baseURL string) error {
 	parsed, err := url.Parse(baseURL)
@@ -496,15 +814,20 @@ func (s *ProviderService) testProviderConnection(provider *models.Provider) erro
 		testURL = strings.TrimSuffix(baseURL, "/") + "/models"
 	case models.ProviderTypeZaiInternational:
 		testURL = strings.TrimSuffix(baseURL, "/") + "/models"
+	case models.ProviderTypeOllama:
+		testURL = strings.TrimSuffix(baseURL, "/") + "/api/tags"
+	case models.ProviderTypeGemini:
+		testURL = geminiEndpointURL(provider, "/models")
+	case models.ProviderTypeAzureOpenAI:
+		testURL = azureModelsURL(provider)
+	case models.ProviderTypeBedrock:
+		testURL = bedrockModelsURL(provider)
+	case models.ProviderTypeCustom:
+		testURL = openAIEndpointURL(provider, "", "/models")
 	default:
 		testURL = strings.TrimSuffix(baseURL, "/") + "/v1/models"
 	}
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
 	req, err := http.NewRequest("GET", testURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -521,12 +844,26 @@ func (s *ProviderService) testProviderConnection(provider *models.Provider) erro
 		req.Method = "POST"
 		// We can't actually test without sending a valid request body,
 		// so we'll accept 400 (bad request) as a success indicator that auth worked
+	case models.ProviderTypeOllama:
+		// Ollama only needs a key when it sits behind an authenticating proxy
+		if provider.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+provider.APIKey)
+		}
+	case models.ProviderTypeGemini:
+		req.Header.Set("x-goog-api-key", provider.APIKey)
+	case models.ProviderTypeAzureOpenAI:
+		req.Header.Set("api-key", provider.APIKey)
+	case models.ProviderTypeBedrock:
+		signBedrockRequest(req, nil, provider)
+	case models.ProviderTypeCustom:
+		setCustomProviderHeaders(req, provider)
 	default:
 		req.Header.Set("Authorization", "Bearer "+provider.APIKey)
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	resp, err := client.Do(req)
+	// Sent under the provider's own timeouts, like proxied requests
+	resp, err := sendUpstream(req, provider)
 	if err != nil {
 		return fmt.Errorf("connection failed: %w", err)
 	}
@@ -584,15 +921,20 @@ func (s *ProviderService) fetchModelsFromProvider(provider *models.Provider) ([]
 		testURL = strings.TrimSuffix(baseURL, "/") + "/v1/models"
 	case models.ProviderTypeZai, models.ProviderTypeZaiInternational:
 		testURL = strings.TrimSuffix(baseURL, "/") + "/models"
+	case models.ProviderTypeOllama:
+		testURL = strings.TrimSuffix(baseURL, "/") + "/api/tags"
+	case models.ProviderTypeGemini:
+		testURL = geminiEndpointURL(provider, "/models?pageSize=1000") // Default page size is 50
+	case models.ProviderTypeAzureOpenAI:
+		testURL = azureModelsURL(provider)
+	case models.ProviderTypeBedrock:
+		testURL = bedrockModelsURL(provider)
+	case models.ProviderTypeCustom:
+		testURL = openAIEndpointURL(provider, "", "/models")
 	default:
 		testURL = strings.TrimSuffix(baseURL, "/") + "/v1/models"
 	}
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
 	req, err := http.NewRequest("GET", testURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -606,12 +948,25 @@ func (s *ProviderService) fetchModelsFromProvider(provider *models.Provider) ([]
 	case models.ProviderTypeAnthropicMax:
 		req.Header.Set("Authorization", "Bearer "+provider.AccessToken)
 		req.Header.Set("anthropic-version", "2023-06-01")
+	case models.ProviderTypeOllama:
+		if provider.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+provider.APIKey)
+		}
+	case models.ProviderTypeGemini:
+		req.Header.Set("x-goog-api-key", provider.APIKey)
+	case models.ProviderTypeAzureOpenAI:
+		req.Header.Set("api-key", provider.APIKey)
+	case models.ProviderTypeBedrock:
+		signBedrockRequest(req, nil, provider)
+	case models.ProviderTypeCustom:
+		setCustomProviderHeaders(req, provider)
 	default:
 		req.Header.Set("Authorization", "Bearer "+provider.APIKey)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
+	// Sent under the provider's own timeouts, like proxied requests
+	resp, err := sendUpstream(req, provider)
 	if err != nil {
 		return nil, fmt.Errorf("connection failed: %w", err)
 	}
@@ -625,6 +980,87 @@ func (s *ProviderService) fetchModelsFromProvider(provider *models.Provider) ([]
 		return nil, fmt.Errorf("provider returned error status: %d", resp.StatusCode)
 	}
 
+	// Ollama lists its local models as {"models": [{"name": "llama3.2:latest", ...}]}
+	if provider.ProviderType == models.ProviderTypeOllama {
+		var tagsResp struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
+			return nil, fmt.Errorf("failed to parse provider response: %w", err)
+		}
+
+		modelIDs := make([]string, 0, len(tagsResp.Models))
+		for _, m := range tagsResp.Models {
+			if m.Name != "" {
+				modelIDs = append(modelIDs, m.Name)
+			}
+		}
+		return modelIDs, nil
+	}
+
+	// Gemini lists models as {"models": [{"name": "models/gemini-2.5-flash", "supportedGenerationMethods": [...]}]}
+	if provider.ProviderType == models.ProviderTypeGemini {
+		var geminiResp struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
+			return nil, fmt.Errorf("failed to parse provider response: %w", err)
+		}
+
+		// Only chat models can be served; embedding and other models can't generate content
+		modelIDs := make([]string, 0, len(geminiResp.Models))
+		for _, m := range geminiResp.Models {
+			for _, method := range m.SupportedGenerationMethods {
+				if method == "generateContent" {
+					modelIDs = append(modelIDs, strings.TrimPrefix(m.Name, "models/"))
+					break
+				}
+			}
+		}
+		return modelIDs, nil
+	}
+
+	// Bedrock lists models as {"modelSummaries": [{"modelId": "...", "outputModalities": ["TEXT"], ...}]}
+	if provider.ProviderType == models.ProviderTypeBedrock {
+		var bedrockResp struct {
+			ModelSummaries []struct {
+				ModelID          string   `json:"modelId"`
+				OutputModalities []string `json:"outputModalities"`
+			} `json:"modelSummaries"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&bedrockResp); err != nil {
+			return nil, fmt.Errorf("failed to parse provider response: %w", err)
+		}
+
+		// Only text models can be served through Converse; skip embedding and image models
+		modelIDs := make([]string, 0, len(bedrockResp.ModelSummaries))
+		for _, m := range bedrockResp.ModelSummaries {
+			for _, modality := range m.OutputModalities {
+				if modality == "TEXT" {
+					modelIDs = append(modelIDs, m.ModelID)
+					break
+				}
+			}
+		}
+		return modelIDs, nil
+	}
+
+	// Azure can only serve models that have a deployment, so prefer the configured mapping
+	// over the resource's catalogue of base models
+	if provider.ProviderType == models.ProviderTypeAzureOpenAI && len(provider.Deployments) > 0 {
+		modelIDs := make([]string, 0, len(provider.Deployments))
+		for model := range provider.Deployments {
+			modelIDs = append(modelIDs, model)
+		}
+		sort.Strings(modelIDs)
+		return modelIDs, nil
+	}
+
 	// Parse response
 	var modelsResp struct {
 		Data []struct {
@@ -645,3 +1081,9 @@ func (s *ProviderService) fetchModelsFromProvider(provider *models.Provider) ([]
 
 	return modelIDs, nil
 }
+
+// azureModelsURL returns the Azure OpenAI endpoint that lists the models available to a resource
+func azureModelsURL(provider *models.Provider) string {
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(provider.GetBaseURL(), "/"), "/openai")
+	return baseURL + "/openai/models?api-version=" + url.QueryEscape(provider.GetAPIVersion())
+}
@@ -28,7 +28,7 @@ func setupProviderTestDB(t *testing.T) *gorm.DB {
 func TestProviderService_CreateProvider(t *testing.T) {
 	t.Run("creates OpenAI provider successfully", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "My OpenAI",
@@ -47,7 +47,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("creates Anthropic provider successfully", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "My Anthropic",
@@ -62,7 +62,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("creates local provider with custom URL", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "Local LLM",
@@ -78,7 +78,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("creates provider with cost configuration", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:                 "Costed Provider",
@@ -96,7 +96,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("creates provider with default model", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "With Default Model",
@@ -110,9 +110,231 @@ func TestProviderService_CreateProvider(t *testing.T) {
 		assert.Equal(t, "gpt-4o", result.DefaultModel)
 	})
 
+	t.Run("creates Azure OpenAI provider with deployments", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		req := &CreateProviderRequest{
+			Name:         "Azure",
+			ProviderType: models.ProviderTypeAzureOpenAI,
+			BaseURL:      "https://contoso.openai.azure.com",
+			APIKey:       "azure-key",
+			APIVersion:   "2024-10-21",
+			Deployments:  map[string]string{"gpt-4o": "prod-gpt4o"},
+		}
+
+		result, err := service.CreateProvider(1, req)
+		require.NoError(t, err)
+		assert.Equal(t, "2024-10-21", result.APIVersion)
+		assert.Equal(t, map[string]string{"gpt-4o": "prod-gpt4o"}, result.Deployments)
+
+		updated, err := service.UpdateProvider(1, result.ID, &UpdateProviderRequest{
+			Deployments: map[string]string{"gpt-4o": "prod-gpt4o", "text-embedding-3-small": "embeddings"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "embeddings", updated.Deployments["text-embedding-3-small"])
+	})
+
+	t.Run("fails for Azure OpenAI without a base URL", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		_, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:         "Azure",
+			ProviderType: models.ProviderTypeAzureOpenAI,
+			APIKey:       "azure-key",
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "base_url is required")
+	})
+
+	t.Run("creates Bedrock provider with AWS credentials", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		provider, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:           "Bedrock",
+			ProviderType:   models.ProviderTypeBedrock,
+			APIKey:         "secret-access-key",
+			AWSAccessKeyID: "AKIDEXAMPLE",
+			AWSRegion:      "eu-west-1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "AKIDEXAMPLE", provider.AWSAccessKeyID)
+		assert.Equal(t, "eu-west-1", provider.AWSRegion)
+		assert.Equal(t, "https://bedrock-runtime.eu-west-1.amazonaws.com", provider.BaseURL)
+	})
+
+	t.Run("fails for Bedrock without a region", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		_, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:           "Bedrock",
+			ProviderType:   models.ProviderTypeBedrock,
+			APIKey:         "secret-access-key",
+			AWSAccessKeyID: "AKIDEXAMPLE",
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "aws_region is required")
+	})
+
+	t.Run("creates custom provider with its config", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		provider, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:         "OpenRouter",
+			ProviderType: models.ProviderTypeCustom,
+			BaseURL:      "https://openrouter.ai",
+			APIKey:       "sk-or-key",
+			CustomConfig: &models.CustomProviderConfig{
+				ChatPath:     "/api/v1/chat/completions",
+				ExtraHeaders: map[string]string{"X-Title": "SmoothLLM"},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, provider.CustomConfig)
+		assert.Equal(t, "/api/v1/chat/completions", provider.CustomConfig.ChatPath)
+
+		updated, err := service.UpdateProvider(1, provider.ID, &UpdateProviderRequest{
+			CustomConfig: &models.CustomProviderConfig{AuthHeader: "X-Api-Key"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "X-Api-Key", updated.CustomConfig.AuthHeader)
+		assert.Empty(t, updated.CustomConfig.ExtraHeaders)
+	})
+
+	t.Run("fails for invalid custom config", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		_, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:         "Custom",
+			ProviderType: models.ProviderTypeCustom,
+			BaseURL:      "https://api.example.com",
+			APIKey:       "key",
+			CustomConfig: &models.CustomProviderConfig{ChatPath: "v1/chat"},
+		})
+		assert.ErrorContains(t, err, "chat_path must start with /")
+
+		_, err = service.CreateProvider(1, &CreateProviderRequest{
+			Name:         "Custom",
+			ProviderType: models.ProviderTypeCustom,
+			BaseURL:      "https://api.example.com",
+			APIKey:       "key",
+			CustomConfig: &models.CustomProviderConfig{ExtraHeaders: map[string]string{"Bad Header": "x"}},
+		})
+		assert.ErrorContains(t, err, "invalid header name")
+	})
+
+	t.Run("creates provider with a retry policy", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		provider, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:         "Anthropic",
+			ProviderType: models.ProviderTypeAnthropic,
+			APIKey:       "sk-ant-key",
+			RetryPolicy:  &models.RetryPolicy{MaxAttempts: 3, RetryStatusCodes: []int{429, 529}},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, provider.RetryPolicy)
+		assert.Equal(t, 3, provider.RetryPolicy.MaxAttempts)
+
+		updated, err := service.UpdateProvider(1, provider.ID, &UpdateProviderRequest{
+			RetryPolicy: &models.RetryPolicy{MaxAttempts: 1},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, updated.RetryPolicy.MaxAttempts)
+		assert.Empty(t, updated.RetryPolicy.RetryStatusCodes)
+	})
+
+	t.Run("fails for invalid retry policy", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		for _, tc := range []struct {
+			policy  models.RetryPolicy
+			message string
+		}{
+			{models.RetryPolicy{MaxAttempts: 50}, "max_attempts must be between"},
+			{models.RetryPolicy{MaxAttempts: 3, InitialBackoffMs: -1}, "cannot be negative"},
+			{models.RetryPolicy{MaxAttempts: 3, InitialBackoffMs: 5000, MaxBackoffMs: 1000}, "cannot exceed max_backoff_ms"},
+			{models.RetryPolicy{MaxAttempts: 3, RetryStatusCodes: []int{200}}, "must be 4xx or 5xx"},
+		} {
+			policy := tc.policy
+			_, err := service.CreateProvider(1, &CreateProviderRequest{
+				Name:         "OpenAI",
+				ProviderType: models.ProviderTypeOpenAI,
+				APIKey:       "sk-key",
+				RetryPolicy:  &policy,
+			})
+			assert.ErrorContains(t, err, tc.message)
+		}
+	})
+
+	t.Run("creates provider with timeouts", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		provider, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:         "Local",
+			ProviderType: models.ProviderTypeOllama,
+			BaseURL:      "http://localhost:11434",
+			Timeouts:     &models.ProviderTimeouts{FirstByteMs: 600000, TotalMs: 1800000},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, provider.Timeouts)
+		assert.Equal(t, 600000, provider.Timeouts.FirstByteMs)
+
+		updated, err := service.UpdateProvider(1, provider.ID, &UpdateProviderRequest{
+			Timeouts: &models.ProviderTimeouts{ConnectMs: 2000, StreamIdleMs: 30000},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, models.ProviderTimeouts{ConnectMs: 2000, StreamIdleMs: 30000}, *updated.Timeouts)
+	})
+
+	t.Run("fails for invalid timeouts", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		for _, tc := range []struct {
+			timeouts models.ProviderTimeouts
+			message  string
+		}{
+			{models.ProviderTimeouts{StreamIdleMs: -1}, "cannot be negative"},
+			{models.ProviderTimeouts{FirstByteMs: 60000, TotalMs: 30000}, "cannot exceed the total timeout"},
+			{models.ProviderTimeouts{FirstByteMs: models.DefaultTotalTimeoutMs + 1}, "cannot exceed the total timeout"},
+		} {
+			timeouts := tc.timeouts
+			_, err := service.CreateProvider(1, &CreateProviderRequest{
+				Name:         "OpenAI",
+				ProviderType: models.ProviderTypeOpenAI,
+				APIKey:       "sk-key",
+				Timeouts:     &timeouts,
+			})
+			assert.ErrorContains(t, err, tc.message)
+		}
+	})
+
+	t.Run("fails for blank deployment names", func(t *testing.T) {
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		_, err := service.CreateProvider(1, &CreateProviderRequest{
+			Name:         "Azure",
+			ProviderType: models.ProviderTypeAzureOpenAI,
+			BaseURL:      "https://contoso.openai.azure.com",
+			APIKey:       "azure-key",
+			Deployments:  map[string]string{"gpt-4o": " "},
+		})
+		assert.Error(t, err)
+	})
+
 	t.Run("fails for empty name", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "",
@@ -128,7 +350,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for name with only whitespace", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "   ",
@@ -144,7 +366,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for name longer than 100 characters", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         strings.Repeat("a", 101),
@@ -160,7 +382,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for invalid provider type", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "Invalid Provider",
@@ -176,7 +398,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for empty API key", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "No Key",
@@ -192,7 +414,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for invalid base URL format", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "Bad URL",
@@ -209,7 +431,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for non-http/https URL scheme", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:         "FTP URL",
@@ -226,7 +448,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for negative input cost", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:                "Negative Cost",
@@ -243,7 +465,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 
 	t.Run("fails for negative output cost", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			Name:                 "Negative Cost",
@@ -262,7 +484,7 @@ func TestProviderService_CreateProvider(t *testing.T) {
 func TestProviderService_GetProvider(t *testing.T) {
 	t.Run("gets provider successfully", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		// Create a provider
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
@@ -281,7 +503,7 @@ func TestProviderService_GetProvider(t *testing.T) {
 
 	t.Run("fails for non-existent provider", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		_, err := service.GetProvider(1, 999)
 		assert.Error(t, err)
@@ -290,7 +512,7 @@ func TestProviderService_GetProvider(t *testing.T) {
 
 	t.Run("fails for provider belonging to different user", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		// Create a provider for user 1
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
@@ -310,7 +532,7 @@ func TestProviderService_GetProvider(t *testing.T) {
 func TestProviderService_ListProviders(t *testing.T) {
 	t.Run("lists providers for user", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		// Create multiple providers
 		for i := 0; i < 3; i++ {
@@ -329,7 +551,7 @@ func TestProviderService_ListProviders(t *testing.T) {
 
 	t.Run("returns empty list for user with no providers", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		providers, err := service.ListProviders(999)
 		require.NoError(t, err)
@@ -338,7 +560,7 @@ func TestProviderService_ListProviders(t *testing.T) {
 
 	t.Run("only returns providers for specified user", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		// Create providers for two users
 		_, err := service.CreateProvider(1, &CreateProviderRequest{
@@ -366,7 +588,7 @@ func TestProviderService_ListProviders(t *testing.T) {
 func TestProviderService_UpdateProvider(t *testing.T) {
 	t.Run("updates provider name", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "Original Name",
@@ -383,7 +605,7 @@ func TestProviderService_UpdateProvider(t *testing.T) {
 
 	t.Run("updates provider type", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "Test Provider",
@@ -402,7 +624,7 @@ func TestProviderService_UpdateProvider(t *testing.T) {
 
 	t.Run("deactivates provider", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "Test Provider",
@@ -419,7 +641,7 @@ func TestProviderService_UpdateProvider(t *testing.T) {
 
 	t.Run("fails for empty name", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "Test Provider",
@@ -436,7 +658,7 @@ func TestProviderService_UpdateProvider(t *testing.T) {
 
 	t.Run("fails for invalid provider type", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "Test Provider",
@@ -453,7 +675,7 @@ func TestProviderService_UpdateProvider(t *testing.T) {
 
 	t.Run("fails for non-existent provider", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		newName := "New Name"
 		_, err := service.UpdateProvider(1, 999, &UpdateProviderRequest{Name: &newName})
@@ -465,7 +687,7 @@ func TestProviderService_UpdateProvider(t *testing.T) {
 func TestProviderService_DeleteProvider(t *testing.T) {
 	t.Run("deletes provider successfully", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "To Delete",
@@ -484,7 +706,7 @@ func TestProviderService_DeleteProvider(t *testing.T) {
 
 	t.Run("fails for non-existent provider", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		err := service.DeleteProvider(1, 999)
 		assert.Error(t, err)
@@ -493,7 +715,7 @@ func TestProviderService_DeleteProvider(t *testing.T) {
 
 	t.Run("fails for provider belonging to different user", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "User 1 Provider",
@@ -523,7 +745,7 @@ func TestProviderService_TestConnection(t *testing.T) {
 		defer server.Close()
 
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		// Create provider with mock server URL
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
@@ -547,7 +769,7 @@ func TestProviderService_TestConnection(t *testing.T) {
 		defer server.Close()
 
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "Test Provider",
@@ -569,7 +791,7 @@ func TestProviderService_TestConnection(t *testing.T) {
 		defer server.Close()
 
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
 			Name:         "Test Provider",
@@ -585,7 +807,7 @@ func TestProviderService_TestConnection(t *testing.T) {
 
 	t.Run("fails for non-existent provider", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		err := service.TestConnection(1, 999)
 		assert.Error(t, err)
@@ -605,7 +827,7 @@ func TestProviderService_TestConnectionWithRequest(t *testing.T) {
 		defer server.Close()
 
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		req := &CreateProviderRequest{
 			ProviderType: models.ProviderTypeOpenAI,
@@ -616,6 +838,127 @@ func TestProviderService_TestConnectionWithRequest(t *testing.T) {
 		err := service.TestConnectionWithRequest(req)
 		assert.NoError(t, err)
 	})
+
+	t.Run("gives up after the provider's first byte timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		req := &CreateProviderRequest{
+			ProviderType: models.ProviderTypeOpenAI,
+			BaseURL:      server.URL,
+			APIKey:       "test-key",
+			Timeouts:     &models.ProviderTimeouts{FirstByteMs: 50},
+		}
+
+		err := service.TestConnectionWithRequest(req)
+		assert.ErrorIs(t, err, errUpstreamTimeout)
+		_, err = service.FetchAvailableModelsWithRequest(req)
+		assert.ErrorIs(t, err, errUpstreamTimeout)
+	})
+}
+
+func TestProviderService_FetchAvailableModelsOllama(t *testing.T) {
+	t.Run("lists local models from /api/tags without an API key", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/tags", r.URL.Path)
+			assert.Empty(t, r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"models":[{"name":"llama3.2:latest"},{"name":"nomic-embed-text:latest"}]}`))
+		}))
+		defer server.Close()
+
+		db := setupProviderTestDB(t)
+		service := NewProviderService(db, nil)
+
+		req := &CreateProviderRequest{
+			ProviderType: models.ProviderTypeOllama,
+			BaseURL:      server.URL,
+		}
+
+		require.NoError(t, service.TestConnectionWithRequest(req))
+		modelNames, err := service.FetchAvailableModelsWithRequest(req)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"llama3.2:latest", "nomic-embed-text:latest"}, modelNames)
+	})
+}
+
+func TestProviderService_FetchAvailableModelsGemini(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models", r.URL.Path)
+		assert.Equal(t, "gemini-key", r.Header.Get("x-goog-api-key"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models":[
+			{"name":"models/gemini-2.5-flash","supportedGenerationMethods":["generateContent","countTokens"]},
+			{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}
+		]}`))
+	}))
+	defer server.Close()
+
+	service := NewProviderService(setupProviderTestDB(t), nil)
+	modelNames, err := service.FetchAvailableModelsWithRequest(&CreateProviderRequest{
+		ProviderType: models.ProviderTypeGemini,
+		BaseURL:      server.URL,
+		APIKey:       "gemini-key",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gemini-2.5-flash"}, modelNames)
+}
+
+func TestProviderService_FetchAvailableModelsBedrock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/foundation-models", r.URL.Path)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/bedrock/aws4_request")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"modelSummaries":[
+			{"modelId":"anthropic.claude-3-5-haiku-20241022-v1:0","outputModalities":["TEXT"]},
+			{"modelId":"amazon.titan-embed-text-v2:0","outputModalities":["EMBEDDING"]}
+		]}`))
+	}))
+	defer server.Close()
+
+	service := NewProviderService(setupProviderTestDB(t), nil)
+	modelNames, err := service.FetchAvailableModelsWithRequest(&CreateProviderRequest{
+		ProviderType:   models.ProviderTypeBedrock,
+		BaseURL:        server.URL,
+		APIKey:         "secret-access-key",
+		AWSAccessKeyID: "AKIDEXAMPLE",
+		AWSRegion:      "us-east-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"anthropic.claude-3-5-haiku-20241022-v1:0"}, modelNames)
+}
+
+func TestProviderService_FetchAvailableModelsCustom(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/openai/v1/models", r.URL.Path)
+		assert.Equal(t, "custom-key", r.Header.Get("X-Api-Key"))
+		assert.Equal(t, "org-123", r.Header.Get("OpenAI-Organization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"id":"llama-3.3-70b-versatile"}]}`))
+	}))
+	defer server.Close()
+
+	service := NewProviderService(setupProviderTestDB(t), nil)
+	modelNames, err := service.FetchAvailableModelsWithRequest(&CreateProviderRequest{
+		ProviderType: models.ProviderTypeCustom,
+		BaseURL:      server.URL,
+		APIKey:       "custom-key",
+		CustomConfig: &models.CustomProviderConfig{
+			AuthHeader:   "X-Api-Key",
+			ChatPath:     "/openai/v1/chat/completions",
+			ExtraHeaders: map[string]string{"OpenAI-Organization": "org-123"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llama-3.3-70b-versatile"}, modelNames)
 }
 
 func TestProviderModel_GetBaseURL(t *testing.T) {
@@ -663,7 +1006,7 @@ func TestProviderModel_GetBaseURL(t *testing.T) {
 func TestProviderService_GetProviderByIDInternal(t *testing.T) {
 	t.Run("gets provider with API key for internal use", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		// Create a provider
 		created, err := service.CreateProvider(1, &CreateProviderRequest{
@@ -683,7 +1026,7 @@ func TestProviderService_GetProviderByIDInternal(t *testing.T) {
 
 	t.Run("fails for non-existent provider", func(t *testing.T) {
 		db := setupProviderTestDB(t)
-		service := NewProviderService(db)
+		service := NewProviderService(db, nil)
 
 		_, err := service.GetProviderByIDInternal(999)
 		assert.Error(t, err)
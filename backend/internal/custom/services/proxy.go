@@ -3,11 +3,12 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -33,34 +34,43 @@ type ProxyService struct {
 	providerService *ProviderService
 	usageService    *UsageService
 	oauthService    *OAuthService
+	responseService *ResponseService
+	balancer        *LoadBalancer
 }
 
-// NewProxyService creates a new ProxyService instance
-func NewProxyService(keyService *KeyService, providerService *ProviderService, usageService *UsageService, oauthService *OAuthService) *ProxyService {
+// NewProxyService creates a new ProxyService instance. balancer orders providers and tracks their
+// circuit breakers; share it with the ProviderService so the providers API reports the same state.
+func NewProxyService(keyService *KeyService, providerService *ProviderService, usageService *UsageService, oauthService *OAuthService, responseService *ResponseService, balancer *LoadBalancer) *ProxyService {
 	return &ProxyService{
 		keyService:      keyService,
 		providerService: providerService,
 		usageService:    usageService,
 		oauthService:    oauthService,
+		responseService: responseService,
+		balancer:        balancer,
 	}
 }
 
 // OpenAIChatRequest represents an OpenAI-compatible chat completion request
 type OpenAIChatRequest struct {
-	Model            string                 `json:"model"`
-	Messages         []OpenAIMessage        `json:"messages"`
-	MaxTokens        *int                   `json:"max_tokens,omitempty"`
-	Temperature      *float64               `json:"temperature,omitempty"`
-	TopP             *float64               `json:"top_p,omitempty"`
-	N                *int                   `json:"n,omitempty"`
-	Stream           *bool                  `json:"stream,omitempty"`
-	StreamOptions    *OpenAIStreamOptions   `json:"stream_options,omitempty"`
-	Stop             interface{}            `json:"stop,omitempty"`
-	PresencePenalty  *float64               `json:"presence_penalty,omitempty"`
-	FrequencyPenalty *float64               `json:"frequency_penalty,omitempty"`
-	LogitBias        map[string]float64     `json:"logit_bias,omitempty"`
-	User             string                 `json:"user,omitempty"`
-	Extra            map[string]interface{} `json:"-"` // Catch any additional fields
+	Model             string                 `json:"model"`
+	Messages          []OpenAIMessage        `json:"messages"`
+	MaxTokens         *int                   `json:"max_tokens,omitempty"`
+	Temperature       *float64               `json:"temperature,omitempty"`
+	TopP              *float64               `json:"top_p,omitempty"`
+	N                 *int                   `json:"n,omitempty"`
+	Stream            *bool                  `json:"stream,omitempty"`
+	StreamOptions     *OpenAIStreamOptions   `json:"stream_options,omitempty"`
+	Stop              interface{}            `json:"stop,omitempty"`
+	PresencePenalty   *float64               `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float64               `json:"frequency_penalty,omitempty"`
+	LogitBias         map[string]float64     `json:"logit_bias,omitempty"`
+	User              string                 `json:"user,omitempty"`
+	Tools             []OpenAITool           `json:"tools,omitempty"`
+	ToolChoice        interface{}            `json:"tool_choice,omitempty"` // "auto", "none", "required" or {"type":"function",...}
+	ParallelToolCalls *bool                  `json:"parallel_tool_calls,omitempty"`
+	SafetySettings    []GeminiSafetySetting  `json:"safety_settings,omitempty"` // Gemini only; dropped for other providers
+	Extra             map[string]interface{} `json:"-"`                         // Catch any additional fields
 }
 
 // OpenAIStreamOptions represents the stream_options field in OpenAI requests
@@ -70,9 +80,38 @@ type OpenAIStreamOptions struct {
 
 // OpenAIMessage represents a message in the OpenAI format
 type OpenAIMessage struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
-	Name    string      `json:"name,omitempty"`
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`   // Set on assistant messages that call tools
+	ToolCallID string           `json:"tool_call_id,omitempty"` // Set on tool messages carrying a result
+}
+
+// OpenAITool represents a tool definition in an OpenAI request
+type OpenAITool struct {
+	Type     string                   `json:"type"`
+	Function OpenAIFunctionDefinition `json:"function"`
+}
+
+// OpenAIFunctionDefinition describes a callable function and its JSON schema parameters
+type OpenAIFunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// OpenAIToolCall represents a tool call made by the assistant
+type OpenAIToolCall struct {
+	Index    *int               `json:"index,omitempty"` // Only set in streaming deltas
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall holds the function name and JSON-encoded arguments of a tool call
+type OpenAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 // GetContentString returns the message content as a string, handling both string and array formats
@@ -97,16 +136,32 @@ func (m OpenAIMessage) GetContentString() string {
 
 // AnthropicRequest represents an Anthropic API request
 type AnthropicRequest struct {
-	Model         string             `json:"model"`
-	Messages      []AnthropicMessage `json:"messages"`
-	MaxTokens     int                `json:"max_tokens"`
-	System        string             `json:"system,omitempty"`
-	Temperature   *float64           `json:"temperature,omitempty"`
-	TopP          *float64           `json:"top_p,omitempty"`
-	TopK          *int               `json:"top_k,omitempty"`
-	Stream        *bool              `json:"stream,omitempty"`
-	StopSequences []string           `json:"stop_sequences,omitempty"`
-	Metadata      map[string]string  `json:"metadata,omitempty"`
+	Model         string               `json:"model"`
+	Messages      []AnthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	System        string               `json:"system,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	TopP          *float64             `json:"top_p,omitempty"`
+	TopK          *int                 `json:"top_k,omitempty"`
+	Stream        *bool                `json:"stream,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Metadata      map[string]string    `json:"metadata,omitempty"`
+	Tools         []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// AnthropicTool represents a tool definition in an Anthropic request
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// AnthropicToolChoice controls how Anthropic models use the provided tools
+type AnthropicToolChoice struct {
+	Type                   string `json:"type"` // auto, any, tool, none
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse *bool  `json:"disable_parallel_tool_use,omitempty"`
 }
 
 // AnthropicMessage represents a message in the Anthropic format
@@ -115,6 +170,80 @@ type AnthropicMessage struct {
 	Content interface{} `json:"content"` // Can be string or array of content blocks
 }
 
+// OpenAIChatResponse represents an OpenAI-compatible chat.completion response
+type OpenAIChatResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
+}
+
+// OpenAIChoice represents a single choice in a chat.completion response
+type OpenAIChoice struct {
+	Index        int           `json:"index"`
+	Message      OpenAIMessage `json:"message"`
+	FinishReason string        `json:"finish_reason"`
+}
+
+// OpenAIUsage represents token usage in the OpenAI format
+type OpenAIUsage struct {
+	PromptTokens        int                       `json:"prompt_tokens"`
+	CompletionTokens    int                       `json:"completion_tokens"`
+	TotalTokens         int                       `json:"total_tokens"`
+	PromptTokensDetails *OpenAIPromptTokenDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+// OpenAIPromptTokenDetails breaks down prompt tokens (e.g. tokens served from cache)
+type OpenAIPromptTokenDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// AnthropicResponse represents a non-streaming Anthropic message response
+type AnthropicResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   string                  `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+// AnthropicContentBlock represents a content block in an Anthropic message
+type AnthropicContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`    // tool_use
+	Name  string          `json:"name,omitempty"`  // tool_use
+	Input json.RawMessage `json:"input,omitempty"` // tool_use
+}
+
+// AnthropicUsage represents token usage in the Anthropic format
+type AnthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// ToOpenAI converts Anthropic usage to OpenAI usage. OpenAI counts cached tokens
+// as part of the prompt, while Anthropic reports them separately.
+func (u AnthropicUsage) ToOpenAI() *OpenAIUsage {
+	promptTokens := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	usage := &OpenAIUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      promptTokens + u.OutputTokens,
+	}
+	if u.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &OpenAIPromptTokenDetails{CachedTokens: u.CacheReadInputTokens}
+	}
+	return usage
+}
+
 // AnthropicPassthroughRequest represents any Anthropic API request (for passthrough)
 type AnthropicPassthroughRequest struct {
 	Model     string `json:"model"`
@@ -122,6 +251,23 @@ type AnthropicPassthroughRequest struct {
 	// We don't parse other fields - just pass them through
 }
 
+// AnthropicMessagesRequest represents a full Anthropic Messages API request as sent by
+// Anthropic SDK clients. System and message content may be strings or arrays of blocks.
+type AnthropicMessagesRequest struct {
+	Model         string                 `json:"model"`
+	Messages      []AnthropicMessage     `json:"messages"`
+	MaxTokens     int                    `json:"max_tokens"`
+	System        interface{}            `json:"system,omitempty"`
+	Temperature   *float64               `json:"temperature,omitempty"`
+	TopP          *float64               `json:"top_p,omitempty"`
+	TopK          *int                   `json:"top_k,omitempty"`
+	Stream        *bool                  `json:"stream,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Tools         []AnthropicTool        `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolChoice   `json:"tool_choice,omitempty"`
+}
+
 // ModelInfo contains parsed model routing information
 type ModelInfo struct {
 	ProviderType string // openai, anthropic, local
@@ -138,6 +284,11 @@ type ProxyResult struct {
 	RequestDuration time.Duration
 	ErrorMessage    string
 	Model           string
+	AudioSeconds    float64 // Audio transcribed or translated
+	Characters      int     // Text synthesized to speech
+	Images          int     // Images generated or edited
+	Alias           string  // Model alias the client asked for; Model is what it resolved to
+	Attempts        int     // Requests sent to the provider, counting retries
 }
 
 // ValidateKey validates the API key and returns the associated key record
@@ -145,12 +296,37 @@ func (s *ProxyService) ValidateKey(apiKey string) (*models.ProxyAPIKey, error) {
 	return s.keyService.ValidateKey(apiKey)
 }
 
-// GetProviderForModel finds the appropriate provider for a given model and proxy key
+// GetProviderForModel finds the appropriate provider for a given model (or model alias) and proxy key,
+// choosing among several according to the key's routing strategy
 func (s *ProxyService) GetProviderForModel(proxyKey *models.ProxyAPIKey, modelName string) (*models.Provider, error) {
+	resolved, _ := s.ResolveModelAlias(proxyKey, modelName)
+	eligible := s.eligibleProviders(proxyKey, resolved)
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("no allowed provider found for model: %s", modelName)
+	}
+	providers := s.balancer.order(proxyKey, resolved, eligible)
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("%w for model %s: every provider is failing, retry later", ErrCircuitOpen, modelName)
+	}
+	return providers[0], nil
+}
+
+// routingErrorStatus returns the status code for a failure to pick a provider: 503 while circuits
+// are open, since the request may succeed later, and 403 when the key can't use the model at all
+func routingErrorStatus(err error) int {
+	if errors.Is(err, ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusForbidden
+}
+
+// eligibleProviders returns every active provider on the key that may serve the model, in key order
+func (s *ProxyService) eligibleProviders(proxyKey *models.ProxyAPIKey, modelName string) []*models.Provider {
 	// Parse the model name to handle provider prefixes (e.g., "openai/gpt-4o")
 	// For selection logic, we don't have a default provider type yet, so we pass empty
 	modelInfo := s.ParseModelName(modelName, "")
 
+	var providers []*models.Provider
 	for _, ap := range proxyKey.AllowedProviders {
 		// 1. Check if model name matches (considering allowed models list)
 		isAllowed := false
@@ -183,10 +359,10 @@ func (s *ProxyService) GetProviderForModel(proxyKey *models.ProxyAPIKey, modelNa
 			continue
 		}
 
-		return ap.Provider, nil
+		providers = append(providers, ap.Provider)
 	}
 
-	return nil, fmt.Errorf("no allowed provider found for model: %s", modelName)
+	return providers
 }
 
 // ValidateAndGetProvider validated the key and finds a provider.
@@ -211,6 +387,24 @@ func (s *ProxyService) ValidateAndGetProvider(apiKey string) (*models.ProxyAPIKe
 	return nil, nil, fmt.Errorf("no active providers found for this API key")
 }
 
+// ResolveModelAlias returns the model an alias visible to the key points to, along with the alias.
+// Models that are not aliases are returned unchanged with a nil alias.
+func (s *ProxyService) ResolveModelAlias(proxyKey *models.ProxyAPIKey, modelName string) (string, *models.ModelAlias) {
+	if alias, ok := proxyKey.ModelAliases[modelName]; ok {
+		return alias.Target(), alias
+	}
+	return modelName, nil
+}
+
+// applyModelAlias resolves result.Model when the client asked for an alias, keeping the alias
+// in result.Alias so usage records both
+func (s *ProxyService) applyModelAlias(proxyKey *models.ProxyAPIKey, result *ProxyResult) {
+	if resolved, alias := s.ResolveModelAlias(proxyKey, result.Model); alias != nil {
+		result.Alias = result.Model
+		result.Model = resolved
+	}
+}
+
 // ParseModelName parses a LiteLLM-style model name (provider/model) into components
 func (s *ProxyService) ParseModelName(model string, defaultProviderType string) *ModelInfo {
 	info := &ModelInfo{
@@ -251,6 +445,10 @@ func (s *ProxyService) ProxyRequest(c *gin.Context, proxyKey *models.ProxyAPIKey
 	}
 
 	result.Model = chatReq.Model
+	s.applyModelAlias(proxyKey, result)
+
+	// Remember whether the client itself asked for a usage chunk before we force one upstream
+	includeUsage := chatReq.StreamOptions != nil && chatReq.StreamOptions.IncludeUsage
 
 	// If streaming is enabled for OpenAI/compatible, ensure usage is included
 	if chatReq.Stream != nil && *chatReq.Stream {
@@ -260,106 +458,44 @@ func (s *ProxyService) ProxyRequest(c *gin.Context, proxyKey *models.ProxyAPIKey
 		}
 	}
 
-	// Determine which provider to use
-	provider, err := s.GetProviderForModel(proxyKey, chatReq.Model)
+	// Determine which providers to try
+	chain, err := s.GetProviderChain(proxyKey, chatReq.Model)
 	if err != nil {
-		result.StatusCode = http.StatusForbidden
+		result.StatusCode = routingErrorStatus(err)
 		result.ErrorMessage = err.Error()
 		return result, err
 	}
 
-	// For OAuth providers, ensure we have a valid access token
-
-	// Parse the model name
-	modelInfo := s.ParseModelName(chatReq.Model, provider.ProviderType)
-
-	// Determine the target URL and transform request if needed
-	var targetURL string
-	var requestBody []byte
-
-	baseURL := strings.TrimSuffix(provider.GetBaseURL(), "/")
-
-	switch provider.ProviderType {
-	case models.ProviderTypeAnthropic, models.ProviderTypeAnthropicMax:
-		// Anthropic-specific endpoint and transformation
-		if !strings.HasSuffix(baseURL, "/v1") {
-			targetURL = baseURL + "/v1/messages"
-		} else {
-			targetURL = baseURL + "/messages"
-		}
-		requestBody, err = s.transformToAnthropic(&chatReq, modelInfo.ModelName)
-		if err != nil {
-			result.StatusCode = http.StatusBadRequest
-			result.ErrorMessage = fmt.Sprintf("failed to transform request: %v", err)
-			return result, err
-		}
-	case models.ProviderTypeZai, models.ProviderTypeZaiInternational:
-		// ZhipuAI (ZAI) specific OpenAI-compatible endpoint
-		// They usually use /at/completions directly on the v4 base
-		if strings.HasSuffix(baseURL, "/v4") {
-			targetURL = baseURL + "/chat/completions"
-		} else {
-			// If v4 is not there, it might be an older API or a different base
-			targetURL = baseURL + "/v4/chat/completions"
-		}
-		// Update the model name in the request
-		chatReq.Model = modelInfo.ModelName
-		requestBody, err = json.Marshal(chatReq)
-		if err != nil {
-			result.StatusCode = http.StatusInternalServerError
-			result.ErrorMessage = "failed to marshal request"
-			return result, fmt.Errorf("failed to marshal request: %w", err)
-		}
-	default:
-		// OpenAI and other standard OpenAI-compatible providers (Ollama, vLLM, etc.)
-		// Be smart about the /v1 prefix
-		if strings.HasSuffix(baseURL, "/v1") {
-			targetURL = baseURL + "/chat/completions"
-		} else if strings.Contains(baseURL, "api.openai.com") {
-			targetURL = baseURL + "/v1/chat/completions"
-		} else {
-			// For local/generic providers, try with /v1 but allow it to be in baseURL
-			targetURL = baseURL + "/v1/chat/completions"
-		}
-
-		// Update the model name in the request if it was prefixed
-		chatReq.Model = modelInfo.ModelName
-		requestBody, err = json.Marshal(chatReq)
-		if err != nil {
-			result.StatusCode = http.StatusInternalServerError
-			result.ErrorMessage = "failed to marshal request"
-			return result, fmt.Errorf("failed to marshal request: %w", err)
-		}
-	}
-
-	// Create the proxy request
-	proxyReq, err := http.NewRequest(c.Request.Method, targetURL, bytes.NewReader(requestBody))
+	// Send the request, transformed to the provider's native chat API where needed,
+	// moving down the chain while providers fail
+	resp, provider, err := s.sendWithFallback(c, proxyKey, chain, result, func(target providerTarget) (*http.Response, error) {
+		modelInfo := s.ParseModelName(target.Model, target.Provider.ProviderType)
+		// doChatRequest rewrites the model on the request, so each attempt gets its own copy
+		attemptReq := chatReq
+		return s.doChatRequest(c, target.Provider, &attemptReq, modelInfo.ModelName, result)
+	})
 	if err != nil {
-		result.StatusCode = http.StatusInternalServerError
-		result.ErrorMessage = "failed to create proxy request"
-		return result, fmt.Errorf("failed to create proxy request: %w", err)
+		result.RequestDuration = time.Since(startTime)
+		return result, err
 	}
+	defer resp.Body.Close()
 
-	// Copy relevant headers, preserving User-Agent
-	s.copyHeaders(c.Request, proxyReq, provider)
-
-	// Execute the proxy request
-	client := &http.Client{
-		Timeout: 5 * time.Minute, // Long timeout for LLM responses
-	}
+	result.StatusCode = resp.StatusCode
 
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		result.StatusCode = http.StatusBadGateway
-		result.ErrorMessage = fmt.Sprintf("proxy request failed: %v", err)
+	// Stream server-sent events to the client as they arrive
+	if isStreamResponse(resp) && resp.StatusCode < 300 {
+		if translator := providerStreamTranslator(provider, includeUsage); translator != nil {
+			err = s.streamTranslated(c, resp, provider.ProviderType, translator, result)
+		} else {
+			err = s.streamPassthrough(c, resp, provider.ProviderType, result)
+		}
 		result.RequestDuration = time.Since(startTime)
-		return result, fmt.Errorf("proxy request failed: %w", err)
+		s.recordUsage(proxyKey, provider, result)
+		return result, err
 	}
-	defer resp.Body.Close()
 
 	// Record timing
 	result.RequestDuration = time.Since(startTime)
-	result.StatusCode = resp.StatusCode
 
 	// Read the response body
 	respBody, err := io.ReadAll(resp.Body)
@@ -374,15 +510,22 @@ func (s *ProxyService) ProxyRequest(c *gin.Context, proxyKey *models.ProxyAPIKey
 	// Record usage asynchronously (non-blocking)
 	s.recordUsage(proxyKey, provider, result)
 
-	// Copy response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
+	// Providers with their own chat API answer in their native format; translate back for OpenAI clients
+	contentType := resp.Header.Get("Content-Type")
+	if !provider.IsOpenAICompatible() {
+		respBody, err = s.chatResponseBody(provider, resp.StatusCode, respBody)
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("failed to transform response: %v", err)
+			return result, fmt.Errorf("failed to transform response: %w", err)
 		}
+		contentType = "application/json"
 	}
 
+	// Copy response headers
+	copyResponseHeaders(c, resp.Header)
+
 	// Write the response
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	c.Data(resp.StatusCode, contentType, respBody)
 
 	return result, nil
 }
@@ -407,71 +550,183 @@ func (s *ProxyService) ProxyAnthropicPassthrough(c *gin.Context, proxyKey *model
 		return result, fmt.Errorf("failed to parse request body: %w", err)
 	}
 	result.Model = anthropicReq.Model
+	s.applyModelAlias(proxyKey, result)
 
-	// Determine which provider to use
-	provider, err := s.GetProviderForModel(proxyKey, result.Model)
+	// Determine which providers to try
+	chain, err := s.GetProviderChain(proxyKey, anthropicReq.Model)
 	if err != nil {
-		result.StatusCode = http.StatusForbidden
+		result.StatusCode = routingErrorStatus(err)
 		result.ErrorMessage = err.Error()
 		return result, err
 	}
 
-	// Verify provider supports Anthropic format
+	// Providers that don't speak the Messages API get a translated chat request
+	resp, provider, err := s.sendWithFallback(c, proxyKey, chain, result, func(target providerTarget) (*http.Response, error) {
+		if !target.Provider.IsAnthropicProvider() {
+			return s.sendMessagesViaChat(c, target, bodyBytes, result)
+		}
+		// The body goes through untouched unless a fallback substitutes the model
+		model := ""
+		if target.Model != anthropicReq.Model {
+			model = s.ParseModelName(target.Model, target.Provider.ProviderType).ModelName
+		}
+		return s.sendAnthropicMessages(c, target.Provider, bodyBytes, model, result)
+	})
+	if err != nil {
+		result.RequestDuration = time.Since(startTime)
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	if !provider.IsAnthropicProvider() {
+		return s.writeMessagesViaChat(c, proxyKey, provider, resp, result, startTime)
+	}
+
+	// Stream server-sent events to the client as they arrive
+	if isEventStream(resp) && resp.StatusCode < 300 {
+		err := s.streamPassthrough(c, resp, models.ProviderTypeAnthropic, result)
+		result.RequestDuration = time.Since(startTime)
+		s.recordUsage(proxyKey, provider, result)
+		return result, err
+	}
 
-	// Build the target URL
-	baseURL := provider.GetBaseURL()
-	targetURL := strings.TrimSuffix(baseURL, "/") + "/v1/messages"
+	// Record timing
+	result.RequestDuration = time.Since(startTime)
 
-	// Create the proxy request
-	proxyReq, err := http.NewRequest(c.Request.Method, targetURL, bytes.NewReader(bodyBytes))
+	// Read the response body
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		result.StatusCode = http.StatusInternalServerError
-		result.ErrorMessage = "failed to create proxy request"
-		return result, fmt.Errorf("failed to create proxy request: %w", err)
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Extract usage information from Anthropic response
+	if result.StatusCode >= 200 && result.StatusCode < 300 {
+		s.extractAnthropicUsage(respBody, result)
 	}
 
-	// Copy headers from original request
-	for key, values := range c.Request.Header {
+	// Record usage asynchronously (non-blocking)
+	s.recordUsage(proxyKey, provider, result)
+
+	// Copy response headers
+	copyResponseHeaders(c, resp.Header)
+
+	// Write the response
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+
+	return result, nil
+}
+
+// copyAnthropicHeaders forwards the client's headers (anthropic-beta etc.) to an Anthropic
+// provider, replacing the client's credentials with the provider's
+func (s *ProxyService) copyAnthropicHeaders(original *http.Request, proxy *http.Request, provider *models.Provider) {
+	for key, values := range original.Header {
 		for _, value := range values {
 			// Skip headers we'll set ourselves
-			if strings.ToLower(key) == "authorization" || strings.ToLower(key) == "x-api-key" || strings.ToLower(key) == "host" {
+			switch strings.ToLower(key) {
+			case "authorization", "x-api-key", "api-key", "host":
 				continue
 			}
-			proxyReq.Header.Add(key, value)
+			proxy.Header.Add(key, value)
 		}
 	}
 
 	// Set auth headers based on provider type
 	switch provider.ProviderType {
 	case models.ProviderTypeAnthropic:
-		proxyReq.Header.Set("x-api-key", provider.APIKey)
+		proxy.Header.Set("x-api-key", provider.APIKey)
 	case models.ProviderTypeAnthropicMax:
-		proxyReq.Header.Set("Authorization", "Bearer "+provider.AccessToken)
+		proxy.Header.Set("Authorization", "Bearer "+provider.AccessToken)
 	}
-	proxyReq.Header.Set("anthropic-version", AnthropicVersion)
+	proxy.Header.Set("anthropic-version", AnthropicVersion)
 
 	// Ensure content type is set
-	if proxyReq.Header.Get("Content-Type") == "" {
-		proxyReq.Header.Set("Content-Type", "application/json")
+	if proxy.Header.Get("Content-Type") == "" {
+		proxy.Header.Set("Content-Type", "application/json")
+	}
+}
+
+// sendAnthropicMessages forwards a Messages request body to an Anthropic provider, replacing the
+// model first when model is set. On failure it fills in result and returns an error.
+func (s *ProxyService) sendAnthropicMessages(c *gin.Context, provider *models.Provider, bodyBytes []byte, model string, result *ProxyResult) (*http.Response, error) {
+	if model != "" {
+		var err error
+		if bodyBytes, err = withRequestModel(bodyBytes, model); err != nil {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = "invalid request body"
+			return nil, err
+		}
 	}
 
-	// Execute the proxy request
-	client := &http.Client{
-		Timeout: 5 * time.Minute, // Long timeout for LLM responses
+	// Create the proxy request (bound to the client request so a disconnect cancels it)
+	proxyReq, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, anthropicMessagesURL(provider), bytes.NewReader(bodyBytes))
+	if err != nil {
+		result.StatusCode = http.StatusInternalServerError
+		result.ErrorMessage = "failed to create proxy request"
+		return nil, fmt.Errorf("failed to create proxy request: %w", err)
 	}
 
-	resp, err := client.Do(proxyReq)
+	// Forward the client's Anthropic headers with the provider's credentials
+	s.copyAnthropicHeaders(c.Request, proxyReq, provider)
+
+	// Execute the proxy request under the provider's timeouts
+	resp, err := sendUpstream(proxyReq, provider)
 	if err != nil {
 		result.StatusCode = http.StatusBadGateway
 		result.ErrorMessage = fmt.Sprintf("proxy request failed: %v", err)
+		return nil, fmt.Errorf("proxy request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// withRequestModel returns a JSON request body with its model replaced, leaving every other field as sent
+func withRequestModel(body []byte, model string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse request body: %w", err)
+	}
+	fields["model"], _ = json.Marshal(model)
+	return json.Marshal(fields)
+}
+
+// sendMessagesViaChat sends an Anthropic Messages request to a non-Anthropic provider by
+// translating it to a chat completions request
+func (s *ProxyService) sendMessagesViaChat(c *gin.Context, target providerTarget, bodyBytes []byte, result *ProxyResult) (*http.Response, error) {
+	var messagesReq AnthropicMessagesRequest
+	if err := json.Unmarshal(bodyBytes, &messagesReq); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid request body"
+		return nil, fmt.Errorf("failed to parse request body: %w", err)
+	}
+
+	modelInfo := s.ParseModelName(target.Model, target.Provider.ProviderType)
+	chatReq, err := s.transformAnthropicToOpenAI(&messagesReq, modelInfo.ModelName)
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = fmt.Sprintf("failed to transform request: %v", err)
+		return nil, err
+	}
+
+	// The client sent Anthropic headers; doChatRequest only forwards what makes sense for the provider
+	return s.doChatRequest(c, target.Provider, chatReq, modelInfo.ModelName, result)
+}
+
+// writeMessagesViaChat translates a non-Anthropic provider's chat response (or stream) back to
+// Anthropic message events for the client
+func (s *ProxyService) writeMessagesViaChat(c *gin.Context, proxyKey *models.ProxyAPIKey, provider *models.Provider, resp *http.Response, result *ProxyResult, startTime time.Time) (*ProxyResult, error) {
+	// Convert chat.completion.chunk events into Anthropic message events as they arrive
+	if isStreamResponse(resp) && resp.StatusCode < 300 {
+		translator := chatStreamTranslator(provider, true, newOpenAIToAnthropicStreamTranslator(result.Model))
+		err := s.streamTranslated(c, resp, provider.ProviderType, translator, result)
 		result.RequestDuration = time.Since(startTime)
-		return result, fmt.Errorf("proxy request failed: %w", err)
+		s.recordUsage(proxyKey, provider, result)
+		return result, err
 	}
-	defer resp.Body.Close()
 
 	// Record timing
 	result.RequestDuration = time.Since(startTime)
-	result.StatusCode = resp.StatusCode
 
 	// Read the response body
 	respBody, err := io.ReadAll(resp.Body)
@@ -480,109 +735,364 @@ func (s *ProxyService) ProxyAnthropicPassthrough(c *gin.Context, proxyKey *model
 		return result, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Extract usage information from Anthropic response
-	s.extractAnthropicUsage(respBody, result)
+	// Extract usage information from the upstream response
+	s.extractUsageFromResponse(respBody, provider.ProviderType, result)
 
 	// Record usage asynchronously (non-blocking)
 	s.recordUsage(proxyKey, provider, result)
 
-	// Copy response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
+	respBody, err = s.chatResponseBody(provider, resp.StatusCode, respBody)
+	if err == nil {
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			respBody, err = s.transformOpenAIResponseToAnthropic(respBody)
+		} else {
+			respBody = s.transformOpenAIErrorToAnthropic(resp.StatusCode, respBody)
 		}
 	}
+	if err != nil {
+		result.ErrorMessage = fmt.Sprintf("failed to transform response: %v", err)
+		return result, fmt.Errorf("failed to transform response: %w", err)
+	}
 
-	// Write the response
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	copyResponseHeaders(c, resp.Header)
+	c.Data(resp.StatusCode, "application/json", respBody)
 
 	return result, nil
 }
 
-// ProxyWithReverseProxy uses httputil.ReverseProxy for streaming support
-func (s *ProxyService) ProxyWithReverseProxy(c *gin.Context, provider *models.Provider, modelInfo *ModelInfo) error {
-	baseURL := provider.GetBaseURL()
-	if baseURL == "" {
-		return fmt.Errorf("no base URL configured for provider")
-	}
-
-	// For OAuth providers, ensure we have a valid access token
-	if provider.IsOAuthProvider() {
-		if !provider.OAuthConnected {
-			return fmt.Errorf("OAuth not connected for this provider")
-		}
-		if s.oauthService != nil {
-			if err := s.oauthService.EnsureValidToken(provider); err != nil {
-				return fmt.Errorf("failed to refresh OAuth token: %w", err)
-			}
-		}
-	}
+// doChatRequest sends an OpenAI chat request to the provider, translating it to the Messages API
+// for Anthropic providers, to /api/chat for Ollama, to generateContent for Gemini and to Converse for Bedrock.
+// On failure result carries the status code and error message; on success the caller must close the response body.
+func (s *ProxyService) doChatRequest(c *gin.Context, provider *models.Provider, chatReq *OpenAIChatRequest, modelName string, result *ProxyResult) (*http.Response, error) {
+	var targetURL string
+	var requestBody []byte
+	var err error
 
-	// Determine target path based on provider type
-	var targetPath string
 	switch provider.ProviderType {
 	case models.ProviderTypeAnthropic, models.ProviderTypeAnthropicMax:
-		targetPath = "/v1/messages"
+		// Anthropic-specific endpoint and transformation
+		targetURL = anthropicMessagesURL(provider)
+		requestBody, err = s.transformToAnthropic(chatReq, modelName)
+		if err != nil {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = fmt.Sprintf("failed to transform request: %v", err)
+			return nil, err
+		}
+	case models.ProviderTypeOllama:
+		// Ollama's native chat API
+		targetURL = ollamaEndpointURL(provider, "/api/chat")
+		requestBody, err = s.transformToOllama(chatReq, modelName)
+		if err != nil {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = fmt.Sprintf("failed to transform request: %v", err)
+			return nil, err
+		}
+	case models.ProviderTypeGemini:
+		// Gemini's generateContent API; the model and streaming mode are part of the URL
+		targetURL = geminiGenerateURL(provider, modelName, chatReq.Stream != nil && *chatReq.Stream)
+		requestBody, err = s.transformToGemini(chatReq)
+		if err != nil {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = fmt.Sprintf("failed to transform request: %v", err)
+			return nil, err
+		}
+	case models.ProviderTypeBedrock:
+		// Bedrock's Converse API; the model and streaming mode are part of the URL
+		targetURL = bedrockConverseURL(provider, modelName, chatReq.Stream != nil && *chatReq.Stream)
+		requestBody, err = s.transformToBedrock(chatReq)
+		if err != nil {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = fmt.Sprintf("failed to transform request: %v", err)
+			return nil, err
+		}
 	default:
-		targetPath = "/v1/chat/completions"
+		// OpenAI and other OpenAI-compatible providers (vLLM, local, zai, etc.)
+		targetURL = openAIChatCompletionsURL(provider, modelName)
+
+		// Update the model name in the request if it was prefixed
+		chatReq.Model = modelName
+		chatReq.SafetySettings = nil
+		requestBody, err = json.Marshal(chatReq)
+		if err != nil {
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to marshal request"
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
 	}
 
-	targetURL, err := url.Parse(baseURL + targetPath)
+	// Create the proxy request (bound to the client request so a disconnect cancels it)
+	proxyReq, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, targetURL, bytes.NewReader(requestBody))
 	if err != nil {
-		return fmt.Errorf("failed to parse target URL: %w", err)
+		result.StatusCode = http.StatusInternalServerError
+		result.ErrorMessage = "failed to create proxy request"
+		return nil, fmt.Errorf("failed to create proxy request: %w", err)
 	}
 
-	proxy := &httputil.ReverseProxy{
-		Rewrite: func(r *httputil.ProxyRequest) {
-			r.SetURL(targetURL)
-			r.Out.Host = targetURL.Host
-
-			// Preserve client User-Agent (use slice assignment per Go docs)
-			if userAgent := r.In.Header.Get("User-Agent"); userAgent != "" {
-				r.Out.Header["User-Agent"] = []string{userAgent}
-			} else {
-				r.Out.Header["User-Agent"] = []string{DefaultUserAgent}
-			}
+	// Copy relevant headers, preserving User-Agent
+	s.copyHeaders(c.Request, proxyReq, provider)
+	proxyReq.Header.Set("Content-Type", "application/json")
 
-			// Set provider-specific auth headers
-			switch provider.ProviderType {
-			case models.ProviderTypeAnthropic:
-				r.Out.Header.Set("x-api-key", provider.APIKey)
-				r.Out.Header.Set("anthropic-version", AnthropicVersion)
-				// Remove Authorization header if present
-				r.Out.Header.Del("Authorization")
-			case models.ProviderTypeAnthropicMax:
-				r.Out.Header.Set("Authorization", "Bearer "+provider.AccessToken)
-				r.Out.Header.Set("anthropic-version", AnthropicVersion)
-			default:
-				r.Out.Header.Set("Authorization", "Bearer "+provider.APIKey)
-			}
+	// Bedrock signs the final headers and body rather than sending a static key
+	if provider.ProviderType == models.ProviderTypeBedrock {
+		signBedrockRequest(proxyReq, requestBody, provider)
+	}
 
-			// Ensure Content-Type is set
-			if r.Out.Header.Get("Content-Type") == "" {
-				r.Out.Header.Set("Content-Type", "application/json")
-			}
-		},
+	// Execute the proxy request under the provider's timeouts
+	resp, err := sendUpstream(proxyReq, provider)
+	if err != nil {
+		result.StatusCode = http.StatusBadGateway
+		result.ErrorMessage = fmt.Sprintf("proxy request failed: %v", err)
+		return nil, fmt.Errorf("proxy request failed: %w", err)
 	}
 
-	proxy.ServeHTTP(c.Writer, c.Request)
-	return nil
+	return resp, nil
 }
 
-// transformToAnthropic transforms an OpenAI-format request to Anthropic format
-func (s *ProxyService) transformToAnthropic(req *OpenAIChatRequest, modelName string) ([]byte, error) {
-	anthropicReq := AnthropicRequest{
-		Model: modelName,
+// chatResponseBody returns a buffered response to doChatRequest as chat.completion JSON
+// (or an OpenAI error body), converting from the provider's native format where needed
+func (s *ProxyService) chatResponseBody(provider *models.Provider, statusCode int, body []byte) ([]byte, error) {
+	success := statusCode >= 200 && statusCode < 300
+
+	switch provider.ProviderType {
+	case models.ProviderTypeAnthropic, models.ProviderTypeAnthropicMax:
+		if success {
+			return s.transformAnthropicResponse(body)
+		}
+		return s.transformAnthropicError(body)
+	case models.ProviderTypeOllama:
+		if success {
+			return s.transformOllamaResponse(body)
+		}
+		return transformOllamaError(statusCode, body), nil
+	case models.ProviderTypeGemini:
+		if success {
+			return s.transformGeminiResponse(body)
+		}
+		return transformGeminiError(body), nil
+	case models.ProviderTypeBedrock:
+		if success {
+			return s.transformBedrockResponse(body)
+		}
+		return transformBedrockError(statusCode, body), nil
+	default:
+		return body, nil
 	}
+}
 
-	// Handle max_tokens - required for Anthropic
-	if req.MaxTokens != nil {
-		anthropicReq.MaxTokens = *req.MaxTokens
-	} else {
-		anthropicReq.MaxTokens = DefaultMaxTokens
+// providerStreamTranslator returns the translator that turns a provider's native stream into
+// chat.completion.chunk events, or nil for OpenAI-compatible providers
+func providerStreamTranslator(provider *models.Provider, includeUsage bool) streamTranslator {
+	switch provider.ProviderType {
+	case models.ProviderTypeAnthropic, models.ProviderTypeAnthropicMax:
+		return newAnthropicStreamTranslator(includeUsage)
+	case models.ProviderTypeOllama:
+		return newOllamaStreamTranslator(includeUsage)
+	case models.ProviderTypeGemini:
+		return newGeminiStreamTranslator(includeUsage)
+	case models.ProviderTypeBedrock:
+		return newBedrockStreamTranslator(includeUsage)
+	default:
+		return nil
 	}
+}
 
-	// Copy optional parameters
+// chatStreamTranslator wraps next so that it receives chat.completion.chunk events
+// whatever format the provider streams in
+func chatStreamTranslator(provider *models.Provider, includeUsage bool, next streamTranslator) streamTranslator {
+	if translator := providerStreamTranslator(provider, includeUsage); translator != nil {
+		return chainStreamTranslators(translator, next)
+	}
+	return next
+}
+
+// anthropicMessagesURL returns the Messages API endpoint of an Anthropic provider
+func anthropicMessagesURL(provider *models.Provider) string {
+	baseURL := strings.TrimSuffix(provider.GetBaseURL(), "/")
+	if strings.HasSuffix(baseURL, "/v1") {
+		return baseURL + "/messages"
+	}
+	return baseURL + "/v1/messages"
+}
+
+// openAIChatCompletionsURL returns the chat completions endpoint of an OpenAI-compatible provider
+func openAIChatCompletionsURL(provider *models.Provider, modelName string) string {
+	return openAIEndpointURL(provider, modelName, "/chat/completions")
+}
+
+// openAIEndpointURL joins an OpenAI API path (e.g. "/embeddings") onto an OpenAI-compatible provider's base URL.
+// modelName (without provider prefix) only matters for Azure OpenAI deployments and custom {model} path templates.
+func openAIEndpointURL(provider *models.Provider, modelName, path string) string {
+	baseURL := strings.TrimSuffix(provider.GetBaseURL(), "/")
+
+	switch provider.ProviderType {
+	case models.ProviderTypeAzureOpenAI:
+		// https://{resource}.openai.azure.com/openai/deployments/{deployment}/chat/completions?api-version=...
+		baseURL = strings.TrimSuffix(baseURL, "/openai")
+		return baseURL + "/openai/deployments/" + url.PathEscape(provider.AzureDeployment(modelName)) + path +
+			"?api-version=" + url.QueryEscape(provider.GetAPIVersion())
+	case models.ProviderTypeCustom:
+		// Paths come from the provider's config; anything without its own template sits beside chat completions
+		cfg := provider.GetCustomConfig()
+		var template string
+		switch path {
+		case "/chat/completions":
+			template = cfg.ChatPath
+		case "/embeddings":
+			template = cfg.EmbeddingsPath
+		case "/models":
+			template = cfg.ModelsPath
+		default:
+			template = strings.TrimSuffix(cfg.ChatPath, "/chat/completions") + path
+		}
+		return baseURL + strings.ReplaceAll(template, "{model}", url.PathEscape(modelName))
+	case models.ProviderTypeZai, models.ProviderTypeZaiInternational:
+		// ZhipuAI (ZAI) serves its OpenAI-compatible API directly on the v4 base
+		if strings.HasSuffix(baseURL, "/v4") {
+			return baseURL + path
+		}
+		// If v4 is not there, it might be an older API or a different base
+		return baseURL + "/v4" + path
+	default:
+		// Be smart about the /v1 prefix; local/generic providers may include it in the base URL
+		if strings.HasSuffix(baseURL, "/v1") {
+			return baseURL + path
+		}
+		return baseURL + "/v1" + path
+	}
+}
+
+// forwardOpenAIResponse records usage from an OpenAI-compatible provider's response and writes
+// the response (or event stream) back to the client unchanged
+func (s *ProxyService) forwardOpenAIResponse(c *gin.Context, proxyKey *models.ProxyAPIKey, provider *models.Provider, resp *http.Response, result *ProxyResult, startTime time.Time) (*ProxyResult, error) {
+	result.StatusCode = resp.StatusCode
+
+	// Stream server-sent events to the client as they arrive
+	if isEventStream(resp) && resp.StatusCode < 300 {
+		err := s.streamPassthrough(c, resp, provider.ProviderType, result)
+		result.RequestDuration = time.Since(startTime)
+		s.recordUsage(proxyKey, provider, result)
+		return result, err
+	}
+
+	// Record timing
+	result.RequestDuration = time.Since(startTime)
+
+	// Read the response body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Extract usage information from response if available
+	s.extractUsageFromResponse(respBody, provider.ProviderType, result)
+
+	// Record usage asynchronously (non-blocking)
+	s.recordUsage(proxyKey, provider, result)
+
+	// Copy response headers and write the response
+	copyResponseHeaders(c, resp.Header)
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+
+	return result, nil
+}
+
+// sendOpenAIRequest posts a body to an OpenAI-compatible endpoint with the provider's credentials.
+// On failure it fills in result and returns an error; otherwise the caller owns the response body.
+func (s *ProxyService) sendOpenAIRequest(c *gin.Context, provider *models.Provider, targetURL, contentType string, body io.Reader, result *ProxyResult, startTime time.Time) (*http.Response, error) {
+	// Create the proxy request (bound to the client request so a disconnect cancels it)
+	proxyReq, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, targetURL, body)
+	if err != nil {
+		result.StatusCode = http.StatusInternalServerError
+		result.ErrorMessage = "failed to create proxy request"
+		return nil, fmt.Errorf("failed to create proxy request: %w", err)
+	}
+
+	// Copy relevant headers, preserving User-Agent
+	s.copyHeaders(c.Request, proxyReq, provider)
+	proxyReq.Header.Set("Content-Type", contentType)
+
+	// Execute the proxy request under the provider's timeouts
+	resp, err := sendUpstream(proxyReq, provider)
+	if err != nil {
+		result.StatusCode = http.StatusBadGateway
+		result.ErrorMessage = fmt.Sprintf("proxy request failed: %v", err)
+		result.RequestDuration = time.Since(startTime)
+		return nil, fmt.Errorf("proxy request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// streamPassthrough forwards an upstream SSE stream to the client event by event,
+// flushing after each one and accumulating token usage as the events pass through
+func (s *ProxyService) streamPassthrough(c *gin.Context, resp *http.Response, providerType string, result *ProxyResult) error {
+	writeStreamHeaders(c, resp.Header, resp.StatusCode)
+
+	reader := newSSEReader(resp.Body)
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("stream interrupted: %v", err)
+			return fmt.Errorf("failed to read upstream stream: %w", err)
+		}
+
+		s.accumulateStreamUsage(ev.Data, providerType, result)
+
+		if err := writeSSE(c, ev.Raw); err != nil {
+			result.ErrorMessage = "client disconnected during stream"
+			return fmt.Errorf("failed to write stream to client: %w", err)
+		}
+	}
+}
+
+// streamTranslated reads an upstream SSE (or NDJSON) stream, converts each event with the given
+// translator and flushes the translated events to the client as they are produced
+func (s *ProxyService) streamTranslated(c *gin.Context, resp *http.Response, providerType string, translator streamTranslator, result *ProxyResult) error {
+	writeStreamHeaders(c, resp.Header, resp.StatusCode)
+
+	reader := newStreamEventReader(resp)
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("stream interrupted: %v", err)
+			return fmt.Errorf("failed to read upstream stream: %w", err)
+		}
+
+		s.accumulateStreamUsage(ev.Data, providerType, result)
+
+		if err := writeSSEEvents(c, translator.Translate(ev)); err != nil {
+			result.ErrorMessage = "client disconnected during stream"
+			return fmt.Errorf("failed to write stream to client: %w", err)
+		}
+	}
+
+	if err := writeSSEEvents(c, translator.Finish()); err != nil {
+		result.ErrorMessage = "client disconnected during stream"
+		return fmt.Errorf("failed to write stream to client: %w", err)
+	}
+	return nil
+}
+
+// transformToAnthropic transforms an OpenAI-format request to Anthropic format
+func (s *ProxyService) transformToAnthropic(req *OpenAIChatRequest, modelName string) ([]byte, error) {
+	anthropicReq := AnthropicRequest{
+		Model: modelName,
+	}
+
+	// Handle max_tokens - required for Anthropic
+	if req.MaxTokens != nil {
+		anthropicReq.MaxTokens = *req.MaxTokens
+	} else {
+		anthropicReq.MaxTokens = DefaultMaxTokens
+	}
+
+	// Copy optional parameters
 	if req.Temperature != nil {
 		anthropicReq.Temperature = req.Temperature
 	}
@@ -594,31 +1104,84 @@ func (s *ProxyService) transformToAnthropic(req *OpenAIChatRequest, modelName st
 	}
 
 	// Handle stop sequences
-	if req.Stop != nil {
-		switch v := req.Stop.(type) {
-		case string:
-			anthropicReq.StopSequences = []string{v}
-		case []interface{}:
-			for _, item := range v {
-				if s, ok := item.(string); ok {
-					anthropicReq.StopSequences = append(anthropicReq.StopSequences, s)
-				}
-			}
+	anthropicReq.StopSequences = stopSequences(req.Stop)
+
+	// Translate tool definitions and tool choice
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		schema := tool.Function.Parameters
+		if len(schema) == 0 || string(schema) == "null" {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
 		}
+		anthropicReq.Tools = append(anthropicReq.Tools, AnthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
 	}
+	anthropicReq.ToolChoice = openAIToolChoiceToAnthropic(req.ToolChoice, req.ParallelToolCalls)
 
 	// Transform messages - extract system message to separate field
 	for _, msg := range req.Messages {
 		content := msg.GetContentString()
 		switch msg.Role {
-		case "system":
+		case "system", "developer":
 			// Anthropic uses a separate system field, not a system message
 			if anthropicReq.System == "" {
 				anthropicReq.System = content
 			} else {
 				anthropicReq.System += "\n\n" + content
 			}
-		case "user", "assistant":
+		case "assistant":
+			if len(msg.ToolCalls) == 0 {
+				anthropicReq.Messages = append(anthropicReq.Messages, AnthropicMessage{
+					Role:    msg.Role,
+					Content: msg.Content,
+				})
+				continue
+			}
+
+			// Assistant tool calls become tool_use blocks after any text
+			var blocks []AnthropicContentBlock
+			if content != "" {
+				blocks = append(blocks, AnthropicContentBlock{Type: "text", Text: content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage(`{}`)
+				}
+				blocks = append(blocks, AnthropicContentBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: input,
+				})
+			}
+			anthropicReq.Messages = append(anthropicReq.Messages, AnthropicMessage{
+				Role:    "assistant",
+				Content: blocks,
+			})
+		case "tool":
+			// Tool results go back as tool_result blocks in a user turn; consecutive
+			// results for the same assistant turn must share a single message
+			block := map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     content,
+			}
+			if last := len(anthropicReq.Messages) - 1; last >= 0 && isToolResultMessage(anthropicReq.Messages[last]) {
+				blocks := anthropicReq.Messages[last].Content.([]map[string]interface{})
+				anthropicReq.Messages[last].Content = append(blocks, block)
+				continue
+			}
+			anthropicReq.Messages = append(anthropicReq.Messages, AnthropicMessage{
+				Role:    "user",
+				Content: []map[string]interface{}{block},
+			})
+		case "user":
 			anthropicReq.Messages = append(anthropicReq.Messages, AnthropicMessage{
 				Role:    msg.Role,
 				Content: msg.Content,
@@ -640,6 +1203,987 @@ func (s *ProxyService) transformToAnthropic(req *OpenAIChatRequest, modelName st
 	return json.Marshal(anthropicReq)
 }
 
+// isToolResultMessage reports whether a translated message holds only tool_result blocks
+func isToolResultMessage(msg AnthropicMessage) bool {
+	blocks, ok := msg.Content.([]map[string]interface{})
+	return ok && msg.Role == "user" && len(blocks) > 0 && blocks[0]["type"] == "tool_result"
+}
+
+// openAIToolChoiceToAnthropic maps OpenAI tool_choice and parallel_tool_calls to an Anthropic tool_choice
+func openAIToolChoiceToAnthropic(toolChoice interface{}, parallelToolCalls *bool) *AnthropicToolChoice {
+	var choice *AnthropicToolChoice
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			choice = &AnthropicToolChoice{Type: "auto"}
+		case "none":
+			choice = &AnthropicToolChoice{Type: "none"}
+		case "required":
+			choice = &AnthropicToolChoice{Type: "any"}
+		}
+	case map[string]interface{}:
+		if fn, ok := v["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				choice = &AnthropicToolChoice{Type: "tool", Name: name}
+			}
+		}
+	}
+
+	if parallelToolCalls != nil && !*parallelToolCalls {
+		if choice == nil {
+			choice = &AnthropicToolChoice{Type: "auto"}
+		}
+		if choice.Type != "none" {
+			disable := true
+			choice.DisableParallelToolUse = &disable
+		}
+	}
+
+	return choice
+}
+
+// OpenAIChatChunk represents an OpenAI chat.completion.chunk streaming event
+type OpenAIChatChunk struct {
+	ID      string              `json:"id"`
+	Object  string              `json:"object"`
+	Created int64               `json:"created"`
+	Model   string              `json:"model"`
+	Choices []OpenAIChunkChoice `json:"choices"`
+	Usage   *OpenAIUsage        `json:"usage,omitempty"`
+}
+
+// OpenAIChunkChoice represents a single choice in a chat.completion.chunk
+type OpenAIChunkChoice struct {
+	Index        int              `json:"index"`
+	Delta        OpenAIChunkDelta `json:"delta"`
+	FinishReason *string          `json:"finish_reason"`
+}
+
+// OpenAIChunkDelta represents the incremental message content in a chunk
+type OpenAIChunkDelta struct {
+	Role      string           `json:"role,omitempty"`
+	Content   *string          `json:"content,omitempty"`
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
+}
+
+// streamTranslator converts upstream stream events into the events sent to the client
+type streamTranslator interface {
+	// Translate converts one upstream event into zero or more client events
+	Translate(ev *sseEvent) []sseEvent
+	// Finish returns any events that must be sent after the upstream stream ends
+	Finish() []sseEvent
+}
+
+// chainedStreamTranslator feeds the output of one translator into another, e.g. Anthropic
+// events -> chat.completion.chunk -> Responses API events
+type chainedStreamTranslator struct {
+	first  streamTranslator
+	second streamTranslator
+}
+
+// chainStreamTranslators creates a translator that applies first, then second
+func chainStreamTranslators(first, second streamTranslator) streamTranslator {
+	return &chainedStreamTranslator{first: first, second: second}
+}
+
+// Translate implements streamTranslator
+func (t *chainedStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	var out []sseEvent
+	for _, intermediate := range t.first.Translate(ev) {
+		intermediate := intermediate
+		out = append(out, t.second.Translate(&intermediate)...)
+	}
+	return out
+}
+
+// Finish implements streamTranslator
+func (t *chainedStreamTranslator) Finish() []sseEvent {
+	var out []sseEvent
+	for _, intermediate := range t.first.Finish() {
+		intermediate := intermediate
+		out = append(out, t.second.Translate(&intermediate)...)
+	}
+	return append(out, t.second.Finish()...)
+}
+
+// chatChunkEmitter holds the state shared by the translators that turn a native provider stream
+// into chat.completion.chunk events, and builds those events. Translators embed it and get Finish
+// from it.
+type chatChunkEmitter struct {
+	includeUsage bool
+
+	id      string
+	model   string
+	created int64
+	usage   *OpenAIUsage
+	done    bool
+}
+
+// newChatChunkEmitter creates an emitter with a fresh completion id; includeUsage adds a final
+// usage chunk as requested by the client's stream_options.include_usage
+func newChatChunkEmitter(includeUsage bool) chatChunkEmitter {
+	return chatChunkEmitter{
+		includeUsage: includeUsage,
+		id:           newResponsesID("chatcmpl-"),
+		created:      time.Now().Unix(),
+	}
+}
+
+// Finish implements streamTranslator, sending the usage chunk and [DONE] unless the stream has
+// already ended
+func (e *chatChunkEmitter) Finish() []sseEvent {
+	if e.done {
+		return nil
+	}
+	e.done = true
+
+	var events []sseEvent
+	if e.includeUsage && e.usage != nil {
+		payload, _ := json.Marshal(OpenAIChatChunk{
+			ID:      e.id,
+			Object:  "chat.completion.chunk",
+			Created: e.created,
+			Model:   e.model,
+			Choices: []OpenAIChunkChoice{},
+			Usage:   e.usage,
+		})
+		events = append(events, sseEvent{Data: string(payload)})
+	}
+	return append(events, sseEvent{Data: "[DONE]"})
+}
+
+// chunk builds a single-choice chat.completion.chunk event
+func (e *chatChunkEmitter) chunk(delta OpenAIChunkDelta, finishReason *string) []sseEvent {
+	payload, _ := json.Marshal(OpenAIChatChunk{
+		ID:      e.id,
+		Object:  "chat.completion.chunk",
+		Created: e.created,
+		Model:   e.model,
+		Choices: []OpenAIChunkChoice{{Index: 0, Delta: delta, FinishReason: finishReason}},
+	})
+	return []sseEvent{{Data: string(payload)}}
+}
+
+// anthropicStreamTranslator converts Anthropic Messages stream events into
+// OpenAI chat.completion.chunk events terminated by data: [DONE]
+type anthropicStreamTranslator struct {
+	includeUsage bool
+
+	id      string
+	model   string
+	created int64
+	usage   AnthropicUsage
+	done    bool
+
+	// toolIndexes maps Anthropic content block indexes to OpenAI tool_calls indexes
+	toolIndexes map[int]int
+}
+
+// newAnthropicStreamTranslator creates a translator; includeUsage adds a final usage chunk
+// as requested by the client's stream_options.include_usage
+func newAnthropicStreamTranslator(includeUsage bool) *anthropicStreamTranslator {
+	return &anthropicStreamTranslator{
+		includeUsage: includeUsage,
+		created:      time.Now().Unix(),
+		toolIndexes:  make(map[int]int),
+	}
+}
+
+// Translate implements streamTranslator
+func (t *anthropicStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	if t.done || ev.Data == "" {
+		return nil
+	}
+
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			ID    string         `json:"id"`
+			Model string         `json:"model"`
+			Usage AnthropicUsage `json:"usage"`
+		} `json:"message"`
+		Index        int                   `json:"index"`
+		ContentBlock AnthropicContentBlock `json:"content_block"`
+		Delta        struct {
+			Type        string `json:"type"`
+			Text        string `json:"text"`
+			PartialJSON string `json:"partial_json"`
+			StopReason  string `json:"stop_reason"`
+		} `json:"delta"`
+		Usage *AnthropicUsage `json:"usage"`
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+		return nil
+	}
+
+	switch event.Type {
+	case "message_start":
+		t.id = event.Message.ID
+		t.model = event.Message.Model
+		t.usage = event.Message.Usage
+		empty := ""
+		return t.chunk(OpenAIChunkDelta{Role: "assistant", Content: &empty}, nil)
+
+	case "content_block_start":
+		if event.ContentBlock.Type != "tool_use" {
+			return nil
+		}
+		// A new tool_use block opens a tool call with its id and name
+		toolIndex := len(t.toolIndexes)
+		t.toolIndexes[event.Index] = toolIndex
+		return t.chunk(OpenAIChunkDelta{ToolCalls: []OpenAIToolCall{{
+			Index:    &toolIndex,
+			ID:       event.ContentBlock.ID,
+			Type:     "function",
+			Function: OpenAIFunctionCall{Name: event.ContentBlock.Name},
+		}}}, nil)
+
+	case "content_block_delta":
+		switch event.Delta.Type {
+		case "text_delta":
+			text := event.Delta.Text
+			return t.chunk(OpenAIChunkDelta{Content: &text}, nil)
+		case "input_json_delta":
+			toolIndex, ok := t.toolIndexes[event.Index]
+			if !ok || event.Delta.PartialJSON == "" {
+				return nil
+			}
+			return t.chunk(OpenAIChunkDelta{ToolCalls: []OpenAIToolCall{{
+				Index:    &toolIndex,
+				Function: OpenAIFunctionCall{Arguments: event.Delta.PartialJSON},
+			}}}, nil)
+		}
+		return nil
+
+	case "message_delta":
+		if event.Usage != nil {
+			t.usage.OutputTokens = event.Usage.OutputTokens
+			if event.Usage.InputTokens > 0 {
+				t.usage.InputTokens = event.Usage.InputTokens
+			}
+		}
+		finishReason := anthropicStopReasonToOpenAI(event.Delta.StopReason)
+		return t.chunk(OpenAIChunkDelta{}, &finishReason)
+
+	case "message_stop":
+		return t.Finish()
+
+	case "error":
+		// Surface the upstream error in the OpenAI shape, then end the stream
+		t.done = true
+		errMessage, errType := "upstream stream error", "api_error"
+		if event.Error != nil {
+			errMessage, errType = event.Error.Message, event.Error.Type
+		}
+		payload, _ := json.Marshal(map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": errMessage,
+				"type":    errType,
+				"code":    nil,
+			},
+		})
+		return []sseEvent{{Data: string(payload)}, {Data: "[DONE]"}}
+	}
+
+	// ping, content_block_stop
+	return nil
+}
+
+// Finish implements streamTranslator
+func (t *anthropicStreamTranslator) Finish() []sseEvent {
+	if t.done {
+		return nil
+	}
+	t.done = true
+
+	var events []sseEvent
+	if t.includeUsage {
+		payload, _ := json.Marshal(OpenAIChatChunk{
+			ID:      t.id,
+			Object:  "chat.completion.chunk",
+			Created: t.created,
+			Model:   t.model,
+			Choices: []OpenAIChunkChoice{},
+			Usage:   t.usage.ToOpenAI(),
+		})
+		events = append(events, sseEvent{Data: string(payload)})
+	}
+	return append(events, sseEvent{Data: "[DONE]"})
+}
+
+// chunk builds a single-choice chat.completion.chunk event
+func (t *anthropicStreamTranslator) chunk(delta OpenAIChunkDelta, finishReason *string) []sseEvent {
+	payload, _ := json.Marshal(OpenAIChatChunk{
+		ID:      t.id,
+		Object:  "chat.completion.chunk",
+		Created: t.created,
+		Model:   t.model,
+		Choices: []OpenAIChunkChoice{{Index: 0, Delta: delta, FinishReason: finishReason}},
+	})
+	return []sseEvent{{Data: string(payload)}}
+}
+
+// transformAnthropicToOpenAI transforms an Anthropic Messages request into an OpenAI chat request
+func (s *ProxyService) transformAnthropicToOpenAI(req *AnthropicMessagesRequest, modelName string) (*OpenAIChatRequest, error) {
+	chatReq := &OpenAIChatRequest{
+		Model:       modelName,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stream:      req.Stream,
+	}
+
+	if req.MaxTokens > 0 {
+		maxTokens := req.MaxTokens
+		chatReq.MaxTokens = &maxTokens
+	}
+	if len(req.StopSequences) > 0 {
+		stop := make([]interface{}, len(req.StopSequences))
+		for i, seq := range req.StopSequences {
+			stop[i] = seq
+		}
+		chatReq.Stop = stop
+	}
+	if req.Stream != nil && *req.Stream {
+		// Usage is needed for the final message_delta and for usage tracking
+		chatReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+	if userID, ok := req.Metadata["user_id"].(string); ok {
+		chatReq.User = userID
+	}
+
+	// Tools and tool choice
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, OpenAITool{
+			Type: "function",
+			Function: OpenAIFunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Type {
+		case "auto":
+			chatReq.ToolChoice = "auto"
+		case "any":
+			chatReq.ToolChoice = "required"
+		case "none":
+			chatReq.ToolChoice = "none"
+		case "tool":
+			chatReq.ToolChoice = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": req.ToolChoice.Name},
+			}
+		}
+		if req.ToolChoice.DisableParallelToolUse != nil && *req.ToolChoice.DisableParallelToolUse && len(chatReq.Tools) > 0 {
+			parallel := false
+			chatReq.ParallelToolCalls = &parallel
+		}
+	}
+
+	// The system prompt becomes the leading system message
+	if system := anthropicTextContent(req.System); system != "" {
+		chatReq.Messages = append(chatReq.Messages, OpenAIMessage{Role: "system", Content: system})
+	}
+
+	for _, msg := range req.Messages {
+		messages, err := anthropicMessageToOpenAI(msg)
+		if err != nil {
+			return nil, err
+		}
+		chatReq.Messages = append(chatReq.Messages, messages...)
+	}
+
+	if len(req.Messages) == 0 {
+		return nil, fmt.Errorf("at least one message is required")
+	}
+
+	return chatReq, nil
+}
+
+// anthropicMessageToOpenAI converts one Anthropic message into one or more OpenAI messages.
+// tool_result blocks become separate tool messages, tool_use blocks become tool_calls.
+func anthropicMessageToOpenAI(msg AnthropicMessage) ([]OpenAIMessage, error) {
+	if text, ok := msg.Content.(string); ok {
+		return []OpenAIMessage{{Role: msg.Role, Content: text}}, nil
+	}
+
+	blocks, ok := msg.Content.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unsupported content for %s message", msg.Role)
+	}
+
+	var messages []OpenAIMessage
+	var parts []interface{}
+	var toolCalls []OpenAIToolCall
+
+	for _, item := range blocks {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		switch block["type"] {
+		case "text":
+			text, _ := block["text"].(string)
+			parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+		case "image":
+			if url := anthropicImageURL(block["source"]); url != "" {
+				parts = append(parts, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]interface{}{"url": url},
+				})
+			}
+		case "tool_use":
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			arguments, err := json.Marshal(block["input"])
+			if err != nil || string(arguments) == "null" {
+				arguments = []byte("{}")
+			}
+			toolCalls = append(toolCalls, OpenAIToolCall{
+				ID:       id,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: name, Arguments: string(arguments)},
+			})
+		case "tool_result":
+			toolUseID, _ := block["tool_use_id"].(string)
+			content := anthropicTextContent(block["content"])
+			if isError, _ := block["is_error"].(bool); isError && content != "" {
+				content = "Error: " + content
+			}
+			messages = append(messages, OpenAIMessage{
+				Role:       "tool",
+				ToolCallID: toolUseID,
+				Content:    content,
+			})
+		}
+		// thinking and other block types have no OpenAI equivalent
+	}
+
+	if len(parts) == 0 && len(toolCalls) == 0 {
+		return messages, nil
+	}
+
+	message := OpenAIMessage{Role: msg.Role, Content: openAIContentFromParts(parts), ToolCalls: toolCalls}
+	if len(toolCalls) > 0 && len(parts) == 0 {
+		message.Content = nil
+	}
+	return append(messages, message), nil
+}
+
+// openAIContentFromParts collapses text-only content into a plain string, which every
+// OpenAI-compatible server accepts, and keeps the parts array when images are present
+func openAIContentFromParts(parts []interface{}) interface{} {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		p := part.(map[string]interface{})
+		if p["type"] != "text" {
+			return parts
+		}
+		texts = append(texts, p["text"].(string))
+	}
+	return strings.Join(texts, "\n")
+}
+
+// anthropicTextContent returns the text of a string or an array of text blocks
+func anthropicTextContent(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var texts []string
+		for _, item := range v {
+			if block, ok := item.(map[string]interface{}); ok {
+				if text, ok := block["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	default:
+		return ""
+	}
+}
+
+// stopSequences reads an OpenAI stop parameter, a single string or an array of strings
+func stopSequences(stop interface{}) []string {
+	switch v := stop.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var sequences []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				sequences = append(sequences, s)
+			}
+		}
+		return sequences
+	}
+	return nil
+}
+
+// parseImageDataURI splits a base64 data URI ("data:image/png;base64,...") into its media type
+// and base64 data; ok is false for anything else, such as an http(s) URL
+func parseImageDataURI(imageURL string) (mediaType, data string, ok bool) {
+	if !strings.HasPrefix(imageURL, "data:") {
+		return "", "", false
+	}
+	return strings.Cut(strings.TrimPrefix(imageURL, "data:"), ";base64,")
+}
+
+// anthropicImageURL converts an Anthropic image source into an OpenAI image URL (data URI for base64)
+func anthropicImageURL(source interface{}) string {
+	src, ok := source.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	switch src["type"] {
+	case "base64":
+		mediaType, _ := src["media_type"].(string)
+		data, _ := src["data"].(string)
+		return "data:" + mediaType + ";base64," + data
+	case "url":
+		url, _ := src["url"].(string)
+		return url
+	}
+	return ""
+}
+
+// transformOpenAIResponseToAnthropic converts an OpenAI chat.completion into an Anthropic message
+func (s *ProxyService) transformOpenAIResponseToAnthropic(body []byte) ([]byte, error) {
+	var chatResp OpenAIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, fmt.Errorf("invalid OpenAI response: %w", err)
+	}
+
+	anthropicResp := AnthropicResponse{
+		ID:         chatResp.ID,
+		Type:       "message",
+		Role:       "assistant",
+		Model:      chatResp.Model,
+		Content:    []AnthropicContentBlock{},
+		StopReason: "end_turn",
+	}
+
+	if len(chatResp.Choices) > 0 {
+		choice := chatResp.Choices[0]
+		if text := choice.Message.GetContentString(); text != "" {
+			anthropicResp.Content = append(anthropicResp.Content, AnthropicContentBlock{Type: "text", Text: text})
+		}
+		for _, call := range choice.Message.ToolCalls {
+			input := json.RawMessage(call.Function.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage(`{}`)
+			}
+			anthropicResp.Content = append(anthropicResp.Content, AnthropicContentBlock{
+				Type:  "tool_use",
+				ID:    call.ID,
+				Name:  call.Function.Name,
+				Input: input,
+			})
+		}
+		anthropicResp.StopReason = openAIFinishReasonToAnthropic(choice.FinishReason)
+	}
+
+	if chatResp.Usage != nil {
+		anthropicResp.Usage = AnthropicUsage{
+			InputTokens:  chatResp.Usage.PromptTokens,
+			OutputTokens: chatResp.Usage.CompletionTokens,
+		}
+	}
+
+	return json.Marshal(anthropicResp)
+}
+
+// transformOpenAIErrorToAnthropic converts an OpenAI-style error body into the Anthropic error shape
+func (s *ProxyService) transformOpenAIErrorToAnthropic(statusCode int, body []byte) []byte {
+	var openAIErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &openAIErr); err == nil && openAIErr.Error.Message != "" {
+		message = openAIErr.Error.Message
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    AnthropicErrorType(statusCode),
+			"message": message,
+		},
+	})
+	return payload
+}
+
+// AnthropicErrorType maps an HTTP status code to the matching Anthropic error type
+func AnthropicErrorType(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// openAIFinishReasonToAnthropic maps an OpenAI finish_reason to an Anthropic stop_reason
+func openAIFinishReasonToAnthropic(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+// openAIToAnthropicStreamTranslator converts OpenAI chat.completion.chunk events into the
+// Anthropic message_start / content_block_* / message_delta / message_stop event sequence
+type openAIToAnthropicStreamTranslator struct {
+	model string
+
+	id         string
+	started    bool
+	done       bool
+	blockIndex int    // index of the next content block
+	openBlock  string // "text", "tool_use" or "" when no block is open
+	stopReason string
+	usage      AnthropicUsage
+
+	// toolBlocks maps OpenAI tool_calls indexes to Anthropic content block indexes
+	toolBlocks map[int]int
+}
+
+// newOpenAIToAnthropicStreamTranslator creates a translator; model is reported until the upstream names one
+func newOpenAIToAnthropicStreamTranslator(model string) *openAIToAnthropicStreamTranslator {
+	return &openAIToAnthropicStreamTranslator{
+		model:      model,
+		stopReason: "end_turn",
+		toolBlocks: make(map[int]int),
+	}
+}
+
+// Translate implements streamTranslator
+func (t *openAIToAnthropicStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	if t.done || ev.Data == "" {
+		return nil
+	}
+	if ev.Data == "[DONE]" {
+		return t.Finish()
+	}
+
+	var chunk struct {
+		OpenAIChatChunk
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+		return nil
+	}
+
+	if chunk.Error != nil {
+		t.done = true
+		return []sseEvent{anthropicEvent("error", map[string]interface{}{
+			"type":  "error",
+			"error": map[string]interface{}{"type": "api_error", "message": chunk.Error.Message},
+		})}
+	}
+
+	events := t.start(chunk.ID, chunk.Model)
+
+	if chunk.Usage != nil {
+		t.usage.InputTokens = chunk.Usage.PromptTokens
+		t.usage.OutputTokens = chunk.Usage.CompletionTokens
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			if t.openBlock != "text" {
+				events = append(events, t.closeBlock()...)
+				events = append(events, anthropicEvent("content_block_start", map[string]interface{}{
+					"type":          "content_block_start",
+					"index":         t.blockIndex,
+					"content_block": map[string]interface{}{"type": "text", "text": ""},
+				}))
+				t.openBlock = "text"
+			}
+			events = append(events, anthropicEvent("content_block_delta", map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": t.blockIndex,
+				"delta": map[string]interface{}{"type": "text_delta", "text": *choice.Delta.Content},
+			}))
+		}
+
+		for _, call := range choice.Delta.ToolCalls {
+			toolIndex := 0
+			if call.Index != nil {
+				toolIndex = *call.Index
+			}
+
+			blockIndex, seen := t.toolBlocks[toolIndex]
+			if !seen {
+				// A new tool call opens a tool_use block
+				events = append(events, t.closeBlock()...)
+				blockIndex = t.blockIndex
+				t.toolBlocks[toolIndex] = blockIndex
+				events = append(events, anthropicEvent("content_block_start", map[string]interface{}{
+					"type":  "content_block_start",
+					"index": blockIndex,
+					"content_block": map[string]interface{}{
+						"type":  "tool_use",
+						"id":    call.ID,
+						"name":  call.Function.Name,
+						"input": map[string]interface{}{},
+					},
+				}))
+				t.openBlock = "tool_use"
+			}
+
+			if call.Function.Arguments != "" {
+				events = append(events, anthropicEvent("content_block_delta", map[string]interface{}{
+					"type":  "content_block_delta",
+					"index": blockIndex,
+					"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": call.Function.Arguments},
+				}))
+			}
+		}
+
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			t.stopReason = openAIFinishReasonToAnthropic(*choice.FinishReason)
+		}
+	}
+
+	return events
+}
+
+// Finish implements streamTranslator
+func (t *openAIToAnthropicStreamTranslator) Finish() []sseEvent {
+	if t.done {
+		return nil
+	}
+	t.done = true
+
+	events := t.start("", "")
+	events = append(events, t.closeBlock()...)
+	events = append(events,
+		anthropicEvent("message_delta", map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": t.stopReason, "stop_sequence": nil},
+			"usage": map[string]interface{}{
+				"input_tokens":  t.usage.InputTokens,
+				"output_tokens": t.usage.OutputTokens,
+			},
+		}),
+		anthropicEvent("message_stop", map[string]interface{}{"type": "message_stop"}),
+	)
+	return events
+}
+
+// start emits message_start the first time it is called
+func (t *openAIToAnthropicStreamTranslator) start(id, model string) []sseEvent {
+	if t.started {
+		return nil
+	}
+	t.started = true
+
+	t.id = id
+	if t.id == "" {
+		t.id = fmt.Sprintf("msg_%d", time.Now().UnixNano())
+	}
+	if model != "" {
+		t.model = model
+	}
+
+	return []sseEvent{anthropicEvent("message_start", map[string]interface{}{
+		"type": "message_start",
+		"message": map[string]interface{}{
+			"id":            t.id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         t.model,
+			"content":       []interface{}{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]interface{}{"input_tokens": 0, "output_tokens": 0},
+		},
+	})}
+}
+
+// closeBlock emits content_block_stop for the currently open block, if any
+func (t *openAIToAnthropicStreamTranslator) closeBlock() []sseEvent {
+	if t.openBlock == "" {
+		return nil
+	}
+	t.openBlock = ""
+	event := anthropicEvent("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": t.blockIndex,
+	})
+	t.blockIndex++
+	return []sseEvent{event}
+}
+
+// anthropicEvent builds a named Anthropic stream event
+func anthropicEvent(name string, payload interface{}) sseEvent {
+	data, _ := json.Marshal(payload)
+	return sseEvent{Event: name, Data: string(data)}
+}
+
+// transformAnthropicResponse converts an Anthropic message response into an OpenAI chat.completion
+func (s *ProxyService) transformAnthropicResponse(body []byte) ([]byte, error) {
+	var anthropicResp AnthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return nil, fmt.Errorf("invalid Anthropic response: %w", err)
+	}
+
+	// Concatenate text blocks and collect tool calls; other block types (e.g. thinking) have no OpenAI equivalent
+	var text strings.Builder
+	var toolCalls []OpenAIToolCall
+	for _, block := range anthropicResp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, OpenAIToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: block.Name, Arguments: arguments},
+			})
+		}
+	}
+
+	message := OpenAIMessage{
+		Role:      "assistant",
+		Content:   text.String(),
+		ToolCalls: toolCalls,
+	}
+	if len(toolCalls) > 0 && text.Len() == 0 {
+		// OpenAI sends null content on pure tool-call messages
+		message.Content = nil
+	}
+
+	chatResp := OpenAIChatResponse{
+		ID:      anthropicResp.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   anthropicResp.Model,
+		Choices: []OpenAIChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: anthropicStopReasonToOpenAI(anthropicResp.StopReason),
+			},
+		},
+		Usage: anthropicResp.Usage.ToOpenAI(),
+	}
+
+	return json.Marshal(chatResp)
+}
+
+// openAIErrorPayload renders a provider error as an OpenAI error body. The type follows the
+// status code; an empty code is sent as null.
+func openAIErrorPayload(statusCode int, code, message string) []byte {
+	errType := "invalid_request_error"
+	if statusCode >= 500 {
+		errType = "api_error"
+	}
+	var errCode interface{}
+	if code != "" {
+		errCode = code
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+			"code":    errCode,
+		},
+	})
+	return payload
+}
+
+// transformAnthropicError converts an Anthropic error body into the OpenAI error shape.
+// Bodies that are not Anthropic errors are returned unchanged.
+func (s *ProxyService) transformAnthropicError(body []byte) ([]byte, error) {
+	var anthropicErr struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &anthropicErr); err != nil || anthropicErr.Type != "error" {
+		return body, nil
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": anthropicErr.Error.Message,
+			"type":    anthropicErr.Error.Type,
+			"code":    nil,
+		},
+	})
+}
+
+// anthropicStopReasonToOpenAI maps an Anthropic stop_reason to an OpenAI finish_reason
+func anthropicStopReasonToOpenAI(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		// end_turn, stop_sequence, pause_turn
+		return "stop"
+	}
+}
+
+// copyResponseHeaders copies upstream response headers to the client.
+// Content-Length is dropped because the body may be rewritten before it is sent.
+func copyResponseHeaders(c *gin.Context, header http.Header) {
+	for key, values := range header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Length", "Transfer-Encoding", "Connection":
+			continue
+		}
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
+		}
+	}
+}
+
 // copyHeaders copies relevant headers from the original request to the proxy request
 func (s *ProxyService) copyHeaders(original *http.Request, proxy *http.Request, provider *models.Provider) {
 	// Preserve User-Agent
@@ -670,11 +2214,38 @@ func (s *ProxyService) copyHeaders(original *http.Request, proxy *http.Request,
 		// Use Bearer token for OAuth-authenticated Claude Max
 		proxy.Header.Set("Authorization", "Bearer "+provider.AccessToken)
 		proxy.Header.Set("anthropic-version", AnthropicVersion)
+	case models.ProviderTypeOllama:
+		// Ollama only needs a key when it sits behind an authenticating proxy
+		if provider.APIKey != "" {
+			proxy.Header.Set("Authorization", "Bearer "+provider.APIKey)
+		}
+	case models.ProviderTypeGemini:
+		// Gemini also accepts ?key=, but the header keeps the key out of URLs and logs
+		proxy.Header.Set("x-goog-api-key", provider.APIKey)
+	case models.ProviderTypeAzureOpenAI:
+		proxy.Header.Set("api-key", provider.APIKey)
+	case models.ProviderTypeBedrock:
+		// Signed in doChatRequest once the body is known; Bedrock picks the response framing itself
+		proxy.Header.Del("Accept")
+	case models.ProviderTypeCustom:
+		setCustomProviderHeaders(proxy, provider)
 	default:
 		proxy.Header.Set("Authorization", "Bearer "+provider.APIKey)
 	}
 }
 
+// setCustomProviderHeaders sets a custom provider's extra headers and its API key in the configured
+// auth header. Keyless providers get no auth header at all.
+func setCustomProviderHeaders(req *http.Request, provider *models.Provider) {
+	cfg := provider.GetCustomConfig()
+	for name, value := range cfg.ExtraHeaders {
+		req.Header.Set(name, value)
+	}
+	if provider.APIKey != "" {
+		req.Header.Set(cfg.AuthHeader, cfg.AuthPrefix+provider.APIKey)
+	}
+}
+
 // extractUsageFromResponse extracts token usage information from the LLM response
 func (s *ProxyService) extractUsageFromResponse(body []byte, providerType string, result *ProxyResult) {
 	// Only try to extract usage from successful responses
@@ -685,56 +2256,57 @@ func (s *ProxyService) extractUsageFromResponse(body []byte, providerType string
 	switch providerType {
 	case models.ProviderTypeAnthropic, models.ProviderTypeAnthropicMax:
 		s.extractAnthropicUsage(body, result)
+	case models.ProviderTypeOllama:
+		accumulateOllamaUsage(string(body), result)
+	case models.ProviderTypeGemini:
+		accumulateGeminiUsage(body, result)
+	case models.ProviderTypeBedrock:
+		accumulateBedrockUsage(body, result)
 	default:
 		s.extractOpenAIUsage(body, result)
 	}
 }
 
+// openAIUsagePayload covers the usage field names of both chat completions
+// (prompt/completion tokens) and the Responses API (input/output tokens)
+type openAIUsagePayload struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// apply copies the usage into result, reporting whether any usage was present
+func (u *openAIUsagePayload) apply(result *ProxyResult) bool {
+	if u == nil || u.TotalTokens == 0 {
+		return false
+	}
+
+	result.InputTokens = u.PromptTokens
+	result.OutputTokens = u.CompletionTokens
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		result.InputTokens = u.InputTokens
+		result.OutputTokens = u.OutputTokens
+	}
+	result.TotalTokens = u.TotalTokens
+	return true
+}
+
 // extractOpenAIUsage extracts usage from an OpenAI-format response
 func (s *ProxyService) extractOpenAIUsage(body []byte, result *ProxyResult) {
 	var resp struct {
-		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		} `json:"usage"`
+		Usage *openAIUsagePayload `json:"usage"`
 	}
 
-	if err := json.Unmarshal(body, &resp); err == nil && resp.Usage.TotalTokens > 0 {
-		result.InputTokens = resp.Usage.PromptTokens
-		result.OutputTokens = resp.Usage.CompletionTokens
-		result.TotalTokens = resp.Usage.TotalTokens
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Usage.apply(result) {
 		return
 	}
 
 	// Try extracting from SSE format if it's a stream
-	lines := strings.Split(string(body), "\n")
-	for i := len(lines) - 1; i >= 0; i-- {
-		line := strings.TrimSpace(lines[i])
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			continue
-		}
-
-		var chunk struct {
-			Usage struct {
-				PromptTokens     int `json:"prompt_tokens"`
-				CompletionTokens int `json:"completion_tokens"`
-				TotalTokens      int `json:"total_tokens"`
-			} `json:"usage"`
-		}
-
-		if err := json.Unmarshal([]byte(data), &chunk); err == nil && chunk.Usage.TotalTokens > 0 {
-			result.InputTokens = chunk.Usage.PromptTokens
-			result.OutputTokens = chunk.Usage.CompletionTokens
-			result.TotalTokens = chunk.Usage.TotalTokens
-			return
-		}
-	}
+	forEachSSEData(body, func(data string) {
+		s.accumulateOpenAIStreamUsage(data, result)
+	})
 }
 
 // extractAnthropicUsage extracts usage from an Anthropic response
@@ -754,45 +2326,84 @@ func (s *ProxyService) extractAnthropicUsage(body []byte, result *ProxyResult) {
 	}
 
 	// Try extracting from Anthropic SSE format
-	// Anthropic streaming ends with a message_stop event, but usage is usually in message_start or message_delta
-	// However, we look for any chunk that has the usage field.
-	lines := strings.Split(string(body), "\n")
-	for i := len(lines) - 1; i >= 0; i-- {
-		line := strings.TrimSpace(lines[i])
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
+	forEachSSEData(body, func(data string) {
+		s.accumulateAnthropicStreamUsage(data, result)
+	})
+}
 
-		data := strings.TrimPrefix(line, "data: ")
-		var chunk struct {
-			Type    string `json:"type"`
-			Message struct {
-				Usage struct {
-					InputTokens  int `json:"input_tokens"`
-					OutputTokens int `json:"output_tokens"`
-				} `json:"usage"`
-			} `json:"message"` // message_start has this
+// accumulateStreamUsage updates the result with usage found in a single streamed data payload
+func (s *ProxyService) accumulateStreamUsage(data string, providerType string, result *ProxyResult) {
+	if data == "" || data == "[DONE]" {
+		return
+	}
+
+	switch providerType {
+	case models.ProviderTypeAnthropic, models.ProviderTypeAnthropicMax:
+		s.accumulateAnthropicStreamUsage(data, result)
+	case models.ProviderTypeOllama:
+		accumulateOllamaUsage(data, result)
+	case models.ProviderTypeGemini:
+		accumulateGeminiUsage([]byte(data), result)
+	case models.ProviderTypeBedrock:
+		accumulateBedrockUsage([]byte(data), result)
+	default:
+		s.accumulateOpenAIStreamUsage(data, result)
+	}
+}
+
+// accumulateOpenAIStreamUsage reads usage from an OpenAI chat.completion.chunk (only sent on the
+// final chunk when stream_options.include_usage is set) or a Responses API response.completed event
+func (s *ProxyService) accumulateOpenAIStreamUsage(data string, result *ProxyResult) {
+	var chunk struct {
+		Usage    *openAIUsagePayload `json:"usage"`
+		Response *struct {
+			Usage *openAIUsagePayload `json:"usage"`
+		} `json:"response"`
+	}
+
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if !chunk.Usage.apply(result) && chunk.Response != nil {
+		chunk.Response.Usage.apply(result)
+	}
+}
+
+// accumulateAnthropicStreamUsage reads usage from an Anthropic stream event.
+// message_start carries the input tokens and message_delta the cumulative output tokens.
+func (s *ProxyService) accumulateAnthropicStreamUsage(data string, result *ProxyResult) {
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
 			Usage struct {
 				InputTokens  int `json:"input_tokens"`
 				OutputTokens int `json:"output_tokens"`
-			} `json:"usage"` // message_delta or final might have this
-		}
+			} `json:"usage"`
+		} `json:"message"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
 
-		if err := json.Unmarshal([]byte(data), &chunk); err == nil {
-			if chunk.Usage.InputTokens > 0 || chunk.Usage.OutputTokens > 0 {
-				result.InputTokens = chunk.Usage.InputTokens
-				result.OutputTokens = chunk.Usage.OutputTokens
-				result.TotalTokens = result.InputTokens + result.OutputTokens
-				return
-			}
-			if chunk.Message.Usage.InputTokens > 0 || chunk.Message.Usage.OutputTokens > 0 {
-				result.InputTokens = chunk.Message.Usage.InputTokens
-				result.OutputTokens = chunk.Message.Usage.OutputTokens
-				result.TotalTokens = result.InputTokens + result.OutputTokens
-				return
-			}
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return
+	}
+
+	switch event.Type {
+	case "message_start":
+		result.InputTokens = event.Message.Usage.InputTokens
+		result.OutputTokens = event.Message.Usage.OutputTokens
+	case "message_delta":
+		if event.Usage.InputTokens > 0 {
+			result.InputTokens = event.Usage.InputTokens
 		}
+		result.OutputTokens = event.Usage.OutputTokens
+	default:
+		return
 	}
+	result.TotalTokens = result.InputTokens + result.OutputTokens
 }
 
 // ListModelsForKey returns a list of available models based on all allowed providers for a key
@@ -848,6 +2459,25 @@ func (s *ProxyService) ListModelsForKey(proxyKey *models.ProxyAPIKey) (interface
 		}
 	}
 
+	// Aliases are listed under their own names when the key can reach their target
+	aliasNames := make([]string, 0, len(proxyKey.ModelAliases))
+	for name := range proxyKey.ModelAliases {
+		aliasNames = append(aliasNames, name)
+	}
+	sort.Strings(aliasNames)
+	for _, name := range aliasNames {
+		if seenModels[name] || len(s.eligibleProviders(proxyKey, proxyKey.ModelAliases[name].Target())) == 0 {
+			continue
+		}
+		modelList = append(modelList, Model{
+			ID:      name,
+			Object:  "model",
+			Created: now,
+			OwnedBy: "smoothllm",
+		})
+		seenModels[name] = true
+	}
+
 	return ModelsResponse{
 		Object: "list",
 		Data:   modelList,
@@ -932,24 +2562,47 @@ func (s *ProxyService) ListModels(provider *models.Provider) (interface{}, error
 	}, nil
 }
 
-// GetProxyKeyFromRequest extracts the proxy API key from the Authorization header
+// proxyKeyHeaders lists the headers a proxy key may arrive in, in order of precedence.
+// Anthropic SDKs send x-api-key and Azure-style clients send api-key.
+var proxyKeyHeaders = []string{"Authorization", "x-api-key", "api-key"}
+
+// GetProxyKeyFromRequest extracts the proxy API key from the Authorization, x-api-key or api-key header
 func (s *ProxyService) GetProxyKeyFromRequest(c *gin.Context) (string, error) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		return "", fmt.Errorf("missing Authorization header")
-	}
+	var fallback string
+	var formatErr error
+	for _, header := range proxyKeyHeaders {
+		value := strings.TrimSpace(c.GetHeader(header))
+		if value == "" {
+			continue
+		}
 
-	// Support "Bearer sk-smoothllm-xxx" format
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		return strings.TrimPrefix(authHeader, "Bearer "), nil
+		// Support "Bearer sk-smoothllm-xxx" format as well as the bare key
+		if header == "Authorization" {
+			if !strings.HasPrefix(value, "Bearer ") && !strings.HasPrefix(value, models.ProxyAPIKeyPrefix) {
+				formatErr = fmt.Errorf("invalid Authorization header format")
+				continue
+			}
+			value = strings.TrimSpace(strings.TrimPrefix(value, "Bearer "))
+		}
+
+		// Prefer a proxy key over anything else a client happens to send alongside it
+		if strings.HasPrefix(value, models.ProxyAPIKeyPrefix) {
+			return value, nil
+		}
+		if fallback == "" {
+			fallback = value
+		}
 	}
 
-	// Also support just the key directly
-	if strings.HasPrefix(authHeader, models.ProxyAPIKeyPrefix) {
-		return authHeader, nil
+	if fallback == "" && formatErr != nil {
+		return "", formatErr
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("missing API key: set the Authorization, x-api-key or api-key header")
 	}
 
-	return "", fmt.Errorf("invalid Authorization header format")
+	// Let key validation report why this key is not accepted
+	return fallback, nil
 }
 
 // HandleProviderError returns appropriate error responses based on provider errors
@@ -997,18 +2650,26 @@ func (s *ProxyService) recordUsage(proxyKey *models.ProxyAPIKey, provider *model
 	}
 
 	req := &RecordUsageRequest{
-		UserID:               proxyKey.UserID,
-		ProxyKeyID:           proxyKey.ID,
-		ProviderID:           provider.ID,
-		Model:                result.Model,
-		InputTokens:          result.InputTokens,
-		OutputTokens:         result.OutputTokens,
-		TotalTokens:          result.TotalTokens,
-		RequestDuration:      int(result.RequestDuration.Milliseconds()),
-		StatusCode:           result.StatusCode,
-		ErrorMessage:         result.ErrorMessage,
-		InputCostPerMillion:  provider.InputCostPerMillion,
-		OutputCostPerMillion: provider.OutputCostPerMillion,
+		UserID:                  proxyKey.UserID,
+		ProxyKeyID:              proxyKey.ID,
+		ProviderID:              provider.ID,
+		Model:                   result.Model,
+		Alias:                   result.Alias,
+		InputTokens:             result.InputTokens,
+		OutputTokens:            result.OutputTokens,
+		TotalTokens:             result.TotalTokens,
+		RequestDuration:         int(result.RequestDuration.Milliseconds()),
+		StatusCode:              result.StatusCode,
+		ErrorMessage:            result.ErrorMessage,
+		Attempts:                result.Attempts,
+		InputCostPerMillion:     provider.InputCostPerMillion,
+		OutputCostPerMillion:    provider.OutputCostPerMillion,
+		AudioSeconds:            result.AudioSeconds,
+		Characters:              result.Characters,
+		Images:                  result.Images,
+		AudioCostPerMinute:      provider.AudioCostPerMinute,
+		CharacterCostPerMillion: provider.CharacterCostPerMillion,
+		ImageCostPerImage:       provider.ImageCostPerImage,
 	}
 
 	s.usageService.RecordUsageAsync(req)
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// Audio request limits
+const (
+	MaxAudioUploadSize   = 25 << 20 // OpenAI's limit for transcription uploads
+	audioStreamChunkSize = 32 << 10
+)
+
+// ProxyAudioTranscription proxies a multipart /v1/audio/transcriptions or /v1/audio/translations
+// request (endpoint is "/audio/transcriptions" or "/audio/translations") to an OpenAI-compatible
+// provider. Usage is recorded in audio seconds, plus tokens for models that report them.
+func (s *ProxyService) ProxyAudioTranscription(c *gin.Context, proxyKey *models.ProxyAPIKey, endpoint string) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	form, err := parseProxyMultipartForm(c, MaxAudioUploadSize, result)
+	if err != nil {
+		return result, err
+	}
+	defer form.RemoveAll()
+
+	model := firstFormValue(form, "model")
+	if model == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "model is required"
+		return result, fmt.Errorf("model is required")
+	}
+	result.Model = model
+	s.applyModelAlias(proxyKey, result)
+
+	if len(form.File["file"]) == 0 {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "file is required"
+		return result, fmt.Errorf("file is required")
+	}
+	if form.File["file"][0].Size > MaxAudioUploadSize {
+		result.StatusCode = http.StatusRequestEntityTooLarge
+		result.ErrorMessage = "audio file is too large"
+		return result, fmt.Errorf("audio file is too large: the limit is %d bytes", MaxAudioUploadSize)
+	}
+
+	// Send to the model's providers in turn (this also enforces the key's allowed models), rebuilding
+	// the form with each one's un-prefixed model name
+	resp, provider, err := s.sendToChain(c, proxyKey, model, result, (*models.Provider).IsOpenAICompatible, "audio", func(target providerTarget) (*http.Response, error) {
+		modelName := s.ParseModelName(target.Model, target.Provider.ProviderType).ModelName
+		body, contentType, err := rebuildMultipartForm(form, modelName)
+		if err != nil {
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to build upstream request"
+			return nil, err
+		}
+		return s.sendOpenAIRequest(c, target.Provider, openAIEndpointURL(target.Provider, modelName, endpoint), contentType, body, result, startTime)
+	})
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	// gpt-4o-transcribe streams transcript deltas as server-sent events when stream=true
+	if isEventStream(resp) && resp.StatusCode < 300 {
+		err := streamEventsWithUsage(c, resp, result, extractAudioUsage)
+		result.RequestDuration = time.Since(startTime)
+		s.recordUsage(proxyKey, provider, result)
+		return result, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	result.RequestDuration = time.Since(startTime)
+	if err != nil {
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		extractAudioUsage(respBody, result)
+		if result.AudioSeconds == 0 {
+			// text, srt and vtt responses carry no usage; subtitles at least end where the audio does
+			result.AudioSeconds = subtitleDuration(respBody)
+		}
+	}
+	s.recordUsage(proxyKey, provider, result)
+
+	copyResponseHeaders(c, resp.Header)
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+
+	return result, nil
+}
+
+// ProxyAudioSpeech proxies a /v1/audio/speech request to an OpenAI-compatible provider, streaming
+// the generated audio to the client as it arrives. Usage is recorded in input characters.
+func (s *ProxyService) ProxyAudioSpeech(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	// Read the request body
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "failed to read request body"
+		return result, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	// Parse into a map so fields we don't know about (instructions, speed, ...) pass through
+	var speechReq map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &speechReq); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid request body"
+		return result, fmt.Errorf("failed to parse request body: %w", err)
+	}
+
+	model, _ := speechReq["model"].(string)
+	if model == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "model is required"
+		return result, fmt.Errorf("model is required")
+	}
+	result.Model = model
+	s.applyModelAlias(proxyKey, result)
+
+	input, _ := speechReq["input"].(string)
+	if input == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "input is required"
+		return result, fmt.Errorf("input is required")
+	}
+
+	// Send to the model's providers in turn (this also enforces the key's allowed models), forwarding
+	// each one's un-prefixed model name
+	resp, provider, err := s.sendToChain(c, proxyKey, model, result, (*models.Provider).IsOpenAICompatible, "audio", func(target providerTarget) (*http.Response, error) {
+		modelName := s.ParseModelName(target.Model, target.Provider.ProviderType).ModelName
+		speechReq["model"] = modelName
+		requestBody, err := json.Marshal(speechReq)
+		if err != nil {
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to marshal request"
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		return s.sendOpenAIRequest(c, target.Provider, openAIEndpointURL(target.Provider, modelName, "/audio/speech"), "application/json", bytes.NewReader(requestBody), result, startTime)
+	})
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	// Errors are small JSON bodies; pass them through whole
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, err := io.ReadAll(resp.Body)
+		result.RequestDuration = time.Since(startTime)
+		if err != nil {
+			result.ErrorMessage = "failed to read response"
+			return result, fmt.Errorf("failed to read response body: %w", err)
+		}
+		s.recordUsage(proxyKey, provider, result)
+		copyResponseHeaders(c, resp.Header)
+		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+		return result, nil
+	}
+
+	result.Characters = utf8.RuneCountInString(input)
+
+	if isEventStream(resp) {
+		// stream_format=sse sends base64 audio deltas and a final event with token usage
+		err = streamEventsWithUsage(c, resp, result, extractAudioUsage)
+	} else {
+		err = streamAudioBody(c, resp, result)
+	}
+	result.RequestDuration = time.Since(startTime)
+	s.recordUsage(proxyKey, provider, result)
+	return result, err
+}
+
+// streamAudioBody copies a binary audio response to the client, flushing each chunk so
+// playback can start before synthesis finishes
+func streamAudioBody(c *gin.Context, resp *http.Response, result *ProxyResult) error {
+	copyResponseHeaders(c, resp.Header)
+	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+
+	buf := make([]byte, audioStreamChunkSize)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				result.ErrorMessage = "client disconnected during stream"
+				return fmt.Errorf("failed to write audio to client: %w", writeErr)
+			}
+			c.Writer.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("stream interrupted: %v", err)
+			return fmt.Errorf("failed to read upstream audio: %w", err)
+		}
+	}
+}
+
+// extractAudioUsage reads usage from an audio response or stream event. Whisper-style models report
+// usage by duration ({"type": "duration", "seconds": N}), token-billed models by tokens, and
+// verbose_json responses also carry the audio's duration.
+func extractAudioUsage(body []byte, result *ProxyResult) {
+	var resp struct {
+		Duration *float64 `json:"duration"`
+		Usage    *struct {
+			Type    string  `json:"type"`
+			Seconds float64 `json:"seconds"`
+			openAIUsagePayload
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return
+	}
+
+	if resp.Usage != nil {
+		if resp.Usage.Type == "duration" {
+			result.AudioSeconds = resp.Usage.Seconds
+		} else {
+			resp.Usage.openAIUsagePayload.apply(result)
+		}
+	}
+	if result.AudioSeconds == 0 && resp.Duration != nil {
+		result.AudioSeconds = *resp.Duration
+	}
+}
+
+// subtitleDuration returns the end time of the last cue in an SRT or WebVTT document, or 0
+func subtitleDuration(body []byte) float64 {
+	var last float64
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		_, end, found := strings.Cut(scanner.Text(), "-->")
+		if !found {
+			continue
+		}
+		// Cue settings may follow the end time in WebVTT
+		fields := strings.Fields(end)
+		if len(fields) == 0 {
+			continue
+		}
+		if seconds, ok := parseSubtitleTimestamp(fields[0]); ok && seconds > last {
+			last = seconds
+		}
+	}
+	return last
+}
+
+// parseSubtitleTimestamp parses [HH:]MM:SS,mmm (SRT) or [HH:]MM:SS.mmm (WebVTT) into seconds
+func parseSubtitleTimestamp(ts string) (float64, bool) {
+	parts := strings.Split(strings.Replace(ts, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, true
+}
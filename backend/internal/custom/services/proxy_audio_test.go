@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAudioForm builds a transcription upload with the given extra fields
+func newAudioForm(t *testing.T, fields map[string][]string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, values := range fields {
+		for _, value := range values {
+			require.NoError(t, writer.WriteField(name, value))
+		}
+	}
+	part, err := writer.CreateFormFile("file", "meeting.mp3")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("fake audio bytes"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return body, writer.FormDataContentType()
+}
+
+func TestExtractAudioUsage(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantSeconds  float64
+		wantInput    int
+		wantOutput   int
+		wantTokenSum int
+	}{
+		{name: "duration usage", body: `{"text":"hi","usage":{"type":"duration","seconds":42}}`, wantSeconds: 42},
+		{name: "verbose_json duration", body: `{"task":"transcribe","duration":12.5,"text":"hi"}`, wantSeconds: 12.5},
+		{
+			name:         "token usage",
+			body:         `{"text":"hi","usage":{"type":"tokens","input_tokens":30,"output_tokens":5,"total_tokens":35}}`,
+			wantInput:    30,
+			wantOutput:   5,
+			wantTokenSum: 35,
+		},
+		{name: "plain text", body: `hello there`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &ProxyResult{}
+			extractAudioUsage([]byte(tt.body), result)
+			assert.Equal(t, tt.wantSeconds, result.AudioSeconds)
+			assert.Equal(t, tt.wantInput, result.InputTokens)
+			assert.Equal(t, tt.wantOutput, result.OutputTokens)
+			assert.Equal(t, tt.wantTokenSum, result.TotalTokens)
+		})
+	}
+}
+
+func TestSubtitleDuration(t *testing.T) {
+	srt := "1\n00:00:00,000 --> 00:00:04,200\nHello\n\n2\n00:01:02,500 --> 00:01:05,750\nWorld\n"
+	assert.InDelta(t, 65.75, subtitleDuration([]byte(srt)), 0.001)
+
+	vtt := "WEBVTT\n\n00:01.000 --> 00:03.500 align:start\nHi\n"
+	assert.InDelta(t, 3.5, subtitleDuration([]byte(vtt)), 0.001)
+
+	assert.Equal(t, 0.0, subtitleDuration([]byte("just a transcript")))
+}
+
+func TestProxyService_ProxyAudioTranscription(t *testing.T) {
+	t.Run("rewrites the model, keeps the upload and records audio seconds", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/audio/transcriptions", r.URL.Path)
+			assert.Equal(t, "Bearer upstream-key", r.Header.Get("Authorization"))
+
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			assert.Equal(t, "whisper-1", r.FormValue("model"))
+			assert.Equal(t, "verbose_json", r.FormValue("response_format"))
+			assert.Equal(t, []string{"word", "segment"}, r.MultipartForm.Value["timestamp_granularities[]"])
+
+			file, header, err := r.FormFile("file")
+			require.NoError(t, err)
+			defer file.Close()
+			content, _ := io.ReadAll(file)
+			assert.Equal(t, "meeting.mp3", header.Filename)
+			assert.Equal(t, "fake audio bytes", string(content))
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"task":"transcribe","language":"english","duration":90,"text":"Hello"}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		proxyKey.AllowedProviders[0].Provider.AudioCostPerMinute = 0.006
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/audio/transcriptions", func(c *gin.Context) {
+			result, _ := service.ProxyAudioTranscription(c, proxyKey, "/audio/transcriptions")
+			results <- result
+		})
+
+		body, contentType := newAudioForm(t, map[string][]string{
+			"model":                     {"openai/whisper-1"},
+			"response_format":           {"verbose_json"},
+			"timestamp_granularities[]": {"word", "segment"},
+		})
+		resp, err := http.Post(server.URL+"/v1/audio/transcriptions", contentType, body)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(respBody), `"text":"Hello"`)
+
+		result := <-results
+		assert.Equal(t, 90.0, result.AudioSeconds)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.AudioSeconds == 90 && record.ModelName == "openai/whisper-1" &&
+				record.Cost > 0.0089 && record.Cost < 0.0091
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("requires an audio file", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://unused")
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		require.NoError(t, writer.WriteField("model", "whisper-1"))
+		require.NoError(t, writer.Close())
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", body)
+		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		result, err := service.ProxyAudioTranscription(c, proxyKey, "/audio/transcriptions")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Contains(t, err.Error(), "file is required")
+	})
+
+	t.Run("rejects anthropic providers", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, "http://unused")
+
+		body, contentType := newAudioForm(t, map[string][]string{"model": {"whisper-1"}})
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/translations", body)
+		c.Request.Header.Set("Content-Type", contentType)
+
+		result, err := service.ProxyAudioTranscription(c, proxyKey, "/audio/translations")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Contains(t, err.Error(), "does not support audio")
+	})
+}
+
+func TestProxyService_ProxyAudioSpeech(t *testing.T) {
+	t.Run("streams audio and records characters", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/audio/speech", r.URL.Path)
+			body, _ := io.ReadAll(r.Body)
+			assert.Contains(t, string(body), `"model":"tts-1"`)
+			assert.Contains(t, string(body), `"voice":"alloy"`)
+
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.WriteHeader(http.StatusOK)
+			for i := 0; i < 3; i++ {
+				w.Write([]byte("chunk"))
+				w.(http.Flusher).Flush()
+			}
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		proxyKey.AllowedProviders[0].Provider.CharacterCostPerMillion = 15
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/audio/speech", func(c *gin.Context) {
+			result, _ := service.ProxyAudioSpeech(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/audio/speech", "application/json",
+			strings.NewReader(`{"model":"openai/tts-1","input":"Héllo world","voice":"alloy"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "audio/mpeg", resp.Header.Get("Content-Type"))
+		audio, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "chunkchunkchunk", string(audio))
+
+		result := <-results
+		assert.Equal(t, 11, result.Characters)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.Characters == 11 && record.Cost > 0
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("passes upstream errors through without billing characters", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"unknown voice"}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech",
+			strings.NewReader(`{"model":"tts-1","input":"Hi","voice":"nobody"}`))
+
+		result, err := service.ProxyAudioSpeech(c, proxyKey)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Equal(t, 0, result.Characters)
+		assert.Contains(t, recorder.Body.String(), "unknown voice")
+	})
+
+	t.Run("requires input", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://unused")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech", strings.NewReader(`{"model":"tts-1"}`))
+
+		result, err := service.ProxyAudioSpeech(c, proxyKey)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// CompletionRequest represents a legacy OpenAI text completion request
+type CompletionRequest struct {
+	Model            string               `json:"model"`
+	Prompt           interface{}          `json:"prompt"` // string, array of strings, or token arrays
+	MaxTokens        *int                 `json:"max_tokens,omitempty"`
+	Temperature      *float64             `json:"temperature,omitempty"`
+	TopP             *float64             `json:"top_p,omitempty"`
+	N                *int                 `json:"n,omitempty"`
+	Stream           *bool                `json:"stream,omitempty"`
+	StreamOptions    *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	Stop             interface{}          `json:"stop,omitempty"`
+	PresencePenalty  *float64             `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64             `json:"frequency_penalty,omitempty"`
+	LogitBias        map[string]float64   `json:"logit_bias,omitempty"`
+	User             string               `json:"user,omitempty"`
+	Echo             bool                 `json:"echo,omitempty"`
+	Suffix           string               `json:"suffix,omitempty"`
+}
+
+// CompletionResponse represents a legacy OpenAI text_completion response or stream chunk
+type CompletionResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []CompletionChoice `json:"choices"`
+	Usage   *OpenAIUsage       `json:"usage,omitempty"`
+}
+
+// CompletionChoice represents a choice in a text_completion response
+type CompletionChoice struct {
+	Text         string      `json:"text"`
+	Index        int         `json:"index"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+// ProxyCompletions handles a legacy /v1/completions request. Providers that serve the completions
+// API receive the request as-is; chat-only providers get the prompt as a single user message and
+// the chat response is turned back into a text_completion object.
+func (s *ProxyService) ProxyCompletions(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	// Read the request body
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "failed to read request body"
+		return result, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var completionReq CompletionRequest
+	if err := json.Unmarshal(bodyBytes, &completionReq); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid request body"
+		return result, fmt.Errorf("failed to parse request body: %w", err)
+	}
+	result.Model = completionReq.Model
+	s.applyModelAlias(proxyKey, result)
+
+	if completionReq.Prompt == nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "prompt is required"
+		return result, fmt.Errorf("prompt is required")
+	}
+
+	stream := completionReq.Stream != nil && *completionReq.Stream
+
+	// Send the request, moving down the chain while providers fail
+	resp, provider, err := s.sendToChain(c, proxyKey, completionReq.Model, result, nil, "", func(target providerTarget) (*http.Response, error) {
+		modelInfo := s.ParseModelName(target.Model, target.Provider.ProviderType)
+
+		if target.Provider.SupportsTextCompletions() {
+			// Pass through everything the client sent, only rewriting the model name
+			var passthroughReq map[string]interface{}
+			if err := json.Unmarshal(bodyBytes, &passthroughReq); err != nil {
+				result.StatusCode = http.StatusBadRequest
+				result.ErrorMessage = "invalid request body"
+				return nil, fmt.Errorf("failed to parse request body: %w", err)
+			}
+			passthroughReq["model"] = modelInfo.ModelName
+
+			// If streaming is enabled, ensure usage is included
+			if stream && completionReq.StreamOptions == nil {
+				passthroughReq["stream_options"] = OpenAIStreamOptions{IncludeUsage: true}
+			}
+
+			requestBody, err := json.Marshal(passthroughReq)
+			if err != nil {
+				result.StatusCode = http.StatusInternalServerError
+				result.ErrorMessage = "failed to marshal request"
+				return nil, fmt.Errorf("failed to marshal request: %w", err)
+			}
+			return s.sendOpenAIRequest(c, target.Provider, openAIEndpointURL(target.Provider, modelInfo.ModelName, "/completions"), "application/json", bytes.NewReader(requestBody), result, startTime)
+		}
+
+		// Chat-only providers: wrap the prompt as a user message
+		chatReq, err := completionToChatRequest(&completionReq)
+		if err != nil {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = err.Error()
+			return nil, err
+		}
+		return s.doChatRequest(c, target.Provider, chatReq, modelInfo.ModelName, result)
+	})
+	if err != nil {
+		result.RequestDuration = time.Since(startTime)
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if provider.SupportsTextCompletions() {
+		return s.forwardOpenAIResponse(c, proxyKey, provider, resp, result, startTime)
+	}
+
+	result.StatusCode = resp.StatusCode
+
+	// Convert chat.completion.chunk events into text_completion chunks as they arrive
+	if isStreamResponse(resp) && resp.StatusCode < 300 {
+		includeUsage := completionReq.StreamOptions != nil && completionReq.StreamOptions.IncludeUsage
+		translator := chatStreamTranslator(provider, true, newCompletionStreamTranslator(includeUsage))
+		err := s.streamTranslated(c, resp, provider.ProviderType, translator, result)
+		result.RequestDuration = time.Since(startTime)
+		s.recordUsage(proxyKey, provider, result)
+		return result, err
+	}
+
+	// Record timing
+	result.RequestDuration = time.Since(startTime)
+
+	// Read the response body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Extract usage information from the upstream response
+	s.extractUsageFromResponse(respBody, provider.ProviderType, result)
+
+	// Record usage asynchronously (non-blocking)
+	s.recordUsage(proxyKey, provider, result)
+
+	respBody, err = s.chatResponseBody(provider, resp.StatusCode, respBody)
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		respBody, err = transformChatToCompletion(respBody)
+	}
+	if err != nil {
+		result.ErrorMessage = fmt.Sprintf("failed to transform response: %v", err)
+		return result, fmt.Errorf("failed to transform response: %w", err)
+	}
+
+	c.Data(resp.StatusCode, "application/json", respBody)
+	return result, nil
+}
+
+// completionToChatRequest wraps a legacy completion request as a single-turn chat request
+func completionToChatRequest(req *CompletionRequest) (*OpenAIChatRequest, error) {
+	var prompt string
+	switch v := req.Prompt.(type) {
+	case string:
+		prompt = v
+	case []interface{}:
+		if len(v) != 1 {
+			return nil, fmt.Errorf("this provider only supports a single prompt per request")
+		}
+		text, ok := v[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("token prompts are not supported by this provider")
+		}
+		prompt = text
+	default:
+		return nil, fmt.Errorf("prompt must be a string")
+	}
+
+	if req.Echo {
+		return nil, fmt.Errorf("echo is not supported by this provider")
+	}
+	if req.Suffix != "" {
+		return nil, fmt.Errorf("suffix is not supported by this provider")
+	}
+	if req.N != nil && *req.N > 1 {
+		return nil, fmt.Errorf("n > 1 is not supported by this provider")
+	}
+
+	chatReq := &OpenAIChatRequest{
+		Model:            req.Model,
+		Messages:         []OpenAIMessage{{Role: "user", Content: prompt}},
+		MaxTokens:        req.MaxTokens,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		Stream:           req.Stream,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		LogitBias:        req.LogitBias,
+		User:             req.User,
+	}
+	if req.Stream != nil && *req.Stream {
+		// Usage is always requested upstream for tracking
+		chatReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	return chatReq, nil
+}
+
+// transformChatToCompletion converts a chat.completion into a text_completion
+func transformChatToCompletion(body []byte) ([]byte, error) {
+	var chatResp OpenAIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, fmt.Errorf("invalid chat response: %w", err)
+	}
+
+	completion := CompletionResponse{
+		ID:      chatResp.ID,
+		Object:  "text_completion",
+		Created: chatResp.Created,
+		Model:   chatResp.Model,
+		Choices: make([]CompletionChoice, 0, len(chatResp.Choices)),
+		Usage:   chatResp.Usage,
+	}
+	for _, choice := range chatResp.Choices {
+		finishReason := choice.FinishReason
+		completion.Choices = append(completion.Choices, CompletionChoice{
+			Text:         choice.Message.GetContentString(),
+			Index:        choice.Index,
+			FinishReason: &finishReason,
+		})
+	}
+
+	return json.Marshal(completion)
+}
+
+// completionStreamTranslator converts chat.completion.chunk events into text_completion chunks
+type completionStreamTranslator struct {
+	includeUsage bool
+	done         bool
+}
+
+// newCompletionStreamTranslator creates a translator; includeUsage forwards the final usage chunk
+func newCompletionStreamTranslator(includeUsage bool) *completionStreamTranslator {
+	return &completionStreamTranslator{includeUsage: includeUsage}
+}
+
+// Translate implements streamTranslator
+func (t *completionStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	if t.done || ev.Data == "" {
+		return nil
+	}
+	if ev.Data == "[DONE]" {
+		return t.Finish()
+	}
+
+	var chunk struct {
+		OpenAIChatChunk
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+		return nil
+	}
+
+	// Errors are already in the OpenAI shape
+	if chunk.Error != nil {
+		return []sseEvent{{Data: ev.Data}}
+	}
+
+	completion := CompletionResponse{
+		ID:      chunk.ID,
+		Object:  "text_completion",
+		Created: chunk.Created,
+		Model:   chunk.Model,
+		Choices: []CompletionChoice{},
+	}
+	for _, choice := range chunk.Choices {
+		text := ""
+		if choice.Delta.Content != nil {
+			text = *choice.Delta.Content
+		}
+		// Role-only and tool call deltas carry no text
+		if text == "" && choice.FinishReason == nil {
+			continue
+		}
+		completion.Choices = append(completion.Choices, CompletionChoice{
+			Text:         text,
+			Index:        choice.Index,
+			FinishReason: choice.FinishReason,
+		})
+	}
+
+	if chunk.Usage != nil && len(chunk.Choices) == 0 {
+		if !t.includeUsage {
+			return nil
+		}
+		completion.Usage = chunk.Usage
+	} else if len(completion.Choices) == 0 {
+		return nil
+	}
+
+	data, _ := json.Marshal(completion)
+	return []sseEvent{{Data: string(data)}}
+}
+
+// Finish implements streamTranslator
+func (t *completionStreamTranslator) Finish() []sseEvent {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	return []sseEvent{{Data: "[DONE]"}}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionToChatRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantPrompt string
+		wantErr    string
+	}{
+		{name: "string prompt", body: `{"model":"m","prompt":"Once upon"}`, wantPrompt: "Once upon"},
+		{name: "single prompt array", body: `{"model":"m","prompt":["Once upon"]}`, wantPrompt: "Once upon"},
+		{name: "multiple prompts", body: `{"model":"m","prompt":["a","b"]}`, wantErr: "single prompt"},
+		{name: "token prompt", body: `{"model":"m","prompt":[[1,2,3]]}`, wantErr: "token prompts"},
+		{name: "echo", body: `{"model":"m","prompt":"a","echo":true}`, wantErr: "echo"},
+		{name: "suffix", body: `{"model":"m","prompt":"a","suffix":"z"}`, wantErr: "suffix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req CompletionRequest
+			require.NoError(t, json.Unmarshal([]byte(tt.body), &req))
+
+			chatReq, err := completionToChatRequest(&req)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, chatReq.Messages, 1)
+			assert.Equal(t, "user", chatReq.Messages[0].Role)
+			assert.Equal(t, tt.wantPrompt, chatReq.Messages[0].Content)
+		})
+	}
+}
+
+func TestCompletionStreamTranslator(t *testing.T) {
+	stream := "data: {\"id\":\"c1\",\"created\":1,\"model\":\"glm\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+		"data: {\"id\":\"c1\",\"created\":1,\"model\":\"glm\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n" +
+		"data: {\"id\":\"c1\",\"created\":1,\"model\":\"glm\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"id\":\"c1\",\"created\":1,\"model\":\"glm\",\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1,\"total_tokens\":4}}\n\n" +
+		"data: [DONE]\n\n"
+
+	tests := []struct {
+		name         string
+		includeUsage bool
+		wantChunks   int
+	}{
+		{name: "without usage", includeUsage: false, wantChunks: 3},
+		{name: "with usage", includeUsage: true, wantChunks: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payloads := translateRecordedStream(t, newCompletionStreamTranslator(tt.includeUsage), stream)
+			require.Len(t, payloads, tt.wantChunks)
+			assert.Equal(t, "[DONE]", payloads[len(payloads)-1])
+
+			var first CompletionResponse
+			require.NoError(t, json.Unmarshal([]byte(payloads[0]), &first))
+			assert.Equal(t, "text_completion", first.Object)
+			require.Len(t, first.Choices, 1)
+			assert.Equal(t, "Hi", first.Choices[0].Text)
+			assert.Nil(t, first.Choices[0].FinishReason)
+
+			var finish CompletionResponse
+			require.NoError(t, json.Unmarshal([]byte(payloads[1]), &finish))
+			require.NotNil(t, finish.Choices[0].FinishReason)
+			assert.Equal(t, "stop", *finish.Choices[0].FinishReason)
+
+			if tt.includeUsage {
+				var usage CompletionResponse
+				require.NoError(t, json.Unmarshal([]byte(payloads[2]), &usage))
+				require.NotNil(t, usage.Usage)
+				assert.Equal(t, 4, usage.Usage.TotalTokens)
+			}
+		})
+	}
+}
+
+func TestProxyService_ProxyCompletions(t *testing.T) {
+	t.Run("passes prompt requests through to vLLM", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/completions", r.URL.Path)
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "llama", body["model"])
+			assert.Equal(t, []interface{}{"a", "b"}, body["prompt"])
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"cmpl-1","object":"text_completion","model":"llama","choices":[{"text":" x","index":0,"finish_reason":"length"},{"text":" y","index":1,"finish_reason":"length"}],"usage":{"prompt_tokens":2,"completion_tokens":2,"total_tokens":4}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeVLLM, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/completions", func(c *gin.Context) {
+			result, _ := service.ProxyCompletions(c, proxyKey)
+			results <- result
+		})
+
+		var completion CompletionResponse
+		status := postJSON(t, server.URL+"/v1/completions", `{"model":"vllm/llama","prompt":["a","b"],"max_tokens":1}`, &completion)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Len(t, completion.Choices, 2)
+
+		result := <-results
+		assert.Equal(t, 4, result.TotalTokens)
+	})
+
+	t.Run("wraps the prompt for chat-only providers", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v4/chat/completions", r.URL.Path)
+
+			var chatReq OpenAIChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chatReq))
+			require.Len(t, chatReq.Messages, 1)
+			assert.Equal(t, "Say hi", chatReq.Messages[0].Content)
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"c1","object":"chat.completion","created":5,"model":"glm-4","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeZai, upstream.URL)
+
+		server := newProxyTestServer(t, "/v1/completions", func(c *gin.Context) {
+			service.ProxyCompletions(c, proxyKey)
+		})
+
+		var completion CompletionResponse
+		status := postJSON(t, server.URL+"/v1/completions", `{"model":"glm-4","prompt":"Say hi"}`, &completion)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "text_completion", completion.Object)
+		assert.Equal(t, int64(5), completion.Created)
+		require.Len(t, completion.Choices, 1)
+		assert.Equal(t, "Hi", completion.Choices[0].Text)
+		require.NotNil(t, completion.Choices[0].FinishReason)
+		assert.Equal(t, "stop", *completion.Choices[0].FinishReason)
+		require.NotNil(t, completion.Usage)
+		assert.Equal(t, 4, completion.Usage.TotalTokens)
+	})
+
+	t.Run("streams Anthropic output as text_completion chunks", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/messages", r.URL.Path)
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, anthropicStreamStart+anthropicStreamText+anthropicStreamEnd)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/completions", func(c *gin.Context) {
+			result, _ := service.ProxyCompletions(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/completions", "application/json",
+			strings.NewReader(`{"model":"claude-sonnet-4","prompt":"Hi","stream":true}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"object":"text_completion"`)
+		assert.Contains(t, string(body), `"text":"Hello"`)
+		assert.NotContains(t, string(body), `"usage"`)
+		assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+
+		result := <-results
+		assert.Equal(t, 21, result.InputTokens)
+		assert.Equal(t, 9, result.OutputTokens)
+	})
+
+	t.Run("requires a prompt", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeVLLM, "http://127.0.0.1:1")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/completions",
+			strings.NewReader(`{"model":"llama","messages":[{"role":"user","content":"hi"}]}`))
+
+		result, err := service.ProxyCompletions(c, proxyKey)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
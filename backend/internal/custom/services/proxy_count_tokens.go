@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// AnthropicCountTokensResponse represents an Anthropic /v1/messages/count_tokens response
+type AnthropicCountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+// ProxyCountTokens handles an Anthropic /v1/messages/count_tokens request. Anthropic providers count
+// the tokens themselves; OpenAI-compatible providers have no equivalent API, so the count is
+// estimated locally. Counting is free upstream and is logged as a zero-cost request.
+func (s *ProxyService) ProxyCountTokens(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	// Read the request body
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "failed to read request body"
+		return result, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var countReq AnthropicMessagesRequest
+	if err := json.Unmarshal(bodyBytes, &countReq); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid request body"
+		return result, fmt.Errorf("failed to parse request body: %w", err)
+	}
+	result.Model = countReq.Model
+	s.applyModelAlias(proxyKey, result)
+
+	// Determine which providers to use
+	chain, err := s.GetProviderChain(proxyKey, countReq.Model)
+	if err != nil {
+		result.StatusCode = routingErrorStatus(err)
+		result.ErrorMessage = err.Error()
+		return result, err
+	}
+
+	if provider := chain[0].Provider; !provider.IsAnthropicProvider() {
+		result.Model = chain[0].Model
+		result.StatusCode = http.StatusOK
+		result.InputTokens = estimateAnthropicInputTokens(&countReq)
+		result.TotalTokens = result.InputTokens
+		result.RequestDuration = time.Since(startTime)
+		s.recordFreeUsage(proxyKey, provider, result)
+
+		c.JSON(http.StatusOK, AnthropicCountTokensResponse{InputTokens: result.InputTokens})
+		return result, nil
+	}
+
+	// Only Anthropic providers can count; the rest of the chain is left out
+	anthropicChain := chain[:0:0]
+	for _, target := range chain {
+		if target.Provider.IsAnthropicProvider() {
+			anthropicChain = append(anthropicChain, target)
+		}
+	}
+
+	// Each attempt runs under its own provider's timeouts, so one slow provider doesn't use up the
+	// time left for the fallbacks
+	resp, provider, err := s.sendWithFallback(c, proxyKey, anthropicChain, result, func(target providerTarget) (*http.Response, error) {
+		// The body goes through untouched unless an alias or fallback substitutes the model
+		body := bodyBytes
+		if target.Model != countReq.Model {
+			var err error
+			if body, err = withRequestModel(bodyBytes, s.ParseModelName(target.Model, target.Provider.ProviderType).ModelName); err != nil {
+				result.StatusCode = http.StatusInternalServerError
+				result.ErrorMessage = "failed to marshal request"
+				return nil, err
+			}
+		}
+
+		proxyReq, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, anthropicMessagesURL(target.Provider)+"/count_tokens", bytes.NewReader(body))
+		if err != nil {
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to create proxy request"
+			return nil, fmt.Errorf("failed to create proxy request: %w", err)
+		}
+		s.copyAnthropicHeaders(c.Request, proxyReq, target.Provider)
+
+		resp, err := sendUpstream(proxyReq, target.Provider)
+		if err != nil {
+			result.StatusCode = http.StatusBadGateway
+			result.ErrorMessage = fmt.Sprintf("proxy request failed: %v", err)
+			return nil, fmt.Errorf("proxy request failed: %w", err)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		result.RequestDuration = time.Since(startTime)
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+
+	respBody, err := io.ReadAll(resp.Body)
+	result.RequestDuration = time.Since(startTime)
+	if err != nil {
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var countResp AnthropicCountTokensResponse
+		if err := json.Unmarshal(respBody, &countResp); err == nil {
+			result.InputTokens = countResp.InputTokens
+			result.TotalTokens = countResp.InputTokens
+		}
+	}
+
+	s.recordFreeUsage(proxyKey, provider, result)
+
+	copyResponseHeaders(c, resp.Header)
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	return result, nil
+}
+
+// recordFreeUsage logs a request that consumes no billable tokens (such as token counting) so it
+// shows up in the request history without affecting token totals or cost
+func (s *ProxyService) recordFreeUsage(proxyKey *models.ProxyAPIKey, provider *models.Provider, result *ProxyResult) {
+	if s.usageService == nil {
+		return
+	}
+
+	s.usageService.RecordUsageAsync(&RecordUsageRequest{
+		UserID:          proxyKey.UserID,
+		ProxyKeyID:      proxyKey.ID,
+		ProviderID:      provider.ID,
+		Model:           result.Model,
+		Alias:           result.Alias,
+		RequestDuration: int(result.RequestDuration.Milliseconds()),
+		StatusCode:      result.StatusCode,
+		ErrorMessage:    result.ErrorMessage,
+	})
+}
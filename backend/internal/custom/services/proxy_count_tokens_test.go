@@ -0,0 +1,153 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyService_ProxyCountTokens(t *testing.T) {
+	t.Run("forwards to Anthropic providers", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/messages/count_tokens", r.URL.Path)
+			assert.Equal(t, "upstream-key", r.Header.Get("x-api-key"))
+			assert.Equal(t, "token-counting-2024-11-01", r.Header.Get("anthropic-beta"))
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"input_tokens":42}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, upstream.URL)
+		proxyKey.AllowedProviders[0].Provider.InputCostPerMillion = 3
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/messages/count_tokens", func(c *gin.Context) {
+			result, _ := service.ProxyCountTokens(c, proxyKey)
+			results <- result
+		})
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/messages/count_tokens",
+			strings.NewReader(`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "sk-smoothllm-client")
+		req.Header.Set("anthropic-beta", "token-counting-2024-11-01")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var count AnthropicCountTokensResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&count))
+		assert.Equal(t, 42, count.InputTokens)
+
+		result := <-results
+		assert.Equal(t, 42, result.InputTokens)
+
+		// Counting is logged, but without tokens or cost
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.ModelName == "claude-sonnet-4" && record.StatusCode == http.StatusOK &&
+				record.TotalTokens == 0 && record.Cost == 0
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("falls back once a provider runs out of its own time", func(t *testing.T) {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer slow.Close()
+		defer close(release)
+		var calls int32
+		healthy := countingUpstream(t, http.StatusOK, `{"input_tokens":7}`, &calls)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, true, nil,
+			&models.Provider{Name: "Slow", ProviderType: models.ProviderTypeAnthropic, BaseURL: slow.URL,
+				Timeouts: &models.ProviderTimeouts{FirstByteMs: 50}},
+			&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeAnthropic, BaseURL: healthy.URL},
+		)
+		server := newProxyTestServer(t, "/v1/messages/count_tokens", func(c *gin.Context) {
+			service.ProxyCountTokens(c, proxyKey)
+		})
+
+		var count AnthropicCountTokensResponse
+		status := postJSON(t, server.URL+"/v1/messages/count_tokens",
+			`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`, &count)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 7, count.InputTokens)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("estimates locally for OpenAI-compatible providers", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeVLLM, "http://127.0.0.1:1")
+
+		server := newProxyTestServer(t, "/v1/messages/count_tokens", func(c *gin.Context) {
+			service.ProxyCountTokens(c, proxyKey)
+		})
+
+		var count AnthropicCountTokensResponse
+		status := postJSON(t, server.URL+"/v1/messages/count_tokens",
+			`{"model":"vllm/llama","system":"Be brief.","messages":[{"role":"user","content":"Hello world"}]}`, &count)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 3+messageTokenOverhead+2, count.InputTokens)
+	})
+
+	t.Run("records the alias the model was requested by", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		_, err := NewAliasService(db).CreateAlias(1, false, &CreateAliasRequest{Name: "local", Provider: "vllm", Model: "llama"})
+		require.NoError(t, err)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeVLLM, "http://127.0.0.1:1")
+
+		server := newProxyTestServer(t, "/v1/messages/count_tokens", func(c *gin.Context) {
+			service.ProxyCountTokens(c, proxyKey)
+		})
+
+		var count AnthropicCountTokensResponse
+		status := postJSON(t, server.URL+"/v1/messages/count_tokens",
+			`{"model":"local","messages":[{"role":"user","content":"Hello world"}]}`, &count)
+		assert.Equal(t, http.StatusOK, status)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			err := db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error
+			return err == nil && record.Alias == "local" && record.ModelName == "vllm/llama"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("rejects models outside the key's allowed list", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeVLLM, "http://127.0.0.1:1")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens",
+			strings.NewReader(`{"model":"openai/gpt-4o","messages":[]}`))
+
+		result, err := service.ProxyCountTokens(c, proxyKey)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+	})
+}
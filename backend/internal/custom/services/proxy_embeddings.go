@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// ProxyEmbeddings handles an OpenAI-compatible /v1/embeddings request. The request is routed by
+// model like chat completions and forwarded as-is (apart from the model name) to the provider.
+func (s *ProxyService) ProxyEmbeddings(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	// Read the request body
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "failed to read request body"
+		return result, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	// Parse into a map so fields we don't know about (dimensions, encoding_format, ...) pass through
+	var embeddingsReq map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &embeddingsReq); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid request body"
+		return result, fmt.Errorf("failed to parse request body: %w", err)
+	}
+
+	model, _ := embeddingsReq["model"].(string)
+	if model == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "model is required"
+		return result, fmt.Errorf("model is required")
+	}
+	result.Model = model
+	s.applyModelAlias(proxyKey, result)
+
+	if err := validateEmbeddingsInput(embeddingsReq["input"]); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = err.Error()
+		return result, err
+	}
+
+	// Send to the providers that serve embeddings, moving down the chain while they fail
+	supportsEmbeddings := func(provider *models.Provider) bool {
+		return provider.IsOpenAICompatible() || provider.ProviderType == models.ProviderTypeOllama
+	}
+	resp, provider, err := s.sendToChain(c, proxyKey, model, result, supportsEmbeddings, "embeddings", func(target providerTarget) (*http.Response, error) {
+		// Forward the un-prefixed model name
+		modelName := s.ParseModelName(target.Model, target.Provider.ProviderType).ModelName
+		embeddingsReq["model"] = modelName
+
+		if target.Provider.ProviderType == models.ProviderTypeOllama {
+			return s.sendOllamaEmbeddings(c, target.Provider, embeddingsReq, result, startTime)
+		}
+
+		requestBody, err := json.Marshal(embeddingsReq)
+		if err != nil {
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to marshal request"
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		return s.sendOpenAIRequest(c, target.Provider, openAIEndpointURL(target.Provider, modelName, "/embeddings"), "application/json", bytes.NewReader(requestBody), result, startTime)
+	})
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if provider.ProviderType == models.ProviderTypeOllama {
+		return s.writeOllamaEmbeddings(c, proxyKey, provider, embeddingsReq, resp, result, startTime)
+	}
+	return s.forwardOpenAIResponse(c, proxyKey, provider, resp, result, startTime)
+}
+
+// validateEmbeddingsInput checks that input is a string, an array of strings, a token array,
+// or an array of token arrays, and that it is not empty
+func validateEmbeddingsInput(input interface{}) error {
+	switch v := input.(type) {
+	case string:
+		if v == "" {
+			return fmt.Errorf("input must not be empty")
+		}
+		return nil
+	case []interface{}:
+		if len(v) == 0 {
+			return fmt.Errorf("input must not be empty")
+		}
+		switch v[0].(type) {
+		case string:
+			for _, item := range v {
+				if _, ok := item.(string); !ok {
+					return fmt.Errorf("input array must not mix strings and tokens")
+				}
+			}
+		case float64:
+			for _, item := range v {
+				if _, ok := item.(float64); !ok {
+					return fmt.Errorf("token array must contain only integers")
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				tokens, ok := item.([]interface{})
+				if !ok || len(tokens) == 0 {
+					return fmt.Errorf("input must be an array of non-empty token arrays")
+				}
+				for _, token := range tokens {
+					if _, ok := token.(float64); !ok {
+						return fmt.Errorf("token array must contain only integers")
+					}
+				}
+			}
+		default:
+			return fmt.Errorf("input must be a string, an array of strings, or an array of tokens")
+		}
+		return nil
+	case nil:
+		return fmt.Errorf("input is required")
+	default:
+		return fmt.Errorf("input must be a string, an array of strings, or an array of tokens")
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEmbeddingsInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "string", input: `"hello"`},
+		{name: "array of strings", input: `["a","b","c"]`},
+		{name: "token array", input: `[1,2,3]`},
+		{name: "array of token arrays", input: `[[1,2],[3]]`},
+		{name: "missing", input: `null`, wantErr: true},
+		{name: "empty string", input: `""`, wantErr: true},
+		{name: "empty array", input: `[]`, wantErr: true},
+		{name: "mixed strings and tokens", input: `["a",1]`, wantErr: true},
+		{name: "empty token array", input: `[[1],[]]`, wantErr: true},
+		{name: "object", input: `{"text":"a"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.input), &input))
+
+			err := validateEmbeddingsInput(input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestProxyService_ProxyEmbeddings(t *testing.T) {
+	t.Run("forwards batch input and records input-token usage and cost", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/embeddings", r.URL.Path)
+			assert.Equal(t, "Bearer upstream-key", r.Header.Get("Authorization"))
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "text-embedding-3-small", body["model"])
+			assert.Equal(t, []interface{}{"first", "second"}, body["input"])
+			assert.Equal(t, float64(256), body["dimensions"])
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]},{"object":"embedding","index":1,"embedding":[0.2]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":8,"total_tokens":8}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		proxyKey.AllowedProviders[0].Provider.InputCostPerMillion = 20
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/embeddings", func(c *gin.Context) {
+			result, _ := service.ProxyEmbeddings(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/embeddings", "application/json",
+			strings.NewReader(`{"model":"openai/text-embedding-3-small","input":["first","second"],"dimensions":256}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var embeddings struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&embeddings))
+		assert.Len(t, embeddings.Data, 2)
+
+		result := <-results
+		assert.Equal(t, 8, result.InputTokens)
+		assert.Equal(t, 0, result.OutputTokens)
+		assert.Equal(t, 8, result.TotalTokens)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.InputTokens == 8 && record.ModelName == "openai/text-embedding-3-small" && record.Cost > 0
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("rejects models outside the key's allowed list", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+
+		provider := &models.Provider{UserID: 1, Name: "OpenAI", ProviderType: models.ProviderTypeOpenAI, APIKey: "k", IsActive: true}
+		require.NoError(t, db.Create(provider).Error)
+		keyService := NewKeyService(db)
+		created, err := keyService.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders: []ProviderSelection{{ProviderID: provider.ID, Models: []string{"gpt-4o"}}},
+			Name:             "Chat Only",
+		})
+		require.NoError(t, err)
+		proxyKey, err := keyService.ValidateKey(created.Key)
+		require.NoError(t, err)
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"text-embedding-3-small","input":"hi"}`))
+
+		result, err := service.ProxyEmbeddings(c, proxyKey)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+	})
+
+	t.Run("rejects Anthropic providers", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, "http://127.0.0.1:1")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"claude-sonnet-4","input":"hi"}`))
+
+		result, err := service.ProxyEmbeddings(c, proxyKey)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Contains(t, result.ErrorMessage, "does not support embeddings")
+	})
+
+	t.Run("rejects invalid input before contacting a provider", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://127.0.0.1:1")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"text-embedding-3-small","input":[]}`))
+
+		result, err := service.ProxyEmbeddings(c, proxyKey)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
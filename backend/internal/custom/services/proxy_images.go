@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// MaxImageEditUploadSize bounds a whole /v1/images/edits upload (all images plus the mask)
+const MaxImageEditUploadSize = 100 << 20
+
+// ProxyImageGenerations proxies a /v1/images/generations request to an OpenAI-compatible provider.
+// The response (URLs or base64 data) passes through unchanged; usage is recorded per image.
+func (s *ProxyService) ProxyImageGenerations(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	// Read the request body
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "failed to read request body"
+		return result, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	// Parse into a map so fields we don't know about (size, quality, background, ...) pass through
+	var imageReq map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &imageReq); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid request body"
+		return result, fmt.Errorf("failed to parse request body: %w", err)
+	}
+
+	model, _ := imageReq["model"].(string)
+	if model == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "model is required"
+		return result, fmt.Errorf("model is required")
+	}
+	result.Model = model
+	s.applyModelAlias(proxyKey, result)
+
+	if prompt, _ := imageReq["prompt"].(string); prompt == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "prompt is required"
+		return result, fmt.Errorf("prompt is required")
+	}
+
+	// Send to the model's providers in turn, forwarding each one's un-prefixed model name
+	resp, provider, err := s.sendImageRequest(c, proxyKey, model, result, func(target providerTarget, modelName string) (*http.Response, error) {
+		imageReq["model"] = modelName
+		requestBody, err := json.Marshal(imageReq)
+		if err != nil {
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to marshal request"
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		return s.sendOpenAIRequest(c, target.Provider, openAIEndpointURL(target.Provider, modelName, "/images/generations"), "application/json", bytes.NewReader(requestBody), result, startTime)
+	})
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	return s.forwardImageResponse(c, proxyKey, provider, resp, result, startTime)
+}
+
+// ProxyImageEdits proxies a multipart /v1/images/edits request to an OpenAI-compatible provider.
+// Only the model field is rewritten; images, mask and other fields pass through as uploaded.
+func (s *ProxyService) ProxyImageEdits(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	form, err := parseProxyMultipartForm(c, MaxImageEditUploadSize, result)
+	if err != nil {
+		return result, err
+	}
+	defer form.RemoveAll()
+
+	model := firstFormValue(form, "model")
+	if model == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "model is required"
+		return result, fmt.Errorf("model is required")
+	}
+	result.Model = model
+	s.applyModelAlias(proxyKey, result)
+
+	if firstFormValue(form, "prompt") == "" {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "prompt is required"
+		return result, fmt.Errorf("prompt is required")
+	}
+
+	// gpt-image-1 accepts several images as image[]; dall-e-2 takes a single image
+	if len(form.File["image"]) == 0 && len(form.File["image[]"]) == 0 {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "image is required"
+		return result, fmt.Errorf("image is required")
+	}
+
+	// Send to the model's providers in turn, rebuilding the form with each one's un-prefixed model name
+	resp, provider, err := s.sendImageRequest(c, proxyKey, model, result, func(target providerTarget, modelName string) (*http.Response, error) {
+		body, contentType, err := rebuildMultipartForm(form, modelName)
+		if err != nil {
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to build upstream request"
+			return nil, err
+		}
+		return s.sendOpenAIRequest(c, target.Provider, openAIEndpointURL(target.Provider, modelName, "/images/edits"), contentType, body, result, startTime)
+	})
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	return s.forwardImageResponse(c, proxyKey, provider, resp, result, startTime)
+}
+
+// sendImageRequest sends an image request down the model's chain of providers that serve the
+// OpenAI images API (this also enforces the key's allowed models). send is given each target's
+// un-prefixed model name.
+func (s *ProxyService) sendImageRequest(c *gin.Context, proxyKey *models.ProxyAPIKey, model string, result *ProxyResult, send func(target providerTarget, modelName string) (*http.Response, error)) (*http.Response, *models.Provider, error) {
+	return s.sendToChain(c, proxyKey, model, result, (*models.Provider).IsOpenAICompatible, "image generation", func(target providerTarget) (*http.Response, error) {
+		return send(target, s.ParseModelName(target.Model, target.Provider.ProviderType).ModelName)
+	})
+}
+
+// forwardImageResponse writes an images API response back to the client unchanged and records
+// the number of images produced along with any token usage
+func (s *ProxyService) forwardImageResponse(c *gin.Context, proxyKey *models.ProxyAPIKey, provider *models.Provider, resp *http.Response, result *ProxyResult, startTime time.Time) (*ProxyResult, error) {
+	result.StatusCode = resp.StatusCode
+
+	// gpt-image-1 streams partial images as server-sent events when stream=true
+	if isEventStream(resp) && resp.StatusCode < 300 {
+		err := streamEventsWithUsage(c, resp, result, extractImageUsage)
+		result.RequestDuration = time.Since(startTime)
+		s.recordUsage(proxyKey, provider, result)
+		return result, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	result.RequestDuration = time.Since(startTime)
+	if err != nil {
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		extractImageUsage(respBody, result)
+	}
+	s.recordUsage(proxyKey, provider, result)
+
+	copyResponseHeaders(c, resp.Header)
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+
+	return result, nil
+}
+
+// extractImageUsage counts the images in an images API response, or the completed image in a
+// stream event, and reads the token usage gpt-image-1 reports alongside
+func extractImageUsage(body []byte, result *ProxyResult) {
+	var resp struct {
+		Type  string              `json:"type"`
+		Data  []json.RawMessage   `json:"data"`
+		Usage *openAIUsagePayload `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return
+	}
+
+	switch {
+	case resp.Type == "":
+		result.Images = len(resp.Data)
+	case strings.HasSuffix(resp.Type, ".completed"):
+		// image_generation.completed and image_edit.completed each carry one final image
+		result.Images++
+	}
+
+	resp.Usage.apply(result)
+}
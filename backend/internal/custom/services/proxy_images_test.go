@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractImageUsage(t *testing.T) {
+	t.Run("counts images in a response", func(t *testing.T) {
+		result := &ProxyResult{}
+		extractImageUsage([]byte(`{"created":1,"data":[{"url":"https://a"},{"url":"https://b"}]}`), result)
+		assert.Equal(t, 2, result.Images)
+		assert.Equal(t, 0, result.TotalTokens)
+	})
+
+	t.Run("reads gpt-image-1 token usage", func(t *testing.T) {
+		result := &ProxyResult{}
+		extractImageUsage([]byte(`{"data":[{"b64_json":"AAAA"}],"usage":{"input_tokens":50,"output_tokens":4160,"total_tokens":4210}}`), result)
+		assert.Equal(t, 1, result.Images)
+		assert.Equal(t, 50, result.InputTokens)
+		assert.Equal(t, 4160, result.OutputTokens)
+	})
+
+	t.Run("counts only completed stream events", func(t *testing.T) {
+		result := &ProxyResult{}
+		extractImageUsage([]byte(`{"type":"image_generation.partial_image","b64_json":"AA","partial_image_index":0}`), result)
+		assert.Equal(t, 0, result.Images)
+		extractImageUsage([]byte(`{"type":"image_generation.completed","b64_json":"AAAA","usage":{"input_tokens":10,"output_tokens":20,"total_tokens":30}}`), result)
+		assert.Equal(t, 1, result.Images)
+		assert.Equal(t, 30, result.TotalTokens)
+	})
+}
+
+func TestProxyService_ProxyImageGenerations(t *testing.T) {
+	t.Run("passes the response through and records per-image cost", func(t *testing.T) {
+		const upstreamBody = `{"created":1700000000,"data":[{"b64_json":"aW1hZ2Ux"},{"b64_json":"aW1hZ2Uy"}]}`
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/images/generations", r.URL.Path)
+			assert.Equal(t, "Bearer upstream-key", r.Header.Get("Authorization"))
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "dall-e-3", body["model"])
+			assert.Equal(t, "b64_json", body["response_format"])
+			assert.Equal(t, "1024x1024", body["size"])
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, upstreamBody)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+		proxyKey.AllowedProviders[0].Provider.ImageCostPerImage = 0.04
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/images/generations", func(c *gin.Context) {
+			result, _ := service.ProxyImageGenerations(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/images/generations", "application/json",
+			strings.NewReader(`{"model":"openai/dall-e-3","prompt":"a lighthouse","n":2,"size":"1024x1024","response_format":"b64_json"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, upstreamBody, string(respBody))
+
+		result := <-results
+		assert.Equal(t, 2, result.Images)
+
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			if db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error != nil {
+				return false
+			}
+			return record.Images == 2 && record.Cost > 0.0799 && record.Cost < 0.0801
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("rejects models outside the key's allowed list", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+
+		provider := &models.Provider{UserID: 1, Name: "OpenAI", ProviderType: models.ProviderTypeOpenAI, APIKey: "k", IsActive: true}
+		require.NoError(t, db.Create(provider).Error)
+		keyService := NewKeyService(db)
+		created, err := keyService.CreateKey(1, &CreateKeyRequest{
+			AllowedProviders: []ProviderSelection{{ProviderID: provider.ID, Models: []string{"gpt-4o"}}},
+			Name:             "Chat Only",
+		})
+		require.NoError(t, err)
+		proxyKey, err := keyService.ValidateKey(created.Key)
+		require.NoError(t, err)
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/generations",
+			strings.NewReader(`{"model":"dall-e-3","prompt":"a lighthouse"}`))
+
+		result, err := service.ProxyImageGenerations(c, proxyKey)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+	})
+
+	t.Run("requires a prompt", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://unused")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"model":"dall-e-3"}`))
+
+		result, err := service.ProxyImageGenerations(c, proxyKey)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
+
+func TestProxyService_ProxyImageEdits(t *testing.T) {
+	t.Run("forwards every image and the mask with the model rewritten", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/images/edits", r.URL.Path)
+
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			assert.Equal(t, "gpt-image-1", r.FormValue("model"))
+			assert.Equal(t, "add a hat", r.FormValue("prompt"))
+			require.Len(t, r.MultipartForm.File["image[]"], 2)
+			assert.Equal(t, "cat.png", r.MultipartForm.File["image[]"][0].Filename)
+			assert.Equal(t, "image/png", r.MultipartForm.File["image[]"][0].Header.Get("Content-Type"))
+			require.Len(t, r.MultipartForm.File["mask"], 1)
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"created":1,"data":[{"b64_json":"AAAA"}],"usage":{"input_tokens":300,"output_tokens":1000,"total_tokens":1300}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		require.NoError(t, writer.WriteField("model", "openai/gpt-image-1"))
+		require.NoError(t, writer.WriteField("prompt", "add a hat"))
+		for _, name := range []string{"cat.png", "hat.png"} {
+			header := make(map[string][]string)
+			header["Content-Disposition"] = []string{`form-data; name="image[]"; filename="` + name + `"`}
+			header["Content-Type"] = []string{"image/png"}
+			part, err := writer.CreatePart(header)
+			require.NoError(t, err)
+			part.Write([]byte("png bytes"))
+		}
+		part, err := writer.CreateFormFile("mask", "mask.png")
+		require.NoError(t, err)
+		part.Write([]byte("mask bytes"))
+		require.NoError(t, writer.Close())
+
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", body)
+		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		result, err := service.ProxyImageEdits(c, proxyKey)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, 1, result.Images)
+		assert.Equal(t, 1300, result.TotalTokens)
+		assert.Contains(t, recorder.Body.String(), `"b64_json":"AAAA"`)
+	})
+
+	t.Run("requires an image", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, "http://unused")
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		require.NoError(t, writer.WriteField("model", "gpt-image-1"))
+		require.NoError(t, writer.WriteField("prompt", "add a hat"))
+		require.NoError(t, writer.Close())
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", body)
+		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		result, err := service.ProxyImageEdits(c, proxyKey)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Contains(t, err.Error(), "image is required")
+	})
+}
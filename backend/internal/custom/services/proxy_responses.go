@@ -0,0 +1,890 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// ResponsesRequest represents an OpenAI Responses API request
+type ResponsesRequest struct {
+	Model              string            `json:"model"`
+	Input              interface{}       `json:"input"` // string or array of input items
+	Instructions       string            `json:"instructions,omitempty"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	MaxOutputTokens    *int              `json:"max_output_tokens,omitempty"`
+	Temperature        *float64          `json:"temperature,omitempty"`
+	TopP               *float64          `json:"top_p,omitempty"`
+	Stream             *bool             `json:"stream,omitempty"`
+	Store              *bool             `json:"store,omitempty"`
+	Tools              []ResponsesTool   `json:"tools,omitempty"`
+	ToolChoice         interface{}       `json:"tool_choice,omitempty"` // "auto", "none", "required" or {"type":"function","name":...}
+	ParallelToolCalls  *bool             `json:"parallel_tool_calls,omitempty"`
+	User               string            `json:"user,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// ResponsesTool represents a tool definition in a Responses API request
+type ResponsesTool struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// ResponsesObject represents a Responses API response object
+type ResponsesObject struct {
+	ID                 string                      `json:"id"`
+	Object             string                      `json:"object"`
+	CreatedAt          int64                       `json:"created_at"`
+	Status             string                      `json:"status"` // in_progress, completed, incomplete, failed
+	Model              string                      `json:"model"`
+	Output             []ResponsesOutputItem       `json:"output"`
+	Usage              *ResponsesUsage             `json:"usage"`
+	Error              *ResponsesError             `json:"error"`
+	IncompleteDetails  *ResponsesIncompleteDetails `json:"incomplete_details"`
+	Instructions       *string                     `json:"instructions"`
+	PreviousResponseID *string                     `json:"previous_response_id"`
+	MaxOutputTokens    *int                        `json:"max_output_tokens"`
+	Temperature        *float64                    `json:"temperature"`
+	TopP               *float64                    `json:"top_p"`
+	ParallelToolCalls  bool                        `json:"parallel_tool_calls"`
+	ToolChoice         interface{}                 `json:"tool_choice"`
+	Tools              []ResponsesTool             `json:"tools"`
+	Store              bool                        `json:"store"`
+	Metadata           map[string]string           `json:"metadata"`
+}
+
+// ResponsesOutputItem is a message or function_call item in a response's output
+type ResponsesOutputItem struct {
+	Type      string                 `json:"type"` // message or function_call
+	ID        string                 `json:"id"`
+	Status    string                 `json:"status"`
+	Role      string                 `json:"role,omitempty"`
+	Content   []ResponsesContentPart `json:"content,omitempty"`
+	CallID    string                 `json:"call_id,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Arguments string                 `json:"arguments,omitempty"`
+}
+
+// MarshalJSON emits only the fields that belong to the item's type, always including
+// content for messages and arguments for function calls (even when empty)
+func (i ResponsesOutputItem) MarshalJSON() ([]byte, error) {
+	if i.Type == "function_call" {
+		return json.Marshal(struct {
+			Type      string `json:"type"`
+			ID        string `json:"id"`
+			Status    string `json:"status"`
+			CallID    string `json:"call_id"`
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		}{i.Type, i.ID, i.Status, i.CallID, i.Name, i.Arguments})
+	}
+
+	content := i.Content
+	if content == nil {
+		content = []ResponsesContentPart{}
+	}
+	return json.Marshal(struct {
+		Type    string                 `json:"type"`
+		ID      string                 `json:"id"`
+		Status  string                 `json:"status"`
+		Role    string                 `json:"role"`
+		Content []ResponsesContentPart `json:"content"`
+	}{i.Type, i.ID, i.Status, i.Role, content})
+}
+
+// ResponsesContentPart is an output_text part of a message item
+type ResponsesContentPart struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+// ResponsesUsage represents token usage in the Responses API format
+type ResponsesUsage struct {
+	InputTokens         int                          `json:"input_tokens"`
+	InputTokensDetails  ResponsesInputTokensDetails  `json:"input_tokens_details"`
+	OutputTokens        int                          `json:"output_tokens"`
+	OutputTokensDetails ResponsesOutputTokensDetails `json:"output_tokens_details"`
+	TotalTokens         int                          `json:"total_tokens"`
+}
+
+// ResponsesInputTokensDetails breaks down input token usage
+type ResponsesInputTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// ResponsesOutputTokensDetails breaks down output token usage
+type ResponsesOutputTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// ResponsesError describes why a response failed
+type ResponsesError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResponsesIncompleteDetails describes why a response is incomplete
+type ResponsesIncompleteDetails struct {
+	Reason string `json:"reason"` // max_output_tokens or content_filter
+}
+
+// ProxyResponses handles an OpenAI Responses API request. OpenAI providers receive the request
+// as-is; every other provider gets a translated chat completion (or Anthropic messages) request
+// and the result is converted back into a response object or Responses stream events.
+func (s *ProxyService) ProxyResponses(c *gin.Context, proxyKey *models.ProxyAPIKey) (*ProxyResult, error) {
+	startTime := time.Now()
+	result := &ProxyResult{}
+
+	// Read the request body
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "failed to read request body"
+		return result, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var responsesReq ResponsesRequest
+	if err := json.Unmarshal(bodyBytes, &responsesReq); err != nil {
+		result.StatusCode = http.StatusBadRequest
+		result.ErrorMessage = "invalid request body"
+		return result, fmt.Errorf("failed to parse request body: %w", err)
+	}
+	result.Model = responsesReq.Model
+	s.applyModelAlias(proxyKey, result)
+
+	// Load the conversation being continued, if it was stored here
+	var history []OpenAIMessage
+	storedLocally := false
+	if responsesReq.PreviousResponseID != "" && s.responseService != nil {
+		previous, err := s.responseService.GetResponse(proxyKey.ID, responsesReq.PreviousResponseID)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal([]byte(previous.Messages), &history); err != nil {
+				result.StatusCode = http.StatusInternalServerError
+				result.ErrorMessage = "failed to load previous response"
+				return result, fmt.Errorf("failed to decode stored response: %w", err)
+			}
+			storedLocally = true
+		case !errors.Is(err, ErrResponseNotFound):
+			result.StatusCode = http.StatusInternalServerError
+			result.ErrorMessage = "failed to load previous response"
+			return result, err
+		}
+	}
+
+	// A previous response that wasn't stored here can only have been kept by OpenAI itself
+	var supports func(*models.Provider) bool
+	if responsesReq.PreviousResponseID != "" && !storedLocally {
+		supports = func(provider *models.Provider) bool {
+			return provider.ProviderType == models.ProviderTypeOpenAI
+		}
+	}
+
+	// Send the request, moving down the chain while providers fail. conversation belongs to the
+	// last attempt, which is the one whose response is returned.
+	var conversation []OpenAIMessage
+	resp, provider, err := s.sendToChain(c, proxyKey, responsesReq.Model, result, supports, "previous_response_id", func(target providerTarget) (*http.Response, error) {
+		modelInfo := s.ParseModelName(target.Model, target.Provider.ProviderType)
+
+		// OpenAI serves the Responses API natively (and keeps its own previous responses)
+		if target.Provider.ProviderType == models.ProviderTypeOpenAI && !storedLocally {
+			var passthroughReq map[string]interface{}
+			if err := json.Unmarshal(bodyBytes, &passthroughReq); err != nil {
+				result.StatusCode = http.StatusBadRequest
+				result.ErrorMessage = "invalid request body"
+				return nil, fmt.Errorf("failed to parse request body: %w", err)
+			}
+			passthroughReq["model"] = modelInfo.ModelName
+			requestBody, err := json.Marshal(passthroughReq)
+			if err != nil {
+				result.StatusCode = http.StatusInternalServerError
+				result.ErrorMessage = "failed to marshal request"
+				return nil, fmt.Errorf("failed to marshal request: %w", err)
+			}
+			return s.sendOpenAIRequest(c, target.Provider, openAIEndpointURL(target.Provider, modelInfo.ModelName, "/responses"), "application/json", bytes.NewReader(requestBody), result, startTime)
+		}
+
+		chatReq, attemptConversation, err := s.transformResponsesToChat(&responsesReq, history, modelInfo.ModelName)
+		if err != nil {
+			result.StatusCode = http.StatusBadRequest
+			result.ErrorMessage = fmt.Sprintf("failed to transform request: %v", err)
+			return nil, err
+		}
+		conversation = attemptConversation
+
+		// Transformed to the provider's native chat API where needed
+		return s.doChatRequest(c, target.Provider, chatReq, modelInfo.ModelName, result)
+	})
+	var unsupported unsupportedProviderError
+	if errors.As(err, &unsupported) {
+		result.StatusCode = http.StatusNotFound
+		result.ErrorMessage = fmt.Sprintf("previous response %s not found", responsesReq.PreviousResponseID)
+		return result, errors.New(result.ErrorMessage)
+	}
+	if err != nil {
+		result.RequestDuration = time.Since(startTime)
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if provider.ProviderType == models.ProviderTypeOpenAI && !storedLocally {
+		return s.forwardOpenAIResponse(c, proxyKey, provider, resp, result, startTime)
+	}
+
+	result.StatusCode = resp.StatusCode
+	response := newResponsesObject(&responsesReq)
+
+	// Convert the upstream stream into Responses API events as they arrive
+	if isStreamResponse(resp) && resp.StatusCode < 300 {
+		translator := chatStreamTranslator(provider, true, newResponsesStreamTranslator(response))
+		err := s.streamTranslated(c, resp, provider.ProviderType, translator, result)
+		result.RequestDuration = time.Since(startTime)
+		s.recordUsage(proxyKey, provider, result)
+		if err == nil && response.Status != "failed" {
+			s.storeResponse(proxyKey, &responsesReq, response, conversation)
+		}
+		return result, err
+	}
+
+	// Record timing
+	result.RequestDuration = time.Since(startTime)
+
+	// Read the response body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		result.ErrorMessage = "failed to read response"
+		return result, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Extract usage information from the upstream response
+	s.extractUsageFromResponse(respBody, provider.ProviderType, result)
+
+	// Record usage asynchronously (non-blocking)
+	s.recordUsage(proxyKey, provider, result)
+
+	// Anthropic responses are first converted to chat completions, then to a response object
+	respBody, err = s.chatResponseBody(provider, resp.StatusCode, respBody)
+	if err != nil {
+		result.ErrorMessage = fmt.Sprintf("failed to transform response: %v", err)
+		return result, fmt.Errorf("failed to transform response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Errors are already in the OpenAI error shape used by the Responses API
+		c.Data(resp.StatusCode, "application/json", respBody)
+		return result, nil
+	}
+
+	var chatResp OpenAIChatResponse
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		result.ErrorMessage = fmt.Sprintf("failed to transform response: %v", err)
+		return result, fmt.Errorf("failed to transform response: %w", err)
+	}
+	applyChatResponseToResponses(response, &chatResp)
+
+	s.storeResponse(proxyKey, &responsesReq, response, conversation)
+
+	c.JSON(resp.StatusCode, response)
+	return result, nil
+}
+
+// GetStoredResponse returns the JSON body of a response stored for previous_response_id chaining
+func (s *ProxyService) GetStoredResponse(proxyKey *models.ProxyAPIKey, responseID string) ([]byte, error) {
+	if s.responseService == nil {
+		return nil, ErrResponseNotFound
+	}
+
+	stored, err := s.responseService.GetResponse(proxyKey.ID, responseID)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(stored.Body), nil
+}
+
+// DeleteStoredResponse deletes a stored response so it can no longer be continued
+func (s *ProxyService) DeleteStoredResponse(proxyKey *models.ProxyAPIKey, responseID string) error {
+	if s.responseService == nil {
+		return ErrResponseNotFound
+	}
+	return s.responseService.DeleteResponse(proxyKey.ID, responseID)
+}
+
+// storeResponse saves a completed response and its conversation so later requests can chain from it.
+// Storage is best-effort: the client already has its response, so a failure here is only logged.
+func (s *ProxyService) storeResponse(proxyKey *models.ProxyAPIKey, req *ResponsesRequest, response *ResponsesObject, conversation []OpenAIMessage) {
+	if s.responseService == nil || !response.Store {
+		return
+	}
+
+	messages := conversation
+	if len(response.Output) > 0 {
+		messages = append(messages, responsesOutputToMessage(response.Output))
+	}
+
+	messagesJSON, err := json.Marshal(messages)
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+
+	if err := s.responseService.SaveResponse(&models.StoredResponse{
+		ResponseID: response.ID,
+		UserID:     proxyKey.UserID,
+		ProxyKeyID: proxyKey.ID,
+		ModelName:  req.Model,
+		Messages:   string(messagesJSON),
+		Body:       string(body),
+	}); err != nil {
+		log.Printf("Failed to store response %s: %v", response.ID, err)
+	}
+}
+
+// transformResponsesToChat converts a Responses API request into an OpenAI chat request. It also
+// returns the conversation (history plus the new input, without instructions) for storage.
+func (s *ProxyService) transformResponsesToChat(req *ResponsesRequest, history []OpenAIMessage, modelName string) (*OpenAIChatRequest, []OpenAIMessage, error) {
+	input, err := responsesInputToMessages(req.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(history) == 0 && len(input) == 0 {
+		return nil, nil, fmt.Errorf("input is required")
+	}
+
+	conversation := append(append([]OpenAIMessage{}, history...), input...)
+
+	chatReq := &OpenAIChatRequest{
+		Model:       modelName,
+		MaxTokens:   req.MaxOutputTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stream:      req.Stream,
+		User:        req.User,
+	}
+	if req.Stream != nil && *req.Stream {
+		chatReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	// Instructions apply to this request only and are not carried over to chained responses
+	if req.Instructions != "" {
+		chatReq.Messages = append(chatReq.Messages, OpenAIMessage{Role: "system", Content: req.Instructions})
+	}
+	chatReq.Messages = append(chatReq.Messages, conversation...)
+
+	for _, tool := range req.Tools {
+		if tool.Type != "function" {
+			return nil, nil, fmt.Errorf("unsupported tool type %q: only function tools are available for this provider", tool.Type)
+		}
+		chatReq.Tools = append(chatReq.Tools, OpenAITool{
+			Type: "function",
+			Function: OpenAIFunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	switch choice := req.ToolChoice.(type) {
+	case nil:
+	case string:
+		chatReq.ToolChoice = choice
+	case map[string]interface{}:
+		name, _ := choice["name"].(string)
+		if choice["type"] != "function" || name == "" {
+			return nil, nil, fmt.Errorf("unsupported tool_choice: only function tool choices are available for this provider")
+		}
+		chatReq.ToolChoice = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": name},
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid tool_choice")
+	}
+
+	if len(chatReq.Tools) > 0 {
+		chatReq.ParallelToolCalls = req.ParallelToolCalls
+	}
+
+	return chatReq, conversation, nil
+}
+
+// responsesInputToMessages converts Responses API input (a string or an array of items) into chat messages
+func responsesInputToMessages(input interface{}) ([]OpenAIMessage, error) {
+	switch v := input.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []OpenAIMessage{{Role: "user", Content: v}}, nil
+	case []interface{}:
+		var messages []OpenAIMessage
+		for _, raw := range v {
+			item, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("input items must be objects")
+			}
+
+			itemType, _ := item["type"].(string)
+			switch itemType {
+			case "", "message":
+				message, err := responsesMessageItemToChat(item)
+				if err != nil {
+					return nil, err
+				}
+				messages = append(messages, message)
+			case "function_call":
+				callID, _ := item["call_id"].(string)
+				name, _ := item["name"].(string)
+				arguments, _ := item["arguments"].(string)
+				call := OpenAIToolCall{
+					ID:       callID,
+					Type:     "function",
+					Function: OpenAIFunctionCall{Name: name, Arguments: arguments},
+				}
+
+				// Consecutive function calls belong to the same assistant turn
+				if n := len(messages); n > 0 && messages[n-1].Role == "assistant" && len(messages[n-1].ToolCalls) > 0 {
+					messages[n-1].ToolCalls = append(messages[n-1].ToolCalls, call)
+				} else {
+					messages = append(messages, OpenAIMessage{Role: "assistant", ToolCalls: []OpenAIToolCall{call}})
+				}
+			case "function_call_output":
+				callID, _ := item["call_id"].(string)
+				output, ok := item["output"].(string)
+				if !ok {
+					encoded, _ := json.Marshal(item["output"])
+					output = string(encoded)
+				}
+				messages = append(messages, OpenAIMessage{Role: "tool", ToolCallID: callID, Content: output})
+			case "reasoning":
+				// Reasoning items from other models cannot be replayed
+			default:
+				return nil, fmt.Errorf("unsupported input item type %q", itemType)
+			}
+		}
+		return messages, nil
+	default:
+		return nil, fmt.Errorf("input must be a string or an array of items")
+	}
+}
+
+// responsesMessageItemToChat converts a Responses API message item into a chat message
+func responsesMessageItemToChat(item map[string]interface{}) (OpenAIMessage, error) {
+	role, _ := item["role"].(string)
+	switch role {
+	case "user", "assistant", "system":
+	case "developer":
+		// Not every OpenAI-compatible server understands the developer role
+		role = "system"
+	default:
+		return OpenAIMessage{}, fmt.Errorf("unsupported message role %q", role)
+	}
+
+	switch content := item["content"].(type) {
+	case string:
+		return OpenAIMessage{Role: role, Content: content}, nil
+	case []interface{}:
+		var parts []interface{}
+		for _, raw := range content {
+			part, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch part["type"] {
+			case "input_text", "output_text", "text":
+				text, _ := part["text"].(string)
+				parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+			case "input_image":
+				url, _ := part["image_url"].(string)
+				if url == "" {
+					return OpenAIMessage{}, fmt.Errorf("input_image must use image_url; file_id is not supported for this provider")
+				}
+				imageURL := map[string]interface{}{"url": url}
+				if detail, ok := part["detail"].(string); ok {
+					imageURL["detail"] = detail
+				}
+				parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": imageURL})
+			case "refusal":
+				refusal, _ := part["refusal"].(string)
+				parts = append(parts, map[string]interface{}{"type": "text", "text": refusal})
+			default:
+				return OpenAIMessage{}, fmt.Errorf("unsupported content part type %v", part["type"])
+			}
+		}
+		return OpenAIMessage{Role: role, Content: openAIContentFromParts(parts)}, nil
+	default:
+		return OpenAIMessage{}, fmt.Errorf("message content must be a string or an array of parts")
+	}
+}
+
+// newResponsesObject creates an in-progress response object echoing the request parameters
+func newResponsesObject(req *ResponsesRequest) *ResponsesObject {
+	response := &ResponsesObject{
+		ID:                newResponsesID("resp_"),
+		Object:            "response",
+		CreatedAt:         time.Now().Unix(),
+		Status:            "in_progress",
+		Model:             req.Model,
+		Output:            []ResponsesOutputItem{},
+		MaxOutputTokens:   req.MaxOutputTokens,
+		Temperature:       req.Temperature,
+		TopP:              req.TopP,
+		ParallelToolCalls: req.ParallelToolCalls == nil || *req.ParallelToolCalls,
+		ToolChoice:        req.ToolChoice,
+		Tools:             req.Tools,
+		Store:             req.Store == nil || *req.Store,
+		Metadata:          req.Metadata,
+	}
+
+	if req.Instructions != "" {
+		instructions := req.Instructions
+		response.Instructions = &instructions
+	}
+	if req.PreviousResponseID != "" {
+		previousID := req.PreviousResponseID
+		response.PreviousResponseID = &previousID
+	}
+	if response.ToolChoice == nil {
+		response.ToolChoice = "auto"
+	}
+	if response.Tools == nil {
+		response.Tools = []ResponsesTool{}
+	}
+	if response.Metadata == nil {
+		response.Metadata = map[string]string{}
+	}
+
+	return response
+}
+
+// applyChatResponseToResponses fills a response object from a chat.completion
+func applyChatResponseToResponses(response *ResponsesObject, chatResp *OpenAIChatResponse) {
+	if len(chatResp.Choices) > 0 {
+		choice := chatResp.Choices[0]
+		if text := choice.Message.GetContentString(); text != "" {
+			response.Output = append(response.Output, ResponsesOutputItem{
+				Type:    "message",
+				ID:      newResponsesID("msg_"),
+				Status:  "completed",
+				Role:    "assistant",
+				Content: []ResponsesContentPart{{Type: "output_text", Text: text, Annotations: []interface{}{}}},
+			})
+		}
+		for _, call := range choice.Message.ToolCalls {
+			response.Output = append(response.Output, ResponsesOutputItem{
+				Type:      "function_call",
+				ID:        newResponsesID("fc_"),
+				Status:    "completed",
+				CallID:    call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		applyResponsesFinishReason(response, choice.FinishReason)
+	} else {
+		applyResponsesFinishReason(response, "")
+	}
+
+	if chatResp.Usage != nil {
+		response.Usage = chatUsageToResponses(chatResp.Usage)
+	}
+}
+
+// applyResponsesFinishReason sets the final status of a response from a chat finish_reason
+func applyResponsesFinishReason(response *ResponsesObject, finishReason string) {
+	switch finishReason {
+	case "length":
+		response.Status = "incomplete"
+		response.IncompleteDetails = &ResponsesIncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		response.Status = "incomplete"
+		response.IncompleteDetails = &ResponsesIncompleteDetails{Reason: "content_filter"}
+	default:
+		response.Status = "completed"
+	}
+}
+
+// chatUsageToResponses converts chat completion usage into Responses API usage
+func chatUsageToResponses(usage *OpenAIUsage) *ResponsesUsage {
+	converted := &ResponsesUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+	if usage.PromptTokensDetails != nil {
+		converted.InputTokensDetails.CachedTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	return converted
+}
+
+// responsesOutputToMessage converts response output items back into an assistant chat message
+func responsesOutputToMessage(output []ResponsesOutputItem) OpenAIMessage {
+	message := OpenAIMessage{Role: "assistant"}
+
+	var texts []string
+	for _, item := range output {
+		switch item.Type {
+		case "message":
+			for _, part := range item.Content {
+				texts = append(texts, part.Text)
+			}
+		case "function_call":
+			message.ToolCalls = append(message.ToolCalls, OpenAIToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: item.Name, Arguments: item.Arguments},
+			})
+		}
+	}
+	if len(texts) > 0 {
+		message.Content = strings.Join(texts, "")
+	}
+
+	return message
+}
+
+// newResponsesID generates a random identifier with the given prefix (resp_, msg_, fc_)
+func newResponsesID(prefix string) string {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+	}
+	return prefix + hex.EncodeToString(randomBytes)
+}
+
+// responsesStreamTranslator converts OpenAI chat.completion.chunk events into Responses API
+// stream events (response.created ... response.completed), building the final response as it goes
+type responsesStreamTranslator struct {
+	response *ResponsesObject
+	sequence int
+	started  bool
+	done     bool
+
+	// The output item currently being streamed (a message or a function call)
+	item      *ResponsesOutputItem
+	itemIndex int
+	toolIndex int // chat tool_calls index of the open function call
+
+	finishReason string
+}
+
+// newResponsesStreamTranslator creates a translator that fills in response
+func newResponsesStreamTranslator(response *ResponsesObject) *responsesStreamTranslator {
+	return &responsesStreamTranslator{response: response}
+}
+
+// Translate implements streamTranslator
+func (t *responsesStreamTranslator) Translate(ev *sseEvent) []sseEvent {
+	if t.done || ev.Data == "" {
+		return nil
+	}
+	if ev.Data == "[DONE]" {
+		return t.Finish()
+	}
+
+	var chunk struct {
+		OpenAIChatChunk
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+		return nil
+	}
+
+	if chunk.Model != "" && !t.started {
+		t.response.Model = chunk.Model
+	}
+	events := t.start()
+
+	if chunk.Error != nil {
+		t.done = true
+		t.response.Status = "failed"
+		t.response.Error = &ResponsesError{Code: "server_error", Message: chunk.Error.Message}
+		return append(events, t.event("response.failed", map[string]interface{}{"response": t.response}))
+	}
+
+	if chunk.Usage != nil {
+		t.response.Usage = chatUsageToResponses(chunk.Usage)
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			if t.item == nil || t.item.Type != "message" {
+				events = append(events, t.closeItem()...)
+				events = append(events, t.openItem(ResponsesOutputItem{
+					Type:   "message",
+					ID:     newResponsesID("msg_"),
+					Status: "in_progress",
+					Role:   "assistant",
+				})...)
+				events = append(events, t.event("response.content_part.added", map[string]interface{}{
+					"item_id":       t.item.ID,
+					"output_index":  t.itemIndex,
+					"content_index": 0,
+					"part":          ResponsesContentPart{Type: "output_text", Annotations: []interface{}{}},
+				}))
+				t.item.Content = []ResponsesContentPart{{Type: "output_text", Annotations: []interface{}{}}}
+			}
+
+			t.item.Content[0].Text += *choice.Delta.Content
+			events = append(events, t.event("response.output_text.delta", map[string]interface{}{
+				"item_id":       t.item.ID,
+				"output_index":  t.itemIndex,
+				"content_index": 0,
+				"delta":         *choice.Delta.Content,
+			}))
+		}
+
+		for _, call := range choice.Delta.ToolCalls {
+			toolIndex := 0
+			if call.Index != nil {
+				toolIndex = *call.Index
+			}
+
+			if t.item == nil || t.item.Type != "function_call" || t.toolIndex != toolIndex {
+				events = append(events, t.closeItem()...)
+				t.toolIndex = toolIndex
+				events = append(events, t.openItem(ResponsesOutputItem{
+					Type:   "function_call",
+					ID:     newResponsesID("fc_"),
+					Status: "in_progress",
+					CallID: call.ID,
+					Name:   call.Function.Name,
+				})...)
+			}
+
+			if call.Function.Arguments != "" {
+				t.item.Arguments += call.Function.Arguments
+				events = append(events, t.event("response.function_call_arguments.delta", map[string]interface{}{
+					"item_id":      t.item.ID,
+					"output_index": t.itemIndex,
+					"delta":        call.Function.Arguments,
+				}))
+			}
+		}
+
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			t.finishReason = *choice.FinishReason
+		}
+	}
+
+	return events
+}
+
+// Finish implements streamTranslator
+func (t *responsesStreamTranslator) Finish() []sseEvent {
+	if t.done {
+		return nil
+	}
+	t.done = true
+
+	events := t.start()
+	events = append(events, t.closeItem()...)
+
+	applyResponsesFinishReason(t.response, t.finishReason)
+	name := "response.completed"
+	if t.response.Status == "incomplete" {
+		name = "response.incomplete"
+	}
+	return append(events, t.event(name, map[string]interface{}{"response": t.response}))
+}
+
+// start emits response.created and response.in_progress the first time it is called
+func (t *responsesStreamTranslator) start() []sseEvent {
+	if t.started {
+		return nil
+	}
+	t.started = true
+
+	return []sseEvent{
+		t.event("response.created", map[string]interface{}{"response": t.response}),
+		t.event("response.in_progress", map[string]interface{}{"response": t.response}),
+	}
+}
+
+// openItem adds a new in-progress output item
+func (t *responsesStreamTranslator) openItem(item ResponsesOutputItem) []sseEvent {
+	t.item = &item
+	t.itemIndex = len(t.response.Output)
+	t.response.Output = append(t.response.Output, item)
+
+	return []sseEvent{t.event("response.output_item.added", map[string]interface{}{
+		"output_index": t.itemIndex,
+		"item":         item,
+	})}
+}
+
+// closeItem emits the *.done events for the open output item, if any
+func (t *responsesStreamTranslator) closeItem() []sseEvent {
+	if t.item == nil {
+		return nil
+	}
+
+	item := t.item
+	item.Status = "completed"
+	t.response.Output[t.itemIndex] = *item
+	t.item = nil
+
+	var events []sseEvent
+	switch item.Type {
+	case "message":
+		part := item.Content[0]
+		events = append(events,
+			t.event("response.output_text.done", map[string]interface{}{
+				"item_id":       item.ID,
+				"output_index":  t.itemIndex,
+				"content_index": 0,
+				"text":          part.Text,
+			}),
+			t.event("response.content_part.done", map[string]interface{}{
+				"item_id":       item.ID,
+				"output_index":  t.itemIndex,
+				"content_index": 0,
+				"part":          part,
+			}),
+		)
+	case "function_call":
+		events = append(events, t.event("response.function_call_arguments.done", map[string]interface{}{
+			"item_id":      item.ID,
+			"output_index": t.itemIndex,
+			"arguments":    item.Arguments,
+		}))
+	}
+
+	return append(events, t.event("response.output_item.done", map[string]interface{}{
+		"output_index": t.itemIndex,
+		"item":         *item,
+	}))
+}
+
+// event builds a named Responses stream event with the next sequence number
+func (t *responsesStreamTranslator) event(name string, payload map[string]interface{}) sseEvent {
+	payload["type"] = name
+	payload["sequence_number"] = t.sequence
+	t.sequence++
+
+	data, _ := json.Marshal(payload)
+	return sseEvent{Event: name, Data: string(data)}
+}
@@ -0,0 +1,430 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smoothweb/backend/internal/custom/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyService_TransformResponsesToChat(t *testing.T) {
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+
+	tests := []struct {
+		name    string
+		body    string
+		history []OpenAIMessage
+		wantErr string
+		check   func(t *testing.T, req *OpenAIChatRequest, conversation []OpenAIMessage)
+	}{
+		{
+			name: "string input with instructions",
+			body: `{"model":"m","input":"Hi","instructions":"Be brief.","max_output_tokens":50,"stream":true}`,
+			check: func(t *testing.T, req *OpenAIChatRequest, conversation []OpenAIMessage) {
+				require.Len(t, req.Messages, 2)
+				assert.Equal(t, "system", req.Messages[0].Role)
+				assert.Equal(t, "Be brief.", req.Messages[0].Content)
+				assert.Equal(t, "Hi", req.Messages[1].Content)
+				require.NotNil(t, req.MaxTokens)
+				assert.Equal(t, 50, *req.MaxTokens)
+				require.NotNil(t, req.StreamOptions)
+				assert.True(t, req.StreamOptions.IncludeUsage)
+
+				// Instructions are not part of the stored conversation
+				require.Len(t, conversation, 1)
+				assert.Equal(t, "user", conversation[0].Role)
+			},
+		},
+		{
+			name: "message items, function calls and outputs",
+			body: `{"model":"m","input":[
+				{"role":"developer","content":"Rules"},
+				{"type":"message","role":"user","content":[{"type":"input_text","text":"Look"},{"type":"input_image","image_url":"https://x/img.png"}]},
+				{"type":"reasoning","id":"rs_1","summary":[]},
+				{"type":"function_call","call_id":"call_1","name":"a","arguments":"{}"},
+				{"type":"function_call","call_id":"call_2","name":"b","arguments":"{\"x\":1}"},
+				{"type":"function_call_output","call_id":"call_1","output":"one"},
+				{"type":"function_call_output","call_id":"call_2","output":{"ok":true}}]}`,
+			check: func(t *testing.T, req *OpenAIChatRequest, conversation []OpenAIMessage) {
+				require.Len(t, req.Messages, 5)
+				assert.Equal(t, "system", req.Messages[0].Role)
+
+				parts, ok := req.Messages[1].Content.([]interface{})
+				require.True(t, ok)
+				require.Len(t, parts, 2)
+				assert.Equal(t, "image_url", parts[1].(map[string]interface{})["type"])
+
+				assistant := req.Messages[2]
+				assert.Equal(t, "assistant", assistant.Role)
+				require.Len(t, assistant.ToolCalls, 2)
+				assert.Equal(t, "call_2", assistant.ToolCalls[1].ID)
+
+				assert.Equal(t, "tool", req.Messages[3].Role)
+				assert.Equal(t, "one", req.Messages[3].Content)
+				assert.JSONEq(t, `{"ok":true}`, req.Messages[4].Content.(string))
+			},
+		},
+		{
+			name: "history precedes new input",
+			body: `{"model":"m","input":"And now?","previous_response_id":"resp_1"}`,
+			history: []OpenAIMessage{
+				{Role: "user", Content: "Hi"},
+				{Role: "assistant", Content: "Hello"},
+			},
+			check: func(t *testing.T, req *OpenAIChatRequest, conversation []OpenAIMessage) {
+				require.Len(t, req.Messages, 3)
+				assert.Equal(t, "Hello", req.Messages[1].Content)
+				assert.Equal(t, "And now?", req.Messages[2].Content)
+				assert.Len(t, conversation, 3)
+			},
+		},
+		{
+			name: "function tools and tool choice",
+			body: `{"model":"m","input":"x","tools":[{"type":"function","name":"lookup","description":"d","parameters":{"type":"object"}}],"tool_choice":{"type":"function","name":"lookup"},"parallel_tool_calls":false}`,
+			check: func(t *testing.T, req *OpenAIChatRequest, conversation []OpenAIMessage) {
+				require.Len(t, req.Tools, 1)
+				assert.Equal(t, "lookup", req.Tools[0].Function.Name)
+				assert.JSONEq(t, `{"type":"object"}`, string(req.Tools[0].Function.Parameters))
+				assert.Equal(t, map[string]interface{}{
+					"type":     "function",
+					"function": map[string]interface{}{"name": "lookup"},
+				}, req.ToolChoice)
+				require.NotNil(t, req.ParallelToolCalls)
+				assert.False(t, *req.ParallelToolCalls)
+			},
+		},
+		{
+			name:    "built-in tools are rejected",
+			body:    `{"model":"m","input":"x","tools":[{"type":"web_search"}]}`,
+			wantErr: "unsupported tool type",
+		},
+		{
+			name:    "unknown item type",
+			body:    `{"model":"m","input":[{"type":"computer_call"}]}`,
+			wantErr: "unsupported input item type",
+		},
+		{
+			name:    "missing input",
+			body:    `{"model":"m"}`,
+			wantErr: "input is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req ResponsesRequest
+			require.NoError(t, json.Unmarshal([]byte(tt.body), &req))
+
+			chatReq, conversation, err := service.transformResponsesToChat(&req, tt.history, "upstream-model")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "upstream-model", chatReq.Model)
+			tt.check(t, chatReq, conversation)
+		})
+	}
+}
+
+func TestResponsesStreamTranslator(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     string
+		wantEvents []string
+		wantStatus string
+		check      func(t *testing.T, response *ResponsesObject)
+	}{
+		{
+			name: "text",
+			stream: "data: {\"id\":\"c1\",\"model\":\"qwen\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+				"data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: {\"id\":\"c1\",\"choices\":[],\"usage\":{\"prompt_tokens\":4,\"completion_tokens\":2,\"total_tokens\":6}}\n\n" +
+				"data: [DONE]\n\n",
+			wantEvents: []string{
+				"response.created", "response.in_progress",
+				"response.output_item.added", "response.content_part.added",
+				"response.output_text.delta", "response.output_text.delta",
+				"response.output_text.done", "response.content_part.done", "response.output_item.done",
+				"response.completed",
+			},
+			wantStatus: "completed",
+			check: func(t *testing.T, response *ResponsesObject) {
+				assert.Equal(t, "qwen", response.Model)
+				require.Len(t, response.Output, 1)
+				assert.Equal(t, "Hello", response.Output[0].Content[0].Text)
+				require.NotNil(t, response.Usage)
+				assert.Equal(t, 6, response.Usage.TotalTokens)
+			},
+		},
+		{
+			name: "parallel function calls",
+			stream: "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"a\",\"arguments\":\"{\\\"x\\\"\"}}]}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\":1}\"}}]}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":1,\"id\":\"call_2\",\"type\":\"function\",\"function\":{\"name\":\"b\",\"arguments\":\"{}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n" +
+				"data: [DONE]\n\n",
+			wantEvents: []string{
+				"response.created", "response.in_progress",
+				"response.output_item.added", "response.function_call_arguments.delta", "response.function_call_arguments.delta",
+				"response.function_call_arguments.done", "response.output_item.done",
+				"response.output_item.added", "response.function_call_arguments.delta",
+				"response.function_call_arguments.done", "response.output_item.done",
+				"response.completed",
+			},
+			wantStatus: "completed",
+			check: func(t *testing.T, response *ResponsesObject) {
+				require.Len(t, response.Output, 2)
+				assert.Equal(t, "call_1", response.Output[0].CallID)
+				assert.Equal(t, `{"x":1}`, response.Output[0].Arguments)
+				assert.Equal(t, "b", response.Output[1].Name)
+				assert.Equal(t, "completed", response.Output[1].Status)
+			},
+		},
+		{
+			name:   "truncated output is incomplete",
+			stream: "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"length\"}]}\n\n",
+			wantEvents: []string{
+				"response.created", "response.in_progress",
+				"response.output_item.added", "response.content_part.added", "response.output_text.delta",
+				"response.output_text.done", "response.content_part.done", "response.output_item.done",
+				"response.incomplete",
+			},
+			wantStatus: "incomplete",
+			check: func(t *testing.T, response *ResponsesObject) {
+				require.NotNil(t, response.IncompleteDetails)
+				assert.Equal(t, "max_output_tokens", response.IncompleteDetails.Reason)
+			},
+		},
+		{
+			name:       "upstream error",
+			stream:     "data: {\"error\":{\"message\":\"boom\"}}\n\n",
+			wantEvents: []string{"response.created", "response.in_progress", "response.failed"},
+			wantStatus: "failed",
+			check: func(t *testing.T, response *ResponsesObject) {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, "boom", response.Error.Message)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := newResponsesObject(&ResponsesRequest{Model: "local/qwen"})
+			events := translateRecordedEvents(t, newResponsesStreamTranslator(response), tt.stream)
+
+			var names []string
+			for i, ev := range events {
+				names = append(names, ev.Event)
+
+				var payload struct {
+					Type           string `json:"type"`
+					SequenceNumber int    `json:"sequence_number"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(ev.Data), &payload))
+				assert.Equal(t, ev.Event, payload.Type)
+				assert.Equal(t, i, payload.SequenceNumber)
+			}
+
+			assert.Equal(t, tt.wantEvents, names)
+			assert.Equal(t, tt.wantStatus, response.Status)
+			tt.check(t, response)
+		})
+	}
+}
+
+// postJSON sends a JSON request to a test server and decodes the JSON response body
+func postJSON(t *testing.T, url, body string, out interface{}) int {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestProxyService_ProxyResponses(t *testing.T) {
+	t.Run("passes through to OpenAI and records Responses usage", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/responses", r.URL.Path)
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "gpt-4.1", body["model"])
+			assert.Equal(t, "low", body["reasoning"].(map[string]interface{})["effort"])
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"resp_openai","object":"response","status":"completed","output":[],"usage":{"input_tokens":9,"output_tokens":4,"total_tokens":13}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/responses", func(c *gin.Context) {
+			result, _ := service.ProxyResponses(c, proxyKey)
+			results <- result
+		})
+
+		var response map[string]interface{}
+		status := postJSON(t, server.URL+"/v1/responses", `{"model":"openai/gpt-4.1","input":"Hi","reasoning":{"effort":"low"}}`, &response)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "resp_openai", response["id"])
+
+		result := <-results
+		assert.Equal(t, 9, result.InputTokens)
+		assert.Equal(t, 4, result.OutputTokens)
+		assert.Equal(t, 13, result.TotalTokens)
+	})
+
+	t.Run("translates for chat providers and chains stored responses", func(t *testing.T) {
+		var requests []OpenAIChatRequest
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+
+			var chatReq OpenAIChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chatReq))
+			requests = append(requests, chatReq)
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"c1","object":"chat.completion","model":"qwen","choices":[{"index":0,"message":{"role":"assistant","content":"Paris"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeVLLM, upstream.URL)
+
+		server := newProxyTestServer(t, "/v1/responses", func(c *gin.Context) {
+			service.ProxyResponses(c, proxyKey)
+		})
+
+		var first ResponsesObject
+		status := postJSON(t, server.URL+"/v1/responses", `{"model":"qwen","instructions":"Answer tersely.","input":"Capital of France?"}`, &first)
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "response", first.Object)
+		assert.Equal(t, "completed", first.Status)
+		assert.True(t, strings.HasPrefix(first.ID, "resp_"))
+		require.Len(t, first.Output, 1)
+		assert.Equal(t, "Paris", first.Output[0].Content[0].Text)
+		require.NotNil(t, first.Usage)
+		assert.Equal(t, 5, first.Usage.InputTokens)
+
+		var second ResponsesObject
+		status = postJSON(t, server.URL+"/v1/responses", `{"model":"qwen","previous_response_id":"`+first.ID+`","input":"And Germany?"}`, &second)
+		require.Equal(t, http.StatusOK, status)
+		require.NotNil(t, second.PreviousResponseID)
+		assert.Equal(t, first.ID, *second.PreviousResponseID)
+
+		require.Len(t, requests, 2)
+		chained := requests[1].Messages
+		require.Len(t, chained, 3)
+		assert.Equal(t, "Capital of France?", chained[0].Content)
+		assert.Equal(t, "assistant", chained[1].Role)
+		assert.Equal(t, "Paris", chained[1].Content)
+		assert.Equal(t, "And Germany?", chained[2].Content)
+
+		// Stored responses can be retrieved and deleted
+		body, err := service.GetStoredResponse(proxyKey, first.ID)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), first.ID)
+		require.NoError(t, service.DeleteStoredResponse(proxyKey, first.ID))
+		_, err = service.GetStoredResponse(proxyKey, first.ID)
+		assert.ErrorIs(t, err, ErrResponseNotFound)
+	})
+
+	t.Run("does not store when store is false", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"c1","model":"qwen","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeLocal, upstream.URL)
+
+		server := newProxyTestServer(t, "/v1/responses", func(c *gin.Context) {
+			service.ProxyResponses(c, proxyKey)
+		})
+
+		var response ResponsesObject
+		postJSON(t, server.URL+"/v1/responses", `{"model":"qwen","input":"x","store":false}`, &response)
+		assert.False(t, response.Store)
+
+		var count int64
+		db.Model(&models.StoredResponse{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("unknown previous_response_id", func(t *testing.T) {
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeVLLM, "http://127.0.0.1:1")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses",
+			strings.NewReader(`{"model":"qwen","input":"x","previous_response_id":"resp_missing"}`))
+
+		result, err := service.ProxyResponses(c, proxyKey)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+
+	t.Run("streams Anthropic output as Responses events", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/messages", r.URL.Path)
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, anthropicStreamStart+anthropicStreamText+anthropicStreamEnd)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/responses", func(c *gin.Context) {
+			result, _ := service.ProxyResponses(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/responses", "application/json",
+			strings.NewReader(`{"model":"claude-sonnet-4","input":"Hi","stream":true}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "event: response.created")
+		assert.Contains(t, string(body), `"delta":"Hello"`)
+		assert.Contains(t, string(body), "event: response.completed")
+		assert.NotContains(t, string(body), "[DONE]")
+
+		result := <-results
+		assert.Equal(t, 21, result.InputTokens)
+		assert.Equal(t, 9, result.OutputTokens)
+
+		var stored models.StoredResponse
+		require.Eventually(t, func() bool {
+			return db.Where("proxy_key_id = ?", proxyKey.ID).First(&stored).Error == nil
+		}, time.Second, 10*time.Millisecond)
+		assert.Contains(t, stored.Messages, "Hello there")
+		assert.Contains(t, stored.Body, `"input_tokens":21`)
+	})
+}
@@ -1,8 +1,16 @@
 package services
 
 import (
+	"bufio"
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,7 +25,7 @@ func setupProxyTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 
-	err = db.AutoMigrate(&models.Provider{}, &models.ProxyAPIKey{}, &models.UsageRecord{}, &models.KeyAllowedProvider{})
+	err = db.AutoMigrate(&models.Provider{}, &models.ProxyAPIKey{}, &models.UsageRecord{}, &models.KeyAllowedProvider{}, &models.StoredResponse{}, &models.ModelAlias{})
 	require.NoError(t, err)
 
 	return db
@@ -25,9 +33,9 @@ func setupProxyTestDB(t *testing.T) *gorm.DB {
 
 func createProxyTestServices(t *testing.T, db *gorm.DB) *ProxyService {
 	keyService := NewKeyService(db)
-	providerService := NewProviderService(db)
+	providerService := NewProviderService(db, nil)
 	usageService := NewUsageService(db)
-	return NewProxyService(keyService, providerService, usageService, nil)
+	return NewProxyService(keyService, providerService, usageService, nil, NewResponseService(db), NewLoadBalancer())
 }
 
 func TestProxyService_ParseModelName(t *testing.T) {
@@ -332,6 +340,804 @@ func TestProxyService_TransformToAnthropic(t *testing.T) {
 	})
 }
 
+func TestProxyService_TransformAnthropicResponse(t *testing.T) {
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+
+	tests := []struct {
+		name         string
+		response     string
+		wantContent  string
+		wantFinish   string
+		wantPrompt   int
+		wantComplete int
+		wantCached   int
+	}{
+		{
+			name:         "maps text content and end_turn",
+			response:     `{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Hello!"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":4}}`,
+			wantContent:  "Hello!",
+			wantFinish:   "stop",
+			wantPrompt:   12,
+			wantComplete: 4,
+		},
+		{
+			name:         "concatenates text blocks and skips thinking",
+			response:     `{"id":"msg_02","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Hello, "},{"type":"text","text":"world"}],"stop_reason":"stop_sequence","usage":{"input_tokens":5,"output_tokens":3}}`,
+			wantContent:  "Hello, world",
+			wantFinish:   "stop",
+			wantPrompt:   5,
+			wantComplete: 3,
+		},
+		{
+			name:         "maps max_tokens to length",
+			response:     `{"id":"msg_03","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Trunc"}],"stop_reason":"max_tokens","usage":{"input_tokens":1,"output_tokens":1}}`,
+			wantContent:  "Trunc",
+			wantFinish:   "length",
+			wantPrompt:   1,
+			wantComplete: 1,
+		},
+		{
+			name:         "includes cached tokens in prompt tokens",
+			response:     `{"id":"msg_04","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"cache_creation_input_tokens":5,"cache_read_input_tokens":100,"output_tokens":2}}`,
+			wantContent:  "Hi",
+			wantFinish:   "stop",
+			wantPrompt:   115,
+			wantComplete: 2,
+			wantCached:   100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := service.transformAnthropicResponse([]byte(tt.response))
+			require.NoError(t, err)
+
+			var chatResp OpenAIChatResponse
+			require.NoError(t, json.Unmarshal(body, &chatResp))
+
+			var anthropicResp AnthropicResponse
+			require.NoError(t, json.Unmarshal([]byte(tt.response), &anthropicResp))
+
+			assert.Equal(t, anthropicResp.ID, chatResp.ID)
+			assert.Equal(t, "chat.completion", chatResp.Object)
+			assert.Equal(t, anthropicResp.Model, chatResp.Model)
+			require.Len(t, chatResp.Choices, 1)
+			assert.Equal(t, "assistant", chatResp.Choices[0].Message.Role)
+			assert.Equal(t, tt.wantContent, chatResp.Choices[0].Message.Content)
+			assert.Equal(t, tt.wantFinish, chatResp.Choices[0].FinishReason)
+			require.NotNil(t, chatResp.Usage)
+			assert.Equal(t, tt.wantPrompt, chatResp.Usage.PromptTokens)
+			assert.Equal(t, tt.wantComplete, chatResp.Usage.CompletionTokens)
+			assert.Equal(t, tt.wantPrompt+tt.wantComplete, chatResp.Usage.TotalTokens)
+			if tt.wantCached > 0 {
+				require.NotNil(t, chatResp.Usage.PromptTokensDetails)
+				assert.Equal(t, tt.wantCached, chatResp.Usage.PromptTokensDetails.CachedTokens)
+			}
+		})
+	}
+
+	t.Run("fails for invalid JSON", func(t *testing.T) {
+		_, err := service.transformAnthropicResponse([]byte("not json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("converts Anthropic errors to the OpenAI error shape", func(t *testing.T) {
+		body, err := service.transformAnthropicError([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens too large"}}`))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"error":{"message":"max_tokens too large","type":"invalid_request_error","code":null}}`, string(body))
+	})
+
+	t.Run("routes anthropic models through the translator end to end", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/messages", r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"msg_05","type":"message","role":"assistant","model":"claude-sonnet-4","content":[{"type":"text","text":"Routed"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, upstream.URL)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"anthropic/claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&chatResp))
+		require.Len(t, chatResp.Choices, 1)
+		assert.Equal(t, "Routed", chatResp.Choices[0].Message.Content)
+		assert.Equal(t, 4, chatResp.Usage.TotalTokens)
+	})
+}
+
+// recorded Anthropic stream fragments used by the stream translation tests
+const (
+	anthropicStreamStart = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_stream\",\"type\":\"message\",\"role\":\"assistant\",\"content\":[],\"model\":\"claude-sonnet-4-20250514\",\"stop_reason\":null,\"usage\":{\"input_tokens\":21,\"output_tokens\":1}}}\n\n"
+	anthropicStreamText  = "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" there\"}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"
+	anthropicStreamEnd = "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\",\"stop_sequence\":null},\"usage\":{\"output_tokens\":9}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+)
+
+// translateRecordedEvents runs a recorded upstream stream through a translator and returns the emitted events
+func translateRecordedEvents(t *testing.T, translator streamTranslator, raw string) []sseEvent {
+	var events []sseEvent
+	reader := newSSEReader(strings.NewReader(raw))
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, translator.Translate(ev)...)
+	}
+	return append(events, translator.Finish()...)
+}
+
+// translateRecordedStream is like translateRecordedEvents but returns only the data payloads
+func translateRecordedStream(t *testing.T, translator streamTranslator, raw string) []string {
+	var payloads []string
+	for _, ev := range translateRecordedEvents(t, translator, raw) {
+		payloads = append(payloads, ev.Data)
+	}
+	return payloads
+}
+
+func TestAnthropicStreamTranslator(t *testing.T) {
+	tests := []struct {
+		name         string
+		stream       string
+		includeUsage bool
+		wantContent  string
+		wantFinish   string
+		wantUsage    *OpenAIUsage
+		wantError    string
+	}{
+		{
+			name:        "text stream without usage",
+			stream:      anthropicStreamStart + anthropicStreamText + anthropicStreamEnd,
+			wantContent: "Hello there",
+			wantFinish:  "stop",
+		},
+		{
+			name:         "text stream with include_usage",
+			stream:       anthropicStreamStart + anthropicStreamText + anthropicStreamEnd,
+			includeUsage: true,
+			wantContent:  "Hello there",
+			wantFinish:   "stop",
+			wantUsage:    &OpenAIUsage{PromptTokens: 21, CompletionTokens: 9, TotalTokens: 30},
+		},
+		{
+			name: "max_tokens maps to length",
+			stream: anthropicStreamStart + anthropicStreamText +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"max_tokens\"},\"usage\":{\"output_tokens\":2}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			wantContent: "Hello there",
+			wantFinish:  "length",
+		},
+		{
+			name:        "truncated stream still terminates with DONE",
+			stream:      anthropicStreamStart + anthropicStreamText,
+			wantContent: "Hello there",
+		},
+		{
+			name: "upstream error is forwarded in the OpenAI shape",
+			stream: anthropicStreamStart +
+				"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+			wantError: "overloaded_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payloads := translateRecordedStream(t, newAnthropicStreamTranslator(tt.includeUsage), tt.stream)
+			require.NotEmpty(t, payloads)
+			assert.Equal(t, "[DONE]", payloads[len(payloads)-1])
+
+			var content strings.Builder
+			var finish string
+			var usage *OpenAIUsage
+			var errType string
+			for i, payload := range payloads[:len(payloads)-1] {
+				var errBody struct {
+					Error *struct {
+						Type string `json:"type"`
+					} `json:"error"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(payload), &errBody))
+				if errBody.Error != nil {
+					errType = errBody.Error.Type
+					continue
+				}
+
+				var chunk OpenAIChatChunk
+				require.NoError(t, json.Unmarshal([]byte(payload), &chunk))
+				assert.Equal(t, "chat.completion.chunk", chunk.Object)
+				assert.Equal(t, "msg_stream", chunk.ID)
+				assert.Equal(t, "claude-sonnet-4-20250514", chunk.Model)
+				if i == 0 {
+					require.Len(t, chunk.Choices, 1)
+					assert.Equal(t, "assistant", chunk.Choices[0].Delta.Role)
+				}
+				if chunk.Usage != nil {
+					assert.Empty(t, chunk.Choices)
+					usage = chunk.Usage
+				}
+				for _, choice := range chunk.Choices {
+					if choice.Delta.Content != nil {
+						content.WriteString(*choice.Delta.Content)
+					}
+					if choice.FinishReason != nil {
+						finish = *choice.FinishReason
+					}
+				}
+			}
+
+			assert.Equal(t, tt.wantContent, content.String())
+			assert.Equal(t, tt.wantFinish, finish)
+			assert.Equal(t, tt.wantUsage, usage)
+			assert.Equal(t, tt.wantError, errType)
+		})
+	}
+
+	t.Run("proxies a streamed anthropic model as chat.completion.chunk events", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, anthropicStreamStart+anthropicStreamText+anthropicStreamEnd)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, upstream.URL)
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"claude-sonnet-4","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"object":"chat.completion.chunk"`)
+		assert.Contains(t, string(body), `"prompt_tokens":21`)
+		assert.NotContains(t, string(body), "message_start")
+		assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+
+		result := <-results
+		assert.Equal(t, 21, result.InputTokens)
+		assert.Equal(t, 9, result.OutputTokens)
+	})
+}
+
+func TestProxyService_ToolTranslation(t *testing.T) {
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+
+	t.Run("translates tools, tool calls and tool results to Anthropic", func(t *testing.T) {
+		var openAIReq OpenAIChatRequest
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"model": "claude-sonnet-4",
+			"tools": [{"type":"function","function":{"name":"get_weather","description":"Get weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}],
+			"tool_choice": "required",
+			"parallel_tool_calls": false,
+			"messages": [
+				{"role":"user","content":"Weather in Paris and Rome?"},
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}
+				]},
+				{"role":"tool","tool_call_id":"call_1","content":"Sunny"},
+				{"role":"tool","tool_call_id":"call_2","content":"Rainy"}
+			]
+		}`), &openAIReq))
+
+		body, err := service.transformToAnthropic(&openAIReq, "claude-sonnet-4")
+		require.NoError(t, err)
+
+		var anthropicReq map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &anthropicReq))
+
+		tools := anthropicReq["tools"].([]interface{})
+		require.Len(t, tools, 1)
+		tool := tools[0].(map[string]interface{})
+		assert.Equal(t, "get_weather", tool["name"])
+		assert.Equal(t, "Get weather", tool["description"])
+		assert.Equal(t, "object", tool["input_schema"].(map[string]interface{})["type"])
+
+		assert.Equal(t, map[string]interface{}{"type": "any", "disable_parallel_tool_use": true}, anthropicReq["tool_choice"])
+
+		messages := anthropicReq["messages"].([]interface{})
+		require.Len(t, messages, 3)
+
+		assistant := messages[1].(map[string]interface{})
+		assert.Equal(t, "assistant", assistant["role"])
+		toolUses := assistant["content"].([]interface{})
+		require.Len(t, toolUses, 2)
+		first := toolUses[0].(map[string]interface{})
+		assert.Equal(t, "tool_use", first["type"])
+		assert.Equal(t, "call_1", first["id"])
+		assert.Equal(t, "get_weather", first["name"])
+		assert.Equal(t, map[string]interface{}{"city": "Paris"}, first["input"])
+
+		results := messages[2].(map[string]interface{})
+		assert.Equal(t, "user", results["role"])
+		resultBlocks := results["content"].([]interface{})
+		require.Len(t, resultBlocks, 2)
+		assert.Equal(t, map[string]interface{}{"type": "tool_result", "tool_use_id": "call_1", "content": "Sunny"}, resultBlocks[0])
+		assert.Equal(t, map[string]interface{}{"type": "tool_result", "tool_use_id": "call_2", "content": "Rainy"}, resultBlocks[1])
+	})
+
+	t.Run("maps tool_choice variants", func(t *testing.T) {
+		assert.Nil(t, openAIToolChoiceToAnthropic(nil, nil))
+		assert.Equal(t, &AnthropicToolChoice{Type: "auto"}, openAIToolChoiceToAnthropic("auto", nil))
+		assert.Equal(t, &AnthropicToolChoice{Type: "none"}, openAIToolChoiceToAnthropic("none", nil))
+		assert.Equal(t, &AnthropicToolChoice{Type: "tool", Name: "lookup"},
+			openAIToolChoiceToAnthropic(map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "lookup"}}, nil))
+	})
+
+	t.Run("converts tool_use blocks to tool_calls in responses", func(t *testing.T) {
+		body, err := service.transformAnthropicResponse([]byte(`{"id":"msg_t","type":"message","role":"assistant","model":"claude-sonnet-4","content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`))
+		require.NoError(t, err)
+
+		var chatResp OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(body, &chatResp))
+		require.Len(t, chatResp.Choices, 1)
+		choice := chatResp.Choices[0]
+		assert.Equal(t, "tool_calls", choice.FinishReason)
+		assert.Nil(t, choice.Message.Content)
+		require.Len(t, choice.Message.ToolCalls, 1)
+		assert.Equal(t, "toolu_1", choice.Message.ToolCalls[0].ID)
+		assert.Equal(t, "function", choice.Message.ToolCalls[0].Type)
+		assert.Equal(t, "get_weather", choice.Message.ToolCalls[0].Function.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, choice.Message.ToolCalls[0].Function.Arguments)
+	})
+
+	t.Run("streams tool_use blocks as tool_calls deltas", func(t *testing.T) {
+		stream := anthropicStreamStart +
+			"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Checking\"}}\n\n" +
+			"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"get_weather\",\"input\":{}}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"city\\\": \"}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"\\\"Paris\\\"}\"}}\n\n" +
+			"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n" +
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":20}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+
+		payloads := translateRecordedStream(t, newAnthropicStreamTranslator(false), stream)
+
+		var arguments strings.Builder
+		var toolID, toolName, finish string
+		for _, payload := range payloads[:len(payloads)-1] {
+			var chunk OpenAIChatChunk
+			require.NoError(t, json.Unmarshal([]byte(payload), &chunk))
+			for _, choice := range chunk.Choices {
+				for _, call := range choice.Delta.ToolCalls {
+					require.NotNil(t, call.Index)
+					assert.Equal(t, 0, *call.Index)
+					if call.ID != "" {
+						toolID, toolName = call.ID, call.Function.Name
+					}
+					arguments.WriteString(call.Function.Arguments)
+				}
+				if choice.FinishReason != nil {
+					finish = *choice.FinishReason
+				}
+			}
+		}
+
+		assert.Equal(t, "toolu_1", toolID)
+		assert.Equal(t, "get_weather", toolName)
+		assert.JSONEq(t, `{"city":"Paris"}`, arguments.String())
+		assert.Equal(t, "tool_calls", finish)
+	})
+}
+
+func TestProxyService_TransformAnthropicToOpenAI(t *testing.T) {
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+		check   func(t *testing.T, req *OpenAIChatRequest)
+	}{
+		{
+			name: "system blocks and stop sequences",
+			body: `{"model":"gpt-4o","max_tokens":256,"system":[{"type":"text","text":"Be brief."}],"stop_sequences":["END"],"metadata":{"user_id":"u-1"},"messages":[{"role":"user","content":"Hi"}]}`,
+			check: func(t *testing.T, req *OpenAIChatRequest) {
+				require.Len(t, req.Messages, 2)
+				assert.Equal(t, "system", req.Messages[0].Role)
+				assert.Equal(t, "Be brief.", req.Messages[0].Content)
+				assert.Equal(t, "Hi", req.Messages[1].Content)
+				require.NotNil(t, req.MaxTokens)
+				assert.Equal(t, 256, *req.MaxTokens)
+				assert.Equal(t, []interface{}{"END"}, req.Stop)
+				assert.Equal(t, "u-1", req.User)
+				assert.Nil(t, req.StreamOptions)
+			},
+		},
+		{
+			name: "streaming requests usage",
+			body: `{"model":"gpt-4o","max_tokens":10,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`,
+			check: func(t *testing.T, req *OpenAIChatRequest) {
+				require.NotNil(t, req.StreamOptions)
+				assert.True(t, req.StreamOptions.IncludeUsage)
+			},
+		},
+		{
+			name: "text blocks collapse and images become image_url parts",
+			body: `{"model":"gpt-4o","max_tokens":10,"messages":[
+				{"role":"user","content":[{"type":"text","text":"a"},{"type":"text","text":"b"}]},
+				{"role":"assistant","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"ok"}]},
+				{"role":"user","content":[{"type":"text","text":"look"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}}]}]}`,
+			check: func(t *testing.T, req *OpenAIChatRequest) {
+				require.Len(t, req.Messages, 3)
+				assert.Equal(t, "a\nb", req.Messages[0].Content)
+				assert.Equal(t, "ok", req.Messages[1].Content)
+
+				parts, ok := req.Messages[2].Content.([]interface{})
+				require.True(t, ok)
+				require.Len(t, parts, 2)
+				image := parts[1].(map[string]interface{})
+				assert.Equal(t, "image_url", image["type"])
+				assert.Equal(t, "data:image/png;base64,AAAA", image["image_url"].(map[string]interface{})["url"])
+			},
+		},
+		{
+			name: "tool use round trip",
+			body: `{"model":"gpt-4o","max_tokens":10,
+				"tools":[{"name":"get_weather","description":"Weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}}],
+				"tool_choice":{"type":"any","disable_parallel_tool_use":true},
+				"messages":[
+					{"role":"user","content":"Weather in Paris?"},
+					{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}]},
+					{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"18C"}]},{"type":"text","text":"Thanks"}]}]}`,
+			check: func(t *testing.T, req *OpenAIChatRequest) {
+				require.Len(t, req.Tools, 1)
+				assert.Equal(t, "get_weather", req.Tools[0].Function.Name)
+				assert.JSONEq(t, `{"type":"object","properties":{"city":{"type":"string"}}}`, string(req.Tools[0].Function.Parameters))
+				assert.Equal(t, "required", req.ToolChoice)
+				require.NotNil(t, req.ParallelToolCalls)
+				assert.False(t, *req.ParallelToolCalls)
+
+				require.Len(t, req.Messages, 4)
+				assistant := req.Messages[1]
+				assert.Nil(t, assistant.Content)
+				require.Len(t, assistant.ToolCalls, 1)
+				assert.Equal(t, "toolu_1", assistant.ToolCalls[0].ID)
+				assert.JSONEq(t, `{"city":"Paris"}`, assistant.ToolCalls[0].Function.Arguments)
+
+				assert.Equal(t, "tool", req.Messages[2].Role)
+				assert.Equal(t, "toolu_1", req.Messages[2].ToolCallID)
+				assert.Equal(t, "18C", req.Messages[2].Content)
+				assert.Equal(t, "user", req.Messages[3].Role)
+				assert.Equal(t, "Thanks", req.Messages[3].Content)
+			},
+		},
+		{
+			name: "named tool choice",
+			body: `{"model":"gpt-4o","max_tokens":10,"tools":[{"name":"lookup","input_schema":{"type":"object"}}],"tool_choice":{"type":"tool","name":"lookup"},"messages":[{"role":"user","content":"x"}]}`,
+			check: func(t *testing.T, req *OpenAIChatRequest) {
+				assert.Equal(t, map[string]interface{}{
+					"type":     "function",
+					"function": map[string]interface{}{"name": "lookup"},
+				}, req.ToolChoice)
+			},
+		},
+		{
+			name:    "no messages",
+			body:    `{"model":"gpt-4o","max_tokens":10,"messages":[]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req AnthropicMessagesRequest
+			require.NoError(t, json.Unmarshal([]byte(tt.body), &req))
+
+			chatReq, err := service.transformAnthropicToOpenAI(&req, "gpt-4o")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "gpt-4o", chatReq.Model)
+			tt.check(t, chatReq)
+		})
+	}
+}
+
+func TestProxyService_TransformOpenAIResponseToAnthropic(t *testing.T) {
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantContent []AnthropicContentBlock
+		wantStop    string
+		wantUsage   AnthropicUsage
+	}{
+		{
+			name:        "text completion",
+			body:        `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+			wantContent: []AnthropicContentBlock{{Type: "text", Text: "Hello"}},
+			wantStop:    "end_turn",
+			wantUsage:   AnthropicUsage{InputTokens: 5, OutputTokens: 2},
+		},
+		{
+			name: "tool calls",
+			body: `{"id":"chatcmpl-2","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`,
+			wantContent: []AnthropicContentBlock{
+				{Type: "tool_use", ID: "call_1", Name: "get_weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+			},
+			wantStop: "tool_use",
+		},
+		{
+			name:        "truncated",
+			body:        `{"id":"chatcmpl-3","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hel"},"finish_reason":"length"}]}`,
+			wantContent: []AnthropicContentBlock{{Type: "text", Text: "Hel"}},
+			wantStop:    "max_tokens",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := service.transformOpenAIResponseToAnthropic([]byte(tt.body))
+			require.NoError(t, err)
+
+			var resp AnthropicResponse
+			require.NoError(t, json.Unmarshal(out, &resp))
+			assert.Equal(t, "message", resp.Type)
+			assert.Equal(t, "assistant", resp.Role)
+			assert.Equal(t, tt.wantStop, resp.StopReason)
+			assert.Equal(t, tt.wantUsage, resp.Usage)
+			require.Len(t, resp.Content, len(tt.wantContent))
+			for i, want := range tt.wantContent {
+				assert.Equal(t, want.Type, resp.Content[i].Type)
+				assert.Equal(t, want.Text, resp.Content[i].Text)
+				assert.Equal(t, want.ID, resp.Content[i].ID)
+				assert.Equal(t, want.Name, resp.Content[i].Name)
+				if want.Input != nil {
+					assert.JSONEq(t, string(want.Input), string(resp.Content[i].Input))
+				}
+			}
+		})
+	}
+
+	t.Run("errors use the Anthropic shape", func(t *testing.T) {
+		out := service.transformOpenAIErrorToAnthropic(http.StatusTooManyRequests, []byte(`{"error":{"message":"slow down","type":"rate_limit"}}`))
+		assert.JSONEq(t, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, string(out))
+
+		out = service.transformOpenAIErrorToAnthropic(http.StatusBadGateway, []byte(`upstream down`))
+		assert.JSONEq(t, `{"type":"error","error":{"type":"api_error","message":"upstream down"}}`, string(out))
+	})
+}
+
+func TestOpenAIToAnthropicStreamTranslator(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     string
+		wantEvents []string
+		wantText   string
+		wantJSON   string
+		wantStop   string
+		wantOutput int
+	}{
+		{
+			name: "text stream",
+			stream: "data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" there\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"choices\":[],\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":2,\"total_tokens\":10}}\n\n" +
+				"data: [DONE]\n\n",
+			wantEvents: []string{"message_start", "content_block_start", "content_block_delta", "content_block_delta", "content_block_stop", "message_delta", "message_stop"},
+			wantText:   "Hello there",
+			wantStop:   "end_turn",
+			wantOutput: 2,
+		},
+		{
+			name: "text followed by a tool call",
+			stream: "data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Checking\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"\"}}]}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"{\\\"city\\\":\"}}]}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"Paris\\\"}\"}}]}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-2\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"tool_calls\"}]}\n\n" +
+				"data: [DONE]\n\n",
+			wantEvents: []string{"message_start", "content_block_start", "content_block_delta", "content_block_stop", "content_block_start", "content_block_delta", "content_block_delta", "content_block_stop", "message_delta", "message_stop"},
+			wantText:   "Checking",
+			wantJSON:   `{"city":"Paris"}`,
+			wantStop:   "tool_use",
+		},
+		{
+			name:       "upstream closes without [DONE]",
+			stream:     "data: {\"id\":\"chatcmpl-3\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"length\"}]}\n\n",
+			wantEvents: []string{"message_start", "content_block_start", "content_block_delta", "content_block_stop", "message_delta", "message_stop"},
+			wantText:   "Hi",
+			wantStop:   "max_tokens",
+		},
+		{
+			name:       "error payload",
+			stream:     "data: {\"error\":{\"message\":\"boom\"}}\n\n",
+			wantEvents: []string{"error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := translateRecordedEvents(t, newOpenAIToAnthropicStreamTranslator("gpt-4o"), tt.stream)
+
+			var names []string
+			var text, partialJSON, stop string
+			output := 0
+			for _, ev := range events {
+				names = append(names, ev.Event)
+
+				var payload struct {
+					Delta struct {
+						Type        string `json:"type"`
+						Text        string `json:"text"`
+						PartialJSON string `json:"partial_json"`
+						StopReason  string `json:"stop_reason"`
+					} `json:"delta"`
+					Usage struct {
+						OutputTokens int `json:"output_tokens"`
+					} `json:"usage"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(ev.Data), &payload))
+				switch ev.Event {
+				case "content_block_delta":
+					text += payload.Delta.Text
+					partialJSON += payload.Delta.PartialJSON
+				case "message_delta":
+					stop = payload.Delta.StopReason
+					output = payload.Usage.OutputTokens
+				}
+			}
+
+			assert.Equal(t, tt.wantEvents, names)
+			assert.Equal(t, tt.wantText, text)
+			if tt.wantJSON != "" {
+				assert.JSONEq(t, tt.wantJSON, partialJSON)
+			}
+			assert.Equal(t, tt.wantStop, stop)
+			assert.Equal(t, tt.wantOutput, output)
+		})
+	}
+}
+
+func TestProxyService_MessagesViaOpenAI(t *testing.T) {
+	t.Run("buffered response", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+			assert.Equal(t, "Bearer upstream-key", r.Header.Get("Authorization"))
+
+			var chatReq OpenAIChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chatReq))
+			assert.Equal(t, "gpt-4o", chatReq.Model)
+			require.Len(t, chatReq.Messages, 2)
+			assert.Equal(t, "system", chatReq.Messages[0].Role)
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/messages", func(c *gin.Context) {
+			result, _ := service.ProxyAnthropicPassthrough(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","max_tokens":100,"system":"Be brief.","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var anthropicResp AnthropicResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&anthropicResp))
+		assert.Equal(t, "message", anthropicResp.Type)
+		require.Len(t, anthropicResp.Content, 1)
+		assert.Equal(t, "Hello", anthropicResp.Content[0].Text)
+		assert.Equal(t, "end_turn", anthropicResp.StopReason)
+		assert.Equal(t, 5, anthropicResp.Usage.InputTokens)
+
+		result := <-results
+		assert.Equal(t, 7, result.TotalTokens)
+	})
+
+	t.Run("streamed response", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var chatReq OpenAIChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&chatReq))
+			require.NotNil(t, chatReq.StreamOptions)
+			assert.True(t, chatReq.StreamOptions.IncludeUsage)
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, "data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+			io.WriteString(w, "data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+			io.WriteString(w, "data: {\"id\":\"chatcmpl-1\",\"choices\":[],\"usage\":{\"prompt_tokens\":4,\"completion_tokens\":1,\"total_tokens\":5}}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/messages", func(c *gin.Context) {
+			result, _ := service.ProxyAnthropicPassthrough(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "event: message_start")
+		assert.Contains(t, string(body), `"text":"Hi"`)
+		assert.Contains(t, string(body), "event: message_stop")
+		assert.NotContains(t, string(body), "[DONE]")
+
+		result := <-results
+		assert.Equal(t, 4, result.InputTokens)
+		assert.Equal(t, 1, result.OutputTokens)
+	})
+
+	t.Run("upstream errors use the Anthropic shape", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error":{"message":"bad key","type":"invalid_request_error"}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+
+		server := newProxyTestServer(t, "/v1/messages", func(c *gin.Context) {
+			service.ProxyAnthropicPassthrough(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.JSONEq(t, `{"type":"error","error":{"type":"authentication_error","message":"bad key"}}`, string(body))
+	})
+}
+
 func TestProxyService_ExtractUsageFromResponse(t *testing.T) {
 	db := setupProxyTestDB(t)
 	service := createProxyTestServices(t, db)
@@ -431,9 +1237,419 @@ func TestProxyService_ExtractStreamingUsage(t *testing.T) {
 	})
 }
 
+// createStreamingTestKey creates a provider pointing at baseURL and a proxy key allowed to use it
+func createStreamingTestKey(t *testing.T, db *gorm.DB, providerType, baseURL string) *models.ProxyAPIKey {
+	provider := &models.Provider{
+		UserID:       1,
+		Name:         "Stream Provider",
+		ProviderType: providerType,
+		BaseURL:      baseURL,
+		APIKey:       "upstream-key",
+		IsActive:     true,
+	}
+	require.NoError(t, db.Create(provider).Error)
+
+	keyService := NewKeyService(db)
+	created, err := keyService.CreateKey(1, &CreateKeyRequest{
+		AllowedProviders: []ProviderSelection{{ProviderID: provider.ID}},
+		Name:             "Stream Key",
+	})
+	require.NoError(t, err)
+
+	proxyKey, err := keyService.ValidateKey(created.Key)
+	require.NoError(t, err)
+	return proxyKey
+}
+
+// newProxyTestServer exposes a ProxyService method behind a real HTTP server
+func newProxyTestServer(t *testing.T, path string, proxy func(c *gin.Context)) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(path, proxy)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyService_StreamingPassthrough(t *testing.T) {
+	t.Run("flushes OpenAI events before the upstream stream completes", func(t *testing.T) {
+		release := make(chan struct{})
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+			w.(http.Flusher).Flush()
+
+			// Hold the rest of the stream until the client has seen the first event
+			<-release
+			io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":3,\"total_tokens\":10}}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeOpenAI, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+		reader := bufio.NewReader(resp.Body)
+		firstLine, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Contains(t, firstLine, "Hel")
+
+		close(release)
+		rest, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Contains(t, string(rest), "data: [DONE]")
+
+		select {
+		case result := <-results:
+			assert.Equal(t, 7, result.InputTokens)
+			assert.Equal(t, 3, result.OutputTokens)
+			assert.Equal(t, 10, result.TotalTokens)
+		case <-time.After(5 * time.Second):
+			t.Fatal("proxy did not finish")
+		}
+
+		// Usage is recorded asynchronously once the stream ends
+		assert.Eventually(t, func() bool {
+			var record models.UsageRecord
+			return db.Where("proxy_key_id = ?", proxyKey.ID).First(&record).Error == nil && record.TotalTokens == 10
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("streams Anthropic passthrough and accumulates usage across events", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/messages", r.URL.Path)
+			// The client's proxy key must be replaced by the provider key
+			assert.Equal(t, "upstream-key", r.Header.Get("x-api-key"))
+			assert.Empty(t, r.Header.Get("api-key"))
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":25,\"output_tokens\":1}}}\n\n")
+			io.WriteString(w, ": ping\n\n")
+			io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n")
+			io.WriteString(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":12}}\n\n")
+			io.WriteString(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAnthropic, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/messages", func(c *gin.Context) {
+			result, _ := service.ProxyAnthropicPassthrough(c, proxyKey)
+			results <- result
+		})
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/messages",
+			strings.NewReader(`{"model":"claude-sonnet-4","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api-key", "sk-smoothllm-client")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "event: message_start")
+		assert.Contains(t, string(body), "event: message_stop")
+
+		result := <-results
+		assert.Equal(t, 25, result.InputTokens)
+		assert.Equal(t, 12, result.OutputTokens)
+		assert.Equal(t, 37, result.TotalTokens)
+	})
+}
+
+func TestProxyService_AzureOpenAI(t *testing.T) {
+	// createAzureTestKey points an Azure provider with one mapped deployment at the upstream server
+	createAzureTestKey := func(t *testing.T, db *gorm.DB, baseURL string) *models.ProxyAPIKey {
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeAzureOpenAI, baseURL)
+		provider := proxyKey.AllowedProviders[0].Provider
+		provider.APIVersion = "2024-10-21"
+		provider.Deployments = map[string]string{"gpt-4o": "prod-gpt4o"}
+		return proxyKey
+	}
+
+	t.Run("routes chat to the mapped deployment with the api-key header", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", r.URL.Path)
+			assert.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+			assert.Equal(t, "upstream-key", r.Header.Get("api-key"))
+			assert.Empty(t, r.Header.Get("Authorization"))
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createAzureTestKey(t, db, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"azure_openai/gpt-4o","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		result := <-results
+		assert.Equal(t, 6, result.TotalTokens)
+	})
+
+	t.Run("streams from the deployment", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", r.URL.Path)
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[],\"prompt_filter_results\":[]}\n\n")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+			io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1,\"total_tokens\":6}}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createAzureTestKey(t, db, upstream.URL)
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"content":"Hi"`)
+
+		result := <-results
+		assert.Equal(t, 6, result.TotalTokens)
+	})
+
+	t.Run("uses the model name as the deployment when unmapped", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/openai/deployments/text-embedding-3-small/embeddings", r.URL.Path)
+			assert.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":2,"total_tokens":2}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createAzureTestKey(t, db, upstream.URL+"/")
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"text-embedding-3-small","input":"hello"}`))
+
+		result, err := service.ProxyEmbeddings(c, proxyKey)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, 2, result.InputTokens)
+	})
+}
+
+func TestProxyService_CustomProvider(t *testing.T) {
+	// createCustomTestKey points a custom provider with the given config at the upstream server
+	createCustomTestKey := func(t *testing.T, db *gorm.DB, baseURL string, cfg *models.CustomProviderConfig) *models.ProxyAPIKey {
+		proxyKey := createStreamingTestKey(t, db, models.ProviderTypeCustom, baseURL)
+		proxyKey.AllowedProviders[0].Provider.CustomConfig = cfg
+		return proxyKey
+	}
+
+	t.Run("uses the configured chat path, auth header and extra headers", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1/chat/completions", r.URL.Path)
+			assert.Equal(t, "Token upstream-key", r.Header.Get("X-Api-Token"))
+			assert.Empty(t, r.Header.Get("Authorization"))
+			assert.Equal(t, "https://smoothllm.example", r.Header.Get("HTTP-Referer"))
+			assert.Equal(t, "SmoothLLM", r.Header.Get("X-Title"))
+
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"llama-3.3-70b","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createCustomTestKey(t, db, upstream.URL, &models.CustomProviderConfig{
+			AuthHeader:   "X-Api-Token",
+			AuthPrefix:   "Token ",
+			ChatPath:     "/api/v1/chat/completions",
+			ExtraHeaders: map[string]string{"HTTP-Referer": "https://smoothllm.example", "X-Title": "SmoothLLM"},
+		})
+
+		results := make(chan *ProxyResult, 1)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			result, _ := service.ProxyRequest(c, proxyKey)
+			results <- result
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"llama-3.3-70b","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		result := <-results
+		assert.Equal(t, 6, result.TotalTokens)
+	})
+
+	t.Run("expands {model} in path templates", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/models/text-embed/embed", r.URL.Path)
+			assert.Equal(t, "Bearer upstream-key", r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]}],"model":"text-embed","usage":{"prompt_tokens":2,"total_tokens":2}}`)
+		}))
+		defer upstream.Close()
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createCustomTestKey(t, db, upstream.URL, &models.CustomProviderConfig{
+			EmbeddingsPath: "/models/{model}/embed",
+		})
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings",
+			strings.NewReader(`{"model":"text-embed","input":"hello"}`))
+
+		result, err := service.ProxyEmbeddings(c, proxyKey)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, 2, result.InputTokens)
+	})
+}
+
+func TestSSEReader(t *testing.T) {
+	t.Run("splits events and joins multi-line data", func(t *testing.T) {
+		reader := newSSEReader(strings.NewReader("event: a\ndata: one\ndata: two\n\n\n: comment\n\ndata: last"))
+
+		ev, err := reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "a", ev.Event)
+		assert.Equal(t, "one\ntwo", ev.Data)
+		assert.Equal(t, "event: a\ndata: one\ndata: two\n\n", string(ev.Raw))
+
+		ev, err = reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "", ev.Data)
+		assert.Equal(t, ": comment\n\n", string(ev.Raw))
+
+		ev, err = reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "last", ev.Data)
+
+		_, err = reader.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
 func TestProxyService_GetProxyKeyFromRequest(t *testing.T) {
-	// Note: This test would require mocking gin.Context
-	// For now, we test the logic paths documented in the function
+	db := setupProxyTestDB(t)
+	service := createProxyTestServices(t, db)
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantKey string
+		wantErr string
+	}{
+		{
+			name:    "bearer token",
+			headers: map[string]string{"Authorization": "Bearer sk-smoothllm-abc"},
+			wantKey: "sk-smoothllm-abc",
+		},
+		{
+			name:    "bare key in Authorization",
+			headers: map[string]string{"Authorization": "sk-smoothllm-abc"},
+			wantKey: "sk-smoothllm-abc",
+		},
+		{
+			name:    "Anthropic x-api-key",
+			headers: map[string]string{"x-api-key": "sk-smoothllm-abc", "anthropic-version": "2023-06-01"},
+			wantKey: "sk-smoothllm-abc",
+		},
+		{
+			name:    "Azure-style api-key",
+			headers: map[string]string{"api-key": "sk-smoothllm-abc"},
+			wantKey: "sk-smoothllm-abc",
+		},
+		{
+			name:    "proxy key preferred over another bearer token",
+			headers: map[string]string{"Authorization": "Bearer some-other-token", "x-api-key": "sk-smoothllm-abc"},
+			wantKey: "sk-smoothllm-abc",
+		},
+		{
+			name:    "x-api-key used when Authorization is malformed",
+			headers: map[string]string{"Authorization": "Basic Zm9vOmJhcg==", "x-api-key": "sk-smoothllm-abc"},
+			wantKey: "sk-smoothllm-abc",
+		},
+		{
+			name:    "non proxy key is returned for validation",
+			headers: map[string]string{"x-api-key": "sk-ant-xyz"},
+			wantKey: "sk-ant-xyz",
+		},
+		{
+			name:    "malformed Authorization",
+			headers: map[string]string{"Authorization": "Basic Zm9vOmJhcg=="},
+			wantErr: "invalid Authorization header format",
+		},
+		{
+			name:    "missing key",
+			headers: map[string]string{},
+			wantErr: "missing API key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			for key, value := range tt.headers {
+				c.Request.Header.Set(key, value)
+			}
+
+			key, err := service.GetProxyKeyFromRequest(c)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKey, key)
+		})
+	}
 }
 
 func TestProxyService_HandleProviderError(t *testing.T) {
@@ -549,9 +1765,9 @@ func TestProxyService_ValidateAndGetProvider(t *testing.T) {
 	t.Run("returns key and provider for valid API key", func(t *testing.T) {
 		db := setupProxyTestDB(t)
 		keyService := NewKeyService(db)
-		providerService := NewProviderService(db)
+		providerService := NewProviderService(db, nil)
 		usageService := NewUsageService(db)
-		service := NewProxyService(keyService, providerService, usageService, nil)
+		service := NewProxyService(keyService, providerService, usageService, nil, nil, NewLoadBalancer())
 
 		// Create provider
 		provider := &models.Provider{
@@ -591,9 +1807,9 @@ func TestProxyService_ValidateAndGetProvider(t *testing.T) {
 	t.Run("fails for inactive provider", func(t *testing.T) {
 		db := setupProxyTestDB(t)
 		keyService := NewKeyService(db)
-		providerService := NewProviderService(db)
+		providerService := NewProviderService(db, nil)
 		usageService := NewUsageService(db)
-		service := NewProxyService(keyService, providerService, usageService, nil)
+		service := NewProxyService(keyService, providerService, usageService, nil, nil, NewLoadBalancer())
 
 		// Create active provider first
 		provider := &models.Provider{
@@ -654,6 +1870,31 @@ func TestOpenAIChatRequest(t *testing.T) {
 	})
 }
 
+func TestProviderRequestHelpers(t *testing.T) {
+	t.Run("reads stop as a string or an array of strings", func(t *testing.T) {
+		assert.Equal(t, []string{"END"}, stopSequences("END"))
+		assert.Equal(t, []string{"a", "b"}, stopSequences([]interface{}{"a", 1, "b"}))
+		assert.Nil(t, stopSequences(nil))
+	})
+
+	t.Run("splits base64 data URIs", func(t *testing.T) {
+		mediaType, data, ok := parseImageDataURI("data:image/png;base64,iVBORw0KGgo=")
+		assert.True(t, ok)
+		assert.Equal(t, "image/png", mediaType)
+		assert.Equal(t, "iVBORw0KGgo=", data)
+
+		_, _, ok = parseImageDataURI("https://example.com/cat.png")
+		assert.False(t, ok)
+		_, _, ok = parseImageDataURI("data:image/png,rawbytes")
+		assert.False(t, ok)
+	})
+
+	t.Run("renders OpenAI error bodies", func(t *testing.T) {
+		assert.JSONEq(t, `{"error":{"message":"slow down","type":"api_error","code":"throttled"}}`, string(openAIErrorPayload(http.StatusServiceUnavailable, "throttled", "slow down")))
+		assert.JSONEq(t, `{"error":{"message":"bad","type":"invalid_request_error","code":null}}`, string(openAIErrorPayload(http.StatusBadRequest, "", "bad")))
+	})
+}
+
 func TestAnthropicRequest(t *testing.T) {
 	t.Run("serializes correctly", func(t *testing.T) {
 		temp := 0.8
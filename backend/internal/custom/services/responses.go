@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// Stored responses are kept for 30 days, as OpenAI does
+const (
+	responseRetention       = 30 * 24 * time.Hour
+	responseCleanupInterval = time.Hour
+)
+
+// ErrResponseNotFound is returned when a stored response does not exist or belongs to another key
+var ErrResponseNotFound = errors.New("response not found")
+
+// ResponseService stores Responses API results for previous_response_id chaining
+type ResponseService struct {
+	db *gorm.DB
+}
+
+// NewResponseService creates a new ResponseService instance
+func NewResponseService(db *gorm.DB) *ResponseService {
+	return &ResponseService{db: db}
+}
+
+// SaveResponse stores a response for the given proxy key
+func (s *ResponseService) SaveResponse(response *models.StoredResponse) error {
+	if err := s.db.Create(response).Error; err != nil {
+		return fmt.Errorf("failed to store response: %w", err)
+	}
+	return nil
+}
+
+// GetResponse retrieves an unexpired stored response, ensuring it was created with the same proxy key
+func (s *ResponseService) GetResponse(proxyKeyID uint, responseID string) (*models.StoredResponse, error) {
+	var response models.StoredResponse
+	if err := s.db.Where("response_id = ? AND proxy_key_id = ?", responseID, proxyKeyID).
+		Where("created_at > ?", time.Now().Add(-responseRetention)).
+		First(&response).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResponseNotFound
+		}
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// DeleteResponse deletes a stored response, ensuring it was created with the same proxy key
+func (s *ResponseService) DeleteResponse(proxyKeyID uint, responseID string) error {
+	result := s.db.Where("response_id = ? AND proxy_key_id = ?", responseID, proxyKeyID).Delete(&models.StoredResponse{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete response: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrResponseNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpiredResponses permanently removes responses older than the retention period, including
+// deleted ones, and returns how many were removed
+func (s *ResponseService) DeleteExpiredResponses() (int64, error) {
+	result := s.db.Unscoped().Where("created_at <= ?", time.Now().Add(-responseRetention)).Delete(&models.StoredResponse{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired responses: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// StartCleanup removes expired responses periodically until ctx is cancelled
+func (s *ResponseService) StartCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(responseCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.DeleteExpiredResponses(); err != nil {
+				log.Printf("Failed to clean up expired responses: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
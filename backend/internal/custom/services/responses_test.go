@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+func TestResponseService_Expiry(t *testing.T) {
+	db := setupProxyTestDB(t)
+	service := NewResponseService(db)
+
+	fresh := &models.StoredResponse{ResponseID: "resp_fresh", UserID: 1, ProxyKeyID: 1, Body: "{}"}
+	expired := &models.StoredResponse{ResponseID: "resp_expired", UserID: 1, ProxyKeyID: 1, Body: "{}"}
+	deleted := &models.StoredResponse{ResponseID: "resp_deleted", UserID: 1, ProxyKeyID: 1, Body: "{}"}
+	for _, response := range []*models.StoredResponse{fresh, expired, deleted} {
+		require.NoError(t, service.SaveResponse(response))
+	}
+	old := time.Now().Add(-responseRetention - time.Minute)
+	require.NoError(t, db.Model(expired).Update("created_at", old).Error)
+	require.NoError(t, db.Model(deleted).Update("created_at", old).Error)
+	require.NoError(t, service.DeleteResponse(1, "resp_deleted"))
+
+	t.Run("hides responses past the retention period", func(t *testing.T) {
+		_, err := service.GetResponse(1, "resp_fresh")
+		assert.NoError(t, err)
+		_, err = service.GetResponse(1, "resp_expired")
+		assert.ErrorIs(t, err, ErrResponseNotFound)
+	})
+
+	t.Run("removes expired responses for good", func(t *testing.T) {
+		removed, err := service.DeleteExpiredResponses()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), removed)
+
+		var count int64
+		db.Unscoped().Model(&models.StoredResponse{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
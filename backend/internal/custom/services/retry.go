@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// sendWithRetries sends a request to one provider, repeating it under the provider's retry policy
+// while it fails with a retryable status or a connection error. Earlier responses are drained and
+// discarded, so a retry only ever happens before anything has been written to the client.
+// result is reset for every attempt and result.Attempts counts the requests sent. Each attempt
+// feeds the load balancer and circuit breaker, and the response returned stays in flight until
+// its body is closed.
+func (s *ProxyService) sendWithRetries(c *gin.Context, provider *models.Provider, result *ProxyResult, send func() (*http.Response, error)) (*http.Response, error) {
+	policy := provider.GetRetryPolicy()
+	ctx := c.Request.Context()
+
+	for attempt := 1; ; attempt++ {
+		*result = ProxyResult{Model: result.Model, Alias: result.Alias, Attempts: attempt}
+		attemptStart := time.Now()
+		done, ok := s.balancer.begin(provider)
+		if !ok {
+			// Another request is already probing the provider's half-open circuit
+			result.Attempts = attempt - 1
+			result.StatusCode = http.StatusServiceUnavailable
+			result.ErrorMessage = fmt.Sprintf("%v: provider %s is being probed", ErrCircuitOpen, provider.Name)
+			return nil, fmt.Errorf("%w: provider %s is being probed", ErrCircuitOpen, provider.Name)
+		}
+
+		resp, err := send()
+		s.balancer.observe(provider, time.Since(attemptStart), attemptOutcomeOf(ctx, resp, err, result))
+		if err != nil {
+			done()
+		} else {
+			resp.Body = &trackedBody{ReadCloser: resp.Body, done: done}
+		}
+
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !isRetryable(&policy, resp, err, result) {
+			return resp, err
+		}
+		// Stop retrying once the failures have opened the provider's circuit
+		if !s.balancer.available(provider) {
+			return resp, err
+		}
+		delay, ok := retryDelay(&policy, attempt, resp)
+		if !ok {
+			return resp, err
+		}
+
+		if err == nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxFallbackDrainSize))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			result.ErrorMessage = "request cancelled while waiting to retry"
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isRetryable reports whether an attempt may be repeated on the same provider: connection
+// failures and timeouts always may, responses only with one of the policy's status codes
+func isRetryable(policy *models.RetryPolicy, resp *http.Response, err error, result *ProxyResult) bool {
+	if err != nil {
+		// doChatRequest and friends report connection failures and timeouts as 502
+		return result.StatusCode == http.StatusBadGateway
+	}
+	for _, code := range policy.RetryStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// attemptOutcomeOf classifies an attempt for the load balancer. Attempts cut short by the client,
+// and requests that failed before anything was sent to the provider, say nothing about its health.
+func attemptOutcomeOf(ctx context.Context, resp *http.Response, err error, result *ProxyResult) attemptOutcome {
+	switch {
+	case ctx.Err() != nil:
+		return attemptSkipped
+	case isUpstreamFailure(resp, err, result):
+		return attemptFailed
+	case err != nil:
+		return attemptSkipped
+	default:
+		return attemptSucceeded
+	}
+}
+
+// retryDelay returns how long to wait before the retry that follows the given attempt. A wait the
+// provider asks for through Retry-After or retry-after-ms is used as is, unless it is longer than
+// the policy's maximum backoff, in which case the request is not retried at all. Otherwise the
+// delay grows exponentially from the initial backoff, with jitter to spread out retrying clients.
+func retryDelay(policy *models.RetryPolicy, attempt int, resp *http.Response) (time.Duration, bool) {
+	maxBackoff := time.Duration(policy.MaxBackoffMs) * time.Millisecond
+
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header); ok {
+			return wait, wait <= maxBackoff
+		}
+	}
+
+	backoff := time.Duration(policy.InitialBackoffMs) * time.Millisecond
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	// Wait somewhere between half and all of the backoff
+	half := int64(backoff / 2)
+	return time.Duration(half + rand.Int63n(half+1)), true
+}
+
+// retryAfter reads the wait a provider asks for: OpenAI's retry-after-ms, or the standard
+// Retry-After in seconds or as an HTTP date
+func retryAfter(header http.Header) (time.Duration, bool) {
+	if value := header.Get("retry-after-ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
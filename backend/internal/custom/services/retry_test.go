@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// sequencedUpstream answers the nth request with responses[n], repeating the last one after that
+func sequencedUpstream(t *testing.T, calls *int32, responses ...func(w http.ResponseWriter)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(calls, 1)) - 1
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		responses[n](w)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// respondWith returns a response writer for sequencedUpstream
+func respondWith(status int, contentType, body string, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := (&models.Provider{RetryPolicy: &models.RetryPolicy{MaxAttempts: 5, InitialBackoffMs: 100, MaxBackoffMs: 1000}}).GetRetryPolicy()
+
+	t.Run("backs off exponentially with jitter up to the maximum", func(t *testing.T) {
+		for attempt, max := range map[int]time.Duration{1: 100, 2: 200, 3: 400, 4: 800, 5: 1000, 12: 1000} {
+			for i := 0; i < 20; i++ {
+				delay, ok := retryDelay(&policy, attempt, nil)
+				require.True(t, ok)
+				assert.GreaterOrEqual(t, delay, max*time.Millisecond/2, "attempt %d", attempt)
+				assert.LessOrEqual(t, delay, max*time.Millisecond, "attempt %d", attempt)
+			}
+		}
+	})
+
+	t.Run("honors retry-after-ms and Retry-After", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("retry-after-ms", "250")
+		resp.Header.Set("Retry-After", "1")
+		delay, ok := retryDelay(&policy, 1, resp)
+		assert.True(t, ok)
+		assert.Equal(t, 250*time.Millisecond, delay)
+
+		resp.Header.Del("retry-after-ms")
+		delay, ok = retryDelay(&policy, 1, resp)
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, delay)
+
+		resp.Header.Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+		delay, ok = retryDelay(&policy, 1, resp)
+		assert.True(t, ok)
+		assert.Equal(t, time.Duration(0), delay)
+	})
+
+	t.Run("gives up when asked to wait longer than the maximum backoff", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", "120")
+		_, ok := retryDelay(&policy, 1, resp)
+		assert.False(t, ok)
+	})
+}
+
+func TestAttemptOutcomeOf(t *testing.T) {
+	ok := &http.Response{StatusCode: http.StatusOK}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, attemptSucceeded, attemptOutcomeOf(context.Background(), ok, nil, &ProxyResult{}))
+	assert.Equal(t, attemptSucceeded, attemptOutcomeOf(context.Background(), &http.Response{StatusCode: http.StatusBadRequest}, nil, &ProxyResult{}))
+	assert.Equal(t, attemptFailed, attemptOutcomeOf(context.Background(), &http.Response{StatusCode: http.StatusServiceUnavailable}, nil, &ProxyResult{}))
+	assert.Equal(t, attemptFailed, attemptOutcomeOf(context.Background(), nil, errors.New("connection refused"), &ProxyResult{StatusCode: http.StatusBadGateway}))
+	// Requests that never reached the provider, and clients that went away, say nothing about it
+	assert.Equal(t, attemptSkipped, attemptOutcomeOf(context.Background(), nil, errors.New("failed to transform request"), &ProxyResult{StatusCode: http.StatusBadRequest}))
+	assert.Equal(t, attemptSkipped, attemptOutcomeOf(cancelled, nil, errors.New("context canceled"), &ProxyResult{StatusCode: http.StatusBadGateway}))
+	assert.Equal(t, attemptSkipped, attemptOutcomeOf(cancelled, ok, nil, &ProxyResult{}))
+}
+
+func TestProxyService_Retries(t *testing.T) {
+	messagesBody := `{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"Hi"}]}`
+	overloaded := respondWith(529, "application/json", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	okMessage := respondWith(http.StatusOK, "application/json", `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":3,"output_tokens":1}}`)
+	fastRetries := &models.RetryPolicy{MaxAttempts: 3, InitialBackoffMs: 1, MaxBackoffMs: 10}
+
+	newMessagesServer := func(t *testing.T, service *ProxyService, proxyKey *models.ProxyAPIKey) *httptest.Server {
+		return newProxyTestServer(t, "/v1/messages", func(c *gin.Context) {
+			service.ProxyAnthropicPassthrough(c, proxyKey)
+		})
+	}
+
+	t.Run("retries an overloaded provider and records the attempts", func(t *testing.T) {
+		var calls int32
+		upstream := sequencedUpstream(t, &calls, overloaded, overloaded, okMessage)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Claude", ProviderType: models.ProviderTypeAnthropic, BaseURL: upstream.URL, RetryPolicy: fastRetries},
+		)
+		server := newMessagesServer(t, service, proxyKey)
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json", strings.NewReader(messagesBody))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), `"type":"message"`)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+		// One usage record for the request, carrying the attempt count
+		assert.Eventually(t, func() bool {
+			var records []models.UsageRecord
+			db.Where("proxy_key_id = ?", proxyKey.ID).Find(&records)
+			return len(records) == 1 && records[0].Attempts == 3 && records[0].StatusCode == http.StatusOK
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("does not retry without a policy", func(t *testing.T) {
+		var calls int32
+		upstream := sequencedUpstream(t, &calls, overloaded, okMessage)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Claude", ProviderType: models.ProviderTypeAnthropic, BaseURL: upstream.URL},
+		)
+		server := newMessagesServer(t, service, proxyKey)
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json", strings.NewReader(messagesBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, 529, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("only retries the policy's status codes", func(t *testing.T) {
+		var calls int32
+		upstream := sequencedUpstream(t, &calls, overloaded, okMessage)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Claude", ProviderType: models.ProviderTypeAnthropic, BaseURL: upstream.URL,
+				RetryPolicy: &models.RetryPolicy{MaxAttempts: 3, InitialBackoffMs: 1, RetryStatusCodes: []int{429}}},
+		)
+		server := newMessagesServer(t, service, proxyKey)
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json", strings.NewReader(messagesBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, 529, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("does not wait out a Retry-After beyond the maximum backoff", func(t *testing.T) {
+		var calls int32
+		upstream := sequencedUpstream(t, &calls,
+			respondWith(http.StatusTooManyRequests, "application/json", `{"error":{"message":"slow down"}}`, "Retry-After", "60"),
+			okMessage,
+		)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Claude", ProviderType: models.ProviderTypeAnthropic, BaseURL: upstream.URL, RetryPolicy: fastRetries},
+		)
+		server := newMessagesServer(t, service, proxyKey)
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json", strings.NewReader(messagesBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("retries a stream before it starts and sends it once", func(t *testing.T) {
+		stream := "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+			"data: [DONE]\n\n"
+		var calls int32
+		upstream := sequencedUpstream(t, &calls,
+			respondWith(http.StatusTooManyRequests, "application/json", `{"error":{"message":"slow down"}}`, "retry-after-ms", "1"),
+			respondWith(http.StatusOK, "text/event-stream", stream),
+		)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "OpenAI", ProviderType: models.ProviderTypeOpenAI, BaseURL: upstream.URL, RetryPolicy: fastRetries},
+		)
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			service.ProxyRequest(c, proxyKey)
+		})
+
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 1, strings.Count(string(body), `"content":"Hello"`))
+		assert.NotContains(t, string(body), "slow down")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("falls back once retries are exhausted", func(t *testing.T) {
+		var failingCalls, okCalls int32
+		failing := sequencedUpstream(t, &failingCalls, overloaded)
+		healthy := sequencedUpstream(t, &okCalls, okMessage)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, true, nil,
+			&models.Provider{Name: "Primary", ProviderType: models.ProviderTypeAnthropic, BaseURL: failing.URL, RetryPolicy: fastRetries},
+			&models.Provider{Name: "Backup", ProviderType: models.ProviderTypeAnthropic, BaseURL: healthy.URL},
+		)
+		server := newMessagesServer(t, service, proxyKey)
+
+		resp, err := http.Post(server.URL+"/v1/messages", "application/json", strings.NewReader(messagesBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Backup", resp.Header.Get(ProviderHeader))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&failingCalls))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&okCalls))
+
+		assert.Eventually(t, func() bool {
+			var records []models.UsageRecord
+			db.Where("proxy_key_id = ?", proxyKey.ID).Order("status_code").Find(&records)
+			return len(records) == 2 &&
+				records[0].StatusCode == http.StatusOK && records[0].Attempts == 1 &&
+				records[1].StatusCode == 529 && records[1].Attempts == 3
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials identifies the signer of an AWS request
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	Service         string // Signing name, e.g. "bedrock"
+}
+
+// signAWSRequest signs req with AWS Signature Version 4, setting the X-Amz-Date and Authorization
+// headers. payload must be the exact request body (nil for none). The host header, Content-Type
+// and any X-Amz-* headers already on the request are signed, so set those before calling.
+func signAWSRequest(req *http.Request, payload []byte, creds awsCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	// Canonical headers: lowercase names, sorted, with whitespace-trimmed values
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			headers[lower] = strings.Join(trimmed, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req),
+		awsCanonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + creds.Region + "/" + creds.Service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, creds.Region)
+	key = hmacSHA256(key, creds.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsCanonicalURI returns the request path with each segment URI-encoded. Services other than S3
+// encode the already-escaped path a second time, so "%3A" in a model ID is signed as "%253A".
+func awsCanonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCanonicalQuery returns the query string with keys and values encoded and sorted
+func awsCanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([][2]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{awsURIEncode(key), awsURIEncode(value)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, pair := range pairs {
+		encoded[i] = pair[0] + "=" + pair[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// awsURIEncode percent-encodes every byte except the RFC 3986 unreserved characters, as SigV4 requires
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	creds := awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	t.Run("matches the get-vanilla test vector", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+		require.NoError(t, err)
+
+		signAWSRequest(req, nil, creds, now)
+
+		assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+			req.Header.Get("Authorization"))
+	})
+
+	t.Run("signs content-type and x-amz headers", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", nil)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Amz-Security-Token", "token")
+		req.Header.Set("User-Agent", "test")
+
+		signAWSRequest(req, []byte(`{}`), creds, now)
+
+		assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,")
+	})
+}
+
+func TestAWSCanonicalRequestParts(t *testing.T) {
+	t.Run("encodes escaped path segments a second time", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/model/"+awsURIEncode("anthropic.claude-v2:1")+"/converse", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "/model/anthropic.claude-v2%253A1/converse", awsCanonicalURI(req))
+	})
+
+	t.Run("sorts and encodes the query string", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/?b=2&a=x%20y&a=1", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "a=1&a=x%20y&b=2", awsCanonicalQuery(req))
+	})
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseEvent is a single server-sent event read from an upstream stream
+type sseEvent struct {
+	Event string // Value of the "event:" field, if any
+	Data  string // Joined "data:" lines
+	Raw   []byte // Original bytes of the event, including the terminating blank line
+}
+
+// sseReader reads server-sent events one at a time so streams never need to be buffered in full
+type sseReader struct {
+	r *bufio.Reader
+}
+
+// newSSEReader creates an sseReader on top of an upstream response body
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Next returns the next complete event, or io.EOF once the stream has ended
+func (r *sseReader) Next() (*sseEvent, error) {
+	ev := &sseEvent{}
+	var data []string
+	hasFields := false
+
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) > 0 {
+			trimmed := strings.TrimRight(string(line), "\r\n")
+			if trimmed == "" {
+				if hasFields {
+					ev.Raw = append(ev.Raw, line...)
+					ev.Data = strings.Join(data, "\n")
+					return ev, nil
+				}
+				// Skip stray blank lines between events
+			} else {
+				ev.Raw = append(ev.Raw, line...)
+				hasFields = true
+
+				// Lines starting with a colon are comments (e.g. keep-alive pings)
+				if !strings.HasPrefix(trimmed, ":") {
+					name, value, _ := strings.Cut(trimmed, ":")
+					value = strings.TrimPrefix(value, " ")
+					switch name {
+					case "event":
+						ev.Event = value
+					case "data":
+						data = append(data, value)
+					}
+				}
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) && hasFields {
+				// Stream ended without a trailing blank line
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// ndjsonReader reads a newline-delimited JSON stream (as sent by Ollama), presenting each line as
+// an event whose Data is the JSON object so it can go through the same translators as SSE
+type ndjsonReader struct {
+	r *bufio.Reader
+}
+
+// Next returns the next non-empty line, or io.EOF once the stream has ended
+func (r *ndjsonReader) Next() (*sseEvent, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+			return &sseEvent{Data: trimmed, Raw: line}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// streamEventReader yields upstream stream events one at a time
+type streamEventReader interface {
+	Next() (*sseEvent, error)
+}
+
+// newStreamEventReader picks the reader matching an upstream stream's content type
+func newStreamEventReader(resp *http.Response) streamEventReader {
+	if isNDJSONStream(resp) {
+		return &ndjsonReader{r: bufio.NewReaderSize(resp.Body, 64*1024)}
+	}
+	if isAWSEventStream(resp) {
+		return &awsEventStreamReader{r: bufio.NewReaderSize(resp.Body, 64*1024)}
+	}
+	return newSSEReader(resp.Body)
+}
+
+// isEventStream reports whether an upstream response is a server-sent event stream
+func isEventStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// isNDJSONStream reports whether an upstream response is a newline-delimited JSON stream
+func isNDJSONStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-ndjson")
+}
+
+// isStreamResponse reports whether an upstream chat response is streamed in any supported format
+func isStreamResponse(resp *http.Response) bool {
+	return isEventStream(resp) || isNDJSONStream(resp) || isAWSEventStream(resp)
+}
+
+// writeStreamHeaders copies upstream headers to the client and commits the status line for a streamed response
+func writeStreamHeaders(c *gin.Context, header http.Header, statusCode int) {
+	copyResponseHeaders(c, header)
+	// Translated NDJSON and AWS event streams reach the client as server-sent events
+	if !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+	}
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	// Disable response buffering in nginx and similar reverse proxies
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+
+	c.Status(statusCode)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+}
+
+// streamEventsWithUsage forwards an SSE stream to the client unchanged, passing each event's data
+// to extract so endpoint-specific usage (audio seconds, images, ...) is picked up on the way
+func streamEventsWithUsage(c *gin.Context, resp *http.Response, result *ProxyResult, extract func(data []byte, result *ProxyResult)) error {
+	writeStreamHeaders(c, resp.Header, resp.StatusCode)
+
+	reader := newSSEReader(resp.Body)
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("stream interrupted: %v", err)
+			return fmt.Errorf("failed to read upstream stream: %w", err)
+		}
+
+		if ev.Data != "" && ev.Data != "[DONE]" {
+			extract([]byte(ev.Data), result)
+		}
+
+		if err := writeSSE(c, ev.Raw); err != nil {
+			result.ErrorMessage = "client disconnected during stream"
+			return fmt.Errorf("failed to write stream to client: %w", err)
+		}
+	}
+}
+
+// writeSSE writes raw event bytes to the client and flushes them immediately
+func writeSSE(c *gin.Context, raw []byte) error {
+	if _, err := c.Writer.Write(raw); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// writeSSEEvents encodes translated events in SSE framing and flushes them to the client
+func writeSSEEvents(c *gin.Context, events []sseEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, ev := range events {
+		if ev.Event != "" {
+			buf.WriteString("event: " + ev.Event + "\n")
+		}
+		buf.WriteString("data: " + ev.Data + "\n\n")
+	}
+	return writeSSE(c, buf.Bytes())
+}
+
+// forEachSSEData calls fn for every data payload in a fully buffered SSE body
+func forEachSSEData(body []byte, fn func(data string)) {
+	reader := newSSEReader(bytes.NewReader(body))
+	for {
+		ev, err := reader.Next()
+		if err != nil {
+			return
+		}
+		if ev.Data != "" {
+			fn(ev.Data)
+		}
+	}
+}
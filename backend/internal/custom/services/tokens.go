@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"unicode"
+)
+
+// Token estimation constants. Providers without a token counting API get an estimate built from
+// the same word/number/punctuation splitting BPE tokenizers do before merging, which is close
+// enough for budgeting context windows but is not an exact count.
+const (
+	lettersPerToken      = 6    // Common words up to this length are a single token
+	digitsPerToken       = 3    // Numbers are split into groups of up to three digits
+	messageTokenOverhead = 3    // Role and separator tokens added per message
+	toolTokenOverhead    = 10   // Framing added around each tool definition
+	imageTokenEstimate   = 1600 // Roughly a 1092x1092 image
+)
+
+// estimateTokens approximates the number of tokens in text
+func estimateTokens(text string) int {
+	runes := []rune(text)
+	tokens := 0
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			j := i
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+			// A single space is merged into the following word
+			if j-i > 1 || r != ' ' {
+				tokens++
+			}
+			i = j
+		case isIdeograph(r):
+			tokens++
+			i++
+		case unicode.IsLetter(r):
+			j := i
+			for j < len(runes) && unicode.IsLetter(runes[j]) && !isIdeograph(runes[j]) {
+				j++
+			}
+			tokens += (j - i + lettersPerToken - 1) / lettersPerToken
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens += (j - i + digitsPerToken - 1) / digitsPerToken
+			i = j
+		default:
+			// Punctuation and symbols are usually a token each
+			tokens++
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// isIdeograph reports whether r belongs to a script that tokenizes roughly one token per character
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// estimateAnthropicInputTokens estimates the input tokens of an Anthropic Messages request
+func estimateAnthropicInputTokens(req *AnthropicMessagesRequest) int {
+	tokens := estimateAnthropicContentTokens(req.System)
+
+	for _, msg := range req.Messages {
+		tokens += messageTokenOverhead + estimateAnthropicContentTokens(msg.Content)
+	}
+
+	for _, tool := range req.Tools {
+		tokens += toolTokenOverhead + estimateTokens(tool.Name) + estimateTokens(tool.Description) +
+			estimateTokens(string(tool.InputSchema))
+	}
+
+	return tokens
+}
+
+// estimateAnthropicContentTokens estimates the tokens in a string or array of content blocks
+func estimateAnthropicContentTokens(content interface{}) int {
+	switch v := content.(type) {
+	case nil:
+		return 0
+	case string:
+		return estimateTokens(v)
+	case []interface{}:
+		tokens := 0
+		for _, item := range v {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			tokens += estimateAnthropicBlockTokens(block)
+		}
+		return tokens
+	default:
+		data, _ := json.Marshal(v)
+		return estimateTokens(string(data))
+	}
+}
+
+// estimateAnthropicBlockTokens estimates the tokens in a single content block
+func estimateAnthropicBlockTokens(block map[string]interface{}) int {
+	blockType, _ := block["type"].(string)
+	switch blockType {
+	case "text":
+		text, _ := block["text"].(string)
+		return estimateTokens(text)
+	case "thinking":
+		thinking, _ := block["thinking"].(string)
+		return estimateTokens(thinking)
+	case "image", "document":
+		return imageTokenEstimate
+	case "tool_use":
+		name, _ := block["name"].(string)
+		input, _ := json.Marshal(block["input"])
+		return estimateTokens(name) + estimateTokens(string(input))
+	case "tool_result":
+		return estimateAnthropicContentTokens(block["content"])
+	default:
+		data, _ := json.Marshal(block)
+		return estimateTokens(string(data))
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "empty", text: "", want: 0},
+		{name: "words", text: "Hello world", want: 2},
+		{name: "long word", text: "internationalization", want: 4},
+		{name: "punctuation", text: "a, b.", want: 4},
+		{name: "numbers", text: "1234567", want: 3},
+		{name: "newlines", text: "a\n\nb", want: 3},
+		{name: "ideographs", text: "你好世界", want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, estimateTokens(tt.text))
+		})
+	}
+}
+
+func TestEstimateAnthropicInputTokens(t *testing.T) {
+	var req AnthropicMessagesRequest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"model": "m",
+		"system": "Be brief.",
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "text", "text": "What is this?"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}}
+			]},
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "t1", "name": "look", "input": {}}]},
+			{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "t1", "content": "A cat"}]}
+		]
+	}`), &req))
+
+	// system 3, messages 3x3 overhead, text 4, image 1600, tool_use 1+2, tool_result 2
+	assert.Equal(t, 1621, estimateAnthropicInputTokens(&req))
+
+	req.Tools = []AnthropicTool{{Name: "look", InputSchema: json.RawMessage(`{}`)}}
+	assert.Equal(t, 1621+toolTokenOverhead+3, estimateAnthropicInputTokens(&req))
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// upstreamTransport carries every request to a provider, so connections are pooled and kept alive
+// across requests, and HTTP/2 is used where the provider offers it
+var upstreamTransport = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           dialUpstream,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          512,
+	MaxIdleConnsPerHost:   64,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// upstreamClient sends proxied requests over upstreamTransport. It has no timeout of its own:
+// sendUpstream applies the provider's timeouts to each request instead.
+var upstreamClient = &http.Client{Transport: upstreamTransport}
+
+// errUpstreamTimeout is wrapped by the errors of requests cut short by a provider timeout
+var errUpstreamTimeout = errors.New("provider timed out")
+
+// connectTimeoutKey carries a request's connect timeout through its context to dialUpstream
+type connectTimeoutKey struct{}
+
+// dialUpstream opens a connection to a provider within the connect timeout of the request it is
+// dialed for, or the default one
+func dialUpstream(ctx context.Context, network, addr string) (net.Conn, error) {
+	timeout, ok := ctx.Value(connectTimeoutKey{}).(time.Duration)
+	if !ok {
+		timeout = models.DefaultConnectTimeoutMs * time.Millisecond
+	}
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// sendUpstream sends a request to a provider under the provider's connect, first-byte, stream idle
+// and total timeouts. The timeouts stay armed until the response body is closed. A request cut
+// short by one of them fails with an error wrapping errUpstreamTimeout, as do reads from its body.
+func sendUpstream(req *http.Request, provider *models.Provider) (*http.Response, error) {
+	timeouts := provider.GetTimeouts()
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	ctx = context.WithValue(ctx, connectTimeoutKey{}, timeouts.Connect())
+	total := time.AfterFunc(timeouts.Total(), func() {
+		cancel(fmt.Errorf("%w: request not finished after %s", errUpstreamTimeout, timeouts.Total()))
+	})
+	stop := func() {
+		total.Stop()
+		cancel(nil)
+	}
+
+	var firstByte *time.Timer
+	if timeouts.FirstByte() > 0 {
+		firstByte = time.AfterFunc(timeouts.FirstByte(), func() {
+			cancel(fmt.Errorf("%w: no response after %s", errUpstreamTimeout, timeouts.FirstByte()))
+		})
+	}
+
+	resp, err := upstreamClient.Do(req.WithContext(ctx))
+	if firstByte != nil {
+		firstByte.Stop()
+	}
+	if err != nil {
+		err = timeoutCause(ctx, err)
+		stop()
+		return nil, err
+	}
+
+	// The idle timer only runs while the body is being read
+	body := &timedBody{ReadCloser: resp.Body, ctx: ctx, idleTimeout: timeouts.StreamIdle(), stop: stop}
+	if body.idleTimeout > 0 {
+		body.idle = time.AfterFunc(body.idleTimeout, func() {
+			cancel(fmt.Errorf("%w: no data for %s", errUpstreamTimeout, body.idleTimeout))
+		})
+		body.idle.Stop()
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// timeoutCause returns the provider timeout that cancelled ctx in place of err, or err if
+// it wasn't one
+func timeoutCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errUpstreamTimeout) {
+		return cause
+	}
+	return err
+}
+
+// timedBody enforces the stream idle timeout while the response body is read, and disarms the
+// request's timeouts once it is closed
+type timedBody struct {
+	io.ReadCloser
+	ctx         context.Context
+	idleTimeout time.Duration
+	idle        *time.Timer // nil without an idle timeout
+	stop        func()
+	closeOnce   sync.Once
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	// Only time spent waiting on the provider counts, not time spent writing to the client
+	if b.idle != nil {
+		b.idle.Reset(b.idleTimeout)
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if b.idle != nil {
+		b.idle.Stop()
+	}
+	if err != nil && err != io.EOF {
+		err = timeoutCause(b.ctx, err)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.closeOnce.Do(func() {
+		if b.idle != nil {
+			b.idle.Stop()
+		}
+		b.stop()
+	})
+	return err
+}
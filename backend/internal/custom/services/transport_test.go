@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smoothweb/backend/internal/custom/models"
+)
+
+// slowUpstream waits headerDelay before answering, then writes each chunk after chunkDelay
+func slowUpstream(t *testing.T, headerDelay, chunkDelay time.Duration, chunks ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(headerDelay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for _, chunk := range chunks {
+			select {
+			case <-time.After(chunkDelay):
+			case <-r.Context().Done():
+				return
+			}
+			io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSendUpstream(t *testing.T) {
+	get := func(t *testing.T, url string, timeouts *models.ProviderTimeouts) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		return sendUpstream(req, &models.Provider{Timeouts: timeouts})
+	}
+
+	t.Run("keeps connections alive between requests", func(t *testing.T) {
+		var connections int32
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&connections, 1)
+			}
+		}
+		server.Start()
+		t.Cleanup(server.Close)
+
+		for i := 0; i < 3; i++ {
+			resp, err := get(t, server.URL, nil)
+			require.NoError(t, err)
+			_, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+	})
+
+	t.Run("times out waiting for the response headers", func(t *testing.T) {
+		server := slowUpstream(t, time.Second, 0)
+
+		start := time.Now()
+		_, err := get(t, server.URL, &models.ProviderTimeouts{FirstByteMs: 50})
+		assert.ErrorIs(t, err, errUpstreamTimeout)
+		assert.ErrorContains(t, err, "no response after 50ms")
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("times out a stream that goes idle", func(t *testing.T) {
+		server := slowUpstream(t, 0, 500*time.Millisecond, "data: 1\n\n")
+
+		resp, err := get(t, server.URL, &models.ProviderTimeouts{FirstByteMs: 200, StreamIdleMs: 50})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		_, err = io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, errUpstreamTimeout)
+		assert.ErrorContains(t, err, "no data for 50ms")
+	})
+
+	t.Run("lets a steady stream run past the idle timeout", func(t *testing.T) {
+		server := slowUpstream(t, 0, 30*time.Millisecond, "data: 1\n\n", "data: 2\n\n", "data: 3\n\n", "data: 4\n\n")
+
+		resp, err := get(t, server.URL, &models.ProviderTimeouts{StreamIdleMs: 100})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 4, strings.Count(string(body), "data:"))
+	})
+
+	t.Run("bounds the whole request with the total timeout", func(t *testing.T) {
+		server := slowUpstream(t, 0, 40*time.Millisecond, "data: 1\n\n", "data: 2\n\n", "data: 3\n\n", "data: 4\n\n", "data: 5\n\n")
+
+		resp, err := get(t, server.URL, &models.ProviderTimeouts{StreamIdleMs: 100, TotalMs: 100})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		_, err = io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, errUpstreamTimeout)
+		assert.ErrorContains(t, err, "request not finished after 100ms")
+	})
+
+	t.Run("dials with the provider's connect timeout", func(t *testing.T) {
+		var seen time.Duration
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		t.Cleanup(server.Close)
+
+		original := upstreamTransport.DialContext
+		t.Cleanup(func() { upstreamTransport.DialContext = original })
+		upstreamTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			seen, _ = ctx.Value(connectTimeoutKey{}).(time.Duration)
+			return original(ctx, network, addr)
+		}
+
+		resp, err := get(t, server.URL, &models.ProviderTimeouts{ConnectMs: 1500})
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, 1500*time.Millisecond, seen)
+	})
+}
+
+func TestProxyService_Timeouts(t *testing.T) {
+	t.Run("fails a request the provider doesn't answer in time", func(t *testing.T) {
+		upstream := slowUpstream(t, time.Second, 0)
+
+		db := setupProxyTestDB(t)
+		service := createProxyTestServices(t, db)
+		proxyKey := createFallbackTestKey(t, db, false, nil,
+			&models.Provider{Name: "Local", ProviderType: models.ProviderTypeOpenAI, BaseURL: upstream.URL,
+				Timeouts: &models.ProviderTimeouts{FirstByteMs: 50}},
+		)
+		var errorMessage string
+		server := newProxyTestServer(t, "/v1/chat/completions", func(c *gin.Context) {
+			// Stand in for the handler, which writes upstream errors
+			if result, err := service.ProxyRequest(c, proxyKey); err != nil && !c.Writer.Written() {
+				errorMessage = result.ErrorMessage
+				c.Status(result.StatusCode)
+			}
+		})
+
+		start := time.Now()
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Contains(t, errorMessage, "provider timed out: no response after 50ms")
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
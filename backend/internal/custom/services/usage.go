@@ -88,6 +88,7 @@ type UsageRecordResponse struct {
 	ProxyKeyID      uint      `json:"proxy_key_id"`
 	ProviderID      uint      `json:"provider_id"`
 	Model           string    `json:"model"`
+	Alias           string    `json:"alias,omitempty"`
 	InputTokens     int       `json:"input_tokens"`
 	OutputTokens    int       `json:"output_tokens"`
 	TotalTokens     int       `json:"total_tokens"`
@@ -95,6 +96,7 @@ type UsageRecordResponse struct {
 	RequestDuration int       `json:"request_duration_ms"`
 	StatusCode      int       `json:"status_code"`
 	ErrorMessage    string    `json:"error_message,omitempty"`
+	Attempts        int       `json:"attempts"`
 	CreatedAt       time.Time `json:"created_at"`
 	// Related info for convenience
 	KeyPrefix    string `json:"key_prefix,omitempty"`
@@ -108,14 +110,24 @@ type RecordUsageRequest struct {
 	ProxyKeyID           uint
 	ProviderID           uint
 	Model                string
+	Alias                string // Model alias the client asked for, if any
 	InputTokens          int
 	OutputTokens         int
 	TotalTokens          int
 	RequestDuration      int // milliseconds
 	StatusCode           int
 	ErrorMessage         string
+	Attempts             int // Requests sent to the provider, counting retries; 0 records 1
 	InputCostPerMillion  float64
 	OutputCostPerMillion float64
+
+	// Non-token usage from the audio and image endpoints, priced per unit
+	AudioSeconds            float64
+	Characters              int
+	Images                  int
+	AudioCostPerMinute      float64
+	CharacterCostPerMillion float64
+	ImageCostPerImage       float64
 }
 
 // UsageQueryParams represents query parameters for filtering usage data
@@ -142,16 +154,22 @@ func (s *UsageService) RecordUsage(req *RecordUsageRequest) (*models.UsageRecord
 		ProxyKeyID:      req.ProxyKeyID,
 		ProviderID:      req.ProviderID,
 		ModelName:       req.Model,
+		Alias:           req.Alias,
 		InputTokens:     req.InputTokens,
 		OutputTokens:    req.OutputTokens,
 		TotalTokens:     totalTokens,
 		RequestDuration: req.RequestDuration,
 		StatusCode:      req.StatusCode,
 		ErrorMessage:    req.ErrorMessage,
+		Attempts:        req.Attempts,
+		AudioSeconds:    req.AudioSeconds,
+		Characters:      req.Characters,
+		Images:          req.Images,
 	}
 
-	// Calculate cost based on provider rates (cost per million tokens)
-	record.Cost = record.CalculateCost(req.InputCostPerMillion, req.OutputCostPerMillion)
+	// Calculate cost based on provider rates (cost per million tokens, plus any per-unit usage)
+	record.Cost = record.CalculateCost(req.InputCostPerMillion, req.OutputCostPerMillion) +
+		record.CalculateUnitCost(req.AudioCostPerMinute, req.CharacterCostPerMillion, req.ImageCostPerImage)
 
 	if err := s.db.Create(record).Error; err != nil {
 		return nil, fmt.Errorf("failed to record usage: %w", err)
@@ -483,6 +501,7 @@ func (s *UsageService) buildUsageRecordResponse(record *models.UsageRecord) Usag
 		ProxyKeyID:      record.ProxyKeyID,
 		ProviderID:      record.ProviderID,
 		Model:           record.ModelName,
+		Alias:           record.Alias,
 		InputTokens:     record.InputTokens,
 		OutputTokens:    record.OutputTokens,
 		TotalTokens:     record.TotalTokens,
@@ -490,6 +509,7 @@ func (s *UsageService) buildUsageRecordResponse(record *models.UsageRecord) Usag
 		RequestDuration: record.RequestDuration,
 		StatusCode:      record.StatusCode,
 		ErrorMessage:    record.ErrorMessage,
+		Attempts:        record.Attempts,
 		CreatedAt:       record.CreatedAt,
 	}
 
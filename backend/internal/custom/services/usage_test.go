@@ -125,6 +125,35 @@ func TestUsageService_RecordUsage(t *testing.T) {
 		assert.InDelta(t, 25.0, record.Cost, 0.0001)
 	})
 
+	t.Run("adds per-unit audio and image cost", func(t *testing.T) {
+		db := setupUsageTestDB(t)
+		service := NewUsageService(db)
+		provider, key := createUsageTestData(t, db)
+
+		req := &RecordUsageRequest{
+			UserID:                  1,
+			ProxyKeyID:              key.ID,
+			ProviderID:              provider.ID,
+			Model:                   "whisper-1",
+			StatusCode:              200,
+			AudioSeconds:            90,
+			Characters:              2000000,
+			Images:                  3,
+			AudioCostPerMinute:      0.006,
+			CharacterCostPerMillion: 15.0,
+			ImageCostPerImage:       0.04,
+		}
+
+		record, err := service.RecordUsage(req)
+		require.NoError(t, err)
+		assert.Equal(t, 90.0, record.AudioSeconds)
+		assert.Equal(t, 2000000, record.Characters)
+		assert.Equal(t, 3, record.Images)
+
+		// Expected: 1.5 min * 0.006 + 2 * 15.0 + 3 * 0.04 = 0.009 + 30.0 + 0.12
+		assert.InDelta(t, 30.129, record.Cost, 0.0001)
+	})
+
 	t.Run("records error with message", func(t *testing.T) {
 		db := setupUsageTestDB(t)
 		service := NewUsageService(db)
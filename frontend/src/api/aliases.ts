@@ -0,0 +1,51 @@
+import apiClient from '@/api/client'
+
+// Model alias: a virtual model name the proxy resolves to a provider and model
+export interface AliasResponse {
+  id: number
+  name: string
+  description: string
+  provider: string
+  model: string
+  fallbacks: string[]
+  global: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface CreateAliasRequest {
+  name: string
+  description?: string
+  provider?: string
+  model: string
+  fallbacks?: string[]
+  global?: boolean
+}
+
+export interface UpdateAliasRequest {
+  description?: string
+  provider?: string
+  model?: string
+  fallbacks?: string[]
+}
+
+export const aliasesApi = {
+  async listAliases(): Promise<AliasResponse[]> {
+    const response = await apiClient.get('/aliases')
+    return response.data
+  },
+
+  async createAlias(payload: CreateAliasRequest): Promise<AliasResponse> {
+    const response = await apiClient.post('/aliases', payload)
+    return response.data
+  },
+
+  async updateAlias(id: number, payload: UpdateAliasRequest): Promise<AliasResponse> {
+    const response = await apiClient.put(`/aliases/${id}`, payload)
+    return response.data
+  },
+
+  async deleteAlias(id: number): Promise<void> {
+    await apiClient.delete(`/aliases/${id}`)
+  },
+}
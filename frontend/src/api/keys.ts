@@ -6,6 +6,7 @@ export interface AllowedProvider {
   provider_name: string
   provider_type: string
   models: string[]
+  weight: number
 }
 
 export interface KeyResponse {
@@ -19,6 +20,10 @@ export interface KeyResponse {
   created_at: string
   updated_at: string
   allowed_providers: AllowedProvider[]
+  fallback_enabled: boolean
+  fallback_models: Record<string, string[]> | null
+  routing_strategy: string
+  model_routing_strategies: Record<string, string> | null
 }
 
 // Key create response - includes the full key (only returned once on creation)
@@ -29,12 +34,25 @@ export interface KeyCreateResponse extends KeyResponse {
 export interface ProviderSelection {
   provider_id: number
   models: string[]
+  weight?: number
 }
 
+// Routing strategies for keys whose model is served by several providers
+export const RoutingStrategy = {
+  PRIORITY: 'priority',
+  ROUND_ROBIN: 'round_robin',
+  WEIGHTED: 'weighted',
+  LEAST_IN_FLIGHT: 'least_in_flight',
+  LOWEST_LATENCY: 'lowest_latency',
+} as const
+
 export interface CreateKeyRequest {
   name: string
   expires_at?: string
   allowed_providers: ProviderSelection[]
+  fallback_enabled?: boolean
+  fallback_models?: Record<string, string[]>
+  routing_strategy?: string
 }
 
 export interface UpdateKeyRequest {
@@ -42,6 +60,9 @@ export interface UpdateKeyRequest {
   is_active?: boolean
   expires_at?: string
   allowed_providers?: ProviderSelection[]
+  fallback_enabled?: boolean
+  fallback_models?: Record<string, string[]>
+  routing_strategy?: string
 }
 
 export interface RevokeKeyResponse {
@@ -9,8 +9,56 @@ export const ProviderType = {
   LOCAL: 'local',
   ZAI: 'zai',
   ZAI_INTERNATIONAL: 'zai_international',
+  OLLAMA: 'ollama',
+  GEMINI: 'gemini',
+  AZURE_OPENAI: 'azure_openai',
+  BEDROCK: 'bedrock',
+  CUSTOM: 'custom',
 } as const
 
+// Describes an OpenAI-compatible vendor without a dedicated provider type.
+// Path templates may contain {model}.
+export interface CustomProviderConfig {
+  auth_header?: string
+  auth_prefix?: string
+  chat_path?: string
+  models_path?: string
+  embeddings_path?: string
+  extra_headers?: Record<string, string>
+}
+
+// How failed requests to a provider are retried before the proxy gives up or falls back
+export interface RetryPolicy {
+  max_attempts: number // Including the first attempt; 1 disables retries
+  initial_backoff_ms?: number
+  max_backoff_ms?: number
+  retry_status_codes?: number[]
+}
+
+// When a provider's circuit opens, and how long it stays open before a probe request is let through
+export interface CircuitBreakerConfig {
+  failure_threshold?: number // Consecutive failures; defaults to 3
+  cooldown_seconds?: number // Defaults to 30
+}
+
+// How long the proxy waits on a provider; unset fields use the defaults
+export interface ProviderTimeouts {
+  connect_ms?: number // Defaults to 10s
+  first_byte_ms?: number // Until the response headers arrive; bounded by total when unset
+  stream_idle_ms?: number // Longest gap between chunks of the response body
+  total_ms?: number // Whole request including the body; defaults to 5 minutes
+}
+
+// Circuit breaker state and load of a provider, as tracked by the running proxy
+export interface ProviderHealth {
+  state: 'closed' | 'open' | 'half_open'
+  consecutive_failures: number
+  opened_at?: string
+  retry_at?: string
+  in_flight: number
+  latency_ms: number
+}
+
 export type ProviderTypeValue = (typeof ProviderType)[keyof typeof ProviderType]
 
 export interface ProviderResponse {
@@ -22,8 +70,20 @@ export interface ProviderResponse {
   is_active: boolean
   models: string[]
   default_model: string
+  api_version?: string
+  deployments?: Record<string, string>
+  aws_access_key_id?: string
+  aws_region?: string
+  custom_config?: CustomProviderConfig
+  retry_policy?: RetryPolicy
+  circuit_breaker?: CircuitBreakerConfig
+  timeouts?: ProviderTimeouts
+  health?: ProviderHealth
   input_cost_per_million: number
   output_cost_per_million: number
+  audio_cost_per_minute: number
+  character_cost_per_million: number
+  image_cost_per_image: number
   oauth_connected: boolean
   created_at: string
   updated_at: string
@@ -37,8 +97,19 @@ export interface CreateProviderRequest {
   is_active?: boolean
   models?: string[]
   default_model?: string
+  api_version?: string
+  deployments?: Record<string, string>
+  aws_access_key_id?: string
+  aws_region?: string
+  custom_config?: CustomProviderConfig
+  retry_policy?: RetryPolicy
+  circuit_breaker?: CircuitBreakerConfig
+  timeouts?: ProviderTimeouts
   input_cost_per_million?: number
   output_cost_per_million?: number
+  audio_cost_per_minute?: number
+  character_cost_per_million?: number
+  image_cost_per_image?: number
 }
 
 export interface UpdateProviderRequest {
@@ -49,8 +120,19 @@ export interface UpdateProviderRequest {
   is_active?: boolean
   models?: string[]
   default_model?: string
+  api_version?: string
+  deployments?: Record<string, string>
+  aws_access_key_id?: string
+  aws_region?: string
+  custom_config?: CustomProviderConfig
+  retry_policy?: RetryPolicy
+  circuit_breaker?: CircuitBreakerConfig
+  timeouts?: ProviderTimeouts
   input_cost_per_million?: number
   output_cost_per_million?: number
+  audio_cost_per_minute?: number
+  character_cost_per_million?: number
+  image_cost_per_image?: number
 }
 
 export interface TestConnectionResponse {
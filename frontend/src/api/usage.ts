@@ -80,6 +80,7 @@ export interface UsageRecordResponse {
   proxy_key_id: number
   provider_id: number
   model: string
+  alias?: string
   input_tokens: number
   output_tokens: number
   total_tokens: number
@@ -87,6 +88,7 @@ export interface UsageRecordResponse {
   request_duration_ms: number
   status_code: number
   error_message?: string
+  attempts: number
   created_at: string
   // Related info for convenience
   key_prefix?: string
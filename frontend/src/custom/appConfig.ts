@@ -11,6 +11,7 @@ export const customAppConfig: Partial<AppConfig> = {
       { path: '/dashboard', label: 'Dashboard' },
       { path: '/providers', label: 'Providers' },
       { path: '/keys', label: 'API Keys' },
+      { path: '/aliases', label: 'Aliases' },
       { path: '/usage', label: 'Usage' },
     ],
     sidebar: {
@@ -19,6 +20,7 @@ export const customAppConfig: Partial<AppConfig> = {
         { path: '/dashboard', label: 'Dashboard', icon: 'dashboard' },
         { path: '/providers', label: 'Providers', icon: 'settings' },
         { path: '/keys', label: 'API Keys', icon: 'lock' },
+        { path: '/aliases', label: 'Model Aliases', icon: 'sparkles' },
         { path: '/usage', label: 'Usage', icon: 'chart' },
         { path: '/profile', label: 'Profile', icon: 'profile' },
       ],
@@ -13,6 +13,12 @@ export const customRoutes: RouteRecordRaw[] = [
     component: () => import('@/views/ApiKeys.vue'),
     meta: { requiresAuth: true },
   },
+  {
+    path: '/aliases',
+    name: 'model-aliases',
+    component: () => import('@/views/ModelAliases.vue'),
+    meta: { requiresAuth: true },
+  },
   {
     path: '/usage',
     name: 'usage',